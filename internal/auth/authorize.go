@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aftaab60/e-library-api/models"
+)
+
+// ErrForbidden is matched by every ForbiddenError
+var ErrForbidden = errors.New("forbidden")
+
+// ForbiddenError is returned when the principal may not perform an action, Reason tells the caller why
+type ForbiddenError struct {
+	Reason string
+}
+
+func (e *ForbiddenError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrForbidden, e.Reason)
+}
+
+func (e *ForbiddenError) Is(target error) bool {
+	return target == ErrForbidden
+}
+
+// Authorize checks the principal in context is granted the permission.
+// A context without principal is an internal call, e.g. a background job, and is always allowed.
+func Authorize(ctx context.Context, permission models.Permission) error {
+	principal := FromContext(ctx)
+	if principal == nil || principal.Can(permission) {
+		return nil
+	}
+	return &ForbiddenError{Reason: fmt.Sprintf("role %s lacks permission %s", principal.Role, permission)}
+}
+
+// AuthorizeBorrower checks the principal in context may act on loans of the borrower: its own loans, or any loan with loan:any
+func AuthorizeBorrower(ctx context.Context, borrowerName string) error {
+	principal := FromContext(ctx)
+	if principal == nil || principal.Can(models.PermissionLoanAny) {
+		return nil
+	}
+	if principal.IsPatron() && principal.Can(models.PermissionLoanOwn) && principal.Name == borrowerName {
+		return nil
+	}
+	return &ForbiddenError{Reason: "patrons may only act on their own loans"}
+}
+
+// AuthorizeMember checks the principal in context may access the member account: its own account, or any with member:manage
+func AuthorizeMember(ctx context.Context, memberId int) error {
+	principal := FromContext(ctx)
+	if principal == nil || principal.Can(models.PermissionMemberManage) {
+		return nil
+	}
+	if principal.IsPatron() && principal.Can(models.PermissionMemberOwn) && principal.Id == memberId {
+		return nil
+	}
+	return &ForbiddenError{Reason: "patrons may only access their own member account"}
+}
@@ -0,0 +1,25 @@
+package auth
+
+import (
+	"context"
+	"github.com/aftaab60/e-library-api/models"
+)
+
+type key string
+
+const (
+	principalKey key = "principal_key"
+)
+
+// NewContext returns a copy of ctx carrying the authenticated principal
+func NewContext(ctx context.Context, principal *models.Principal) context.Context {
+	return context.WithValue(ctx, principalKey, principal)
+}
+
+// FromContext retrieves the principal from context, nil when the request is not authenticated
+func FromContext(ctx context.Context) *models.Principal {
+	if p, ok := ctx.Value(principalKey).(*models.Principal); ok {
+		return p
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time. Services read the time through a Clock, so that tests and time travel can move it.
+type Clock interface {
+	Now() time.Time
+}
+
+// System is the wall clock
+type System struct{}
+
+func (System) Now() time.Time {
+	return time.Now()
+}
+
+// State is where an Adjustable clock stands: the time it tells, how far it is from the wall clock, and whether it
+// stands still
+type State struct {
+	Now    time.Time `json:"now"`
+	Offset string    `json:"offset"`
+	Frozen bool      `json:"frozen"`
+}
+
+// Adjustable is a clock that can be moved away from the wall clock, forward or backward, and frozen. It is meant for
+// tests, staff training and demonstrations, never for production.
+type Adjustable struct {
+	//base is the wall clock the adjustments are made on
+	base   Clock
+	offset time.Duration
+	//frozenAt is the time told while frozen, nil while running
+	frozenAt *time.Time
+	mutex    sync.RWMutex
+}
+
+// NewAdjustable returns a clock telling the time of base, until it is adjusted
+func NewAdjustable(base Clock) *Adjustable {
+	return &Adjustable{base: base}
+}
+
+func (c *Adjustable) Now() time.Time {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if c.frozenAt != nil {
+		return *c.frozenAt
+	}
+	return c.base.Now().Add(c.offset)
+}
+
+// Advance moves the clock by d, backward when d is negative
+func (c *Adjustable) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.frozenAt != nil {
+		t := c.frozenAt.Add(d)
+		c.frozenAt = &t
+	}
+	c.offset += d
+}
+
+// Set moves the clock to t, it goes on from there unless frozen
+func (c *Adjustable) Set(t time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.frozenAt != nil {
+		c.frozenAt = &t
+	}
+	c.offset = t.Sub(c.base.Now())
+}
+
+// Freeze stops the clock at the time it tells, Unfreeze lets it go on from there
+func (c *Adjustable) Freeze() {
+	now := c.Now()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.frozenAt == nil {
+		c.frozenAt = &now
+	}
+}
+
+func (c *Adjustable) Unfreeze() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.frozenAt != nil {
+		c.offset = c.frozenAt.Sub(c.base.Now())
+		c.frozenAt = nil
+	}
+}
+
+// Reset puts the clock back to the wall clock
+func (c *Adjustable) Reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.offset, c.frozenAt = 0, nil
+}
+
+func (c *Adjustable) State() State {
+	now := c.Now()
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return State{Now: now, Offset: now.Sub(c.base.Now()).Round(time.Second).String(), Frozen: c.frozenAt != nil}
+}
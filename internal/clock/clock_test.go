@@ -0,0 +1,52 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fixed is a wall clock that only moves when told to
+type fixed struct {
+	now time.Time
+}
+
+func (f *fixed) Now() time.Time {
+	return f.now
+}
+
+func TestAdjustable(t *testing.T) {
+	wall := &fixed{now: time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)}
+	c := NewAdjustable(wall)
+	assert.Equal(t, wall.now, c.Now())
+
+	t.Run("Advance keeps following the wall clock", func(t *testing.T) {
+		c.Advance(14 * 24 * time.Hour)
+		assert.Equal(t, time.Date(2025, 3, 15, 9, 0, 0, 0, time.UTC), c.Now())
+		wall.now = wall.now.Add(time.Hour)
+		assert.Equal(t, time.Date(2025, 3, 15, 10, 0, 0, 0, time.UTC), c.Now())
+		assert.Equal(t, "336h0m0s", c.State().Offset)
+	})
+
+	t.Run("Frozen clock stands still until advanced or unfrozen", func(t *testing.T) {
+		c.Freeze()
+		wall.now = wall.now.Add(time.Hour)
+		assert.Equal(t, time.Date(2025, 3, 15, 10, 0, 0, 0, time.UTC), c.Now())
+		c.Advance(time.Hour)
+		assert.Equal(t, time.Date(2025, 3, 15, 11, 0, 0, 0, time.UTC), c.Now())
+		assert.True(t, c.State().Frozen)
+
+		c.Unfreeze()
+		wall.now = wall.now.Add(time.Hour)
+		assert.Equal(t, time.Date(2025, 3, 15, 12, 0, 0, 0, time.UTC), c.Now())
+	})
+
+	t.Run("Set and reset", func(t *testing.T) {
+		c.Set(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+		assert.Equal(t, time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), c.Now())
+		c.Reset()
+		assert.Equal(t, wall.now, c.Now())
+		assert.Equal(t, State{Now: wall.now, Offset: "0s"}, c.State())
+	})
+}
@@ -0,0 +1,46 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	at := func(value string) time.Time {
+		parsed, err := time.Parse("2006-01-02 15:04", value)
+		assert.NoError(t, err)
+		return parsed
+	}
+	//2025-02-03 is a Monday
+	now := at("2025-02-03 10:17")
+
+	for spec, next := range map[string]string{
+		"*/15 * * * *":     "2025-02-03 10:30",
+		"0 2 * * *":        "2025-02-04 02:00",
+		"@hourly":          "2025-02-03 11:00",
+		"@daily":           "2025-02-04 00:00",
+		"30 9 * * 1-5":     "2025-02-04 09:30",
+		"0 0 * * 7":        "2025-02-09 00:00",
+		"0 0 1 3 *":        "2025-03-01 00:00",
+		"0 0 13 * 5":       "2025-02-07 00:00",
+		"5,40 10,12 * * *": "2025-02-03 10:40",
+		"@every 5m":        "2025-02-03 10:20",
+	} {
+		schedule, err := Parse(spec)
+		assert.NoError(t, err, spec)
+		assert.Equal(t, at(next), schedule.Next(now), spec)
+	}
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "@every 1ms", "@often"} {
+		_, err := Parse(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestParse_NeverMatches(t *testing.T) {
+	schedule, err := Parse("0 0 30 2 *")
+	assert.NoError(t, err)
+	assert.True(t, schedule.Next(time.Now()).IsZero())
+}
@@ -28,7 +28,8 @@ func InitPgsqlConnection() *DB {
 		dbPassword := "dev123"
 		dbName := "db_pgsql"
 
-		dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		//timestamps are stored as TIMESTAMPTZ and read back in UTC, whatever the zone of the server
+		dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable timezone=UTC",
 			dbHost, dbPort, dbUser, dbPassword, dbName)
 
 		var err error
@@ -52,6 +53,9 @@ func (d *DB) CreateRecord(ctx context.Context, query string, args ...interface{}
 	if tx != nil {
 		return tx.QueryRowContext(ctx, query, args...)
 	}
+	if conn := getTenantConnFromContext(ctx); conn != nil {
+		return conn.QueryRowContext(ctx, query, args...)
+	}
 	return d.db.QueryRowContext(ctx, query, args...)
 }
 
@@ -60,22 +64,54 @@ func (d *DB) GetRecord(ctx context.Context, query string, args ...interface{}) *
 	if tx != nil {
 		return tx.QueryRowContext(ctx, query, args...)
 	}
+	if conn := getTenantConnFromContext(ctx); conn != nil {
+		return conn.QueryRowContext(ctx, query, args...)
+	}
 	return d.db.QueryRowContext(ctx, query, args...)
 }
 
+func (d *DB) GetRecords(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	tx := GetTransactionFromContext(ctx)
+	if tx != nil {
+		return tx.QueryContext(ctx, query, args...)
+	}
+	if conn := getTenantConnFromContext(ctx); conn != nil {
+		return conn.QueryContext(ctx, query, args...)
+	}
+	return d.db.QueryContext(ctx, query, args...)
+}
+
 func (d *DB) UpdateRecord(ctx context.Context, query string, args ...interface{}) *sql.Row {
 	tx := GetTransactionFromContext(ctx)
 	if tx != nil {
 		return tx.QueryRowContext(ctx, query, args...)
 	}
+	if conn := getTenantConnFromContext(ctx); conn != nil {
+		return conn.QueryRowContext(ctx, query, args...)
+	}
 	return d.db.QueryRowContext(ctx, query, args...)
 }
 
+// UpdateRecords runs an update that may touch several rows and returns how many were affected
+func (d *DB) UpdateRecords(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	tx := GetTransactionFromContext(ctx)
+	if tx != nil {
+		return tx.ExecContext(ctx, query, args...)
+	}
+	if conn := getTenantConnFromContext(ctx); conn != nil {
+		return conn.ExecContext(ctx, query, args...)
+	}
+	return d.db.ExecContext(ctx, query, args...)
+}
+
 func (d *DB) DeleteRecord(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
 	tx := GetTransactionFromContext(ctx)
 	if tx != nil {
 		return tx.ExecContext(ctx, query, args...)
 	}
+	if conn := getTenantConnFromContext(ctx); conn != nil {
+		return conn.ExecContext(ctx, query, args...)
+	}
 	return d.db.ExecContext(ctx, query, args...)
 }
 
@@ -83,6 +119,14 @@ func (d *DB) Begin() (*sql.Tx, error) {
 	return d.db.Begin()
 }
 
+// BeginTx starts a transaction on the tenant connection in context, so that row level security applies inside it too
+func (d *DB) BeginTx(ctx context.Context) (*sql.Tx, error) {
+	if conn := getTenantConnFromContext(ctx); conn != nil {
+		return conn.BeginTx(ctx, nil)
+	}
+	return d.db.BeginTx(ctx, nil)
+}
+
 func CloseDB() {
 	if db != nil {
 		err := db.db.Close()
@@ -96,5 +140,5 @@ func CloseDB() {
 
 // methods for transaction
 type ItxDB interface {
-	Begin() (*sql.Tx, error)
+	BeginTx(ctx context.Context) (*sql.Tx, error)
 }
@@ -0,0 +1,37 @@
+package db_manager
+
+import (
+	"context"
+	"log"
+)
+
+// TryAdvisoryLock takes the Postgres session advisory lock (key1, key2) without waiting, ok is false when another
+// session holds it. The lock is shared by every instance of the service using the database. It is taken on the tenant
+// connection in context, or on a connection of its own otherwise, and held until unlock is called.
+func (d *DB) TryAdvisoryLock(ctx context.Context, key1 int32, key2 int32) (unlock func(), ok bool, err error) {
+	conn := getTenantConnFromContext(ctx)
+	release := func() {}
+	if conn == nil {
+		if conn, err = d.db.Conn(ctx); err != nil {
+			return nil, false, err
+		}
+		release = func() {
+			if err := conn.Close(); err != nil {
+				log.Printf("error releasing lock connection: %v", err)
+			}
+		}
+	}
+
+	if err = conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1, $2)", key1, key2).Scan(&ok); err != nil || !ok {
+		release()
+		return nil, false, err
+	}
+	unlock = func() {
+		//the lock outlives cancellation of ctx, it must be released whatever happened to the job holding it
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1, $2)", key1, key2); err != nil {
+			log.Printf("error releasing advisory lock (%d, %d): %v", key1, key2, err)
+		}
+		release()
+	}
+	return unlock, true, nil
+}
@@ -0,0 +1,46 @@
+package db_manager
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"log"
+	"strconv"
+)
+
+const (
+	tenantConnKey key = "tenant_conn_key"
+)
+
+// BindTenant pins a pooled connection to the request and sets app.tenant_id on it.
+// Row level security policies and tenant_id column defaults read this setting, so every query made
+// with the returned context only sees and writes rows of the tenant. release must be called when the request ends.
+func (d *DB) BindTenant(ctx context.Context, tenantId int) (context.Context, func(), error) {
+	conn, err := d.db.Conn(ctx)
+	if err != nil {
+		return ctx, nil, err
+	}
+	if _, err = conn.ExecContext(ctx, "SELECT set_config('app.tenant_id', $1, false)", strconv.Itoa(tenantId)); err != nil {
+		_ = conn.Close()
+		return ctx, nil, err
+	}
+
+	release := func() {
+		//reset before the connection goes back to the pool, so it can't leak to another tenant
+		if _, err := conn.ExecContext(context.Background(), "RESET app.tenant_id"); err != nil {
+			log.Printf("error resetting tenant on connection, discarding it: %v", err)
+			_ = conn.Raw(func(driverConn any) error { return driver.ErrBadConn })
+		}
+		if err := conn.Close(); err != nil {
+			log.Printf("error releasing tenant connection: %v", err)
+		}
+	}
+	return context.WithValue(ctx, tenantConnKey, conn), release, nil
+}
+
+func getTenantConnFromContext(ctx context.Context) *sql.Conn {
+	if conn, ok := ctx.Value(tenantConnKey).(*sql.Conn); ok {
+		return conn
+	}
+	return nil
+}
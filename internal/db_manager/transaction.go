@@ -18,7 +18,7 @@ func WrapInTransaction(ctx context.Context, db ItxDB, f func(ctx context.Context
 	if db != nil {
 		tx := GetTransactionFromContext(ctx)
 		if tx == nil {
-			tx, err = db.Begin()
+			tx, err = db.BeginTx(ctx)
 			if err != nil {
 				return err
 			}
@@ -1,8 +1,44 @@
+-- Tenants are independent library systems hosted in the same deployment.
+-- Every other table carries tenant_id, defaulted from the app.tenant_id setting of the connection,
+-- and is protected by a row level security policy on it.
+CREATE TABLE IF NOT EXISTS tenants (
+    id SERIAL PRIMARY KEY,
+    slug TEXT UNIQUE NOT NULL,
+    name TEXT NOT NULL,
+    api_key_hash TEXT UNIQUE,
+    default_branch_id INT NOT NULL DEFAULT 1,
+    loan_period_days INT NOT NULL DEFAULT 28 CHECK (loan_period_days > 0),
+    extension_days INT NOT NULL DEFAULT 21 CHECK (extension_days > 0),
+    max_renewals INT NOT NULL DEFAULT 3 CHECK (max_renewals >= 0),
+    replacement_fee INT NOT NULL DEFAULT 2500 CHECK (replacement_fee >= 0),
+    damage_fee INT NOT NULL DEFAULT 1500 CHECK (damage_fee >= 0),
+    overdue_fine INT NOT NULL DEFAULT 0 CHECK (overdue_fine >= 0),
+    max_loans INT NOT NULL DEFAULT 10 CHECK (max_loans >= 0),
+    fine_limit INT NOT NULL DEFAULT 1000 CHECK (fine_limit >= 0)
+);
+
+-- api key hashes are sha256 of 'default-library-key' and 'city-library-key'
+INSERT INTO tenants (slug, name, api_key_hash) VALUES
+    ('default', 'Default Library', encode(sha256('default-library-key'), 'hex')),
+    ('city', 'City Library', encode(sha256('city-library-key'), 'hex'))
+ON CONFLICT (slug) DO NOTHING;
+
+-- seed data below belongs to the default tenant, other tenants get their own books and branches
+-- (and default_branch_id pointing at one of them) when they are provisioned
+SET app.tenant_id = '1';
+
 -- Create the books table
 CREATE TABLE IF NOT EXISTS books (
     id SERIAL PRIMARY KEY,
-    title TEXT UNIQUE NOT NULL,
-    available_copies INT NOT NULL CHECK (available_copies >= 0)
+    tenant_id INT NOT NULL DEFAULT current_setting('app.tenant_id')::int REFERENCES tenants(id),
+    title TEXT NOT NULL,
+    available_copies INT NOT NULL CHECK (available_copies >= 0),
+    -- how copies are lent: standard, short_loan for loan_hours, reference_only or non_circulating
+    loan_type TEXT NOT NULL DEFAULT 'standard' CHECK (loan_type IN ('standard', 'short_loan', 'reference_only', 'non_circulating')),
+    loan_hours INT NOT NULL DEFAULT 0 CHECK (loan_hours >= 0),
+    -- ISBN-10 or ISBN-13, NULL until the catalog sets it
+    isbn TEXT,
+    UNIQUE (tenant_id, title)
 );
 
 -- Insert initial book records
@@ -11,18 +47,367 @@ INSERT INTO books (title, available_copies) VALUES
     ('book2', 3),
     ('book3', 1),
     ('book4', 0)
-ON CONFLICT (title) DO NOTHING; -- Prevent duplicate inserts
+ON CONFLICT (tenant_id, title) DO NOTHING; -- Prevent duplicate inserts
+
+-- Library branches, id 1 is the default branch
+CREATE TABLE IF NOT EXISTS branches (
+    id SERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL DEFAULT current_setting('app.tenant_id')::int REFERENCES tenants(id),
+    name TEXT NOT NULL,
+    -- IANA time zone, loans are due at the end of a day in the branch's time zone
+    time_zone TEXT NOT NULL DEFAULT 'UTC',
+    UNIQUE (tenant_id, name)
+);
+
+INSERT INTO branches (name) VALUES
+    ('main'),
+    ('east')
+ON CONFLICT (tenant_id, name) DO NOTHING;
+
+-- Copies of a book held by each branch. books.available_copies stays the total across branches
+CREATE TABLE IF NOT EXISTS branch_stock (
+    tenant_id INT NOT NULL DEFAULT current_setting('app.tenant_id')::int REFERENCES tenants(id),
+    book_id INT NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    branch_id INT NOT NULL REFERENCES branches(id) ON DELETE CASCADE,
+    available_copies INT NOT NULL DEFAULT 0 CHECK (available_copies >= 0),
+    in_transit_copies INT NOT NULL DEFAULT 0 CHECK (in_transit_copies >= 0),
+    PRIMARY KEY (book_id, branch_id)
+);
+
+INSERT INTO branch_stock (book_id, branch_id, available_copies)
+SELECT id, 1, available_copies FROM books
+ON CONFLICT (book_id, branch_id) DO NOTHING;
 
 CREATE TABLE IF NOT EXISTS loans (
     id SERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL DEFAULT current_setting('app.tenant_id')::int REFERENCES tenants(id),
     book_id INT NOT NULL REFERENCES books(id) ON DELETE CASCADE,
     borrower_name TEXT NOT NULL,
-    loan_date TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    return_date TIMESTAMP NOT NULL,
-    is_returned BOOLEAN DEFAULT FALSE
+    loan_date TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    return_date TIMESTAMPTZ NOT NULL,
+    is_returned BOOLEAN DEFAULT FALSE,
+    status TEXT NOT NULL DEFAULT 'active',
+    -- home branch, the service sets the tenant's default branch when a loan doesn't name one
+    branch_id INT NOT NULL REFERENCES branches(id)
 );
 
 CREATE UNIQUE INDEX unique_active_loan
     ON loans (book_id, borrower_name)
     WHERE is_returned = FALSE;
 
+-- Fees billed against a loan, e.g. replacement of a lost copy
+CREATE TABLE IF NOT EXISTS charges (
+    id SERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL DEFAULT current_setting('app.tenant_id')::int REFERENCES tenants(id),
+    loan_id INT NOT NULL REFERENCES loans(id) ON DELETE CASCADE,
+    borrower_name TEXT NOT NULL,
+    type TEXT NOT NULL,
+    amount INT NOT NULL CHECK (amount >= 0),
+    status TEXT NOT NULL DEFAULT 'outstanding',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Copies moving between branches, either requested by staff or returned away from their home branch
+CREATE TABLE IF NOT EXISTS transfers (
+    id SERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL DEFAULT current_setting('app.tenant_id')::int REFERENCES tenants(id),
+    book_id INT NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    from_branch_id INT NOT NULL REFERENCES branches(id),
+    to_branch_id INT NOT NULL REFERENCES branches(id),
+    loan_id INT REFERENCES loans(id) ON DELETE SET NULL,
+    status TEXT NOT NULL DEFAULT 'requested',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Registered members, their standing and their privacy and notification preferences, history of members who opted out is anonymized on return
+CREATE TABLE IF NOT EXISTS members (
+    id SERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL DEFAULT current_setting('app.tenant_id')::int REFERENCES tenants(id),
+    name TEXT NOT NULL,
+    email TEXT,
+    retain_history BOOLEAN NOT NULL DEFAULT TRUE,
+    due_reminders BOOLEAN NOT NULL DEFAULT TRUE,
+    overdue_notices BOOLEAN NOT NULL DEFAULT TRUE,
+    suspended BOOLEAN NOT NULL DEFAULT FALSE,
+    expires_on DATE,
+    -- number of the library card, NULL for members without a card
+    card_number TEXT,
+    UNIQUE (tenant_id, name),
+    UNIQUE (tenant_id, card_number)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS unique_member_email
+    ON members (tenant_id, lower(email))
+    WHERE email IS NOT NULL;
+
+INSERT INTO members (name) VALUES
+    ('user1'),
+    ('user2')
+ON CONFLICT (tenant_id, name) DO NOTHING;
+
+-- API keys issued to integrations, only the sha256 of a key is stored
+CREATE TABLE IF NOT EXISTS api_keys (
+    id SERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL DEFAULT current_setting('app.tenant_id')::int REFERENCES tenants(id),
+    name TEXT NOT NULL,
+    role TEXT NOT NULL DEFAULT 'librarian',
+    prefix TEXT NOT NULL,
+    key_hash TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMPTZ
+);
+
+-- Bearer tokens issued to members and staff, kept so that they can be revoked before they expire
+CREATE TABLE IF NOT EXISTS auth_tokens (
+    id TEXT PRIMARY KEY,
+    tenant_id INT NOT NULL DEFAULT current_setting('app.tenant_id')::int REFERENCES tenants(id),
+    subject TEXT NOT NULL,
+    member_id INT REFERENCES members(id) ON DELETE CASCADE,
+    role TEXT NOT NULL DEFAULT 'patron',
+    issued_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ
+);
+
+-- Password logins of members, only bcrypt hashes are stored
+CREATE TABLE IF NOT EXISTS credentials (
+    member_id INT PRIMARY KEY REFERENCES members(id) ON DELETE CASCADE,
+    tenant_id INT NOT NULL DEFAULT current_setting('app.tenant_id')::int REFERENCES tenants(id),
+    password_hash TEXT NOT NULL,
+    failed_logins INT NOT NULL DEFAULT 0,
+    locked_until TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Single use password reset tokens mailed to members, only the sha256 of a token is stored
+CREATE TABLE IF NOT EXISTS password_resets (
+    token_hash TEXT PRIMARY KEY,
+    tenant_id INT NOT NULL DEFAULT current_setting('app.tenant_id')::int REFERENCES tenants(id),
+    member_id INT NOT NULL REFERENCES members(id) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ
+);
+
+-- First responses to requests sent with an Idempotency-Key, replayed to retries until they expire
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    tenant_id INT NOT NULL DEFAULT current_setting('app.tenant_id')::int REFERENCES tenants(id),
+    key TEXT NOT NULL,
+    request_hash TEXT NOT NULL,
+    status_code INT NOT NULL DEFAULT 0,
+    content_type TEXT NOT NULL DEFAULT '',
+    response_body BYTEA,
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (tenant_id, key)
+);
+
+-- Event streams of loans, the loans table and book availability are projections of them
+CREATE TABLE IF NOT EXISTS loan_events (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL DEFAULT current_setting('app.tenant_id')::int REFERENCES tenants(id),
+    loan_id INT NOT NULL,
+    version INT NOT NULL,
+    type TEXT NOT NULL,
+    data JSONB NOT NULL,
+    occurred_at TIMESTAMPTZ NOT NULL,
+    UNIQUE (tenant_id, loan_id, version)
+);
+
+-- Append-only record of state changes, who made them and in which request
+CREATE TABLE IF NOT EXISTS audit_log (
+    id SERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL DEFAULT current_setting('app.tenant_id')::int REFERENCES tenants(id),
+    action TEXT NOT NULL,
+    actor_type TEXT NOT NULL,
+    actor_id INT NOT NULL DEFAULT 0,
+    actor_name TEXT NOT NULL DEFAULT '',
+    request_id TEXT NOT NULL DEFAULT '',
+    entity_type TEXT NOT NULL,
+    entity_id INT NOT NULL,
+    changes JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS audit_log_entity_idx ON audit_log (tenant_id, entity_type, entity_id);
+
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE OR TRUNCATE ON audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
+
+-- Transactional outbox: domain events written along with the change they announce, dispatched to webhooks afterwards
+CREATE TABLE IF NOT EXISTS outbox_messages (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL DEFAULT current_setting('app.tenant_id')::int REFERENCES tenants(id),
+    event_type TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    dispatched_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS outbox_messages_pending_idx ON outbox_messages (tenant_id, id) WHERE dispatched_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+    id SERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL DEFAULT current_setting('app.tenant_id')::int REFERENCES tenants(id),
+    url TEXT NOT NULL,
+    event_types TEXT[] NOT NULL DEFAULT '{}',
+    secret TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+-- Deliveries of outbox messages to webhook subscriptions, dead deliveries are the dead letter queue
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL DEFAULT current_setting('app.tenant_id')::int REFERENCES tenants(id),
+    subscription_id INT NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+    message_id BIGINT NOT NULL REFERENCES outbox_messages(id),
+    event_type TEXT NOT NULL,
+    status TEXT NOT NULL,
+    body JSONB NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    last_status_code INT NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL,
+    delivered_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (tenant_id, next_attempt_at) WHERE status = 'pending';
+
+-- Notifications sent to members about their loans, one of each kind per loan and due date
+CREATE TABLE IF NOT EXISTS notifications (
+    id SERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL DEFAULT current_setting('app.tenant_id')::int REFERENCES tenants(id),
+    loan_id INT NOT NULL REFERENCES loans(id) ON DELETE CASCADE,
+    member_id INT NOT NULL REFERENCES members(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    due_date TIMESTAMPTZ NOT NULL,
+    recipient TEXT NOT NULL,
+    sent_at TIMESTAMPTZ NOT NULL,
+    UNIQUE (tenant_id, loan_id, kind, due_date)
+);
+
+-- Automatic renewals of loans, a loan is attempted once per due date
+CREATE TABLE IF NOT EXISTS auto_renewals (
+    id SERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL DEFAULT current_setting('app.tenant_id')::int REFERENCES tenants(id),
+    loan_id INT NOT NULL REFERENCES loans(id) ON DELETE CASCADE,
+    due_date TIMESTAMPTZ NOT NULL,
+    outcome TEXT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    new_due_date TIMESTAMPTZ,
+    attempted_at TIMESTAMPTZ NOT NULL,
+    UNIQUE (tenant_id, loan_id, due_date)
+);
+
+-- Opening hours of branches, weekday 0 is Sunday. A branch without opening hours is open every day
+CREATE TABLE IF NOT EXISTS opening_hours (
+    tenant_id INT NOT NULL DEFAULT current_setting('app.tenant_id')::int REFERENCES tenants(id),
+    branch_id INT NOT NULL REFERENCES branches(id) ON DELETE CASCADE,
+    weekday INT NOT NULL CHECK (weekday BETWEEN 0 AND 6),
+    opens TIME NOT NULL,
+    closes TIME NOT NULL CHECK (closes > opens),
+    PRIMARY KEY (tenant_id, branch_id, weekday)
+);
+
+-- Days branches are closed, a closure without branch closes every branch. Recurring closures repeat every year
+CREATE TABLE IF NOT EXISTS closures (
+    id SERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL DEFAULT current_setting('app.tenant_id')::int REFERENCES tenants(id),
+    branch_id INT REFERENCES branches(id) ON DELETE CASCADE,
+    date DATE NOT NULL,
+    name TEXT NOT NULL,
+    recurring BOOLEAN NOT NULL DEFAULT FALSE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS closures_branch_date_idx ON closures (tenant_id, COALESCE(branch_id, 0), date);
+
+-- Copies of a book held at a branch for a range of days ahead, e.g. for a class, lent against the booking once it starts
+CREATE TABLE IF NOT EXISTS bookings (
+    id SERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL DEFAULT current_setting('app.tenant_id')::int REFERENCES tenants(id),
+    book_id INT NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    branch_id INT NOT NULL REFERENCES branches(id),
+    borrower_name TEXT NOT NULL,
+    starts_on DATE NOT NULL,
+    ends_on DATE NOT NULL CHECK (ends_on >= starts_on),
+    copies INT NOT NULL CHECK (copies > 0),
+    lent_copies INT NOT NULL DEFAULT 0 CHECK (lent_copies BETWEEN 0 AND copies),
+    status TEXT NOT NULL DEFAULT 'active',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS bookings_book_days_idx ON bookings (tenant_id, book_id, starts_on, ends_on);
+
+-- Teaching periods, the reserves of their courses end once the last day is over
+CREATE TABLE IF NOT EXISTS terms (
+    id SERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL DEFAULT current_setting('app.tenant_id')::int REFERENCES tenants(id),
+    name TEXT NOT NULL,
+    starts_on DATE NOT NULL,
+    ends_on DATE NOT NULL CHECK (ends_on >= starts_on)
+);
+
+CREATE TABLE IF NOT EXISTS courses (
+    id SERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL DEFAULT current_setting('app.tenant_id')::int REFERENCES tenants(id),
+    term_id INT NOT NULL REFERENCES terms(id) ON DELETE CASCADE,
+    code TEXT NOT NULL,
+    name TEXT NOT NULL,
+    instructors TEXT[] NOT NULL DEFAULT '{}',
+    UNIQUE (tenant_id, term_id, code)
+);
+
+-- Books on reserve for a course, lent with the loan type of the reserve until it ends and the previous one is given back
+CREATE TABLE IF NOT EXISTS course_reserves (
+    id SERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL DEFAULT current_setting('app.tenant_id')::int REFERENCES tenants(id),
+    course_id INT NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    book_id INT NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    loan_type TEXT NOT NULL,
+    loan_hours INT NOT NULL DEFAULT 0,
+    previous_loan_type TEXT NOT NULL,
+    previous_loan_hours INT NOT NULL DEFAULT 0,
+    status TEXT NOT NULL DEFAULT 'active',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ended_at TIMESTAMPTZ
+);
+-- a book is on one active reserve at most
+CREATE UNIQUE INDEX IF NOT EXISTS course_reserves_active_book_idx ON course_reserves (tenant_id, book_id) WHERE status = 'active';
+
+-- Runs of background jobs, a scheduled time of a job is run once whatever the number of instances of the service
+CREATE TABLE IF NOT EXISTS job_runs (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL DEFAULT current_setting('app.tenant_id')::int REFERENCES tenants(id),
+    job TEXT NOT NULL,
+    trigger TEXT NOT NULL,
+    status TEXT NOT NULL,
+    scheduled_at TIMESTAMPTZ,
+    triggered_by TEXT NOT NULL DEFAULT '',
+    started_at TIMESTAMPTZ NOT NULL,
+    finished_at TIMESTAMPTZ,
+    processed INT NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    UNIQUE (tenant_id, job, scheduled_at)
+);
+CREATE INDEX IF NOT EXISTS job_runs_job_idx ON job_runs (tenant_id, job, id);
+
+-- Row level security: a connection only sees and writes rows of the tenant in app.tenant_id.
+-- FORCE applies the policies to the table owner as well, which is the user the API connects with.
+-- A connection without app.tenant_id set sees no rows at all.
+DO $$
+DECLARE
+    t TEXT;
+BEGIN
+    FOREACH t IN ARRAY ARRAY['books', 'branches', 'branch_stock', 'loans', 'charges', 'transfers', 'members', 'api_keys', 'auth_tokens', 'credentials', 'password_resets', 'idempotency_keys', 'audit_log', 'loan_events', 'outbox_messages', 'webhook_subscriptions', 'webhook_deliveries', 'notifications', 'auto_renewals', 'job_runs', 'opening_hours', 'closures', 'bookings', 'terms', 'courses', 'course_reserves'] LOOP
+        EXECUTE format('ALTER TABLE %I ENABLE ROW LEVEL SECURITY', t);
+        EXECUTE format('ALTER TABLE %I FORCE ROW LEVEL SECURITY', t);
+        EXECUTE format('DROP POLICY IF EXISTS tenant_isolation ON %I', t);
+        EXECUTE format('CREATE POLICY tenant_isolation ON %I USING (tenant_id = NULLIF(current_setting(''app.tenant_id'', true), '''')::int) WITH CHECK (tenant_id = NULLIF(current_setting(''app.tenant_id'', true), '''')::int)', t);
+    END LOOP;
+END
+$$;
+
+RESET app.tenant_id;
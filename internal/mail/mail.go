@@ -0,0 +1,68 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is a plain text mail
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers mail. SMTPSender sends it through a mail server, FileSender and LogSender stand in for one in local setups.
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// FileSender writes every message as an .eml file in Dir instead of sending it
+type FileSender struct {
+	Dir   string
+	From  string
+	mutex sync.Mutex
+	count int
+}
+
+func NewFileSender(dir string, from string) *FileSender {
+	return &FileSender{Dir: dir, From: from}
+}
+
+func (s *FileSender) Send(ctx context.Context, msg Message) error {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return fmt.Errorf("error creating mail directory: %w", err)
+	}
+
+	s.mutex.Lock()
+	s.count++
+	name := fmt.Sprintf("%s-%03d.eml", time.Now().Format("20060102T150405.000000"), s.count)
+	s.mutex.Unlock()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", s.From)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(msg.Body)
+
+	if err := os.WriteFile(filepath.Join(s.Dir, name), []byte(b.String()), 0o600); err != nil {
+		return fmt.Errorf("error writing mail to %s: %w", msg.To, err)
+	}
+	return nil
+}
+
+// LogSender writes every message to the log instead of sending it
+type LogSender struct{}
+
+func (LogSender) Send(ctx context.Context, msg Message) error {
+	log.Printf("mail to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
@@ -0,0 +1,33 @@
+package mail
+
+import (
+	"strings"
+	"text/template"
+)
+
+// Template renders messages, its subject and body are text/template templates executed with the same data
+type Template struct {
+	Subject *template.Template
+	Body    *template.Template
+}
+
+// MustParseTemplate parses a template and panics when it is invalid, for templates defined in code
+func MustParseTemplate(name string, subject string, body string) Template {
+	return Template{
+		Subject: template.Must(template.New(name + ".subject").Parse(subject)),
+		Body:    template.Must(template.New(name + ".body").Parse(body)),
+	}
+}
+
+// Render executes the template with data into a message to the given address
+func (t Template) Render(to string, data any) (Message, error) {
+	var subject, body strings.Builder
+	if err := t.Subject.Execute(&subject, data); err != nil {
+		return Message{}, err
+	}
+	if err := t.Body.Execute(&body, data); err != nil {
+		return Message{}, err
+	}
+	//a subject is a single header line
+	return Message{To: to, Subject: strings.Join(strings.Fields(subject.String()), " "), Body: body.String()}, nil
+}
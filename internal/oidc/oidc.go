@@ -0,0 +1,313 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config of the identity provider client
+type Config struct {
+	//Issuer is the identity provider URL, its discovery document is served under /.well-known/openid-configuration
+	Issuer       string
+	ClientId     string
+	ClientSecret string
+	//RedirectURL is the callback of this API registered with the identity provider
+	RedirectURL string
+	//Scopes requested besides openid
+	Scopes []string
+}
+
+// Metadata is the part of the discovery document the authorization code flow needs
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksURI               string `json:"jwks_uri"`
+}
+
+var (
+	ErrInvalidIdToken = errors.New("invalid id token")
+	ErrIdTokenExpired = errors.New("id token expired")
+)
+
+// Provider runs the authorization code flow against an identity provider.
+// Discovery happens on first use and signing keys are refetched when a token is signed by an unknown key.
+type Provider struct {
+	Config     Config
+	HttpClient *http.Client
+
+	mutex    sync.Mutex
+	metadata *Metadata
+	keys     map[string]*rsa.PublicKey
+}
+
+func NewProvider(config Config, httpClient *http.Client) *Provider {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	config.Issuer = strings.TrimSuffix(config.Issuer, "/")
+	return &Provider{Config: config, HttpClient: httpClient}
+}
+
+func (p *Provider) getJSON(ctx context.Context, endpoint string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.HttpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, endpoint)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// Metadata returns the discovery document, fetched once
+func (p *Provider) Metadata(ctx context.Context) (*Metadata, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+	var metadata Metadata
+	if err := p.getJSON(ctx, p.Config.Issuer+"/.well-known/openid-configuration", &metadata); err != nil {
+		return nil, fmt.Errorf("error discovering identity provider: %w", err)
+	}
+	if strings.TrimSuffix(metadata.Issuer, "/") != p.Config.Issuer {
+		return nil, fmt.Errorf("discovered issuer %q doesn't match %q", metadata.Issuer, p.Config.Issuer)
+	}
+	p.metadata = &metadata
+	return p.metadata, nil
+}
+
+// AuthCodeURL is where the user agent is sent to log in at the identity provider
+func (p *Provider) AuthCodeURL(ctx context.Context, state string, nonce string) (string, error) {
+	metadata, err := p.Metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+	query := url.Values{
+		"response_type": {"code"},
+		"client_id":     {p.Config.ClientId},
+		"redirect_uri":  {p.Config.RedirectURL},
+		"scope":         {strings.Join(append([]string{"openid"}, p.Config.Scopes...), " ")},
+		"state":         {state},
+		"nonce":         {nonce},
+	}
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return metadata.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange redeems an authorization code at the token endpoint and returns the raw ID token
+func (p *Provider) Exchange(ctx context.Context, code string) (string, error) {
+	metadata, err := p.Metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {p.Config.RedirectURL},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(p.Config.ClientId), url.QueryEscape(p.Config.ClientSecret))
+
+	resp, err := p.HttpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var token struct {
+		IdToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("error decoding token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || token.Error != "" {
+		return "", fmt.Errorf("token endpoint refused the code: %s %s", token.Error, token.ErrorDescription)
+	}
+	if token.IdToken == "" {
+		return "", errors.New("token response has no id_token")
+	}
+	return token.IdToken, nil
+}
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+func (p *Provider) fetchKeys(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	metadata, err := p.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := p.getJSON(ctx, metadata.JwksURI, &set); err != nil {
+		return nil, fmt.Errorf("error fetching signing keys: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	return keys, nil
+}
+
+// key returns the signing key with the id, refetching the key set once when it is unknown, e.g. after a key rotation
+func (p *Provider) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	p.mutex.Lock()
+	key, ok := p.keys[kid]
+	p.mutex.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	keys, err := p.fetchKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.mutex.Lock()
+	p.keys = keys
+	p.mutex.Unlock()
+
+	if key, ok = keys[kid]; !ok {
+		return nil, fmt.Errorf("%w: unknown signing key %q", ErrInvalidIdToken, kid)
+	}
+	return key, nil
+}
+
+// audience is either a single string or an array in ID tokens
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(b, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+// IdToken holds the verified claims of an ID token. Claims has every claim, for mapping custom ones such as groups.
+type IdToken struct {
+	Issuer    string         `json:"iss"`
+	Subject   string         `json:"sub"`
+	Audience  audience       `json:"aud"`
+	ExpiresAt int64          `json:"exp"`
+	IssuedAt  int64          `json:"iat"`
+	Nonce     string         `json:"nonce"`
+	Name      string         `json:"name"`
+	Email     string         `json:"email"`
+	Claims    map[string]any `json:"-"`
+}
+
+// Verify checks the RS256 signature of an ID token against the provider keys, then its issuer, audience, expiry and nonce
+func (p *Provider) Verify(ctx context.Context, rawIdToken string, nonce string, now time.Time) (*IdToken, error) {
+	parts := strings.Split(rawIdToken, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidIdToken
+	}
+
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidIdToken
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := json.Unmarshal(rawHeader, &header); err != nil || header.Alg != "RS256" {
+		return nil, fmt.Errorf("%w: unsupported algorithm", ErrInvalidIdToken)
+	}
+
+	key, err := p.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidIdToken
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidIdToken)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidIdToken
+	}
+	var token IdToken
+	if err := json.Unmarshal(payload, &token); err != nil {
+		return nil, ErrInvalidIdToken
+	}
+	if err := json.Unmarshal(payload, &token.Claims); err != nil {
+		return nil, ErrInvalidIdToken
+	}
+
+	if strings.TrimSuffix(token.Issuer, "/") != p.Config.Issuer {
+		return nil, fmt.Errorf("%w: wrong issuer", ErrInvalidIdToken)
+	}
+	audienceOk := false
+	for _, aud := range token.Audience {
+		audienceOk = audienceOk || aud == p.Config.ClientId
+	}
+	if !audienceOk {
+		return nil, fmt.Errorf("%w: wrong audience", ErrInvalidIdToken)
+	}
+	if now.Unix() >= token.ExpiresAt {
+		return nil, ErrIdTokenExpired
+	}
+	if token.Nonce != nonce {
+		return nil, fmt.Errorf("%w: wrong nonce", ErrInvalidIdToken)
+	}
+	return &token, nil
+}
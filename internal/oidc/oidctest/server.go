@@ -0,0 +1,158 @@
+// Package oidctest runs an in-process identity provider, so that the OIDC flow is tested without network access
+package oidctest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Server is a fake identity provider serving discovery, signing keys and the token endpoint
+type Server struct {
+	*httptest.Server
+	ClientId     string
+	ClientSecret string
+	Key          *rsa.PrivateKey
+	KeyId        string
+
+	mutex sync.Mutex
+	codes map[string]map[string]any
+}
+
+func NewServer(clientId string, clientSecret string) *Server {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(fmt.Sprintf("oidctest: generating key: %v", err))
+	}
+	s := &Server{
+		ClientId:     clientId,
+		ClientSecret: clientSecret,
+		Key:          key,
+		KeyId:        "test-key",
+		codes:        make(map[string]map[string]any),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("/jwks", s.jwks)
+	mux.HandleFunc("/token", s.token)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+func (s *Server) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                 s.URL,
+		"authorization_endpoint": s.URL + "/authorize",
+		"token_endpoint":         s.URL + "/token",
+		"jwks_uri":               s.URL + "/jwks",
+	})
+}
+
+func (s *Server) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kid": s.KeyId,
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(s.Key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(s.Key.E)).Bytes()),
+		}},
+	})
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	clientId, clientSecret, ok := r.BasicAuth()
+	if !ok || clientId != s.ClientId || clientSecret != s.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	s.mutex.Lock()
+	claims, ok := s.codes[r.PostForm.Get("code")]
+	delete(s.codes, r.PostForm.Get("code"))
+	s.mutex.Unlock()
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"access_token": "access-token",
+		"token_type":   "Bearer",
+		"id_token":     s.SignIdToken(claims),
+	})
+}
+
+// Login stands in for a user logging in at the authorization endpoint. It returns the code and state
+// the identity provider would redirect back with, the ID token of the code carries claims and the nonce of authCodeURL.
+func (s *Server) Login(authCodeURL string, claims map[string]any) (code string, state string, err error) {
+	u, err := url.Parse(authCodeURL)
+	if err != nil {
+		return "", "", err
+	}
+	query := u.Query()
+	if query.Get("client_id") != s.ClientId {
+		return "", "", fmt.Errorf("oidctest: unknown client %q", query.Get("client_id"))
+	}
+
+	idClaims := s.Claims(claims)
+	idClaims["nonce"] = query.Get("nonce")
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	code = hex.EncodeToString(b)
+
+	s.mutex.Lock()
+	s.codes[code] = idClaims
+	s.mutex.Unlock()
+	return code, query.Get("state"), nil
+}
+
+// Claims returns valid ID token claims for the client, overridden by claims
+func (s *Server) Claims(claims map[string]any) map[string]any {
+	now := time.Now()
+	idClaims := map[string]any{
+		"iss": s.URL,
+		"aud": s.ClientId,
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}
+	for k, v := range claims {
+		idClaims[k] = v
+	}
+	return idClaims
+}
+
+// SignIdToken signs claims as an RS256 ID token with the server key
+func (s *Server) SignIdToken(claims map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": s.KeyId})
+	payload, _ := json.Marshal(claims)
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.Key, crypto.SHA256, digest[:])
+	if err != nil {
+		panic(fmt.Sprintf("oidctest: signing token: %v", err))
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
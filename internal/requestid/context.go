@@ -0,0 +1,31 @@
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+type key string
+
+const (
+	requestIdKey key = "request_id_key"
+)
+
+// NewContext returns a copy of ctx carrying the id of the request it serves
+func NewContext(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, requestIdKey, requestId)
+}
+
+// FromContext retrieves the request id from context, empty outside of a request
+func FromContext(ctx context.Context) string {
+	requestId, _ := ctx.Value(requestIdKey).(string)
+	return requestId
+}
+
+// New generates a random request id
+func New() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,57 @@
+package tenant
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/aftaab60/e-library-api/models"
+)
+
+type key string
+
+const (
+	tenantKey key = "tenant_key"
+)
+
+// NewContext returns a copy of ctx scoped to the given tenant
+func NewContext(ctx context.Context, t *models.Tenant) context.Context {
+	return context.WithValue(ctx, tenantKey, t)
+}
+
+// FromContext retrieves the tenant from context, nil when the request is not scoped to a tenant
+func FromContext(ctx context.Context) *models.Tenant {
+	if t, ok := ctx.Value(tenantKey).(*models.Tenant); ok {
+		return t
+	}
+	return nil
+}
+
+// Id returns the tenant id from context, 0 when the request is not scoped to a tenant
+func Id(ctx context.Context) int {
+	if t := FromContext(ctx); t != nil {
+		return t.Id
+	}
+	return 0
+}
+
+// LoanPolicy returns the loan policy of the tenant in context, or the default policy
+func LoanPolicy(ctx context.Context) models.LoanPolicy {
+	if t := FromContext(ctx); t != nil {
+		return t.Policy
+	}
+	return models.DefaultLoanPolicy
+}
+
+// DefaultBranchId returns the default branch of the tenant in context
+func DefaultBranchId(ctx context.Context) int {
+	if t := FromContext(ctx); t != nil && t.DefaultBranchId != 0 {
+		return t.DefaultBranchId
+	}
+	return models.DefaultBranchId
+}
+
+// HashApiKey hashes a tenant API key, only hashes are stored
+func HashApiKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
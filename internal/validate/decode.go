@@ -0,0 +1,88 @@
+package validate
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// DecodeJSON decodes a JSON object into the struct pointed to by v. Unlike json.Unmarshal it rejects fields v
+// doesn't have and reports every unknown or mistyped field as Errors, instead of stopping at the first.
+func DecodeJSON(data []byte, v any) error {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil || object == nil {
+		return Errors{{Field: "", Code: CodeMalformed, Message: "must be a JSON object"}}
+	}
+
+	fields := jsonFields(reflect.ValueOf(v).Elem())
+	var validator Validator
+	for name, raw := range object {
+		field, ok := fields[name]
+		if !ok {
+			validator.Fail(name, CodeUnknownField, "is not a known field")
+			continue
+		}
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		if err := decoder.Decode(field.Addr().Interface()); err != nil {
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) {
+				validator.Fail(name, CodeInvalidType, "must be a "+jsonType(typeErr.Type))
+			} else {
+				validator.Fail(name, CodeMalformed, err.Error())
+			}
+		}
+	}
+	//fields are reported in a stable order
+	if errs, ok := validator.Err().(Errors); ok {
+		sort.SliceStable(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+		return errs
+	}
+	return nil
+}
+
+// jsonFields maps the JSON names of the fields of a struct, embedded structs included, to the fields
+func jsonFields(v reflect.Value) map[string]reflect.Value {
+	fields := make(map[string]reflect.Value)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		structField := t.Field(i)
+		name, _, _ := strings.Cut(structField.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		//fields of embedded structs are promoted, even when the struct type is unexported
+		if structField.Anonymous && name == "" && structField.Type.Kind() == reflect.Struct {
+			for embeddedName, embedded := range jsonFields(v.Field(i)) {
+				fields[embeddedName] = embedded
+			}
+			continue
+		}
+		if !structField.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = structField.Name
+		}
+		fields[name] = v.Field(i)
+	}
+	return fields
+}
+
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "whole number"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "list"
+	}
+	return "valid value"
+}
@@ -0,0 +1,228 @@
+// Package validate checks request fields and collects every failure, so that a client can fix a request in one go.
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrInvalid is matched by Errors
+var ErrInvalid = errors.New("validation failed")
+
+// Field error codes, stable for clients to act on
+const (
+	CodeRequired          = "required"
+	CodeTooShort          = "too_short"
+	CodeTooLong           = "too_long"
+	CodeInvalidCharacters = "invalid_characters"
+	CodeOutOfRange        = "out_of_range"
+	CodeNotAllowed        = "not_allowed"
+	CodeInvalidFormat     = "invalid_format"
+	CodeInvalidIsbn       = "invalid_isbn"
+	CodeInvalidCardNumber = "invalid_card_number"
+	CodeInvalidType       = "invalid_type"
+	CodeUnknownField      = "unknown_field"
+	CodeMalformed         = "malformed"
+)
+
+// FieldError is a failed check of one request field, Field is its JSON name
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Errors are all the failed checks of a request
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldErr := range e {
+		messages[i] = fieldErr.Field + ": " + fieldErr.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e Errors) Is(target error) bool {
+	return target == ErrInvalid
+}
+
+// Validator collects field errors, checks of a field stop at its first failure
+type Validator struct {
+	errors Errors
+	failed map[string]bool
+}
+
+// Err returns the collected Errors, or nil when every check passed
+func (v *Validator) Err() error {
+	if len(v.errors) == 0 {
+		return nil
+	}
+	return v.errors
+}
+
+// Fail records a failed check of field
+func (v *Validator) Fail(field string, code string, message string) {
+	if v.failed == nil {
+		v.failed = make(map[string]bool)
+	}
+	v.failed[field] = true
+	v.errors = append(v.errors, FieldError{Field: field, Code: code, Message: message})
+}
+
+// Check records a failed check of field unless ok. It is skipped when the field failed already.
+func (v *Validator) Check(ok bool, field string, code string, message string) bool {
+	if v.failed[field] {
+		return false
+	}
+	if !ok {
+		v.Fail(field, code, message)
+	}
+	return ok
+}
+
+// Required checks that value is not empty
+func (v *Validator) Required(field string, value string) bool {
+	return v.Check(value != "", field, CodeRequired, "is required")
+}
+
+// Length checks that value has between min and max characters
+func (v *Validator) Length(field string, value string, min int, max int) bool {
+	length := utf8.RuneCountInString(value)
+	if !v.Check(length >= min, field, CodeTooShort, fmt.Sprintf("must be at least %d characters", min)) {
+		return false
+	}
+	return v.Check(length <= max, field, CodeTooLong, fmt.Sprintf("must be at most %d characters", max))
+}
+
+// Charset checks that every character of value is allowed, description tells clients which ones are
+func (v *Validator) Charset(field string, value string, allowed func(r rune) bool, description string) bool {
+	ok := utf8.ValidString(value)
+	for _, r := range value {
+		if !allowed(r) {
+			ok = false
+			break
+		}
+	}
+	return v.Check(ok, field, CodeInvalidCharacters, "may only contain "+description)
+}
+
+// Range checks that value is between min and max
+func (v *Validator) Range(field string, value int, min int, max int) bool {
+	return v.Check(value >= min && value <= max, field, CodeOutOfRange, fmt.Sprintf("must be between %d and %d", min, max))
+}
+
+// Min checks that value is at least min
+func (v *Validator) Min(field string, value int, min int) bool {
+	return v.Check(value >= min, field, CodeOutOfRange, fmt.Sprintf("must be at least %d", min))
+}
+
+// OneOf checks that value is one of allowed
+func OneOf[T ~string](v *Validator, field string, value T, allowed ...T) bool {
+	names := make([]string, len(allowed))
+	for i, a := range allowed {
+		if value == a {
+			return true
+		}
+		names[i] = string(a)
+	}
+	return v.Check(false, field, CodeNotAllowed, "must be one of "+strings.Join(names, ", "))
+}
+
+// Email checks that value is a plain email address, without a display name
+func (v *Validator) Email(field string, value string) bool {
+	address, err := mail.ParseAddress(value)
+	return v.Check(err == nil && address.Address == value, field, CodeInvalidFormat, "must be an email address")
+}
+
+// Isbn checks that value is an ISBN-10 or ISBN-13 with a valid check digit, hyphens and spaces aside
+func (v *Validator) Isbn(field string, value string) bool {
+	return v.Check(IsIsbn(value), field, CodeInvalidIsbn, "must be an ISBN-10 or ISBN-13")
+}
+
+// CardNumber checks that value is a library card number, see IsCardNumber
+func (v *Validator) CardNumber(field string, value string) bool {
+	return v.Check(IsCardNumber(value), field, CodeInvalidCardNumber, fmt.Sprintf("must be a %d digit library card number", CardNumberLength))
+}
+
+// IsName tells whether r may be part of a person or integration name: letters, digits, spaces and . ' - _
+func IsName(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == ' ' || r == '.' || r == '\'' || r == '-' || r == '_'
+}
+
+// NameCharacters describes IsName to clients
+const NameCharacters = "letters, digits, spaces and . ' - _"
+
+// IsText tells whether r may be part of free text like a title: anything printable
+func IsText(r rune) bool {
+	return unicode.IsPrint(r)
+}
+
+// TextCharacters describes IsText to clients
+const TextCharacters = "printable characters"
+
+// IsIsbn tells whether s is an ISBN-10 or ISBN-13 with a valid check digit, hyphens and spaces aside
+func IsIsbn(s string) bool {
+	digits := strings.NewReplacer("-", "", " ", "").Replace(s)
+	switch len(digits) {
+	case 10:
+		sum := 0
+		for i, r := range digits {
+			var d int
+			switch {
+			case r >= '0' && r <= '9':
+				d = int(r - '0')
+			case (r == 'X' || r == 'x') && i == 9:
+				d = 10
+			default:
+				return false
+			}
+			sum += (10 - i) * d
+		}
+		return sum%11 == 0
+	case 13:
+		sum := 0
+		for i, r := range digits {
+			if r < '0' || r > '9' {
+				return false
+			}
+			weight := 1
+			if i%2 == 1 {
+				weight = 3
+			}
+			sum += weight * int(r-'0')
+		}
+		return sum%10 == 0
+	}
+	return false
+}
+
+// CardNumberLength is the number of digits of a library card number
+const CardNumberLength = 14
+
+// IsCardNumber tells whether s is a library card number: 14 digits, like the Codabar barcodes printed on
+// library cards, the last one a Luhn check digit
+func IsCardNumber(s string) bool {
+	if len(s) != CardNumberLength {
+		return false
+	}
+	sum := 0
+	for i := len(s) - 1; i >= 0; i-- {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+		d := int(s[i] - '0')
+		if (len(s)-i)%2 == 0 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
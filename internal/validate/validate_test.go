@@ -0,0 +1,75 @@
+package validate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsIsbn(t *testing.T) {
+	assert.True(t, IsIsbn("0-306-40615-2"))
+	assert.True(t, IsIsbn("080442957X"))
+	assert.True(t, IsIsbn("978-0-306-40615-7"))
+	assert.False(t, IsIsbn("978-0-306-40615-8"))
+	assert.False(t, IsIsbn("0-306-40615-3"))
+	assert.False(t, IsIsbn("X804429570"))
+	assert.False(t, IsIsbn("book1"))
+}
+
+func TestIsCardNumber(t *testing.T) {
+	assert.True(t, IsCardNumber("21234000012344"))
+	assert.False(t, IsCardNumber("21234000012347"))
+	assert.False(t, IsCardNumber("2123400001234"))
+	assert.False(t, IsCardNumber("2123400001234a"))
+}
+
+func TestValidator(t *testing.T) {
+	var v Validator
+	v.Required("title", "")
+	v.Length("title", "", 1, 10)
+	v.Length("name", "a very long name", 1, 10)
+	v.Charset("borrower_name", "user<1>", IsName, NameCharacters)
+	v.Range("limit", 0, 1, 100)
+	OneOf(&v, "status", "lent", "active", "returned")
+
+	assert.ErrorIs(t, v.Err(), ErrInvalid)
+	assert.Equal(t, Errors{
+		{Field: "title", Code: CodeRequired, Message: "is required"},
+		{Field: "name", Code: CodeTooLong, Message: "must be at most 10 characters"},
+		{Field: "borrower_name", Code: CodeInvalidCharacters, Message: "may only contain " + NameCharacters},
+		{Field: "limit", Code: CodeOutOfRange, Message: "must be between 1 and 100"},
+		{Field: "status", Code: CodeNotAllowed, Message: "must be one of active, returned"},
+	}, v.Err())
+
+	var valid Validator
+	valid.Required("title", "book1")
+	valid.Email("email", "user1@example.com")
+	assert.NoError(t, valid.Err())
+}
+
+func TestDecodeJSON(t *testing.T) {
+	type embedded struct {
+		BranchId int `json:"branch_id"`
+	}
+	type request struct {
+		embedded
+		Title  string `json:"title"`
+		Copies int    `json:"copies"`
+		Secret string `json:"-"`
+	}
+
+	var r request
+	assert.NoError(t, DecodeJSON([]byte(`{"title": "book1", "copies": 2, "branch_id": 3}`), &r))
+	assert.Equal(t, request{embedded: embedded{BranchId: 3}, Title: "book1", Copies: 2}, r)
+
+	err := DecodeJSON([]byte(`{"title": 1, "copies": "two", "isbn": "x", "Secret": "s"}`), &request{})
+	assert.Equal(t, Errors{
+		{Field: "Secret", Code: CodeUnknownField, Message: "is not a known field"},
+		{Field: "copies", Code: CodeInvalidType, Message: "must be a whole number"},
+		{Field: "isbn", Code: CodeUnknownField, Message: "is not a known field"},
+		{Field: "title", Code: CodeInvalidType, Message: "must be a string"},
+	}, err)
+
+	assert.ErrorIs(t, DecodeJSON([]byte(`[]`), &request{}), ErrInvalid)
+	assert.ErrorIs(t, DecodeJSON([]byte(`{"title":`), &request{}), ErrInvalid)
+}
@@ -1,11 +1,27 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"github.com/aftaab60/e-library-api/internal/clock"
+	"github.com/aftaab60/e-library-api/internal/db_manager"
+	"github.com/aftaab60/e-library-api/internal/mail"
+	"github.com/aftaab60/e-library-api/internal/oidc"
+	"github.com/aftaab60/e-library-api/models"
 	"github.com/aftaab60/e-library-api/repositories"
 	"github.com/aftaab60/e-library-api/routes"
 	"github.com/aftaab60/e-library-api/services"
 	"github.com/gin-gonic/gin"
 	"log"
+	"net/http"
+	"net/smtp"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+	//branch time zones resolve even where the system has no time zone database
+	_ "time/tzdata"
 )
 
 func main() {
@@ -13,26 +29,348 @@ func main() {
 	r.Use(gin.Logger())
 	r.Use(gin.Recovery()) // to recover from panics in execution
 
-	setupRoutes(r)
-	if err := r.Run(":3000"); err != nil {
-		log.Fatalf("Failed to run server: %v", err)
+	jobService := setupRoutes(r)
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	jobService.Start(jobsCtx)
+
+	server := &http.Server{Addr: ":3000", Handler: r}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to run server: %v", err)
+		}
+	}()
+
+	//on SIGINT or SIGTERM requests in flight are answered, then running jobs are cancelled and waited for
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	<-ctx.Done()
+	stop()
+	log.Println("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("error shutting down server: %v", err)
 	}
+	stopJobs()
+	jobService.Wait()
+	db_manager.CloseDB()
 }
 
-func setupRoutes(r *gin.Engine) {
-	//From below 2 lines, select either in-memory or pgsql db repository.
+// setupRoutes registers the routes on r and returns the scheduler of background jobs, for main to start
+func setupRoutes(r *gin.Engine) *services.JobService {
+	//From below lines, select either in-memory or pgsql db repository.
 	//Implementation are on interfaces hence same service works in both cases.
-	
-	bookRepository := repositories.NewBookRepository()
-	loanRepository := repositories.NewLoanRepository()
+	//In-memory repositories keep a separate store per tenant, pgsql isolates tenants with row level security.
+
+	tenantRepository := repositories.NewTenantRepository()
+	bookRepository := repositories.NewTenantBookRepository()
+	loanRepository := repositories.NewTenantLoanRepository()
+	chargeRepository := repositories.NewTenantChargeRepository()
+	branchRepository := repositories.NewTenantBranchRepository()
+	transferRepository := repositories.NewTenantTransferRepository()
+	memberRepository := repositories.NewTenantMemberRepository()
+	apiKeyRepository := repositories.NewTenantApiKeyRepository()
+	tokenRepository := repositories.NewTenantTokenRepository()
+	credentialRepository := repositories.NewTenantCredentialRepository()
+	idempotencyRepository := repositories.NewTenantIdempotencyRepository()
+	auditRepository := repositories.NewTenantAuditRepository()
+	loanEventRepository := repositories.NewTenantLoanEventRepository()
+	outboxRepository := repositories.NewTenantOutboxRepository()
+	webhookRepository := repositories.NewTenantWebhookRepository()
+	notificationRepository := repositories.NewTenantNotificationRepository()
+	jobRunRepository := repositories.NewTenantJobRunRepository()
+	autoRenewalRepository := repositories.NewTenantAutoRenewalRepository()
+	calendarRepository := repositories.NewTenantCalendarRepository()
+	bookingRepository := repositories.NewTenantBookingRepository()
+	courseRepository := repositories.NewTenantCourseRepository()
+	var txDB db_manager.ItxDB
+	var tenantBinder routes.TenantBinder
+	var jobLocker services.JobLocker
+	//tenantRepository := repositories.NewTenantRepositoryDB(db_manager.InitPgsqlConnection())
 	//bookRepository := repositories.NewBookRepositoryDB(db_manager.InitPgsqlConnection())
 	//loanRepository := repositories.NewLoanRepositoryDB(db_manager.InitPgsqlConnection())
+	//chargeRepository := repositories.NewChargeRepositoryDB(db_manager.InitPgsqlConnection())
+	//branchRepository := repositories.NewBranchRepositoryDB(db_manager.InitPgsqlConnection())
+	//transferRepository := repositories.NewTransferRepositoryDB(db_manager.InitPgsqlConnection())
+	//memberRepository := repositories.NewMemberRepositoryDB(db_manager.InitPgsqlConnection())
+	//apiKeyRepository := repositories.NewApiKeyRepositoryDB(db_manager.InitPgsqlConnection())
+	//tokenRepository := repositories.NewTokenRepositoryDB(db_manager.InitPgsqlConnection())
+	//credentialRepository := repositories.NewCredentialRepositoryDB(db_manager.InitPgsqlConnection())
+	//idempotencyRepository := repositories.NewIdempotencyRepositoryDB(db_manager.InitPgsqlConnection())
+	//auditRepository := repositories.NewAuditRepositoryDB(db_manager.InitPgsqlConnection())
+	//loanEventRepository := repositories.NewLoanEventRepositoryDB(db_manager.InitPgsqlConnection())
+	//outboxRepository := repositories.NewOutboxRepositoryDB(db_manager.InitPgsqlConnection())
+	//webhookRepository := repositories.NewWebhookRepositoryDB(db_manager.InitPgsqlConnection())
+	//notificationRepository := repositories.NewNotificationRepositoryDB(db_manager.InitPgsqlConnection())
+	//jobRunRepository := repositories.NewJobRunRepositoryDB(db_manager.InitPgsqlConnection())
+	//autoRenewalRepository := repositories.NewAutoRenewalRepositoryDB(db_manager.InitPgsqlConnection())
+	//calendarRepository := repositories.NewCalendarRepositoryDB(db_manager.InitPgsqlConnection())
+	//bookingRepository := repositories.NewBookingRepositoryDB(db_manager.InitPgsqlConnection())
+	//courseRepository := repositories.NewCourseRepositoryDB(db_manager.InitPgsqlConnection())
+	//txDB = db_manager.InitPgsqlConnection()
+	//tenantBinder = db_manager.InitPgsqlConnection()
+	//jobLocker = db_manager.InitPgsqlConnection()
+
+	//services read the time from appClock, which TIME_TRAVEL=true lets the admins of the operator tenant,
+	//TIME_TRAVEL_TENANT or default, move outside of production
+	var appClock clock.Clock = clock.System{}
+	var travelClock *clock.Adjustable
+	operatorTenant := os.Getenv("TIME_TRAVEL_TENANT")
+	if operatorTenant == "" {
+		operatorTenant = "default"
+	}
+	if os.Getenv("TIME_TRAVEL") == "true" {
+		if gin.Mode() == gin.ReleaseMode {
+			log.Fatal("TIME_TRAVEL can't be enabled in release mode")
+		}
+		log.Printf("time travel enabled, admins of tenant %s can move the clock with /admin/clock", operatorTenant)
+		travelClock = clock.NewAdjustable(clock.System{})
+		appClock = travelClock
+	}
+
+	//state changes are recorded in the audit log within the transaction making them
+	auditService := services.NewAuditService(auditRepository)
+	//loan events are written to the outbox within the transaction making them, and delivered to webhooks in the background
+	webhookService := services.NewWebhookService(webhookRepository, outboxRepository)
+	webhookService.TxDB = txDB
+	//loans fall due on days their branch is open, and overdue fines only accrue on those days
+	calendarService := services.NewCalendarService(calendarRepository, branchRepository)
+	calendarService.TxDB = txDB
+	calendarService.Auditor = auditService
+	calendarService.Clock = appClock
+	//copies held by advance bookings aren't lent to others, nor kept past the day a booking starts
+	bookingService := services.NewBookingService(bookingRepository, bookRepository, branchRepository, loanRepository)
+	bookingService.TxDB = txDB
+	bookingService.Auditor = auditService
+	bookingService.Clock = appClock
+	loanService := services.NewLoanService(loanRepository, bookRepository, chargeRepository, branchRepository, transferRepository, memberRepository, loanEventRepository)
+	loanService.TxDB = txDB
+	loanService.Auditor = auditService
+	loanService.Outbox = webhookService
+	loanService.Calendar = calendarService
+	loanService.Bookings = bookingService
+	loanService.Clock = appClock
+	branchService := services.NewBranchService(bookRepository, branchRepository, transferRepository)
+	branchService.TxDB = txDB
+	branchService.Auditor = auditService
+	branchService.Clock = appClock
+	//books on reserve for a course are lent with the loan type of the reserve until the term ends
+	courseService := services.NewCourseService(courseRepository, bookRepository, branchRepository)
+	courseService.TxDB = txDB
+	courseService.Auditor = auditService
+	courseService.Clock = appClock
+	tenantService := services.NewTenantService(tenantRepository)
+	tenantService.TxDB = txDB
+	tenantService.Auditor = auditService
+
+	//JWT_SECRET signs patron tokens, a random secret is used when unset
+	authService := services.NewAuthService(apiKeyRepository, tokenRepository, memberRepository, []byte(os.Getenv("JWT_SECRET")))
+	authService.TxDB = txDB
+	authService.Auditor = auditService
+	mailSender := newMailSender()
+	accountService := services.NewAccountService(memberRepository, credentialRepository, authService, mailSender)
+	accountService.TxDB = txDB
+	reminderService := services.NewReminderService(loanRepository, bookRepository, memberRepository, branchRepository, notificationRepository, mailSender)
+	renewalService := services.NewRenewalService(&loanService, bookRepository, memberRepository, autoRenewalRepository, mailSender)
+	renewalService.TxDB = txDB
+
+	//background jobs run for every tenant, at most once at a time across instances of the service
+	jobService := services.NewJobService(jobRunRepository, tenantRepository)
+	jobService.Binder = tenantBinder
+	jobService.Locker = jobLocker
+	for _, job := range []struct {
+		name, schedule, description string
+		run                         services.JobFunc
+	}{
+		{"dispatch-webhooks", "@every 5s", "Delivers loan events of the outbox to webhooks", webhookService.Dispatch},
+		{"send-reminders", "@hourly", "Mails members about loans due soon and overdue loans", func(ctx context.Context) (int, error) {
+			report, err := reminderService.SendReminders(ctx, appClock.Now())
+			if err != nil {
+				return 0, err
+			}
+			return report.DueSoon + report.Overdue, nil
+		}},
+		{"auto-renew-loans", "30 * * * *", "Renews loans due within a day", func(ctx context.Context) (int, error) {
+			report, err := renewalService.RenewLoans(ctx, appClock.Now())
+			if err != nil {
+				return 0, err
+			}
+			return report.Renewed + report.Refused, nil
+		}},
+		{"end-course-reserves", "@hourly", "Gives books on reserve for courses of ended terms their loan type back", courseService.EndReserves},
+	} {
+		if err := jobService.Register(job.name, job.schedule, job.description, job.run); err != nil {
+			log.Fatalf("invalid job %s: %v", job.name, err)
+		}
+	}
+
+	authRoute := routes.NewAuthRoute(authService)
+	accountRoute := routes.NewAccountRoute(accountService)
+	tenantRoute := routes.NewTenantRoute(tenantService)
+	bookService := services.NewBookService(bookRepository, branchRepository)
+	bookService.TxDB = txDB
+	bookService.Auditor = auditService
+	bookRoute := routes.NewBookRoute(bookService)
+	loanRoute := routes.NewLoanRoute(loanService)
+	branchRoute := routes.NewBranchRoute(branchService)
+	idempotencyRoute := routes.NewIdempotencyRoute(services.NewIdempotencyService(idempotencyRepository))
+	memberService := services.NewMemberService(memberRepository, loanRepository, bookRepository, branchRepository, loanEventRepository)
+	memberService.Clock = appClock
+	memberService.TxDB = txDB
+	memberService.Auditor = auditService
+	memberRoute := routes.NewMemberRoute(memberService)
+	auditRoute := routes.NewAuditRoute(auditService)
+	webhookRoute := routes.NewWebhookRoute(webhookService)
+	jobRoute := routes.NewJobRoute(jobService)
+	renewalRoute := routes.NewRenewalRoute(renewalService)
+	calendarRoute := routes.NewCalendarRoute(calendarService)
+	bookingRoute := routes.NewBookingRoute(bookingService, &loanService)
+	courseRoute := routes.NewCourseRoute(courseService)
+
+	//errors of every route are answered with problem details, see routes/problem.go for the error registry
+	r.Use(routes.ErrorMiddleware())
+	r.Use(routes.RequestIdMiddleware())
+	r.NoRoute(routes.NoRoute)
+	r.GET("/problems", routes.ListProblemTypes)
+	r.GET("/problems/:code", routes.GetProblemType)
 
-	bookRoute := routes.NewBookRoute(services.NewBookService(bookRepository))
-	loanRoute := routes.NewLoanRoute(services.NewLoanService(loanRepository, bookRepository))
+	//every request is scoped to a tenant, requests that don't identify one are served by the default tenant
+	r.Use(tenantRoute.TenantMiddleware(tenantBinder, "default"))
 
-	r.GET("/book/:title", bookRoute.GetBookByTitle)
-	r.POST("/borrow", loanRoute.BorrowBook)
-	r.POST("/extend", loanRoute.ExtendLoan)
-	r.POST("/return", loanRoute.ReturnBook)
+	//accounts are public, it is how patrons get credentials in the first place
+	r.POST("/accounts/register", accountRoute.Register)
+	r.POST("/accounts/login", accountRoute.Login)
+	r.POST("/accounts/password-reset", accountRoute.RequestPasswordReset)
+	r.POST("/accounts/password-reset/confirm", accountRoute.ConfirmPasswordReset)
+
+	//staff log in through the identity provider when OIDC_ISSUER is set
+	if issuer := os.Getenv("OIDC_ISSUER"); issuer != "" {
+		roleMapping, err := services.ParseRoleMapping(os.Getenv("OIDC_ROLE_MAPPING"))
+		if err != nil {
+			log.Fatalf("invalid OIDC_ROLE_MAPPING: %v", err)
+		}
+		roleClaim := os.Getenv("OIDC_ROLE_CLAIM")
+		if roleClaim == "" {
+			roleClaim = "groups"
+		}
+		tenantClaim := os.Getenv("OIDC_TENANT_CLAIM")
+		if tenantClaim == "" {
+			tenantClaim = "tenants"
+		}
+		scopes := os.Getenv("OIDC_SCOPES")
+		if scopes == "" {
+			scopes = "profile email"
+		}
+		provider := oidc.NewProvider(oidc.Config{
+			Issuer:       issuer,
+			ClientId:     os.Getenv("OIDC_CLIENT_ID"),
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+			Scopes:       strings.Fields(scopes),
+		}, nil)
+		oidcRoute := routes.NewOidcRoute(services.NewOidcService(provider, authService, roleClaim, roleMapping, tenantClaim))
+		r.GET("/auth/oidc/login", oidcRoute.Login)
+		r.GET("/auth/oidc/callback", oidcRoute.Callback)
+	}
+
+	//every other request is authenticated, either by an API key or by a patron bearer token
+	api := r.Group("", authRoute.AuthMiddleware())
+	api.PUT("/accounts/password", authRoute.Require(models.PermissionMemberOwn), accountRoute.ChangePassword)
+
+	api.GET("/auth/me", authRoute.GetPrincipal)
+	api.POST("/auth/tokens", authRoute.Require(models.PermissionMemberManage), authRoute.IssueToken)
+	api.DELETE("/auth/tokens/:id", authRoute.RevokeToken)
+	api.GET("/auth/api-keys", authRoute.Require(models.PermissionConfigManage), authRoute.ListApiKeys)
+	api.POST("/auth/api-keys", authRoute.Require(models.PermissionConfigManage), authRoute.CreateApiKey)
+	api.DELETE("/auth/api-keys/:id", authRoute.Require(models.PermissionConfigManage), authRoute.RevokeApiKey)
+
+	//route permissions are coarse, services further restrict patrons to their own loans and member account
+	api.GET("/tenant", tenantRoute.GetTenant)
+	api.PUT("/tenant/policy", authRoute.Require(models.PermissionConfigManage), tenantRoute.UpdateLoanPolicy)
+
+	api.GET("/book/:title", authRoute.Require(models.PermissionCatalogRead), bookRoute.GetBookByTitle)
+	api.PUT("/book/:title/loan-type", authRoute.Require(models.PermissionCatalogManage), bookRoute.UpdateLoanType)
+	api.PUT("/book/:title/isbn", authRoute.Require(models.PermissionCatalogManage), bookRoute.UpdateIsbn)
+	api.GET("/book/:title/bookings", authRoute.Require(models.PermissionCatalogRead), bookingRoute.GetBookingCalendar)
+	//retries sent with the same Idempotency-Key get the first response instead of borrowing, extending or returning twice
+	api.POST("/borrow", authRoute.Require(models.PermissionLoanOwn), idempotencyRoute.IdempotencyMiddleware(), loanRoute.BorrowBook)
+	api.POST("/extend", authRoute.Require(models.PermissionLoanOwn), idempotencyRoute.IdempotencyMiddleware(), loanRoute.ExtendLoan)
+	api.POST("/return", authRoute.Require(models.PermissionLoanOwn), idempotencyRoute.IdempotencyMiddleware(), loanRoute.ReturnBook)
+	api.GET("/loans", authRoute.Require(models.PermissionLoanOwn), loanRoute.ListLoans)
+	api.GET("/loans/:id", authRoute.Require(models.PermissionLoanOwn), loanRoute.GetLoan)
+	api.GET("/loans/:id/events", authRoute.Require(models.PermissionLoanOwn), loanRoute.GetLoanEvents)
+	api.GET("/loans/:id/renewals", authRoute.Require(models.PermissionLoanOwn), renewalRoute.ListAutoRenewals)
+	api.POST("/loans/:id/extend", authRoute.Require(models.PermissionLoanOwn), idempotencyRoute.IdempotencyMiddleware(), loanRoute.ExtendLoanById)
+	api.POST("/loans/:id/return", authRoute.Require(models.PermissionLoanOwn), idempotencyRoute.IdempotencyMiddleware(), loanRoute.ReturnLoanById)
+	api.POST("/loans/:id/lost", authRoute.Require(models.PermissionLoanManage), idempotencyRoute.IdempotencyMiddleware(), loanRoute.MarkLoanLost)
+	api.POST("/loans/:id/damaged", authRoute.Require(models.PermissionLoanManage), idempotencyRoute.IdempotencyMiddleware(), loanRoute.MarkLoanDamaged)
+	api.POST("/loans/:id/found", authRoute.Require(models.PermissionLoanManage), idempotencyRoute.IdempotencyMiddleware(), loanRoute.MarkLoanFound)
+	api.POST("/bookings", authRoute.Require(models.PermissionLoanOwn), bookingRoute.CreateBooking)
+	api.GET("/bookings/:id", authRoute.Require(models.PermissionLoanOwn), bookingRoute.GetBooking)
+	api.POST("/bookings/:id/cancel", authRoute.Require(models.PermissionLoanOwn), bookingRoute.CancelBooking)
+	api.POST("/bookings/:id/borrow", authRoute.Require(models.PermissionLoanOwn), idempotencyRoute.IdempotencyMiddleware(), bookingRoute.BorrowBooking)
+	api.GET("/terms", authRoute.Require(models.PermissionCatalogRead), courseRoute.ListTerms)
+	api.POST("/terms", authRoute.Require(models.PermissionCourseManage), courseRoute.CreateTerm)
+	api.GET("/courses", authRoute.Require(models.PermissionCatalogRead), courseRoute.ListCourses)
+	api.POST("/courses", authRoute.Require(models.PermissionCourseManage), courseRoute.CreateCourse)
+	api.GET("/courses/:id", authRoute.Require(models.PermissionCatalogRead), courseRoute.GetCourse)
+	api.GET("/courses/:id/reserves", authRoute.Require(models.PermissionCatalogRead), courseRoute.GetCourseReserves)
+	api.POST("/courses/:id/reserves", authRoute.Require(models.PermissionCourseManage), courseRoute.AddReserve)
+	api.POST("/reserves/:id/end", authRoute.Require(models.PermissionCourseManage), courseRoute.EndReserve)
+	api.POST("/members", authRoute.Require(models.PermissionMemberManage), memberRoute.CreateMember)
+	api.GET("/members/:id", authRoute.Require(models.PermissionMemberOwn), memberRoute.GetMember)
+	api.GET("/members/:id/loans", authRoute.Require(models.PermissionMemberOwn), memberRoute.GetMemberLoans)
+	api.PUT("/members/:id/preferences", authRoute.Require(models.PermissionMemberOwn), memberRoute.UpdatePreferences)
+	api.PUT("/members/:id/standing", authRoute.Require(models.PermissionMemberManage), memberRoute.UpdateStanding)
+	api.GET("/branches", authRoute.Require(models.PermissionCatalogRead), branchRoute.ListBranches)
+	api.POST("/branches", authRoute.Require(models.PermissionConfigManage), branchRoute.CreateBranch)
+	api.PUT("/branches/:id", authRoute.Require(models.PermissionConfigManage), branchRoute.UpdateBranch)
+	api.GET("/branches/:id/calendar", authRoute.Require(models.PermissionCatalogRead), calendarRoute.GetCalendar)
+	api.PUT("/branches/:id/hours", authRoute.Require(models.PermissionConfigManage), calendarRoute.SetOpeningHours)
+	api.POST("/closures", authRoute.Require(models.PermissionConfigManage), calendarRoute.CreateClosure)
+	api.DELETE("/closures/:id", authRoute.Require(models.PermissionConfigManage), calendarRoute.DeleteClosure)
+	api.GET("/transfers", authRoute.Require(models.PermissionInventoryManage), branchRoute.ListTransfers)
+	api.POST("/transfers", authRoute.Require(models.PermissionInventoryManage), branchRoute.RequestTransfer)
+	api.POST("/transfers/:id/dispatch", authRoute.Require(models.PermissionInventoryManage), branchRoute.DispatchTransfer)
+	api.POST("/transfers/:id/receive", authRoute.Require(models.PermissionInventoryManage), branchRoute.ReceiveTransfer)
+	api.POST("/transfers/:id/cancel", authRoute.Require(models.PermissionInventoryManage), branchRoute.CancelTransfer)
+	api.GET("/admin/audit", authRoute.Require(models.PermissionAuditRead), auditRoute.ListAuditEntries)
+	api.GET("/webhooks", authRoute.Require(models.PermissionConfigManage), webhookRoute.ListSubscriptions)
+	api.POST("/webhooks", authRoute.Require(models.PermissionConfigManage), webhookRoute.CreateSubscription)
+	api.DELETE("/webhooks/:id", authRoute.Require(models.PermissionConfigManage), webhookRoute.DeleteSubscription)
+	api.GET("/webhooks/deliveries", authRoute.Require(models.PermissionConfigManage), webhookRoute.ListDeliveries)
+	api.POST("/webhooks/deliveries/:id/retry", authRoute.Require(models.PermissionConfigManage), webhookRoute.RetryDelivery)
+	api.GET("/admin/jobs", authRoute.Require(models.PermissionConfigManage), jobRoute.ListJobs)
+	api.GET("/admin/jobs/runs", authRoute.Require(models.PermissionConfigManage), jobRoute.ListRuns)
+	api.POST("/admin/jobs/:name/run", authRoute.Require(models.PermissionConfigManage), jobRoute.TriggerJob)
+	if travelClock != nil {
+		clockService := services.NewClockService(travelClock, operatorTenant)
+		clockService.Auditor = auditService
+		clockRoute := routes.NewClockRoute(clockService)
+		api.GET("/admin/clock", authRoute.Require(models.PermissionConfigManage), clockRoute.GetClock)
+		api.PUT("/admin/clock", authRoute.Require(models.PermissionConfigManage), clockRoute.AdjustClock)
+		api.DELETE("/admin/clock", authRoute.Require(models.PermissionConfigManage), clockRoute.ResetClock)
+	}
+	return jobService
+}
+
+// newMailSender sends mail through the SMTP server at MAIL_SMTP_ADDR when set, e.g. localhost:1025 for the local
+// stand-in server of docker-compose, authenticated with MAIL_SMTP_USER and MAIL_SMTP_PASSWORD when set.
+// Otherwise mail is logged with MAIL_SINK=log, or written to files in mail_outbox.
+func newMailSender() mail.Sender {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "library@example.com"
+	}
+	if addr := os.Getenv("MAIL_SMTP_ADDR"); addr != "" {
+		var smtpAuth smtp.Auth
+		if user := os.Getenv("MAIL_SMTP_USER"); user != "" {
+			host, _, _ := strings.Cut(addr, ":")
+			smtpAuth = smtp.PlainAuth("", user, os.Getenv("MAIL_SMTP_PASSWORD"), host)
+		}
+		return mail.NewSMTPSender(addr, from, smtpAuth)
+	}
+	if os.Getenv("MAIL_SINK") == "log" {
+		return mail.LogSender{}
+	}
+	return mail.NewFileSender("mail_outbox", from)
 }
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"github.com/aftaab60/e-library-api/internal/validate"
+	"time"
+)
+
+const (
+	MinPasswordLength = 8
+	//MaxPasswordLength is the most bcrypt hashes, longer passwords would be silently truncated
+	MaxPasswordLength = 72
+)
+
+// Credential is the password login of a member, only the bcrypt hash of the password is stored
+type Credential struct {
+	MemberId     int
+	PasswordHash string
+	//FailedLogins counts consecutive failed logins, it is reset on success and when the account gets locked
+	FailedLogins int
+	LockedUntil  *time.Time
+	UpdatedAt    time.Time
+}
+
+// PasswordReset is a single use token mailed to a member, only its hash is stored
+type PasswordReset struct {
+	TokenHash string
+	MemberId  int
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+type RegisterRequest struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+func (r *RegisterRequest) Validate() error {
+	var v validate.Validator
+	validateMemberName(&v, "name", r.Name)
+	validateEmail(&v, "email", r.Email)
+	validatePassword(&v, "password", r.Password)
+	return v.Err()
+}
+
+type LoginRequest struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+func (r *LoginRequest) Validate() error {
+	var v validate.Validator
+	v.Required("name", r.Name)
+	v.Length("name", r.Name, 1, MaxNameLength)
+	v.Required("password", r.Password)
+	return v.Err()
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+func (r *ChangePasswordRequest) Validate() error {
+	var v validate.Validator
+	v.Required("current_password", r.CurrentPassword)
+	validatePassword(&v, "new_password", r.NewPassword)
+	return v.Err()
+}
+
+type PasswordResetRequest struct {
+	Email string `json:"email"`
+}
+
+func (r *PasswordResetRequest) Validate() error {
+	var v validate.Validator
+	validateEmail(&v, "email", r.Email)
+	return v.Err()
+}
+
+type ConfirmPasswordResetRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+func (r *ConfirmPasswordResetRequest) Validate() error {
+	var v validate.Validator
+	v.Required("token", r.Token)
+	v.Length("token", r.Token, 1, MaxTokenLength)
+	validatePassword(&v, "password", r.Password)
+	return v.Err()
+}
@@ -0,0 +1,197 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/aftaab60/e-library-api/internal/validate"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// AuditAction is a state change recorded in the audit log
+type AuditAction string
+
+const (
+	AuditActionLoanBorrowed       AuditAction = "loan.borrowed"
+	AuditActionLoanExtended       AuditAction = "loan.extended"
+	AuditActionLoanReturned       AuditAction = "loan.returned"
+	AuditActionLoanLost           AuditAction = "loan.lost"
+	AuditActionLoanDamaged        AuditAction = "loan.damaged"
+	AuditActionLoanFound          AuditAction = "loan.found"
+	AuditActionTransferRequested  AuditAction = "transfer.requested"
+	AuditActionTransferDispatched AuditAction = "transfer.dispatched"
+	AuditActionTransferReceived   AuditAction = "transfer.received"
+	AuditActionTransferCancelled  AuditAction = "transfer.cancelled"
+	AuditActionPolicyUpdated      AuditAction = "policy.updated"
+	AuditActionApiKeyCreated      AuditAction = "api_key.created"
+	AuditActionApiKeyRevoked      AuditAction = "api_key.revoked"
+	AuditActionIsbnUpdated        AuditAction = "book.isbn_updated"
+	AuditActionBranchCreated      AuditAction = "branch.created"
+	AuditActionBranchUpdated      AuditAction = "branch.updated"
+	AuditActionHoursUpdated       AuditAction = "calendar.hours_updated"
+	AuditActionClosureCreated     AuditAction = "calendar.closure_created"
+	AuditActionClosureDeleted     AuditAction = "calendar.closure_deleted"
+	AuditActionClockAdjusted      AuditAction = "clock.adjusted"
+	AuditActionBlockOverridden    AuditAction = "loan.block_overridden"
+	AuditActionStandingUpdated    AuditAction = "member.standing_updated"
+	AuditActionBookingCreated     AuditAction = "booking.created"
+	AuditActionBookingCancelled   AuditAction = "booking.cancelled"
+	AuditActionLoanTypeUpdated    AuditAction = "book.loan_type_updated"
+	AuditActionTermCreated        AuditAction = "course.term_created"
+	AuditActionCourseCreated      AuditAction = "course.created"
+	AuditActionReserveAdded       AuditAction = "course.reserve_added"
+	AuditActionReserveEnded       AuditAction = "course.reserve_ended"
+)
+
+// Types of the entities audit entries are about, and of the records their changes touch
+const (
+	AuditEntityLoan        = "loan"
+	AuditEntityBook        = "book"
+	AuditEntityBranchStock = "branch_stock"
+	AuditEntityCharge      = "charge"
+	AuditEntityTransfer    = "transfer"
+	AuditEntityTenant      = "tenant"
+	AuditEntityApiKey      = "api_key"
+	AuditEntityBranch      = "branch"
+	AuditEntityClosure     = "closure"
+	AuditEntityClock       = "clock"
+	AuditEntityMember      = "member"
+	AuditEntityBooking     = "booking"
+	AuditEntityTerm        = "term"
+	AuditEntityCourse      = "course"
+	AuditEntityReserve     = "reserve"
+)
+
+// AuditEntity names a record in audit changes by its type and ids, e.g. "loan:5" or "branch_stock:2:1"
+func AuditEntity(entityType string, ids ...int) string {
+	entity := entityType
+	for _, id := range ids {
+		entity += fmt.Sprintf(":%d", id)
+	}
+	return entity
+}
+
+// AuditEntry records who changed what and how. Entries are only ever appended, never updated or deleted.
+type AuditEntry struct {
+	Id     int         `json:"id"`
+	Action AuditAction `json:"action"`
+	//ActorType, ActorId and ActorName identify the principal, ActorType is system for changes made without one
+	ActorType PrincipalType `json:"actor_type"`
+	ActorId   int           `json:"actor_id,omitempty"`
+	ActorName string        `json:"actor_name,omitempty"`
+	RequestId string        `json:"request_id,omitempty"`
+	//EntityType and EntityId identify what the action was taken on, e.g. a loan
+	EntityType string        `json:"entity_type"`
+	EntityId   int           `json:"entity_id"`
+	Changes    []AuditChange `json:"changes"`
+	CreatedAt  time.Time     `json:"created_at"`
+}
+
+// AuditChange is one changed field. Entity names the changed record, e.g. "book:1" or "branch_stock:2:1" for
+// the stock of book 1 at branch 2. Before is null for created records.
+type AuditChange struct {
+	Entity string `json:"entity"`
+	Field  string `json:"field"`
+	Before any    `json:"before"`
+	After  any    `json:"after"`
+}
+
+// Diff lists the fields that differ between two versions of a record, compared by their JSON encoding.
+// before is nil for a created record.
+func Diff(entity string, before any, after any) []AuditChange {
+	beforeFields, afterFields := jsonObject(before), jsonObject(after)
+	fields := make([]string, 0, len(afterFields))
+	for field := range afterFields {
+		fields = append(fields, field)
+	}
+	for field := range beforeFields {
+		if _, ok := afterFields[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	changes := make([]AuditChange, 0)
+	for _, field := range fields {
+		if !reflect.DeepEqual(beforeFields[field], afterFields[field]) {
+			changes = append(changes, AuditChange{Entity: entity, Field: field, Before: beforeFields[field], After: afterFields[field]})
+		}
+	}
+	return changes
+}
+
+func jsonObject(v any) map[string]any {
+	object := make(map[string]any)
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Pointer && reflect.ValueOf(v).IsNil()) {
+		return object
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return object
+	}
+	_ = json.Unmarshal(b, &object)
+	return object
+}
+
+// AuditFilter selects audit entries, zero values don't filter. From and To are inclusive days.
+type AuditFilter struct {
+	Action     AuditAction `form:"action"`
+	ActorName  string      `form:"actor"`
+	EntityType string      `form:"entity_type"`
+	EntityId   int         `form:"entity_id"`
+	RequestId  string      `form:"request_id"`
+	From       time.Time   `form:"from" time_format:"2006-01-02"`
+	To         time.Time   `form:"to" time_format:"2006-01-02"`
+	Limit      int         `form:"limit"`
+	Offset     int         `form:"offset"`
+}
+
+const (
+	DefaultAuditPageSize = 50
+	MaxAuditPageSize     = 500
+)
+
+func (f *AuditFilter) Validate() error {
+	var v validate.Validator
+	if !f.From.IsZero() && !f.To.IsZero() {
+		v.Check(!f.To.Before(f.From), "to", validate.CodeOutOfRange, "must not be before from")
+	}
+	v.Min("entity_id", f.EntityId, 0)
+	v.Range("limit", f.Limit, 1, MaxAuditPageSize)
+	v.Min("offset", f.Offset, 0)
+	return v.Err()
+}
+
+// Matches tells whether an entry passes the filter, pagination aside
+func (f *AuditFilter) Matches(entry *AuditEntry) bool {
+	if f.Action != "" && entry.Action != f.Action {
+		return false
+	}
+	if f.ActorName != "" && entry.ActorName != f.ActorName {
+		return false
+	}
+	if f.EntityType != "" && entry.EntityType != f.EntityType {
+		return false
+	}
+	if f.EntityId != 0 && entry.EntityId != f.EntityId {
+		return false
+	}
+	if f.RequestId != "" && entry.RequestId != f.RequestId {
+		return false
+	}
+	if !f.From.IsZero() && entry.CreatedAt.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !entry.CreatedAt.Before(f.To.AddDate(0, 0, 1)) {
+		return false
+	}
+	return true
+}
+
+type AuditPage struct {
+	Entries []AuditEntry `json:"entries"`
+	Total   int          `json:"total"`
+	Limit   int          `json:"limit"`
+	Offset  int          `json:"offset"`
+}
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"github.com/aftaab60/e-library-api/internal/validate"
+	"time"
+)
+
+type PrincipalType string
+
+const (
+	// PrincipalTypeApiKey is an integration authenticated with an API key, it acts on behalf of any borrower
+	PrincipalTypeApiKey PrincipalType = "api_key"
+	// PrincipalTypePatron is a member authenticated with a bearer token, it only acts on its own behalf
+	PrincipalTypePatron PrincipalType = "patron"
+	// PrincipalTypeStaff is a librarian or admin logged in through the identity provider, with a session token
+	PrincipalTypeStaff PrincipalType = "staff"
+	// PrincipalTypeSystem is the actor recorded in the audit log for changes made without a principal
+	PrincipalTypeSystem PrincipalType = "system"
+)
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	Type PrincipalType `json:"type"`
+	Role Role          `json:"role"`
+	//Id is the member id of a patron, or the API key id of an integration. 0 for the tenant's own API key and staff.
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+	//TokenId is the id of the bearer token a patron or staff member authenticated with
+	TokenId  string `json:"token_id,omitempty"`
+	TenantId int    `json:"tenant_id"`
+}
+
+func (p *Principal) IsPatron() bool {
+	return p != nil && p.Type == PrincipalTypePatron
+}
+
+func (p *Principal) IsApiKey() bool {
+	return p != nil && p.Type == PrincipalTypeApiKey
+}
+
+func (p *Principal) Can(permission Permission) bool {
+	return p != nil && p.Role.Can(permission)
+}
+
+// ApiKey is an integration credential, only the hash of the key is stored
+type ApiKey struct {
+	Id int `json:"id"`
+	//Name describes the integration using the key
+	Name string `json:"name"`
+	//Role granted to the integration, librarian or admin
+	Role Role `json:"role"`
+	//Prefix is the first characters of the key, enough to recognise it in listings
+	Prefix    string     `json:"prefix"`
+	KeyHash   string     `json:"-"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+type ApiKeyRequest struct {
+	Name string `json:"name"`
+	//Role defaults to librarian when omitted
+	Role Role `json:"role"`
+}
+
+func (r *ApiKeyRequest) Validate() error {
+	var v validate.Validator
+	validateName(&v, "name", r.Name)
+	validate.OneOf(&v, "role", r.Role, RoleLibrarian, RoleAdmin)
+	return v.Err()
+}
+
+// IssuedApiKey is returned once when a key is created, the plain key can't be retrieved later
+type IssuedApiKey struct {
+	ApiKey
+	Key string `json:"key"`
+}
+
+// Token is a bearer token issued to a patron or staff member, kept so that it can be revoked before it expires
+type Token struct {
+	Id string `json:"id"`
+	//Subject is the member id of patrons, the identity provider subject of staff
+	Subject   string     `json:"subject"`
+	MemberId  int        `json:"member_id,omitempty"`
+	Role      Role       `json:"role"`
+	IssuedAt  time.Time  `json:"issued_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+type TokenRequest struct {
+	MemberId int `json:"member_id"`
+}
+
+func (r *TokenRequest) Validate() error {
+	var v validate.Validator
+	v.Check(r.MemberId > 0, "member_id", validate.CodeRequired, "is required")
+	return v.Err()
+}
+
+type IssuedToken struct {
+	Token     string    `json:"token"`
+	TokenId   string    `json:"token_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
@@ -1,12 +1,81 @@
 package models
 
+import "github.com/aftaab60/e-library-api/internal/validate"
+
+// LoanType is how the copies of a book are lent
+type LoanType string
+
+const (
+	//LoanTypeStandard books are lent for the loan period of the tenant's loan policy
+	LoanTypeStandard LoanType = "standard"
+	//LoanTypeShortLoan books are lent for a number of hours, e.g. course texts in high demand
+	LoanTypeShortLoan LoanType = "short_loan"
+	//LoanTypeReferenceOnly books are read in the library and never lent
+	LoanTypeReferenceOnly LoanType = "reference_only"
+	//LoanTypeNonCirculating books are neither lent nor handed to readers, e.g. archives and books being repaired
+	LoanTypeNonCirculating LoanType = "non_circulating"
+)
+
+// LoanTypes are the loan types books may have
+var LoanTypes = []LoanType{LoanTypeStandard, LoanTypeShortLoan, LoanTypeReferenceOnly, LoanTypeNonCirculating}
+
+// maxLoanHours bounds the hours of a short loan, longer loans are standard ones
+const maxLoanHours = 168
+
 type Book struct {
-	Id              int    `json:"id"`
-	Title           string `json:"title"`
-	AvailableCopies int    `json:"available_copies"`
+	Id              int      `json:"id"`
+	Title           string   `json:"title"`
+	AvailableCopies int      `json:"available_copies"`
+	LoanType        LoanType `json:"loan_type"`
+	//LoanHours is how long a short loan lasts, 0 for other loan types
+	LoanHours int `json:"loan_hours,omitempty"`
+	//Isbn is the ISBN-10 or ISBN-13 of the book, empty until the catalog sets it
+	Isbn string `json:"isbn,omitempty"`
 }
 
 type BookDetail struct {
-	Title           string `json:"title"`
-	AvailableCopies int    `json:"available_copies"`
+	Title           string               `json:"title"`
+	AvailableCopies int                  `json:"available_copies"`
+	LoanType        LoanType             `json:"loan_type"`
+	LoanHours       int                  `json:"loan_hours,omitempty"`
+	Isbn            string               `json:"isbn,omitempty"`
+	Branches        []BranchAvailability `json:"branches,omitempty"`
+}
+
+// LoanTypeRequest changes how the copies of a book are lent
+type LoanTypeRequest struct {
+	LoanType LoanType `json:"loan_type"`
+	//LoanHours is how long a short loan lasts, only set for short loans
+	LoanHours int `json:"loan_hours"`
+}
+
+func (r *LoanTypeRequest) Validate() error {
+	var v validate.Validator
+	validateLoanType(&v, r.LoanType, r.LoanHours)
+	return v.Err()
+}
+
+// IsbnRequest sets the ISBN of a book
+type IsbnRequest struct {
+	Isbn string `json:"isbn"`
+}
+
+func (r *IsbnRequest) Validate() error {
+	var v validate.Validator
+	if v.Required("isbn", r.Isbn) {
+		v.Isbn("isbn", r.Isbn)
+	}
+	return v.Err()
+}
+
+// validateLoanType checks a loan type along with its hours, only short loans have some
+func validateLoanType(v *validate.Validator, loanType LoanType, loanHours int) {
+	if v.Required("loan_type", string(loanType)) {
+		validate.OneOf(v, "loan_type", loanType, LoanTypes...)
+	}
+	if loanType == LoanTypeShortLoan {
+		v.Range("loan_hours", loanHours, 1, maxLoanHours)
+	} else {
+		v.Check(loanHours == 0, "loan_hours", validate.CodeNotAllowed, "only applies to short_loan")
+	}
 }
@@ -0,0 +1,171 @@
+package models
+
+import (
+	"fmt"
+	"github.com/aftaab60/e-library-api/internal/validate"
+	"time"
+)
+
+type BookingStatus string
+
+const (
+	//BookingStatusActive bookings hold copies for their days
+	BookingStatusActive BookingStatus = "active"
+	//BookingStatusFulfilled bookings have had every copy they hold lent
+	BookingStatusFulfilled BookingStatus = "fulfilled"
+	BookingStatusCancelled BookingStatus = "cancelled"
+)
+
+// Bounds of a booking: how many days it lasts, how far ahead it starts and how many copies it holds
+const (
+	maxBookingDays     = 120
+	maxBookingDaysAway = 366
+	maxBookingCopies   = 100
+)
+
+// Booking holds copies of a book at a branch for the days from StartsOn to EndsOn, both in DateLayout and inclusive,
+// e.g. for a class starting next month. Copies held are lent against the booking from its first day on.
+type Booking struct {
+	Id           int    `json:"id"`
+	BookId       int    `json:"book_id"`
+	BranchId     int    `json:"branch_id"`
+	BorrowerName string `json:"borrower_name"`
+	StartsOn     string `json:"starts_on"`
+	EndsOn       string `json:"ends_on"`
+	Copies       int    `json:"copies"`
+	//LentCopies are the copies lent against the booking so far
+	LentCopies int           `json:"lent_copies"`
+	Status     BookingStatus `json:"status"`
+	CreatedAt  time.Time     `json:"created_at"`
+}
+
+// Held is how many copies the booking still holds for its days
+func (b *Booking) Held() int {
+	if b.Status != BookingStatusActive {
+		return 0
+	}
+	return b.Copies - b.LentCopies
+}
+
+// Overlaps tells whether the booking holds copies on any day from from to to, in DateLayout and inclusive
+func (b *Booking) Overlaps(from string, to string) bool {
+	return b.StartsOn <= to && b.EndsOn >= from
+}
+
+type BookingRequest struct {
+	Title        string `json:"title"`
+	BorrowerName string `json:"borrower_name"`
+	//BranchId is where the copies are collected, default branch when omitted
+	BranchId int    `json:"branch_id"`
+	StartsOn string `json:"starts_on"`
+	EndsOn   string `json:"ends_on"`
+	//Copies is how many copies are held, 1 when omitted
+	Copies int `json:"copies"`
+}
+
+// Validate checks the request and fills in its defaults, a booking can't start before today
+func (r *BookingRequest) Validate(today time.Time) error {
+	var v validate.Validator
+	validateTitle(&v, "title", r.Title)
+	validateName(&v, "borrower_name", r.BorrowerName)
+	v.Min("branch_id", r.BranchId, 0)
+	if r.Copies == 0 {
+		r.Copies = 1
+	}
+	v.Check(r.Copies >= 1 && r.Copies <= maxBookingCopies, "copies", validate.CodeOutOfRange,
+		fmt.Sprintf("must be between 1 and %d", maxBookingCopies))
+
+	var startsOn time.Time
+	if v.Required("starts_on", r.StartsOn) {
+		var err error
+		startsOn, err = time.Parse(DateLayout, r.StartsOn)
+		if v.Check(err == nil, "starts_on", validate.CodeInvalidFormat, "must be a date as YYYY-MM-DD") {
+			v.Check(r.StartsOn >= today.Format(DateLayout) && startsOn.Sub(today) < maxBookingDaysAway*24*time.Hour,
+				"starts_on", validate.CodeOutOfRange, fmt.Sprintf("must be from today and within %d days", maxBookingDaysAway))
+		}
+	}
+	if v.Required("ends_on", r.EndsOn) {
+		endsOn, err := time.Parse(DateLayout, r.EndsOn)
+		if v.Check(err == nil, "ends_on", validate.CodeInvalidFormat, "must be a date as YYYY-MM-DD") && !startsOn.IsZero() {
+			v.Check(!endsOn.Before(startsOn) && endsOn.Sub(startsOn) < maxBookingDays*24*time.Hour, "ends_on",
+				validate.CodeOutOfRange, fmt.Sprintf("must be on or after starts_on, and within %d days of it", maxBookingDays))
+		}
+	}
+	return v.Err()
+}
+
+// BookingFilter selects the bookings of a calendar, from today for 4 weeks by default. A zero BranchId selects every branch.
+type BookingFilter struct {
+	CalendarFilter
+	BranchId int `form:"branch_id"`
+}
+
+func (f *BookingFilter) Validate(today time.Time) error {
+	if err := f.CalendarFilter.Validate(today); err != nil {
+		return err
+	}
+	var v validate.Validator
+	v.Min("branch_id", f.BranchId, 0)
+	return v.Err()
+}
+
+// BookingCalendar shows the bookings of a book over a range of days, along with how many copies are booked each day
+type BookingCalendar struct {
+	BookId   int          `json:"book_id"`
+	Title    string       `json:"title"`
+	Bookings []Booking    `json:"bookings"`
+	Days     []BookingDay `json:"days"`
+}
+
+// BookingDay counts the copies booked on a day, BookedCopies includes the copies already lent against the bookings
+type BookingDay struct {
+	Date         string `json:"date"`
+	BookedCopies int    `json:"booked_copies"`
+}
+
+// CopyPlan tells whether the copies of a book held by a branch cover its bookings. Copies on the shelf or in transit
+// cover any booking, copies on loan only cover the bookings starting after the day they are due.
+type CopyPlan struct {
+	Copies int
+	//DueDays are the days the copies on loan are due, in DateLayout
+	DueDays  []string
+	Bookings []Booking
+}
+
+// Shortfall returns the first of the bookings matched by affected that the copies don't cover. Every booking
+// overlapping it is counted as holding its copies at once, which may overstate the copies it needs.
+func (p *CopyPlan) Shortfall(affected func(b *Booking) bool) *Booking {
+	for i := range p.Bookings {
+		booking := &p.Bookings[i]
+		if booking.Held() == 0 || !affected(booking) {
+			continue
+		}
+		copies := p.Copies
+		for _, due := range p.DueDays {
+			if due < booking.StartsOn {
+				copies++
+			}
+		}
+		held := 0
+		for _, other := range p.Bookings {
+			if other.Overlaps(booking.StartsOn, booking.EndsOn) {
+				held += other.Held()
+			}
+		}
+		if held > copies {
+			return booking
+		}
+	}
+	return nil
+}
+
+// BookingLoanRequest lends a copy held by a booking
+type BookingLoanRequest struct {
+	BorrowerName string `json:"borrower_name"`
+}
+
+func (r *BookingLoanRequest) Validate() error {
+	var v validate.Validator
+	validateName(&v, "borrower_name", r.BorrowerName)
+	return v.Err()
+}
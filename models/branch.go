@@ -0,0 +1,95 @@
+package models
+
+import (
+	"github.com/aftaab60/e-library-api/internal/validate"
+	"time"
+)
+
+// DefaultBranchId is the branch used when a request does not name one
+const DefaultBranchId = 1
+
+type Branch struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+	//TimeZone is the IANA time zone of the branch, e.g. Europe/London. Loans of the branch are due at the end of a day there.
+	TimeZone string `json:"time_zone"`
+}
+
+// Location returns the time zone of the branch, UTC when it is unset or unknown
+func (b *Branch) Location() *time.Location {
+	loc, err := time.LoadLocation(b.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// BranchRequest creates a branch or renames it and moves it to another time zone
+type BranchRequest struct {
+	Name     string `json:"name"`
+	TimeZone string `json:"time_zone"`
+}
+
+func (r *BranchRequest) Validate() error {
+	var v validate.Validator
+	validateName(&v, "name", r.Name)
+	if v.Required("time_zone", r.TimeZone) {
+		_, err := time.LoadLocation(r.TimeZone)
+		v.Check(err == nil && r.TimeZone != "Local", "time_zone", validate.CodeInvalidFormat,
+			"must be an IANA time zone, e.g. Europe/London")
+	}
+	return v.Err()
+}
+
+// BranchStock is the number of copies of a book held by a branch.
+// InTransitCopies are on their way to the branch and can't be lent yet.
+type BranchStock struct {
+	BookId          int `json:"book_id"`
+	BranchId        int `json:"branch_id"`
+	AvailableCopies int `json:"available_copies"`
+	InTransitCopies int `json:"in_transit_copies"`
+}
+
+type BranchAvailability struct {
+	BranchId        int    `json:"branch_id"`
+	BranchName      string `json:"branch_name"`
+	AvailableCopies int    `json:"available_copies"`
+	InTransitCopies int    `json:"in_transit_copies"`
+}
+
+type TransferStatus string
+
+const (
+	TransferStatusRequested TransferStatus = "requested"
+	TransferStatusInTransit TransferStatus = "in_transit"
+	TransferStatusReceived  TransferStatus = "received"
+	TransferStatusCancelled TransferStatus = "cancelled"
+)
+
+// Transfer moves one copy of a book between branches. LoanId is set when the transfer was
+// created by a book returned away from its home branch.
+type Transfer struct {
+	Id           int            `json:"id"`
+	BookId       int            `json:"book_id"`
+	FromBranchId int            `json:"from_branch_id"`
+	ToBranchId   int            `json:"to_branch_id"`
+	LoanId       int            `json:"loan_id,omitempty"`
+	Status       TransferStatus `json:"status"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+}
+
+type TransferRequest struct {
+	Title        string `json:"title"`
+	FromBranchId int    `json:"from_branch_id"`
+	ToBranchId   int    `json:"to_branch_id"`
+}
+
+func (t *TransferRequest) Validate() error {
+	var v validate.Validator
+	validateTitle(&v, "title", t.Title)
+	v.Check(t.FromBranchId > 0, "from_branch_id", validate.CodeRequired, "is required")
+	v.Check(t.ToBranchId > 0, "to_branch_id", validate.CodeRequired, "is required")
+	v.Check(t.FromBranchId != t.ToBranchId, "to_branch_id", validate.CodeNotAllowed, "must differ from from_branch_id")
+	return v.Err()
+}
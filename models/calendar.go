@@ -0,0 +1,239 @@
+package models
+
+import (
+	"fmt"
+	"github.com/aftaab60/e-library-api/internal/validate"
+	"strings"
+	"time"
+)
+
+// DateLayout is the layout of calendar days, e.g. 2025-12-25
+const DateLayout = "2006-01-02"
+
+// TimeOfDayLayout is the layout of opening and closing times, e.g. 09:30
+const TimeOfDayLayout = "15:04"
+
+// Weekdays name the days of the week in opening hours, indexed by time.Weekday
+var Weekdays = []string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"}
+
+// EndOfDay returns the last second of the day of t, in the time zone of t, as UTC
+func EndOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 23, 59, 59, 0, t.Location()).UTC()
+}
+
+// maxClosedDays bounds the search of an open day, a branch closed for longer is treated as open
+const maxClosedDays = 366
+
+// OpeningHours are the hours a branch is open on a day of the week
+type OpeningHours struct {
+	Weekday string `json:"weekday"`
+	Opens   string `json:"opens"`
+	Closes  string `json:"closes"`
+}
+
+// OpeningHoursRequest replaces the opening hours of a branch. Days of the week left out are closed every week, a
+// branch without opening hours is open every day.
+type OpeningHoursRequest struct {
+	Hours []OpeningHours `json:"hours"`
+}
+
+func (r *OpeningHoursRequest) Validate() error {
+	var v validate.Validator
+	seen := make(map[string]bool)
+	for i, h := range r.Hours {
+		field := fmt.Sprintf("hours[%d]", i)
+		h.Weekday = strings.ToLower(h.Weekday)
+		r.Hours[i].Weekday = h.Weekday
+		if validate.OneOf(&v, field+".weekday", h.Weekday, Weekdays...) {
+			v.Check(!seen[h.Weekday], field+".weekday", validate.CodeNotAllowed, "is given more than once")
+			seen[h.Weekday] = true
+		}
+		opens, err := time.Parse(TimeOfDayLayout, h.Opens)
+		v.Check(err == nil, field+".opens", validate.CodeInvalidFormat, "must be a time of day as HH:MM")
+		closes, err := time.Parse(TimeOfDayLayout, h.Closes)
+		if v.Check(err == nil, field+".closes", validate.CodeInvalidFormat, "must be a time of day as HH:MM") {
+			v.Check(closes.After(opens), field+".closes", validate.CodeOutOfRange, "must be after opens")
+		}
+	}
+	return v.Err()
+}
+
+// Closure is a day a branch is closed, such as a holiday. BranchId 0 closes every branch of the tenant.
+// Recurring closures are repeated every year on the same month and day.
+type Closure struct {
+	Id        int    `json:"id"`
+	BranchId  int    `json:"branch_id"`
+	Date      string `json:"date"`
+	Name      string `json:"name"`
+	Recurring bool   `json:"recurring"`
+}
+
+// on tells whether the closure falls on day, given as DateLayout
+func (c *Closure) on(day string) bool {
+	if c.Recurring {
+		return c.Date[4:] == day[4:]
+	}
+	return c.Date == day
+}
+
+type ClosureRequest struct {
+	//BranchId is the branch closed, every branch when omitted
+	BranchId  int    `json:"branch_id"`
+	Date      string `json:"date"`
+	Name      string `json:"name"`
+	Recurring bool   `json:"recurring"`
+}
+
+func (r *ClosureRequest) Validate() error {
+	var v validate.Validator
+	v.Min("branch_id", r.BranchId, 0)
+	if v.Required("date", r.Date) {
+		_, err := time.Parse(DateLayout, r.Date)
+		v.Check(err == nil, "date", validate.CodeInvalidFormat, "must be a date as YYYY-MM-DD")
+	}
+	if v.Required("name", r.Name) {
+		v.Length("name", r.Name, 1, 100)
+		v.Charset("name", r.Name, validate.IsText, validate.TextCharacters)
+	}
+	return v.Err()
+}
+
+// CalendarDay tells whether a branch is open on a day, Reason is the name of the closure or "closed" on a day
+// without opening hours
+type CalendarDay struct {
+	Date   string `json:"date"`
+	Open   bool   `json:"open"`
+	Opens  string `json:"opens,omitempty"`
+	Closes string `json:"closes,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// BranchCalendar is when a branch is open. Loans are due on open days, and overdue fines only accrue on them.
+type BranchCalendar struct {
+	BranchId int `json:"branch_id"`
+	//TimeZone is the time zone of the branch, days and opening hours are in it
+	TimeZone string         `json:"time_zone"`
+	Hours    []OpeningHours `json:"hours"`
+	Closures []Closure      `json:"closures"`
+	//Days are the days of the range asked for
+	Days []CalendarDay `json:"days,omitempty"`
+}
+
+// Location returns the time zone of the branch, UTC when it is unset or unknown
+func (c *BranchCalendar) Location() *time.Location {
+	return (&Branch{TimeZone: c.TimeZone}).Location()
+}
+
+// Day tells whether the branch is open on the day of t, in the time zone of the branch
+func (c *BranchCalendar) Day(t time.Time) CalendarDay {
+	t = t.In(c.Location())
+	day := CalendarDay{Date: t.Format(DateLayout), Open: true}
+	for _, closure := range c.Closures {
+		if closure.on(day.Date) {
+			day.Open, day.Reason = false, closure.Name
+			return day
+		}
+	}
+	if len(c.Hours) == 0 {
+		return day
+	}
+	for _, h := range c.Hours {
+		if h.Weekday == Weekdays[t.Weekday()] {
+			day.Opens, day.Closes = h.Opens, h.Closes
+			return day
+		}
+	}
+	day.Open, day.Reason = false, "closed"
+	return day
+}
+
+// NextOpenDay returns t, moved forward by whole days to the first day the branch is open, in the time zone of the branch
+func (c *BranchCalendar) NextOpenDay(t time.Time) time.Time {
+	t = t.In(c.Location())
+	for i := 0; i < maxClosedDays; i++ {
+		day := t.AddDate(0, 0, i)
+		if c.Day(day).Open {
+			return day
+		}
+	}
+	return t
+}
+
+// NextOpenTime returns t when the branch is open at t, or else the next time it opens, in the time zone of the branch.
+// A day without opening hours opens at midnight.
+func (c *BranchCalendar) NextOpenTime(t time.Time) time.Time {
+	t = t.In(c.Location())
+	for i := 0; i < maxClosedDays; i++ {
+		day := t.AddDate(0, 0, i)
+		calendarDay := c.Day(day)
+		if !calendarDay.Open {
+			continue
+		}
+		opens, closes := timeOn(day, "00:00"), timeOn(day, "24:00")
+		if calendarDay.Opens != "" {
+			opens, closes = timeOn(day, calendarDay.Opens), timeOn(day, calendarDay.Closes)
+		}
+		if i > 0 || t.Before(opens) {
+			return opens
+		}
+		if t.Before(closes) {
+			return t
+		}
+	}
+	return t
+}
+
+// timeOn returns the time of day, in TimeOfDayLayout or 24:00 for the end of the day, on the day of t
+func timeOn(t time.Time, timeOfDay string) time.Time {
+	year, month, day := t.Date()
+	if timeOfDay == "24:00" {
+		return time.Date(year, month, day+1, 0, 0, 0, 0, t.Location())
+	}
+	clock, _ := time.Parse(TimeOfDayLayout, timeOfDay)
+	return time.Date(year, month, day, clock.Hour(), clock.Minute(), 0, 0, t.Location())
+}
+
+// OpenDaysBetween counts the days the branch is open after the day of from, up to and including the day of to, in
+// the time zone of the branch
+func (c *BranchCalendar) OpenDaysBetween(from time.Time, to time.Time) int {
+	from = from.In(c.Location())
+	open := 0
+	last := to.In(c.Location()).Format(DateLayout)
+	for day := from.AddDate(0, 0, 1); day.Format(DateLayout) <= last; day = day.AddDate(0, 0, 1) {
+		if c.Day(day).Open {
+			open++
+		}
+	}
+	return open
+}
+
+// maxCalendarDays bounds the days of a calendar asked for at once
+const maxCalendarDays = 366
+
+// CalendarFilter is the range of days of a calendar, from today for 4 weeks by default
+type CalendarFilter struct {
+	From string `form:"from"`
+	To   string `form:"to"`
+}
+
+// Validate checks the range and fills in its defaults, relative to today
+func (f *CalendarFilter) Validate(today time.Time) error {
+	var v validate.Validator
+	if f.From == "" {
+		f.From = today.Format(DateLayout)
+	}
+	from, err := time.Parse(DateLayout, f.From)
+	if !v.Check(err == nil, "from", validate.CodeInvalidFormat, "must be a date as YYYY-MM-DD") {
+		return v.Err()
+	}
+	if f.To == "" {
+		f.To = from.AddDate(0, 0, 27).Format(DateLayout)
+	}
+	to, err := time.Parse(DateLayout, f.To)
+	if v.Check(err == nil, "to", validate.CodeInvalidFormat, "must be a date as YYYY-MM-DD") {
+		v.Check(!to.Before(from) && to.Sub(from) < maxCalendarDays*24*time.Hour, "to", validate.CodeOutOfRange,
+			fmt.Sprintf("must be on or after from, and within %d days of it", maxCalendarDays))
+	}
+	return v.Err()
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+type ChargeType string
+
+const (
+	ChargeTypeReplacement ChargeType = "replacement"
+	ChargeTypeDamage      ChargeType = "damage"
+	ChargeTypeOverdue     ChargeType = "overdue"
+)
+
+type ChargeStatus string
+
+const (
+	ChargeStatusOutstanding ChargeStatus = "outstanding"
+	ChargeStatusPaid        ChargeStatus = "paid"
+	ChargeStatusRefunded    ChargeStatus = "refunded"
+)
+
+// Charge is a fee billed to a borrower against a loan. Amount is in cents.
+type Charge struct {
+	Id           int          `json:"id"`
+	LoanId       int          `json:"loan_id"`
+	BorrowerName string       `json:"borrower_name"`
+	Type         ChargeType   `json:"type"`
+	Amount       int          `json:"amount"`
+	Status       ChargeStatus `json:"status"`
+	CreatedAt    time.Time    `json:"created_at"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"github.com/aftaab60/e-library-api/internal/validate"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxClockAdvance bounds how far the clock moves at once, either way
+const maxClockAdvance = 10 * 365 * 24 * time.Hour
+
+// ClockRequest moves the clock of a non-production deployment. Now is applied first, then Advance. Fields left out are
+// left as they are.
+type ClockRequest struct {
+	Now *time.Time `json:"now"`
+	//Advance is a duration such as 36h or 90m, or a number of days such as 14d, negative to go back
+	Advance string `json:"advance"`
+	Frozen  *bool  `json:"frozen"`
+}
+
+// AdvanceDuration returns Advance as a duration, 0 when it is not set or invalid
+func (r *ClockRequest) AdvanceDuration() time.Duration {
+	if days, ok := strings.CutSuffix(r.Advance, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0
+		}
+		return time.Duration(n) * 24 * time.Hour
+	}
+	d, _ := time.ParseDuration(r.Advance)
+	return d
+}
+
+func (r *ClockRequest) Validate() error {
+	var v validate.Validator
+	if r.Advance != "" {
+		d := r.AdvanceDuration()
+		if v.Check(d != 0, "advance", validate.CodeInvalidFormat, "must be a duration such as 36h, or a number of days such as 14d") {
+			v.Check(d < maxClockAdvance && d > -maxClockAdvance, "advance", validate.CodeOutOfRange, "must be within 10 years")
+		}
+	}
+	return v.Err()
+}
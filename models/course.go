@@ -0,0 +1,150 @@
+package models
+
+import (
+	"fmt"
+	"github.com/aftaab60/e-library-api/internal/validate"
+	"time"
+)
+
+// Bounds of courses: the length of a course code, how many instructors teach it and how many days a term lasts
+const (
+	maxCourseCodeLength = 20
+	maxInstructors      = 20
+	maxTermDays         = 366
+)
+
+// Term is a teaching period, from StartsOn to EndsOn in DateLayout and inclusive. Reserves of its courses end once
+// its last day is over.
+type Term struct {
+	Id       int    `json:"id"`
+	Name     string `json:"name"`
+	StartsOn string `json:"starts_on"`
+	EndsOn   string `json:"ends_on"`
+}
+
+// Ended tells whether the last day of the term is before today, given in DateLayout
+func (t *Term) Ended(today string) bool {
+	return t.EndsOn < today
+}
+
+type TermRequest struct {
+	Name     string `json:"name"`
+	StartsOn string `json:"starts_on"`
+	EndsOn   string `json:"ends_on"`
+}
+
+func (r *TermRequest) Validate() error {
+	var v validate.Validator
+	if v.Required("name", r.Name) {
+		v.Length("name", r.Name, 1, 100)
+		v.Charset("name", r.Name, validate.IsText, validate.TextCharacters)
+	}
+	var startsOn time.Time
+	if v.Required("starts_on", r.StartsOn) {
+		var err error
+		startsOn, err = time.Parse(DateLayout, r.StartsOn)
+		v.Check(err == nil, "starts_on", validate.CodeInvalidFormat, "must be a date as YYYY-MM-DD")
+	}
+	if v.Required("ends_on", r.EndsOn) {
+		endsOn, err := time.Parse(DateLayout, r.EndsOn)
+		if v.Check(err == nil, "ends_on", validate.CodeInvalidFormat, "must be a date as YYYY-MM-DD") && !startsOn.IsZero() {
+			v.Check(!endsOn.Before(startsOn) && endsOn.Sub(startsOn) < maxTermDays*24*time.Hour, "ends_on",
+				validate.CodeOutOfRange, fmt.Sprintf("must be on or after starts_on, and within %d days of it", maxTermDays))
+		}
+	}
+	return v.Err()
+}
+
+// Course is taught in a term by its instructors, e.g. CS101 in Spring 2025. A code is used once per term.
+type Course struct {
+	Id          int      `json:"id"`
+	TermId      int      `json:"term_id"`
+	Code        string   `json:"code"`
+	Name        string   `json:"name"`
+	Instructors []string `json:"instructors"`
+}
+
+type CourseRequest struct {
+	TermId      int      `json:"term_id"`
+	Code        string   `json:"code"`
+	Name        string   `json:"name"`
+	Instructors []string `json:"instructors"`
+}
+
+func (r *CourseRequest) Validate() error {
+	var v validate.Validator
+	v.Min("term_id", r.TermId, 1)
+	if v.Required("code", r.Code) {
+		v.Length("code", r.Code, 1, maxCourseCodeLength)
+		v.Charset("code", r.Code, validate.IsName, validate.NameCharacters)
+	}
+	validateTitle(&v, "name", r.Name)
+	v.Check(len(r.Instructors) <= maxInstructors, "instructors", validate.CodeOutOfRange,
+		fmt.Sprintf("must be at most %d", maxInstructors))
+	for i, instructor := range r.Instructors {
+		validateName(&v, fmt.Sprintf("instructors[%d]", i), instructor)
+	}
+	if r.Instructors == nil {
+		r.Instructors = make([]string, 0)
+	}
+	return v.Err()
+}
+
+// CourseFilter selects the courses of a term, every course when TermId is 0
+type CourseFilter struct {
+	TermId int `form:"term_id"`
+}
+
+func (f *CourseFilter) Validate() error {
+	var v validate.Validator
+	v.Min("term_id", f.TermId, 0)
+	return v.Err()
+}
+
+type ReserveStatus string
+
+const (
+	//ReserveStatusActive reserves lend the book with the loan type of the reserve
+	ReserveStatusActive ReserveStatus = "active"
+	//ReserveStatusEnded reserves gave the book its loan type back
+	ReserveStatusEnded ReserveStatus = "ended"
+)
+
+// Reserve puts a book on reserve for a course: until the term ends, or the reserve is ended sooner, copies of the
+// book are lent with LoanType and LoanHours instead of the loan type the book had before, which is kept to give it back.
+// A book is on one active reserve at most.
+type Reserve struct {
+	Id        int      `json:"id"`
+	CourseId  int      `json:"course_id"`
+	BookId    int      `json:"book_id"`
+	Title     string   `json:"title"`
+	LoanType  LoanType `json:"loan_type"`
+	LoanHours int      `json:"loan_hours,omitempty"`
+	//PreviousLoanType and PreviousLoanHours are given back to the book when the reserve ends
+	PreviousLoanType  LoanType      `json:"previous_loan_type"`
+	PreviousLoanHours int           `json:"previous_loan_hours,omitempty"`
+	Status            ReserveStatus `json:"status"`
+	CreatedAt         time.Time     `json:"created_at"`
+	EndedAt           *time.Time    `json:"ended_at,omitempty"`
+}
+
+// ReserveRequest puts a book on the reserve list of a course, short loans of a few hours are the usual loan type
+type ReserveRequest struct {
+	Title     string   `json:"title"`
+	LoanType  LoanType `json:"loan_type"`
+	LoanHours int      `json:"loan_hours"`
+}
+
+func (r *ReserveRequest) Validate() error {
+	var v validate.Validator
+	validateTitle(&v, "title", r.Title)
+	validateLoanType(&v, r.LoanType, r.LoanHours)
+	return v.Err()
+}
+
+// CourseReserves is the reserve list of a course, active reserves along with those that ended
+type CourseReserves struct {
+	Course   Course    `json:"course"`
+	Term     Term      `json:"term"`
+	Reserves []Reserve `json:"reserves"`
+}
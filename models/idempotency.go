@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// IdempotencyRecord is the first response to a request sent with an Idempotency-Key, it is replayed to retries of the request
+type IdempotencyRecord struct {
+	Key string
+	//RequestHash identifies the request the key was first used with
+	RequestHash string
+	//StatusCode is 0 while the first request is still being processed
+	StatusCode  int
+	ContentType string
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+func (r *IdempotencyRecord) IsCompleted() bool {
+	return r.StatusCode != 0
+}
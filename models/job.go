@@ -0,0 +1,93 @@
+package models
+
+import (
+	"github.com/aftaab60/e-library-api/internal/validate"
+	"time"
+)
+
+// JobTrigger tells what started a job run
+type JobTrigger string
+
+const (
+	JobTriggerSchedule JobTrigger = "schedule"
+	JobTriggerManual   JobTrigger = "manual"
+)
+
+type JobRunStatus string
+
+const (
+	JobRunRunning   JobRunStatus = "running"
+	JobRunSucceeded JobRunStatus = "succeeded"
+	JobRunFailed    JobRunStatus = "failed"
+	// JobRunCancelled runs were stopped because the service shut down
+	JobRunCancelled JobRunStatus = "cancelled"
+)
+
+// Job is a background job of the scheduler, as seen by a tenant
+type Job struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	//Schedule is the cron-style schedule of the job, see cron.Parse
+	Schedule  string    `json:"schedule"`
+	NextRunAt time.Time `json:"next_run_at"`
+	LastRun   *JobRun   `json:"last_run,omitempty"`
+}
+
+// JobRun is one run of a job for a tenant
+type JobRun struct {
+	Id      int          `json:"id"`
+	Job     string       `json:"job"`
+	Trigger JobTrigger   `json:"trigger"`
+	Status  JobRunStatus `json:"status"`
+	//ScheduledAt is the time of the schedule the run is for, nil for manual runs. A job runs once per scheduled time
+	//whatever the number of instances of the service.
+	ScheduledAt *time.Time `json:"scheduled_at,omitempty"`
+	//TriggeredBy names who started a manual run
+	TriggeredBy string     `json:"triggered_by,omitempty"`
+	StartedAt   time.Time  `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	//Processed counts what the run worked on, e.g. reminders sent or webhook deliveries made
+	Processed int    `json:"processed"`
+	Error     string `json:"error,omitempty"`
+}
+
+// JobRunFilter selects job runs, zero values don't filter
+type JobRunFilter struct {
+	Job    string       `form:"job"`
+	Status JobRunStatus `form:"status"`
+	Limit  int          `form:"limit"`
+	Offset int          `form:"offset"`
+}
+
+const (
+	DefaultJobRunPageSize = 50
+	MaxJobRunPageSize     = 500
+)
+
+func (f *JobRunFilter) Validate() error {
+	var v validate.Validator
+	if f.Status != "" {
+		validate.OneOf(&v, "status", f.Status, JobRunRunning, JobRunSucceeded, JobRunFailed, JobRunCancelled)
+	}
+	v.Range("limit", f.Limit, 1, MaxJobRunPageSize)
+	v.Min("offset", f.Offset, 0)
+	return v.Err()
+}
+
+// Matches tells whether a run passes the filter, pagination aside
+func (f *JobRunFilter) Matches(run *JobRun) bool {
+	if f.Job != "" && run.Job != f.Job {
+		return false
+	}
+	if f.Status != "" && run.Status != f.Status {
+		return false
+	}
+	return true
+}
+
+type JobRunPage struct {
+	Runs   []JobRun `json:"runs"`
+	Total  int      `json:"total"`
+	Limit  int      `json:"limit"`
+	Offset int      `json:"offset"`
+}
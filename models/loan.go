@@ -1,43 +1,148 @@
 package models
 
 import (
-	"errors"
+	"github.com/aftaab60/e-library-api/internal/validate"
 	"time"
 )
 
+// LoanStatus tells how a loan currently stands or how it has been closed
+type LoanStatus string
+
+const (
+	LoanStatusActive   LoanStatus = "active"
+	LoanStatusReturned LoanStatus = "returned"
+	LoanStatusLost     LoanStatus = "lost"
+	LoanStatusDamaged  LoanStatus = "damaged"
+)
+
 type Loan struct {
-	Id           int       `json:"id"`
-	BookId       int       `json:"book_id"`
-	BorrowerName string    `json:"borrower_name"`
-	LoanDate     time.Time `json:"loan_date"`
-	ReturnDate   time.Time `json:"return_date"`
-	IsReturn     bool      `json:"is_return"`
+	Id           int        `json:"id"`
+	BookId       int        `json:"book_id"`
+	BorrowerName string     `json:"borrower_name"`
+	LoanDate     time.Time  `json:"loan_date"`
+	ReturnDate   time.Time  `json:"return_date"`
+	IsReturn     bool       `json:"is_return"`
+	Status       LoanStatus `json:"status"`
+	BranchId     int        `json:"branch_id"`
 }
 
+// LoanDetail renders the dates of a loan in TimeZone, the time zone of its home branch
 type LoanDetail struct {
 	NameOfBorrower string    `json:"name_of_borrower"`
 	LoanDate       time.Time `json:"loan_date"`
 	ReturnDate     time.Time `json:"return_date"`
+	TimeZone       string    `json:"time_zone"`
+}
+
+// ReturnLoanRequest is the optional body of returning a loan by id
+type ReturnLoanRequest struct {
+	//BranchId is where the book is handed in, the loan's home branch when omitted
+	BranchId int `json:"branch_id"`
+}
+
+func (r *ReturnLoanRequest) Validate() error {
+	var v validate.Validator
+	v.Min("branch_id", r.BranchId, 0)
+	return v.Err()
 }
 
 type LoanRequest struct {
 	Title        string `json:"title"`
 	BorrowerName string `json:"borrower_name"`
+	//BranchId is where the book is borrowed or returned, default branch when omitted
+	BranchId int `json:"branch_id"`
+	//Override lends to a borrower who may not borrow, e.g. over the loan limit, only librarians may set it
+	Override bool `json:"override"`
 }
 
 func (b *LoanRequest) Validate() error {
-	if len(b.Title) == 0 {
-		return errors.New("missing title")
-	}
-	if len(b.BorrowerName) == 0 {
-		return errors.New("missing borrower_name")
-	}
-	//other validations as needed...
-	return nil
+	var v validate.Validator
+	validateTitle(&v, "title", b.Title)
+	validateName(&v, "borrower_name", b.BorrowerName)
+	v.Min("branch_id", b.BranchId, 0)
+	return v.Err()
 }
 
 // LoanUpdate allowed fields that can be updated
 type LoanUpdate struct {
-	ReturnDate *time.Time `json:"return_date"`
-	IsReturn   *bool      `json:"is_return"`
+	ReturnDate *time.Time  `json:"return_date"`
+	IsReturn   *bool       `json:"is_return"`
+	Status     *LoanStatus `json:"status"`
+}
+
+// LoanFilter selects loans to list, zero values don't filter. LoanDateFrom and LoanDateTo are inclusive days.
+type LoanFilter struct {
+	BorrowerName string     `form:"borrower"`
+	Title        string     `form:"title"`
+	BookId       int        `form:"-"`
+	Status       LoanStatus `form:"status"`
+	Overdue      bool       `form:"overdue"`
+	LoanDateFrom time.Time  `form:"from" time_format:"2006-01-02"`
+	LoanDateTo   time.Time  `form:"to" time_format:"2006-01-02"`
+	Limit        int        `form:"limit"`
+	Offset       int        `form:"offset"`
+	//AsOf is the time Overdue is decided at, set by the service from its clock
+	AsOf time.Time `form:"-"`
+}
+
+const (
+	DefaultLoanPageSize = 20
+	MaxLoanPageSize     = 100
+)
+
+func (f *LoanFilter) Validate() error {
+	var v validate.Validator
+	if f.BorrowerName != "" {
+		validateName(&v, "borrower", f.BorrowerName)
+	}
+	if f.Title != "" {
+		validateTitle(&v, "title", f.Title)
+	}
+	if f.Status != "" {
+		validate.OneOf(&v, "status", f.Status, LoanStatusActive, LoanStatusReturned, LoanStatusLost, LoanStatusDamaged)
+	}
+	if !f.LoanDateFrom.IsZero() && !f.LoanDateTo.IsZero() {
+		v.Check(!f.LoanDateTo.Before(f.LoanDateFrom), "to", validate.CodeOutOfRange, "must not be before from")
+	}
+	v.Range("limit", f.Limit, 1, MaxLoanPageSize)
+	v.Min("offset", f.Offset, 0)
+	return v.Err()
+}
+
+// Matches tells whether a loan passes the filter, pagination aside
+func (f *LoanFilter) Matches(loan *Loan) bool {
+	if f.BorrowerName != "" && loan.BorrowerName != f.BorrowerName {
+		return false
+	}
+	if f.BookId != 0 && loan.BookId != f.BookId {
+		return false
+	}
+	if f.Status != "" && loan.Status != f.Status {
+		return false
+	}
+	if f.Overdue && (loan.IsReturn || !loan.ReturnDate.Before(f.AsOf)) {
+		return false
+	}
+	if !f.LoanDateFrom.IsZero() && loan.LoanDate.Before(f.LoanDateFrom) {
+		return false
+	}
+	if !f.LoanDateTo.IsZero() && !loan.LoanDate.Before(f.LoanDateTo.AddDate(0, 0, 1)) {
+		return false
+	}
+	return true
+}
+
+type LoanPage struct {
+	Loans  []Loan `json:"loans"`
+	Total  int    `json:"total"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
+
+// LoanResolution is the outcome of closing a loan as lost or damaged, or of reversing a lost loan once the copy is found
+type LoanResolution struct {
+	LoanId         int        `json:"loan_id"`
+	NameOfBorrower string     `json:"name_of_borrower"`
+	Status         LoanStatus `json:"status"`
+	Charge         *Charge    `json:"charge,omitempty"`
 }
@@ -0,0 +1,170 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// LoanEventType is a step in the lifecycle of a loan
+type LoanEventType string
+
+const (
+	LoanEventCreated  LoanEventType = "LoanCreated"
+	LoanEventExtended LoanEventType = "LoanExtended"
+	LoanEventReturned LoanEventType = "LoanReturned"
+	LoanEventLost     LoanEventType = "LoanLost"
+	LoanEventDamaged  LoanEventType = "LoanDamaged"
+	LoanEventFound    LoanEventType = "LoanFound"
+	// LoanEventImported starts the stream of a loan made before loans were event sourced, with the loan's state at the time
+	LoanEventImported LoanEventType = "LoanImported"
+)
+
+// LoanEvent is one entry of a loan's event stream. The stream is the source of truth of a loan,
+// loans and book availability are projections of it.
+type LoanEvent struct {
+	//Id orders events across all loans, Version orders the events of one loan starting at 1
+	Id         int           `json:"id"`
+	LoanId     int           `json:"loan_id"`
+	Version    int           `json:"version"`
+	Type       LoanEventType `json:"type"`
+	Data       LoanEventData `json:"data"`
+	OccurredAt time.Time     `json:"occurred_at"`
+}
+
+// LoanEventData holds what an event changes, only the fields of the event type are set
+type LoanEventData struct {
+	//Title, BookId, BorrowerName and BranchId are set by LoanCreated and LoanImported, BranchId is the home branch
+	Title        string `json:"title,omitempty"`
+	BookId       int    `json:"book_id,omitempty"`
+	BorrowerName string `json:"borrower_name,omitempty"`
+	BranchId     int    `json:"branch_id,omitempty"`
+	//DueDate is set by LoanCreated, LoanExtended and LoanImported
+	DueDate *time.Time `json:"due_date,omitempty"`
+	//ReturnedAt and ReturnBranchId are set by LoanReturned, ReturnedAt by LoanFound
+	ReturnedAt     *time.Time `json:"returned_at,omitempty"`
+	ReturnBranchId int        `json:"return_branch_id,omitempty"`
+	//LoanDate, IsReturn and Status are only set by LoanImported
+	LoanDate *time.Time `json:"loan_date,omitempty"`
+	IsReturn bool       `json:"is_return,omitempty"`
+	Status   LoanStatus `json:"status,omitempty"`
+}
+
+var ErrInvalidLoanEvent = errors.New("invalid loan event")
+
+// NewLoanImportedEvent snapshots the current state of a loan to start its stream
+func NewLoanImportedEvent(title string, loan *Loan, occurredAt time.Time) LoanEvent {
+	loanDate, dueDate := loan.LoanDate, loan.ReturnDate
+	return LoanEvent{
+		LoanId:     loan.Id,
+		Type:       LoanEventImported,
+		OccurredAt: occurredAt,
+		Data: LoanEventData{
+			Title:        title,
+			BookId:       loan.BookId,
+			BorrowerName: loan.BorrowerName,
+			BranchId:     loan.BranchId,
+			DueDate:      &dueDate,
+			LoanDate:     &loanDate,
+			IsReturn:     loan.IsReturn,
+			Status:       loan.Status,
+		},
+	}
+}
+
+// Apply folds the event into the state of a loan, which is nil before the first event of the stream.
+// It returns the new state and leaves loan untouched.
+func (e *LoanEvent) Apply(loan *Loan) (*Loan, error) {
+	if loan == nil {
+		switch e.Type {
+		case LoanEventCreated:
+			if e.Data.DueDate == nil {
+				return nil, fmt.Errorf("%w: %s without due date", ErrInvalidLoanEvent, e.Type)
+			}
+			return &Loan{
+				Id:           e.LoanId,
+				BookId:       e.Data.BookId,
+				BorrowerName: e.Data.BorrowerName,
+				LoanDate:     e.OccurredAt,
+				ReturnDate:   *e.Data.DueDate,
+				Status:       LoanStatusActive,
+				BranchId:     e.Data.BranchId,
+			}, nil
+		case LoanEventImported:
+			if e.Data.DueDate == nil || e.Data.LoanDate == nil {
+				return nil, fmt.Errorf("%w: %s without dates", ErrInvalidLoanEvent, e.Type)
+			}
+			return &Loan{
+				Id:           e.LoanId,
+				BookId:       e.Data.BookId,
+				BorrowerName: e.Data.BorrowerName,
+				LoanDate:     *e.Data.LoanDate,
+				ReturnDate:   *e.Data.DueDate,
+				IsReturn:     e.Data.IsReturn,
+				Status:       e.Data.Status,
+				BranchId:     e.Data.BranchId,
+			}, nil
+		}
+		return nil, fmt.Errorf("%w: loan %d starts with %s", ErrInvalidLoanEvent, e.LoanId, e.Type)
+	}
+
+	next := *loan
+	switch e.Type {
+	case LoanEventExtended:
+		if e.Data.DueDate == nil {
+			return nil, fmt.Errorf("%w: %s without due date", ErrInvalidLoanEvent, e.Type)
+		}
+		next.ReturnDate = *e.Data.DueDate
+	case LoanEventReturned, LoanEventFound:
+		if e.Data.ReturnedAt == nil {
+			return nil, fmt.Errorf("%w: %s without return date", ErrInvalidLoanEvent, e.Type)
+		}
+		next.ReturnDate = *e.Data.ReturnedAt
+		next.IsReturn = true
+		next.Status = LoanStatusReturned
+	case LoanEventLost:
+		next.IsReturn = true
+		next.Status = LoanStatusLost
+	case LoanEventDamaged:
+		next.IsReturn = true
+		next.Status = LoanStatusDamaged
+	default:
+		return nil, fmt.Errorf("%w: %s after loan %d started", ErrInvalidLoanEvent, e.Type, e.LoanId)
+	}
+	return &next, nil
+}
+
+// ProjectLoan rebuilds a loan from its stream, events are in version order
+func ProjectLoan(events []LoanEvent) (*Loan, error) {
+	var loan *Loan
+	for i := range events {
+		next, err := events[i].Apply(loan)
+		if err != nil {
+			return nil, err
+		}
+		loan = next
+	}
+	return loan, nil
+}
+
+// HoldsCopy tells whether the loan keeps a copy off its home branch shelf: while it is active, and for good once lost or damaged
+func (l *Loan) HoldsCopy() bool {
+	return l != nil && l.Status != LoanStatusReturned
+}
+
+// LoanReplayReport tells what replaying the loan event streams found and changed
+type LoanReplayReport struct {
+	DryRun bool `json:"dry_run"`
+	Events int  `json:"events"`
+	Loans  int  `json:"loans"`
+	//Rebuilt lists the ids of loans whose stored state differed from their stream
+	Rebuilt []int `json:"rebuilt"`
+	//Adjustments are the changes of available copies made to match the rebuilt loans
+	Adjustments []AvailabilityAdjustment `json:"adjustments"`
+}
+
+type AvailabilityAdjustment struct {
+	BookId   int `json:"book_id"`
+	BranchId int `json:"branch_id"`
+	Delta    int `json:"delta"`
+}
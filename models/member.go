@@ -0,0 +1,157 @@
+package models
+
+import (
+	"github.com/aftaab60/e-library-api/internal/validate"
+	"time"
+)
+
+// AnonymizedBorrower replaces the borrower name of returned loans of members who opted out of history retention
+const AnonymizedBorrower = "anonymized"
+
+// Member is a library patron. Loans are linked to a member by borrower name.
+type Member struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+	//Email is where account mail is sent, empty for members without a login
+	Email string `json:"email,omitempty"`
+	//RetainHistory false means returned loans are anonymized, only current loans stay linked to the member
+	RetainHistory bool `json:"retain_history"`
+	//DueReminders and OverdueNotices tell whether the member is mailed when a loan is due soon or overdue
+	DueReminders   bool `json:"due_reminders"`
+	OverdueNotices bool `json:"overdue_notices"`
+	//Suspended members may not borrow or renew until a librarian reinstates them
+	Suspended bool `json:"suspended"`
+	//ExpiresOn is the last day of the membership, in DateLayout, empty for memberships that don't expire
+	ExpiresOn string `json:"expires_on,omitempty"`
+	//CardNumber is the number of the member's library card, empty for members without a card
+	CardNumber string `json:"card_number,omitempty"`
+}
+
+// Expired reports whether the membership ended before the day of t
+func (m *Member) Expired(t time.Time) bool {
+	return m.ExpiresOn != "" && t.Format(DateLayout) > m.ExpiresOn
+}
+
+// NewMember returns a member with the default preferences: history is retained and notifications are sent
+func NewMember(name string, email string) *Member {
+	return &Member{Name: name, Email: email, RetainHistory: true, DueReminders: true, OverdueNotices: true}
+}
+
+type MemberRequest struct {
+	Name string `json:"name"`
+	//CardNumber is optional, members may be given a card later
+	CardNumber string `json:"card_number"`
+}
+
+func (m *MemberRequest) Validate() error {
+	var v validate.Validator
+	validateMemberName(&v, "name", m.Name)
+	if m.CardNumber != "" {
+		v.CardNumber("card_number", m.CardNumber)
+	}
+	return v.Err()
+}
+
+func validateMemberName(v *validate.Validator, field string, name string) {
+	validateName(v, field, name)
+	v.Check(name != AnonymizedBorrower, field, validate.CodeNotAllowed, "is reserved")
+}
+
+// MemberPreferences updates the preferences that are set, the others are kept
+type MemberPreferences struct {
+	RetainHistory  *bool `json:"retain_history"`
+	DueReminders   *bool `json:"due_reminders"`
+	OverdueNotices *bool `json:"overdue_notices"`
+}
+
+func (p *MemberPreferences) Validate() error {
+	var v validate.Validator
+	v.Check(p.RetainHistory != nil || p.DueReminders != nil || p.OverdueNotices != nil, "retain_history", validate.CodeRequired,
+		"at least one of retain_history, due_reminders and overdue_notices is required")
+	return v.Err()
+}
+
+// Apply sets the preferences of member that are set
+func (p *MemberPreferences) Apply(member *Member) {
+	if p.RetainHistory != nil {
+		member.RetainHistory = *p.RetainHistory
+	}
+	if p.DueReminders != nil {
+		member.DueReminders = *p.DueReminders
+	}
+	if p.OverdueNotices != nil {
+		member.OverdueNotices = *p.OverdueNotices
+	}
+}
+
+// MemberStanding updates whether a member may borrow, the fields that are set are updated and the others are kept.
+// An empty ExpiresOn removes the expiry.
+type MemberStanding struct {
+	Suspended *bool   `json:"suspended"`
+	ExpiresOn *string `json:"expires_on"`
+}
+
+func (s *MemberStanding) Validate() error {
+	var v validate.Validator
+	v.Check(s.Suspended != nil || s.ExpiresOn != nil, "suspended", validate.CodeRequired,
+		"at least one of suspended and expires_on is required")
+	if s.ExpiresOn != nil && *s.ExpiresOn != "" {
+		_, err := time.Parse(DateLayout, *s.ExpiresOn)
+		v.Check(err == nil, "expires_on", validate.CodeInvalidFormat, "must be a date as YYYY-MM-DD")
+	}
+	return v.Err()
+}
+
+// Apply sets the standing of member that is set
+func (s *MemberStanding) Apply(member *Member) {
+	if s.Suspended != nil {
+		member.Suspended = *s.Suspended
+	}
+	if s.ExpiresOn != nil {
+		member.ExpiresOn = *s.ExpiresOn
+	}
+}
+
+// Reasons a member may not borrow, or renew a loan
+const (
+	BlockReasonLoanLimit = "loan_limit_reached"
+	BlockReasonFineLimit = "fine_limit_exceeded"
+	BlockReasonSuspended = "account_suspended"
+	BlockReasonExpired   = "account_expired"
+)
+
+type DueStatus string
+
+const (
+	DueStatusOnLoan   DueStatus = "on_loan"
+	DueStatusDueSoon  DueStatus = "due_soon"
+	DueStatusOverdue  DueStatus = "overdue"
+	DueStatusReturned DueStatus = "returned"
+	DueStatusClosed   DueStatus = "closed"
+)
+
+// DueSoonDays is how close to its return date an active loan is reported as due soon
+const DueSoonDays = 3
+
+// MemberLoan is a loan as seen by the member, with book details and due date status. Its dates are in TimeZone, the
+// time zone of its home branch. DaysRemaining is only set for active loans and is negative once overdue.
+type MemberLoan struct {
+	LoanId        int        `json:"loan_id"`
+	BookId        int        `json:"book_id"`
+	Title         string     `json:"title"`
+	LoanDate      time.Time  `json:"loan_date"`
+	ReturnDate    time.Time  `json:"return_date"`
+	Status        LoanStatus `json:"status"`
+	DueStatus     DueStatus  `json:"due_status"`
+	DaysRemaining *int       `json:"days_remaining,omitempty"`
+	BranchId      int        `json:"branch_id"`
+	TimeZone      string     `json:"time_zone"`
+}
+
+type MemberLoanPage struct {
+	Member Member       `json:"member"`
+	Loans  []MemberLoan `json:"loans"`
+	Total  int          `json:"total"`
+	Limit  int          `json:"limit"`
+	Offset int          `json:"offset"`
+}
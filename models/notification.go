@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// NotificationKind is what a notification tells the member about a loan
+type NotificationKind string
+
+const (
+	// NotificationDueSoon reminds the member that a loan is due within DueSoonDays
+	NotificationDueSoon NotificationKind = "due_soon"
+	// NotificationOverdue tells the member that a loan is past its return date
+	NotificationOverdue NotificationKind = "overdue"
+)
+
+// Notification records that a member was told about a loan. A loan gets one notification of each kind per due date,
+// extending the loan makes it due soon again.
+type Notification struct {
+	Id        int              `json:"id"`
+	LoanId    int              `json:"loan_id"`
+	MemberId  int              `json:"member_id"`
+	Kind      NotificationKind `json:"kind"`
+	DueDate   time.Time        `json:"due_date"`
+	Recipient string           `json:"recipient"`
+	SentAt    time.Time        `json:"sent_at"`
+}
+
+// ReminderReport counts the outcome of a reminder run. Skipped loans belong to borrowers who aren't members with an
+// email or who opted out, loans notified before aren't counted.
+type ReminderReport struct {
+	DueSoon int `json:"due_soon"`
+	Overdue int `json:"overdue"`
+	Skipped int `json:"skipped"`
+	Failed  int `json:"failed"`
+}
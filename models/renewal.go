@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// RenewalOutcome is how an automatic renewal of a loan went
+type RenewalOutcome string
+
+const (
+	RenewalRenewed RenewalOutcome = "renewed"
+	RenewalRefused RenewalOutcome = "refused"
+)
+
+// Reasons an automatic renewal is refused, they match the error codes of extending the loan by hand. Renewals of
+// members who may not borrow are refused with the reason they are blocked, e.g. BlockReasonSuspended.
+const (
+	RenewalReasonLimitReached   = "renewal_limit_reached"
+	RenewalReasonNotActive      = "loan_not_active"
+	RenewalReasonBooked         = "booking_conflict"
+	RenewalReasonReferenceOnly  = "reference_only"
+	RenewalReasonNonCirculating = "non_circulating"
+)
+
+// AutoRenewal records an automatic renewal attempt of a loan. A loan is attempted once per due date.
+type AutoRenewal struct {
+	Id      int            `json:"id"`
+	LoanId  int            `json:"loan_id"`
+	DueDate time.Time      `json:"due_date"`
+	Outcome RenewalOutcome `json:"outcome"`
+	//Reason tells why a renewal was refused, NewDueDate is the due date of a renewed loan
+	Reason      string     `json:"reason,omitempty"`
+	NewDueDate  *time.Time `json:"new_due_date,omitempty"`
+	AttemptedAt time.Time  `json:"attempted_at"`
+}
+
+// AutoRenewalReport counts the outcome of an auto-renewal run
+type AutoRenewalReport struct {
+	Renewed int `json:"renewed"`
+	Refused int `json:"refused"`
+}
@@ -0,0 +1,64 @@
+package models
+
+import "errors"
+
+type Role string
+
+const (
+	// RolePatron borrows, extends and returns its own loans
+	RolePatron Role = "patron"
+	// RoleLibrarian acts on behalf of any member, manages loans, members and inventory, and may override loan policies
+	RoleLibrarian Role = "librarian"
+	// RoleAdmin manages the catalog and the tenant's configuration, and may do anything a librarian does
+	RoleAdmin Role = "admin"
+)
+
+type Permission string
+
+const (
+	PermissionCatalogRead     Permission = "catalog:read"
+	PermissionCatalogManage   Permission = "catalog:manage"
+	PermissionConfigManage    Permission = "config:manage"
+	PermissionLoanOwn         Permission = "loan:own"
+	PermissionLoanAny         Permission = "loan:any"
+	PermissionLoanManage      Permission = "loan:manage"
+	PermissionPolicyOverride  Permission = "policy:override"
+	PermissionInventoryManage Permission = "inventory:manage"
+	PermissionMemberOwn       Permission = "member:own"
+	PermissionMemberManage    Permission = "member:manage"
+	PermissionAuditRead       Permission = "audit:read"
+	PermissionCourseManage    Permission = "course:manage"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RolePatron: {
+		PermissionCatalogRead, PermissionLoanOwn, PermissionMemberOwn,
+	},
+	RoleLibrarian: {
+		PermissionCatalogRead, PermissionLoanOwn, PermissionMemberOwn,
+		PermissionLoanAny, PermissionLoanManage, PermissionPolicyOverride, PermissionInventoryManage, PermissionMemberManage,
+		PermissionCourseManage,
+	},
+	RoleAdmin: {
+		PermissionCatalogRead, PermissionLoanOwn, PermissionMemberOwn,
+		PermissionLoanAny, PermissionLoanManage, PermissionPolicyOverride, PermissionInventoryManage, PermissionMemberManage,
+		PermissionCourseManage, PermissionCatalogManage, PermissionConfigManage, PermissionAuditRead,
+	},
+}
+
+// Can reports whether the role is granted the permission
+func (r Role) Can(permission Permission) bool {
+	for _, p := range rolePermissions[r] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Role) Validate() error {
+	if _, ok := rolePermissions[r]; !ok {
+		return errors.New("invalid role")
+	}
+	return nil
+}
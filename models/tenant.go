@@ -0,0 +1,54 @@
+package models
+
+import "github.com/aftaab60/e-library-api/internal/validate"
+
+// LoanPolicy holds the loan rules a tenant can configure. Fees are in cents.
+type LoanPolicy struct {
+	LoanPeriodDays int `json:"loan_period_days"`
+	ExtensionDays  int `json:"extension_days"`
+	//MaxRenewals is how many times a loan is renewed automatically, extensions by hand count towards it but aren't capped.
+	//0 renews none
+	MaxRenewals    int `json:"max_renewals"`
+	ReplacementFee int `json:"replacement_fee"`
+	DamageFee      int `json:"damage_fee"`
+	//OverdueFine is charged per open day a loan is returned late, 0 charges nothing
+	OverdueFine int `json:"overdue_fine"`
+	//MaxLoans is how many loans a member may have at once, 0 sets no limit
+	MaxLoans int `json:"max_loans"`
+	//FineLimit blocks borrowing and renewals while a member owes more than it in outstanding charges, 0 sets no limit
+	FineLimit int `json:"fine_limit"`
+}
+
+// DefaultLoanPolicy applies when a request is not scoped to a tenant
+var DefaultLoanPolicy = LoanPolicy{
+	LoanPeriodDays: 28,
+	ExtensionDays:  21,
+	MaxRenewals:    3,
+	ReplacementFee: 2500,
+	DamageFee:      1500,
+	MaxLoans:       10,
+	FineLimit:      1000,
+}
+
+func (p *LoanPolicy) Validate() error {
+	var v validate.Validator
+	v.Min("loan_period_days", p.LoanPeriodDays, 1)
+	v.Min("extension_days", p.ExtensionDays, 1)
+	v.Min("max_renewals", p.MaxRenewals, 0)
+	v.Min("replacement_fee", p.ReplacementFee, 0)
+	v.Min("damage_fee", p.DamageFee, 0)
+	v.Min("overdue_fine", p.OverdueFine, 0)
+	v.Min("max_loans", p.MaxLoans, 0)
+	v.Min("fine_limit", p.FineLimit, 0)
+	return v.Err()
+}
+
+// Tenant is an independent library system hosted in the same deployment
+type Tenant struct {
+	Id              int        `json:"id"`
+	Slug            string     `json:"slug"`
+	Name            string     `json:"name"`
+	ApiKeyHash      string     `json:"-"`
+	DefaultBranchId int        `json:"default_branch_id"`
+	Policy          LoanPolicy `json:"policy"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"fmt"
+	"github.com/aftaab60/e-library-api/internal/validate"
+)
+
+// Limits of request fields, they match what the pgsql schema and the UI can hold
+const (
+	MaxTitleLength = 255
+	MaxNameLength  = 100
+	MaxEmailLength = 254
+	MaxTokenLength = 128
+)
+
+// validateTitle checks a book title
+func validateTitle(v *validate.Validator, field string, title string) {
+	v.Required(field, title)
+	v.Length(field, title, 1, MaxTitleLength)
+	v.Charset(field, title, validate.IsText, validate.TextCharacters)
+}
+
+// validateName checks the name of a member, borrower or integration
+func validateName(v *validate.Validator, field string, name string) {
+	v.Required(field, name)
+	v.Length(field, name, 1, MaxNameLength)
+	v.Charset(field, name, validate.IsName, validate.NameCharacters)
+}
+
+func validateEmail(v *validate.Validator, field string, email string) {
+	v.Required(field, email)
+	v.Length(field, email, 1, MaxEmailLength)
+	v.Email(field, email)
+}
+
+// validatePassword checks a new password, passwords are limited in bytes as bcrypt hashes at most 72 bytes
+func validatePassword(v *validate.Validator, field string, password string) {
+	v.Check(len(password) >= MinPasswordLength, field, validate.CodeTooShort, fmt.Sprintf("must be at least %d characters", MinPasswordLength))
+	v.Check(len(password) <= MaxPasswordLength, field, validate.CodeTooLong, fmt.Sprintf("must be at most %d bytes", MaxPasswordLength))
+}
@@ -0,0 +1,170 @@
+package models
+
+import (
+	"encoding/json"
+	"github.com/aftaab60/e-library-api/internal/validate"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// WebhookEventTypes are the events webhooks can subscribe to, the loan events except LoanImported which only starts a stream
+var WebhookEventTypes = []LoanEventType{LoanEventCreated, LoanEventExtended, LoanEventReturned, LoanEventLost, LoanEventDamaged, LoanEventFound}
+
+// OutboxMessage is a domain event waiting to be delivered to webhooks. It is written in the transaction of the change
+// it announces, so that an event is published if and only if the change is committed.
+type OutboxMessage struct {
+	Id        int             `json:"id"`
+	EventType LoanEventType   `json:"event_type"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+	//DispatchedAt is set once deliveries to the subscriptions of the event were queued
+	DispatchedAt *time.Time `json:"dispatched_at,omitempty"`
+}
+
+// LoanEventPayload is the payload of loan events, the event along with the state of the loan after it
+type LoanEventPayload struct {
+	Event LoanEvent `json:"event"`
+	Loan  Loan      `json:"loan"`
+}
+
+// WebhookEvent is the body POSTed to webhooks
+type WebhookEvent struct {
+	//Id is the same in every delivery of an event, receivers use it to ignore duplicates
+	Id        int             `json:"id"`
+	Type      LoanEventType   `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// WebhookSubscription registers a URL to be called on events. The secret signs deliveries, it is only shown when the
+// subscription is created.
+type WebhookSubscription struct {
+	Id  int    `json:"id"`
+	Url string `json:"url"`
+	//EventTypes the subscription receives, all events when empty
+	EventTypes []LoanEventType `json:"event_types"`
+	Secret     string          `json:"-"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// Receives tells whether events of eventType are delivered to the subscription
+func (s *WebhookSubscription) Receives(eventType LoanEventType) bool {
+	if len(s.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range s.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+type WebhookSubscriptionRequest struct {
+	Url        string          `json:"url"`
+	EventTypes []LoanEventType `json:"event_types"`
+}
+
+func (r *WebhookSubscriptionRequest) Validate() error {
+	var v validate.Validator
+	if v.Required("url", r.Url) {
+		u, err := url.Parse(r.Url)
+		if v.Check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Hostname() != "", "url", validate.CodeInvalidFormat, "must be an http or https URL") {
+			//host names are checked again for the addresses they resolve to when deliveries are sent
+			host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+			ip := net.ParseIP(host)
+			v.Check(host != "localhost" && !strings.HasSuffix(host, ".localhost") && (ip == nil || PublicAddress(ip)), "url", validate.CodeNotAllowed,
+				"must not be a loopback, link-local or private host")
+		}
+	}
+	for _, eventType := range r.EventTypes {
+		validate.OneOf(&v, "event_types", eventType, WebhookEventTypes...)
+	}
+	return v.Err()
+}
+
+// PublicAddress tells whether webhooks may be delivered to an address: loopback, link-local, private and unspecified
+// addresses of the network the library runs in are refused
+func PublicAddress(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() && !ip.IsPrivate() &&
+		!ip.IsUnspecified() && !ip.IsMulticast()
+}
+
+// IssuedWebhookSubscription is returned once when a subscription is created, the secret can't be retrieved later
+type IssuedWebhookSubscription struct {
+	WebhookSubscription
+	Secret string `json:"secret"`
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	// WebhookDeliveryPending deliveries are attempted at NextAttemptAt
+	WebhookDeliveryPending WebhookDeliveryStatus = "pending"
+	// WebhookDeliveryDelivered deliveries got a 2xx response
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "delivered"
+	// WebhookDeliveryDead deliveries failed every attempt, they wait in the dead letter queue until retried by hand
+	WebhookDeliveryDead WebhookDeliveryStatus = "dead"
+)
+
+// WebhookDelivery is an event on its way to one subscription
+type WebhookDelivery struct {
+	Id             int                   `json:"id"`
+	SubscriptionId int                   `json:"subscription_id"`
+	MessageId      int                   `json:"message_id"`
+	EventType      LoanEventType         `json:"event_type"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	//Body is the WebhookEvent sent, kept as is so that every attempt sends the same bytes
+	Body          json.RawMessage `json:"body"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	//LastStatusCode is the response status of the last attempt, 0 when no response was received. LastError tells why it failed.
+	LastStatusCode int        `json:"last_status_code,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
+
+// WebhookDeliveryFilter selects deliveries, zero values don't filter. status=dead is the dead letter queue.
+type WebhookDeliveryFilter struct {
+	Status         WebhookDeliveryStatus `form:"status"`
+	SubscriptionId int                   `form:"subscription_id"`
+	Limit          int                   `form:"limit"`
+	Offset         int                   `form:"offset"`
+}
+
+const (
+	DefaultWebhookDeliveryPageSize = 50
+	MaxWebhookDeliveryPageSize     = 500
+)
+
+func (f *WebhookDeliveryFilter) Validate() error {
+	var v validate.Validator
+	if f.Status != "" {
+		validate.OneOf(&v, "status", f.Status, WebhookDeliveryPending, WebhookDeliveryDelivered, WebhookDeliveryDead)
+	}
+	v.Min("subscription_id", f.SubscriptionId, 0)
+	v.Range("limit", f.Limit, 1, MaxWebhookDeliveryPageSize)
+	v.Min("offset", f.Offset, 0)
+	return v.Err()
+}
+
+// Matches tells whether a delivery passes the filter, pagination aside
+func (f *WebhookDeliveryFilter) Matches(delivery *WebhookDelivery) bool {
+	if f.Status != "" && delivery.Status != f.Status {
+		return false
+	}
+	if f.SubscriptionId != 0 && delivery.SubscriptionId != f.SubscriptionId {
+		return false
+	}
+	return true
+}
+
+type WebhookDeliveryPage struct {
+	Deliveries []WebhookDelivery `json:"deliveries"`
+	Total      int               `json:"total"`
+	Limit      int               `json:"limit"`
+	Offset     int               `json:"offset"`
+}
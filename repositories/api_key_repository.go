@@ -0,0 +1,78 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"github.com/aftaab60/e-library-api/models"
+	"sync"
+	"time"
+)
+
+type IApiKeyRepository interface {
+	CreateApiKey(ctx context.Context, apiKey *models.ApiKey) (*models.ApiKey, error)
+	GetApiKeyByHash(ctx context.Context, keyHash string) (*models.ApiKey, error)
+	ListApiKeys(ctx context.Context) ([]models.ApiKey, error)
+	RevokeApiKey(ctx context.Context, id int, revokedAt time.Time) (*models.ApiKey, error)
+}
+
+type ApiKeyRepository struct {
+	apiKeys []models.ApiKey
+	mutex   sync.RWMutex
+}
+
+func NewApiKeyRepository() *ApiKeyRepository {
+	return &ApiKeyRepository{
+		apiKeys: make([]models.ApiKey, 0),
+	}
+}
+
+// ErrApiKeyNotFound is returned when an API key is not found
+var ErrApiKeyNotFound = errors.New("api key not found")
+
+func (ar *ApiKeyRepository) CreateApiKey(ctx context.Context, apiKey *models.ApiKey) (*models.ApiKey, error) {
+	ar.mutex.Lock()
+	defer ar.mutex.Unlock()
+
+	apiKey.Id = len(ar.apiKeys) + 1 //incremental id
+	ar.apiKeys = append(ar.apiKeys, *apiKey)
+
+	createdApiKey := ar.apiKeys[len(ar.apiKeys)-1]
+	return &createdApiKey, nil
+}
+
+func (ar *ApiKeyRepository) GetApiKeyByHash(ctx context.Context, keyHash string) (*models.ApiKey, error) {
+	ar.mutex.RLock()
+	defer ar.mutex.RUnlock()
+
+	for _, apiKey := range ar.apiKeys {
+		if apiKey.KeyHash == keyHash {
+			return &apiKey, nil
+		}
+	}
+	return nil, ErrApiKeyNotFound
+}
+
+func (ar *ApiKeyRepository) ListApiKeys(ctx context.Context) ([]models.ApiKey, error) {
+	ar.mutex.RLock()
+	defer ar.mutex.RUnlock()
+
+	apiKeys := make([]models.ApiKey, len(ar.apiKeys))
+	copy(apiKeys, ar.apiKeys)
+	return apiKeys, nil
+}
+
+func (ar *ApiKeyRepository) RevokeApiKey(ctx context.Context, id int, revokedAt time.Time) (*models.ApiKey, error) {
+	ar.mutex.Lock()
+	defer ar.mutex.Unlock()
+
+	for i := range ar.apiKeys {
+		if ar.apiKeys[i].Id == id {
+			if ar.apiKeys[i].RevokedAt == nil {
+				ar.apiKeys[i].RevokedAt = &revokedAt
+			}
+			revokedApiKey := ar.apiKeys[i]
+			return &revokedApiKey, nil
+		}
+	}
+	return nil, ErrApiKeyNotFound
+}
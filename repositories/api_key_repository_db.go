@@ -0,0 +1,85 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/aftaab60/e-library-api/internal/db_manager"
+	"github.com/aftaab60/e-library-api/models"
+	"time"
+)
+
+type ApiKeyRepositoryDB struct {
+	DB *db_manager.DB
+}
+
+func NewApiKeyRepositoryDB(db *db_manager.DB) *ApiKeyRepositoryDB {
+	return &ApiKeyRepositoryDB{DB: db}
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanApiKey(row scanner) (*models.ApiKey, error) {
+	var apiKey models.ApiKey
+	var revokedAt sql.NullTime
+	if err := row.Scan(&apiKey.Id, &apiKey.Name, &apiKey.Role, &apiKey.Prefix, &apiKey.KeyHash, &apiKey.CreatedAt, &revokedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrApiKeyNotFound
+		}
+		return nil, err
+	}
+	if revokedAt.Valid {
+		apiKey.RevokedAt = &revokedAt.Time
+	}
+	return &apiKey, nil
+}
+
+func (ar *ApiKeyRepositoryDB) CreateApiKey(ctx context.Context, apiKey *models.ApiKey) (*models.ApiKey, error) {
+	insertQuery := `
+        INSERT INTO api_keys (name, role, prefix, key_hash, created_at)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id, name, role, prefix, key_hash, created_at, revoked_at
+    `
+	createdApiKey, err := scanApiKey(ar.DB.CreateRecord(ctx, insertQuery, apiKey.Name, apiKey.Role, apiKey.Prefix, apiKey.KeyHash, apiKey.CreatedAt))
+	if err != nil {
+		return nil, fmt.Errorf("error creating api key %s: %w", apiKey.Name, err)
+	}
+	return createdApiKey, nil
+}
+
+func (ar *ApiKeyRepositoryDB) GetApiKeyByHash(ctx context.Context, keyHash string) (*models.ApiKey, error) {
+	query := "SELECT id, name, role, prefix, key_hash, created_at, revoked_at FROM api_keys WHERE key_hash = $1"
+	return scanApiKey(ar.DB.GetRecord(ctx, query, keyHash))
+}
+
+func (ar *ApiKeyRepositoryDB) ListApiKeys(ctx context.Context) ([]models.ApiKey, error) {
+	query := "SELECT id, name, role, prefix, key_hash, created_at, revoked_at FROM api_keys ORDER BY id"
+	rows, err := ar.DB.GetRecords(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching api keys: %w", err)
+	}
+	defer rows.Close()
+
+	apiKeys := make([]models.ApiKey, 0)
+	for rows.Next() {
+		apiKey, err := scanApiKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		apiKeys = append(apiKeys, *apiKey)
+	}
+	return apiKeys, rows.Err()
+}
+
+func (ar *ApiKeyRepositoryDB) RevokeApiKey(ctx context.Context, id int, revokedAt time.Time) (*models.ApiKey, error) {
+	updateQuery := `
+        UPDATE api_keys
+        SET revoked_at = COALESCE(revoked_at, $1)
+        WHERE id = $2
+        RETURNING id, name, role, prefix, key_hash, created_at, revoked_at
+    `
+	return scanApiKey(ar.DB.UpdateRecord(ctx, updateQuery, revokedAt, id))
+}
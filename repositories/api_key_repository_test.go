@@ -0,0 +1,66 @@
+package repositories
+
+import (
+	"context"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestApiKeyRepository_RevokeApiKey(t *testing.T) {
+	repo := NewApiKeyRepository()
+	ctx := context.Background()
+
+	apiKey, err := repo.CreateApiKey(ctx, &models.ApiKey{Name: "kiosk", Prefix: "lib_1234", KeyHash: "hash", CreatedAt: time.Now()})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, apiKey.Id)
+
+	t.Run("Revoke existing key", func(t *testing.T) {
+		revokedAt := time.Now()
+		revoked, err := repo.RevokeApiKey(ctx, apiKey.Id, revokedAt)
+		assert.NoError(t, err)
+		assert.Equal(t, revokedAt, *revoked.RevokedAt)
+
+		found, err := repo.GetApiKeyByHash(ctx, "hash")
+		assert.NoError(t, err)
+		assert.NotNil(t, found.RevokedAt)
+
+		// revoking again keeps the first revocation time
+		again, err := repo.RevokeApiKey(ctx, apiKey.Id, revokedAt.Add(time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, revokedAt, *again.RevokedAt)
+	})
+
+	t.Run("Fail to revoke non-existent key", func(t *testing.T) {
+		_, err := repo.RevokeApiKey(ctx, 100, time.Now())
+		assert.Equal(t, ErrApiKeyNotFound, err)
+	})
+}
+
+func TestTokenRepository_RevokeToken(t *testing.T) {
+	repo := NewTokenRepository()
+	ctx := context.Background()
+	now := time.Now()
+
+	_, err := repo.CreateToken(ctx, &models.Token{Id: "expired", MemberId: 1, IssuedAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(-time.Hour)})
+	assert.NoError(t, err)
+	_, err = repo.CreateToken(ctx, &models.Token{Id: "current", MemberId: 1, IssuedAt: now, ExpiresAt: now.Add(time.Hour)})
+	assert.NoError(t, err)
+
+	t.Run("Expired tokens are pruned", func(t *testing.T) {
+		_, err := repo.GetToken(ctx, "expired")
+		assert.Equal(t, ErrTokenNotFound, err)
+	})
+
+	t.Run("Revoke existing token", func(t *testing.T) {
+		revoked, err := repo.RevokeToken(ctx, "current", now)
+		assert.NoError(t, err)
+		assert.NotNil(t, revoked.RevokedAt)
+	})
+
+	t.Run("Fail to revoke non-existent token", func(t *testing.T) {
+		_, err := repo.RevokeToken(ctx, "unknown", now)
+		assert.Equal(t, ErrTokenNotFound, err)
+	})
+}
@@ -0,0 +1,58 @@
+package repositories
+
+import (
+	"context"
+	"github.com/aftaab60/e-library-api/models"
+	"sync"
+)
+
+// IAuditRepository stores the audit log. It is append-only, entries are never updated or deleted.
+type IAuditRepository interface {
+	CreateAuditEntry(ctx context.Context, entry *models.AuditEntry) (*models.AuditEntry, error)
+	// ListAuditEntries returns a page of entries matching the filter, newest first, along with the total number of matching entries
+	ListAuditEntries(ctx context.Context, filter *models.AuditFilter) ([]models.AuditEntry, int, error)
+}
+
+type AuditRepository struct {
+	entries []models.AuditEntry
+	mutex   sync.RWMutex
+}
+
+func NewAuditRepository() *AuditRepository {
+	return &AuditRepository{
+		entries: make([]models.AuditEntry, 0),
+	}
+}
+
+func (ar *AuditRepository) CreateAuditEntry(ctx context.Context, entry *models.AuditEntry) (*models.AuditEntry, error) {
+	ar.mutex.Lock()
+	defer ar.mutex.Unlock()
+
+	createdEntry := *entry
+	createdEntry.Id = len(ar.entries) + 1
+	createdEntry.Changes = append([]models.AuditChange(nil), entry.Changes...)
+	ar.entries = append(ar.entries, createdEntry)
+	return &createdEntry, nil
+}
+
+func (ar *AuditRepository) ListAuditEntries(ctx context.Context, filter *models.AuditFilter) ([]models.AuditEntry, int, error) {
+	ar.mutex.RLock()
+	defer ar.mutex.RUnlock()
+
+	matched := make([]models.AuditEntry, 0)
+	for i := len(ar.entries) - 1; i >= 0; i-- {
+		if filter.Matches(&ar.entries[i]) {
+			matched = append(matched, ar.entries[i])
+		}
+	}
+
+	total := len(matched)
+	if filter.Offset >= total {
+		return make([]models.AuditEntry, 0), total, nil
+	}
+	end := total
+	if filter.Limit > 0 && filter.Offset+filter.Limit < total {
+		end = filter.Offset + filter.Limit
+	}
+	return matched[filter.Offset:end], total, nil
+}
@@ -0,0 +1,105 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/aftaab60/e-library-api/internal/db_manager"
+	"github.com/aftaab60/e-library-api/models"
+	"strings"
+)
+
+type AuditRepositoryDB struct {
+	DB *db_manager.DB
+}
+
+func NewAuditRepositoryDB(db *db_manager.DB) *AuditRepositoryDB {
+	return &AuditRepositoryDB{DB: db}
+}
+
+func (ar *AuditRepositoryDB) CreateAuditEntry(ctx context.Context, entry *models.AuditEntry) (*models.AuditEntry, error) {
+	changes, err := json.Marshal(entry.Changes)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding audit changes: %w", err)
+	}
+	insertQuery := `
+        INSERT INTO audit_log (action, actor_type, actor_id, actor_name, request_id, entity_type, entity_id, changes, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        RETURNING id
+    `
+	createdEntry := *entry
+	row := ar.DB.CreateRecord(ctx, insertQuery, entry.Action, entry.ActorType, entry.ActorId, entry.ActorName, entry.RequestId,
+		entry.EntityType, entry.EntityId, changes, entry.CreatedAt)
+	if err := row.Scan(&createdEntry.Id); err != nil {
+		return nil, fmt.Errorf("error creating audit entry: %w", err)
+	}
+	return &createdEntry, nil
+}
+
+func (ar *AuditRepositoryDB) ListAuditEntries(ctx context.Context, filter *models.AuditFilter) ([]models.AuditEntry, int, error) {
+	conditions := make([]string, 0)
+	args := make([]interface{}, 0)
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if filter.Action != "" {
+		addCondition("action = $%d", filter.Action)
+	}
+	if filter.ActorName != "" {
+		addCondition("actor_name = $%d", filter.ActorName)
+	}
+	if filter.EntityType != "" {
+		addCondition("entity_type = $%d", filter.EntityType)
+	}
+	if filter.EntityId != 0 {
+		addCondition("entity_id = $%d", filter.EntityId)
+	}
+	if filter.RequestId != "" {
+		addCondition("request_id = $%d", filter.RequestId)
+	}
+	if !filter.From.IsZero() {
+		addCondition("created_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		addCondition("created_at < $%d", filter.To.AddDate(0, 0, 1))
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	countQuery := "SELECT COUNT(*) FROM audit_log " + where
+	if err := ar.DB.GetRecord(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("error counting audit entries: %w", err)
+	}
+
+	query := fmt.Sprintf(`
+        SELECT id, action, actor_type, actor_id, actor_name, request_id, entity_type, entity_id, changes, created_at
+        FROM audit_log
+        %s
+        ORDER BY id DESC
+        LIMIT $%d OFFSET $%d
+    `, where, len(args)+1, len(args)+2)
+	rows, err := ar.DB.GetRecords(ctx, query, append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error fetching audit entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]models.AuditEntry, 0)
+	for rows.Next() {
+		var entry models.AuditEntry
+		var changes []byte
+		if err := rows.Scan(&entry.Id, &entry.Action, &entry.ActorType, &entry.ActorId, &entry.ActorName, &entry.RequestId,
+			&entry.EntityType, &entry.EntityId, &changes, &entry.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		if err := json.Unmarshal(changes, &entry.Changes); err != nil {
+			return nil, 0, fmt.Errorf("error decoding audit changes: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, total, rows.Err()
+}
@@ -0,0 +1,62 @@
+package repositories
+
+import (
+	"context"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestAuditRepository_ListAuditEntries(t *testing.T) {
+	repo := NewAuditRepository()
+	ctx := context.Background()
+	now := time.Now()
+
+	for i, action := range []models.AuditAction{models.AuditActionLoanBorrowed, models.AuditActionLoanExtended, models.AuditActionLoanReturned} {
+		entry, err := repo.CreateAuditEntry(ctx, &models.AuditEntry{
+			Action:     action,
+			ActorType:  models.PrincipalTypePatron,
+			ActorName:  "user1",
+			RequestId:  "request1",
+			EntityType: models.AuditEntityLoan,
+			EntityId:   1,
+			CreatedAt:  now.Add(time.Duration(i) * time.Minute),
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, i+1, entry.Id)
+	}
+	_, err := repo.CreateAuditEntry(ctx, &models.AuditEntry{Action: models.AuditActionPolicyUpdated, ActorType: models.PrincipalTypeStaff,
+		ActorName: "admin1", EntityType: models.AuditEntityTenant, EntityId: 1, CreatedAt: now})
+	assert.NoError(t, err)
+
+	t.Run("List newest first", func(t *testing.T) {
+		entries, total, err := repo.ListAuditEntries(ctx, &models.AuditFilter{Limit: 2})
+		assert.NoError(t, err)
+		assert.Equal(t, 4, total)
+		assert.Len(t, entries, 2)
+		assert.Equal(t, 4, entries[0].Id)
+		assert.Equal(t, 3, entries[1].Id)
+	})
+
+	t.Run("Filter by entity and actor", func(t *testing.T) {
+		entries, total, err := repo.ListAuditEntries(ctx, &models.AuditFilter{EntityType: models.AuditEntityLoan, EntityId: 1, ActorName: "user1", Offset: 1, Limit: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, total)
+		assert.Len(t, entries, 2)
+		assert.Equal(t, models.AuditActionLoanExtended, entries[0].Action)
+	})
+
+	t.Run("Filter by action and request id", func(t *testing.T) {
+		entries, total, err := repo.ListAuditEntries(ctx, &models.AuditFilter{Action: models.AuditActionLoanReturned, RequestId: "request1", Limit: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, total)
+		assert.Equal(t, 3, entries[0].Id)
+	})
+
+	t.Run("Filter by day", func(t *testing.T) {
+		_, total, err := repo.ListAuditEntries(ctx, &models.AuditFilter{From: now.AddDate(0, 0, 1), Limit: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, 0, total)
+	})
+}
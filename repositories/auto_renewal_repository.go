@@ -0,0 +1,59 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"github.com/aftaab60/e-library-api/models"
+	"sync"
+)
+
+// IAutoRenewalRepository keeps the outcome of automatic renewals of loans
+type IAutoRenewalRepository interface {
+	// CreateAutoRenewal records an attempt, it fails with ErrExistingAutoRenewal when the loan was already attempted
+	// for the due date
+	CreateAutoRenewal(ctx context.Context, renewal *models.AutoRenewal) (*models.AutoRenewal, error)
+	// ListAutoRenewals returns the attempts of a loan, oldest first
+	ListAutoRenewals(ctx context.Context, loanId int) ([]models.AutoRenewal, error)
+}
+
+type AutoRenewalRepository struct {
+	renewals []models.AutoRenewal
+	mutex    sync.RWMutex
+}
+
+func NewAutoRenewalRepository() *AutoRenewalRepository {
+	return &AutoRenewalRepository{
+		renewals: make([]models.AutoRenewal, 0),
+	}
+}
+
+// ErrExistingAutoRenewal is returned when a loan was already attempted for the due date
+var ErrExistingAutoRenewal = errors.New("existing auto renewal")
+
+func (ar *AutoRenewalRepository) CreateAutoRenewal(ctx context.Context, renewal *models.AutoRenewal) (*models.AutoRenewal, error) {
+	ar.mutex.Lock()
+	defer ar.mutex.Unlock()
+
+	for _, r := range ar.renewals {
+		if r.LoanId == renewal.LoanId && r.DueDate.Equal(renewal.DueDate) {
+			return nil, ErrExistingAutoRenewal
+		}
+	}
+	createdRenewal := *renewal
+	createdRenewal.Id = len(ar.renewals) + 1
+	ar.renewals = append(ar.renewals, createdRenewal)
+	return &createdRenewal, nil
+}
+
+func (ar *AutoRenewalRepository) ListAutoRenewals(ctx context.Context, loanId int) ([]models.AutoRenewal, error) {
+	ar.mutex.RLock()
+	defer ar.mutex.RUnlock()
+
+	renewals := make([]models.AutoRenewal, 0)
+	for _, r := range ar.renewals {
+		if r.LoanId == loanId {
+			renewals = append(renewals, r)
+		}
+	}
+	return renewals, nil
+}
@@ -0,0 +1,61 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aftaab60/e-library-api/internal/db_manager"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/lib/pq"
+)
+
+type AutoRenewalRepositoryDB struct {
+	DB *db_manager.DB
+}
+
+func NewAutoRenewalRepositoryDB(db *db_manager.DB) *AutoRenewalRepositoryDB {
+	return &AutoRenewalRepositoryDB{DB: db}
+}
+
+func (ar *AutoRenewalRepositoryDB) CreateAutoRenewal(ctx context.Context, renewal *models.AutoRenewal) (*models.AutoRenewal, error) {
+	insertQuery := `
+        INSERT INTO auto_renewals (loan_id, due_date, outcome, reason, new_due_date, attempted_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING id
+    `
+	createdRenewal := *renewal
+	row := ar.DB.CreateRecord(ctx, insertQuery, renewal.LoanId, renewal.DueDate, renewal.Outcome, renewal.Reason,
+		renewal.NewDueDate, renewal.AttemptedAt)
+	if err := row.Scan(&createdRenewal.Id); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" { //unique_violation
+			return nil, ErrExistingAutoRenewal
+		}
+		return nil, fmt.Errorf("error creating auto renewal of loan %d: %w", renewal.LoanId, err)
+	}
+	return &createdRenewal, nil
+}
+
+func (ar *AutoRenewalRepositoryDB) ListAutoRenewals(ctx context.Context, loanId int) ([]models.AutoRenewal, error) {
+	query := `
+        SELECT id, loan_id, due_date, outcome, reason, new_due_date, attempted_at
+        FROM auto_renewals
+        WHERE loan_id = $1
+        ORDER BY id
+    `
+	rows, err := ar.DB.GetRecords(ctx, query, loanId)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching auto renewals of loan %d: %w", loanId, err)
+	}
+	defer rows.Close()
+
+	renewals := make([]models.AutoRenewal, 0)
+	for rows.Next() {
+		var r models.AutoRenewal
+		if err := rows.Scan(&r.Id, &r.LoanId, &r.DueDate, &r.Outcome, &r.Reason, &r.NewDueDate, &r.AttemptedAt); err != nil {
+			return nil, err
+		}
+		renewals = append(renewals, r)
+	}
+	return renewals, rows.Err()
+}
@@ -10,6 +10,11 @@ import (
 type IBookRepository interface {
 	GetBook(ctx context.Context, title string) (*models.Book, error)
 	UpdateBook(ctx context.Context, title string, availableQuantity int) (*models.Book, error)
+	GetBookById(ctx context.Context, id int) (*models.Book, error)
+	// UpdateBookLoanType sets how the copies of a book are lent, loanHours is only set for short loans
+	UpdateBookLoanType(ctx context.Context, title string, loanType models.LoanType, loanHours int) (*models.Book, error)
+	// UpdateBookIsbn sets the ISBN of a book
+	UpdateBookIsbn(ctx context.Context, title string, isbn string) (*models.Book, error)
 }
 
 type BookRepository struct {
@@ -31,10 +36,10 @@ func (br *BookRepository) initBookRepository() {
 	defer br.mutex.Unlock()
 
 	books := []models.Book{
-		{Id: 1, Title: "book1", AvailableCopies: 5},
-		{Id: 2, Title: "book2", AvailableCopies: 3},
-		{Id: 3, Title: "book3", AvailableCopies: 1},
-		{Id: 4, Title: "book4", AvailableCopies: 0},
+		{Id: 1, Title: "book1", AvailableCopies: 5, LoanType: models.LoanTypeStandard},
+		{Id: 2, Title: "book2", AvailableCopies: 3, LoanType: models.LoanTypeStandard},
+		{Id: 3, Title: "book3", AvailableCopies: 1, LoanType: models.LoanTypeStandard},
+		{Id: 4, Title: "book4", AvailableCopies: 0, LoanType: models.LoanTypeStandard},
 	}
 	for _, book := range books {
 		br.books[book.Title] = &book
@@ -61,3 +66,40 @@ func (br *BookRepository) UpdateBook(ctx context.Context, title string, availabl
 	br.books[title] = book
 	return book, nil
 }
+
+func (br *BookRepository) GetBookById(ctx context.Context, id int) (*models.Book, error) {
+	br.mutex.RLock()
+	defer br.mutex.RUnlock()
+
+	for _, book := range br.books {
+		if book.Id == id {
+			return book, nil
+		}
+	}
+	return nil, ErrBookNotFound
+}
+
+func (br *BookRepository) UpdateBookLoanType(ctx context.Context, title string, loanType models.LoanType, loanHours int) (*models.Book, error) {
+	br.mutex.Lock()
+	defer br.mutex.Unlock()
+
+	book, ok := br.books[title]
+	if !ok {
+		return nil, ErrBookNotFound
+	}
+	book.LoanType = loanType
+	book.LoanHours = loanHours
+	return book, nil
+}
+
+func (br *BookRepository) UpdateBookIsbn(ctx context.Context, title string, isbn string) (*models.Book, error) {
+	br.mutex.Lock()
+	defer br.mutex.Unlock()
+
+	book, ok := br.books[title]
+	if !ok {
+		return nil, ErrBookNotFound
+	}
+	book.Isbn = isbn
+	return book, nil
+}
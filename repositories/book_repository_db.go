@@ -2,6 +2,8 @@ package repositories
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"github.com/aftaab60/e-library-api/internal/db_manager"
 	"github.com/aftaab60/e-library-api/models"
 )
@@ -14,24 +16,49 @@ func NewBookRepositoryDB(db *db_manager.DB) *BookRepositoryDB {
 	return &BookRepositoryDB{DB: db}
 }
 
-func (br *BookRepositoryDB) GetBook(ctx context.Context, title string) (*models.Book, error) {
-	query := "SELECT id, title, available_copies FROM books WHERE title = $1"
-	result := br.DB.GetRecord(ctx, query, title)
+const bookColumns = "id, title, available_copies, loan_type, loan_hours, COALESCE(isbn, '')"
 
-	var bookDetail models.Book
-	if err := result.Scan(&bookDetail.Id, &bookDetail.Title, &bookDetail.AvailableCopies); err != nil {
+func scanBook(row scanner) (*models.Book, error) {
+	var book models.Book
+	if err := row.Scan(&book.Id, &book.Title, &book.AvailableCopies, &book.LoanType, &book.LoanHours, &book.Isbn); err != nil {
 		return nil, err
 	}
-	return &bookDetail, nil
+	return &book, nil
+}
+
+func (br *BookRepositoryDB) GetBook(ctx context.Context, title string) (*models.Book, error) {
+	query := "SELECT " + bookColumns + " FROM books WHERE title = $1"
+	return scanBook(br.DB.GetRecord(ctx, query, title))
 }
 
 func (br *BookRepositoryDB) UpdateBook(ctx context.Context, title string, availableCopies int) (*models.Book, error) {
-	query := "UPDATE books SET available_copies = $1 WHERE title = $2 RETURNING id, title, available_copies"
-	row := br.DB.UpdateRecord(ctx, query, availableCopies, title)
+	query := "UPDATE books SET available_copies = $1 WHERE title = $2 RETURNING " + bookColumns
+	return scanBook(br.DB.UpdateRecord(ctx, query, availableCopies, title))
+}
 
-	var bookDetail models.Book
-	if err := row.Scan(&bookDetail.Id, &bookDetail.Title, &bookDetail.AvailableCopies); err != nil {
-		return nil, err
+func (br *BookRepositoryDB) GetBookById(ctx context.Context, id int) (*models.Book, error) {
+	query := "SELECT " + bookColumns + " FROM books WHERE id = $1"
+	book, err := scanBook(br.DB.GetRecord(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrBookNotFound
+	}
+	return book, err
+}
+
+func (br *BookRepositoryDB) UpdateBookLoanType(ctx context.Context, title string, loanType models.LoanType, loanHours int) (*models.Book, error) {
+	query := "UPDATE books SET loan_type = $1, loan_hours = $2 WHERE title = $3 RETURNING " + bookColumns
+	book, err := scanBook(br.DB.UpdateRecord(ctx, query, loanType, loanHours, title))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrBookNotFound
+	}
+	return book, err
+}
+
+func (br *BookRepositoryDB) UpdateBookIsbn(ctx context.Context, title string, isbn string) (*models.Book, error) {
+	query := "UPDATE books SET isbn = $1 WHERE title = $2 RETURNING " + bookColumns
+	book, err := scanBook(br.DB.UpdateRecord(ctx, query, isbn, title))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrBookNotFound
 	}
-	return &bookDetail, nil
+	return book, err
 }
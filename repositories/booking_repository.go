@@ -0,0 +1,88 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"github.com/aftaab60/e-library-api/models"
+	"sort"
+	"sync"
+)
+
+// IBookingRepository stores the advance bookings of books
+type IBookingRepository interface {
+	CreateBooking(ctx context.Context, booking *models.Booking) (*models.Booking, error)
+	GetBookingById(ctx context.Context, id int) (*models.Booking, error)
+	// UpdateBooking sets the lent copies and the status of a booking
+	UpdateBooking(ctx context.Context, booking *models.Booking) (*models.Booking, error)
+	// ListBookings returns the bookings of a book at a branch, or at every branch when branchId is 0, overlapping the
+	// days from from to to, by first day
+	ListBookings(ctx context.Context, bookId int, branchId int, from string, to string) ([]models.Booking, error)
+}
+
+type BookingRepository struct {
+	bookings []models.Booking
+	mutex    sync.RWMutex
+}
+
+func NewBookingRepository() *BookingRepository {
+	return &BookingRepository{
+		bookings: make([]models.Booking, 0),
+	}
+}
+
+// ErrBookingNotFound is returned when a booking is not found
+var ErrBookingNotFound = errors.New("booking not found")
+
+func (br *BookingRepository) CreateBooking(ctx context.Context, booking *models.Booking) (*models.Booking, error) {
+	br.mutex.Lock()
+	defer br.mutex.Unlock()
+
+	booking.Id = len(br.bookings) + 1 //incremental id
+	br.bookings = append(br.bookings, *booking)
+
+	createdBooking := br.bookings[len(br.bookings)-1]
+	return &createdBooking, nil
+}
+
+func (br *BookingRepository) GetBookingById(ctx context.Context, id int) (*models.Booking, error) {
+	br.mutex.RLock()
+	defer br.mutex.RUnlock()
+
+	for _, booking := range br.bookings {
+		if booking.Id == id {
+			return &booking, nil
+		}
+	}
+	return nil, ErrBookingNotFound
+}
+
+func (br *BookingRepository) UpdateBooking(ctx context.Context, booking *models.Booking) (*models.Booking, error) {
+	br.mutex.Lock()
+	defer br.mutex.Unlock()
+
+	for i := range br.bookings {
+		if br.bookings[i].Id == booking.Id {
+			br.bookings[i].LentCopies = booking.LentCopies
+			br.bookings[i].Status = booking.Status
+			updatedBooking := br.bookings[i]
+			return &updatedBooking, nil
+		}
+	}
+	return nil, ErrBookingNotFound
+}
+
+func (br *BookingRepository) ListBookings(ctx context.Context, bookId int, branchId int, from string, to string) ([]models.Booking, error) {
+	br.mutex.RLock()
+	defer br.mutex.RUnlock()
+
+	bookings := make([]models.Booking, 0)
+	for _, booking := range br.bookings {
+		if booking.BookId == bookId && (branchId == 0 || booking.BranchId == branchId) && booking.Overlaps(from, to) {
+			bookings = append(bookings, booking)
+		}
+	}
+	sort.SliceStable(bookings, func(i, j int) bool {
+		return bookings[i].StartsOn < bookings[j].StartsOn
+	})
+	return bookings, nil
+}
@@ -0,0 +1,92 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/aftaab60/e-library-api/internal/db_manager"
+	"github.com/aftaab60/e-library-api/models"
+	"time"
+)
+
+type BookingRepositoryDB struct {
+	DB *db_manager.DB
+}
+
+func NewBookingRepositoryDB(db *db_manager.DB) *BookingRepositoryDB {
+	return &BookingRepositoryDB{DB: db}
+}
+
+const bookingColumns = "id, book_id, branch_id, borrower_name, starts_on, ends_on, copies, lent_copies, status, created_at"
+
+func scanBooking(row scanner) (*models.Booking, error) {
+	var booking models.Booking
+	var startsOn, endsOn time.Time
+	err := row.Scan(&booking.Id, &booking.BookId, &booking.BranchId, &booking.BorrowerName, &startsOn, &endsOn,
+		&booking.Copies, &booking.LentCopies, &booking.Status, &booking.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrBookingNotFound
+		}
+		return nil, err
+	}
+	booking.StartsOn = startsOn.Format(models.DateLayout)
+	booking.EndsOn = endsOn.Format(models.DateLayout)
+	return &booking, nil
+}
+
+func (br *BookingRepositoryDB) CreateBooking(ctx context.Context, booking *models.Booking) (*models.Booking, error) {
+	insertQuery := `
+        INSERT INTO bookings (book_id, branch_id, borrower_name, starts_on, ends_on, copies, lent_copies, status, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        RETURNING ` + bookingColumns
+	createdBooking, err := scanBooking(br.DB.CreateRecord(ctx, insertQuery, booking.BookId, booking.BranchId, booking.BorrowerName,
+		booking.StartsOn, booking.EndsOn, booking.Copies, booking.LentCopies, booking.Status, booking.CreatedAt))
+	if err != nil {
+		return nil, fmt.Errorf("error creating booking of book %d: %w", booking.BookId, err)
+	}
+	return createdBooking, nil
+}
+
+func (br *BookingRepositoryDB) GetBookingById(ctx context.Context, id int) (*models.Booking, error) {
+	query := "SELECT " + bookingColumns + " FROM bookings WHERE id = $1"
+	return scanBooking(br.DB.GetRecord(ctx, query, id))
+}
+
+func (br *BookingRepositoryDB) UpdateBooking(ctx context.Context, booking *models.Booking) (*models.Booking, error) {
+	updateQuery := `
+        UPDATE bookings
+        SET lent_copies = $1, status = $2
+        WHERE id = $3
+        RETURNING ` + bookingColumns
+	updatedBooking, err := scanBooking(br.DB.UpdateRecord(ctx, updateQuery, booking.LentCopies, booking.Status, booking.Id))
+	if err != nil && !errors.Is(err, ErrBookingNotFound) {
+		return nil, fmt.Errorf("error updating booking %d: %w", booking.Id, err)
+	}
+	return updatedBooking, err
+}
+
+func (br *BookingRepositoryDB) ListBookings(ctx context.Context, bookId int, branchId int, from string, to string) ([]models.Booking, error) {
+	query := `
+        SELECT ` + bookingColumns + `
+        FROM bookings
+        WHERE book_id = $1 AND ($2 = 0 OR branch_id = $2) AND starts_on <= $4 AND ends_on >= $3
+        ORDER BY starts_on, id
+    `
+	rows, err := br.DB.GetRecords(ctx, query, bookId, branchId, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching bookings of book %d: %w", bookId, err)
+	}
+	defer rows.Close()
+
+	bookings := make([]models.Booking, 0)
+	for rows.Next() {
+		booking, err := scanBooking(rows)
+		if err != nil {
+			return nil, err
+		}
+		bookings = append(bookings, *booking)
+	}
+	return bookings, rows.Err()
+}
@@ -0,0 +1,57 @@
+package repositories
+
+import (
+	"context"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBookingRepository_Bookings(t *testing.T) {
+	repo := NewBookingRepository()
+	ctx := context.Background()
+
+	term, err := repo.CreateBooking(ctx, &models.Booking{BookId: 1, BranchId: 1, BorrowerName: "teacher", StartsOn: "2025-04-01",
+		EndsOn: "2025-04-30", Copies: 3, Status: models.BookingStatusActive})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, term.Id)
+	_, err = repo.CreateBooking(ctx, &models.Booking{BookId: 1, BranchId: 2, BorrowerName: "teacher", StartsOn: "2025-03-10",
+		EndsOn: "2025-04-05", Copies: 1, Status: models.BookingStatusActive})
+	assert.NoError(t, err)
+
+	t.Run("List bookings overlapping the days, by first day", func(t *testing.T) {
+		bookings, err := repo.ListBookings(ctx, 1, 0, "2025-04-05", "2025-04-05")
+		assert.NoError(t, err)
+		assert.Len(t, bookings, 2)
+		assert.Equal(t, "2025-03-10", bookings[0].StartsOn)
+
+		bookings, err = repo.ListBookings(ctx, 1, 1, "2025-03-01", "2025-03-31")
+		assert.NoError(t, err)
+		assert.Empty(t, bookings)
+		bookings, err = repo.ListBookings(ctx, 2, 0, "2025-04-05", "2025-04-05")
+		assert.NoError(t, err)
+		assert.Empty(t, bookings)
+	})
+
+	t.Run("Update lent copies and status", func(t *testing.T) {
+		update := *term
+		update.LentCopies = 3
+		update.Status = models.BookingStatusFulfilled
+		update.Copies = 10
+		updated, err := repo.UpdateBooking(ctx, &update)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, updated.LentCopies)
+		assert.Equal(t, 3, updated.Copies)
+
+		booking, err := repo.GetBookingById(ctx, term.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, models.BookingStatusFulfilled, booking.Status)
+	})
+
+	t.Run("Booking not found", func(t *testing.T) {
+		_, err := repo.GetBookingById(ctx, 3)
+		assert.Equal(t, ErrBookingNotFound, err)
+		_, err = repo.UpdateBooking(ctx, &models.Booking{Id: 3})
+		assert.Equal(t, ErrBookingNotFound, err)
+	})
+}
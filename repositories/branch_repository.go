@@ -0,0 +1,174 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"github.com/aftaab60/e-library-api/models"
+	"sort"
+	"sync"
+)
+
+type IBranchRepository interface {
+	GetBranch(ctx context.Context, id int) (*models.Branch, error)
+	ListBranches(ctx context.Context) ([]models.Branch, error)
+	// CreateBranch adds a branch, it fails with ErrExistingBranch when a branch has the name
+	CreateBranch(ctx context.Context, branch *models.Branch) (*models.Branch, error)
+	// UpdateBranch sets the name and time zone of a branch
+	UpdateBranch(ctx context.Context, branch *models.Branch) (*models.Branch, error)
+	GetStock(ctx context.Context, bookId int) ([]models.BranchStock, error)
+	GetBranchStock(ctx context.Context, bookId int, branchId int) (*models.BranchStock, error)
+	UpdateBranchStock(ctx context.Context, stock *models.BranchStock) (*models.BranchStock, error)
+}
+
+type stockKey struct {
+	bookId   int
+	branchId int
+}
+
+type BranchRepository struct {
+	branches map[int]*models.Branch
+	stock    map[stockKey]*models.BranchStock
+	mutex    sync.RWMutex
+}
+
+func NewBranchRepository() *BranchRepository {
+	repo := &BranchRepository{
+		branches: make(map[int]*models.Branch),
+		stock:    make(map[stockKey]*models.BranchStock),
+	}
+	repo.initBranchRepository()
+	return repo
+}
+
+// initialise some branches by default at launch, all copies of default books are held by main branch
+func (br *BranchRepository) initBranchRepository() {
+	br.mutex.Lock()
+	defer br.mutex.Unlock()
+
+	branches := []models.Branch{
+		{Id: models.DefaultBranchId, Name: "main", TimeZone: "UTC"},
+		{Id: 2, Name: "east", TimeZone: "UTC"},
+	}
+	for _, branch := range branches {
+		br.branches[branch.Id] = &branch
+	}
+
+	stock := []models.BranchStock{
+		{BookId: 1, BranchId: models.DefaultBranchId, AvailableCopies: 5},
+		{BookId: 2, BranchId: models.DefaultBranchId, AvailableCopies: 3},
+		{BookId: 3, BranchId: models.DefaultBranchId, AvailableCopies: 1},
+		{BookId: 4, BranchId: models.DefaultBranchId, AvailableCopies: 0},
+	}
+	for _, s := range stock {
+		br.stock[stockKey{s.BookId, s.BranchId}] = &s
+	}
+}
+
+// ErrBranchNotFound is returned when a branch is not found
+var ErrBranchNotFound = errors.New("branch not found")
+
+// ErrExistingBranch is returned when a branch already has the name
+var ErrExistingBranch = errors.New("existing branch")
+
+func (br *BranchRepository) GetBranch(ctx context.Context, id int) (*models.Branch, error) {
+	br.mutex.RLock()
+	defer br.mutex.RUnlock()
+
+	branch, ok := br.branches[id]
+	if !ok {
+		return nil, ErrBranchNotFound
+	}
+	branchCopy := *branch
+	return &branchCopy, nil
+}
+
+func (br *BranchRepository) ListBranches(ctx context.Context) ([]models.Branch, error) {
+	br.mutex.RLock()
+	defer br.mutex.RUnlock()
+
+	branches := make([]models.Branch, 0, len(br.branches))
+	for _, branch := range br.branches {
+		branches = append(branches, *branch)
+	}
+	sort.Slice(branches, func(i, j int) bool { return branches[i].Id < branches[j].Id })
+	return branches, nil
+}
+
+func (br *BranchRepository) CreateBranch(ctx context.Context, branch *models.Branch) (*models.Branch, error) {
+	br.mutex.Lock()
+	defer br.mutex.Unlock()
+
+	maxId := 0
+	for _, b := range br.branches {
+		if b.Name == branch.Name {
+			return nil, ErrExistingBranch
+		}
+		maxId = max(maxId, b.Id)
+	}
+	createdBranch := *branch
+	createdBranch.Id = maxId + 1 //incremental id
+	br.branches[createdBranch.Id] = &createdBranch
+	result := createdBranch
+	return &result, nil
+}
+
+func (br *BranchRepository) UpdateBranch(ctx context.Context, branch *models.Branch) (*models.Branch, error) {
+	br.mutex.Lock()
+	defer br.mutex.Unlock()
+
+	stored, ok := br.branches[branch.Id]
+	if !ok {
+		return nil, ErrBranchNotFound
+	}
+	for _, b := range br.branches {
+		if b.Id != branch.Id && b.Name == branch.Name {
+			return nil, ErrExistingBranch
+		}
+	}
+	stored.Name, stored.TimeZone = branch.Name, branch.TimeZone
+	updatedBranch := *stored
+	return &updatedBranch, nil
+}
+
+func (br *BranchRepository) GetStock(ctx context.Context, bookId int) ([]models.BranchStock, error) {
+	br.mutex.RLock()
+	defer br.mutex.RUnlock()
+
+	stock := make([]models.BranchStock, 0)
+	for key, s := range br.stock {
+		if key.bookId == bookId {
+			stock = append(stock, *s)
+		}
+	}
+	sort.Slice(stock, func(i, j int) bool { return stock[i].BranchId < stock[j].BranchId })
+	return stock, nil
+}
+
+// GetBranchStock returns an empty stock if the branch never held the book
+func (br *BranchRepository) GetBranchStock(ctx context.Context, bookId int, branchId int) (*models.BranchStock, error) {
+	br.mutex.RLock()
+	defer br.mutex.RUnlock()
+
+	if _, ok := br.branches[branchId]; !ok {
+		return nil, ErrBranchNotFound
+	}
+	s, ok := br.stock[stockKey{bookId, branchId}]
+	if !ok {
+		return &models.BranchStock{BookId: bookId, BranchId: branchId}, nil
+	}
+	stock := *s
+	return &stock, nil
+}
+
+// UpdateBranchStock creates or replaces the stock of a book at a branch
+func (br *BranchRepository) UpdateBranchStock(ctx context.Context, stock *models.BranchStock) (*models.BranchStock, error) {
+	br.mutex.Lock()
+	defer br.mutex.Unlock()
+
+	if _, ok := br.branches[stock.BranchId]; !ok {
+		return nil, ErrBranchNotFound
+	}
+	updatedStock := *stock
+	br.stock[stockKey{stock.BookId, stock.BranchId}] = &updatedStock
+	return stock, nil
+}
@@ -0,0 +1,146 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/aftaab60/e-library-api/internal/db_manager"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/lib/pq"
+)
+
+type BranchRepositoryDB struct {
+	DB *db_manager.DB
+}
+
+func NewBranchRepositoryDB(db *db_manager.DB) *BranchRepositoryDB {
+	return &BranchRepositoryDB{DB: db}
+}
+
+func (br *BranchRepositoryDB) GetBranch(ctx context.Context, id int) (*models.Branch, error) {
+	query := "SELECT id, name, time_zone FROM branches WHERE id = $1"
+	row := br.DB.GetRecord(ctx, query, id)
+
+	var branch models.Branch
+	if err := row.Scan(&branch.Id, &branch.Name, &branch.TimeZone); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrBranchNotFound
+		}
+		return nil, err
+	}
+	return &branch, nil
+}
+
+func (br *BranchRepositoryDB) ListBranches(ctx context.Context) ([]models.Branch, error) {
+	query := "SELECT id, name, time_zone FROM branches ORDER BY id"
+	rows, err := br.DB.GetRecords(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching branches: %w", err)
+	}
+	defer rows.Close()
+
+	branches := make([]models.Branch, 0)
+	for rows.Next() {
+		var branch models.Branch
+		if err := rows.Scan(&branch.Id, &branch.Name, &branch.TimeZone); err != nil {
+			return nil, err
+		}
+		branches = append(branches, branch)
+	}
+	return branches, rows.Err()
+}
+
+func (br *BranchRepositoryDB) CreateBranch(ctx context.Context, branch *models.Branch) (*models.Branch, error) {
+	insertQuery := "INSERT INTO branches (name, time_zone) VALUES ($1, $2) RETURNING id, name, time_zone"
+	var createdBranch models.Branch
+	err := br.DB.CreateRecord(ctx, insertQuery, branch.Name, branch.TimeZone).Scan(&createdBranch.Id, &createdBranch.Name, &createdBranch.TimeZone)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" { //unique_violation
+			return nil, ErrExistingBranch
+		}
+		return nil, fmt.Errorf("error creating branch %s: %w", branch.Name, err)
+	}
+	return &createdBranch, nil
+}
+
+func (br *BranchRepositoryDB) UpdateBranch(ctx context.Context, branch *models.Branch) (*models.Branch, error) {
+	updateQuery := "UPDATE branches SET name = $1, time_zone = $2 WHERE id = $3 RETURNING id, name, time_zone"
+	var updatedBranch models.Branch
+	err := br.DB.UpdateRecord(ctx, updateQuery, branch.Name, branch.TimeZone, branch.Id).Scan(&updatedBranch.Id, &updatedBranch.Name, &updatedBranch.TimeZone)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrBranchNotFound
+		}
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" { //unique_violation
+			return nil, ErrExistingBranch
+		}
+		return nil, fmt.Errorf("error updating branch %d: %w", branch.Id, err)
+	}
+	return &updatedBranch, nil
+}
+
+func (br *BranchRepositoryDB) GetStock(ctx context.Context, bookId int) ([]models.BranchStock, error) {
+	query := `
+        SELECT book_id, branch_id, available_copies, in_transit_copies
+        FROM branch_stock
+        WHERE book_id = $1
+        ORDER BY branch_id
+    `
+	rows, err := br.DB.GetRecords(ctx, query, bookId)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching stock for book %d: %w", bookId, err)
+	}
+	defer rows.Close()
+
+	stock := make([]models.BranchStock, 0)
+	for rows.Next() {
+		var s models.BranchStock
+		if err := rows.Scan(&s.BookId, &s.BranchId, &s.AvailableCopies, &s.InTransitCopies); err != nil {
+			return nil, err
+		}
+		stock = append(stock, s)
+	}
+	return stock, rows.Err()
+}
+
+func (br *BranchRepositoryDB) GetBranchStock(ctx context.Context, bookId int, branchId int) (*models.BranchStock, error) {
+	if _, err := br.GetBranch(ctx, branchId); err != nil {
+		return nil, err
+	}
+
+	query := `
+        SELECT book_id, branch_id, available_copies, in_transit_copies
+        FROM branch_stock
+        WHERE book_id = $1 AND branch_id = $2
+    `
+	row := br.DB.GetRecord(ctx, query, bookId, branchId)
+
+	var stock models.BranchStock
+	if err := row.Scan(&stock.BookId, &stock.BranchId, &stock.AvailableCopies, &stock.InTransitCopies); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &models.BranchStock{BookId: bookId, BranchId: branchId}, nil
+		}
+		return nil, err
+	}
+	return &stock, nil
+}
+
+func (br *BranchRepositoryDB) UpdateBranchStock(ctx context.Context, stock *models.BranchStock) (*models.BranchStock, error) {
+	upsertQuery := `
+        INSERT INTO branch_stock (book_id, branch_id, available_copies, in_transit_copies)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (book_id, branch_id) DO UPDATE
+        SET available_copies = EXCLUDED.available_copies, in_transit_copies = EXCLUDED.in_transit_copies
+        RETURNING book_id, branch_id, available_copies, in_transit_copies
+    `
+	row := br.DB.UpdateRecord(ctx, upsertQuery, stock.BookId, stock.BranchId, stock.AvailableCopies, stock.InTransitCopies)
+
+	var updatedStock models.BranchStock
+	if err := row.Scan(&updatedStock.BookId, &updatedStock.BranchId, &updatedStock.AvailableCopies, &updatedStock.InTransitCopies); err != nil {
+		return nil, fmt.Errorf("error updating stock of book %d at branch %d: %w", stock.BookId, stock.BranchId, err)
+	}
+	return &updatedStock, nil
+}
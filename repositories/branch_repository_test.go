@@ -0,0 +1,70 @@
+package repositories
+
+import (
+	"context"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBranchRepository_GetBranchStock(t *testing.T) {
+	repo := NewBranchRepository()
+	ctx := context.Background()
+
+	t.Run("Get seeded stock", func(t *testing.T) {
+		stock, err := repo.GetBranchStock(ctx, 1, models.DefaultBranchId)
+		assert.NoError(t, err)
+		assert.Equal(t, 5, stock.AvailableCopies)
+	})
+
+	t.Run("Get empty stock of a branch without copies", func(t *testing.T) {
+		stock, err := repo.GetBranchStock(ctx, 1, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, stock.AvailableCopies)
+	})
+
+	t.Run("Get stock of non-existent branch", func(t *testing.T) {
+		_, err := repo.GetBranchStock(ctx, 1, 100)
+		assert.Equal(t, ErrBranchNotFound, err)
+	})
+}
+
+func TestBranchRepository_UpdateBranchStock(t *testing.T) {
+	repo := NewBranchRepository()
+	ctx := context.Background()
+
+	t.Run("Create stock at a new branch", func(t *testing.T) {
+		_, err := repo.UpdateBranchStock(ctx, &models.BranchStock{BookId: 1, BranchId: 2, AvailableCopies: 2, InTransitCopies: 1})
+		assert.NoError(t, err)
+
+		stock, err := repo.GetStock(ctx, 1)
+		assert.NoError(t, err)
+		assert.Len(t, stock, 2)
+		assert.Equal(t, 1, stock[1].InTransitCopies)
+	})
+
+	t.Run("Fail to update stock of non-existent branch", func(t *testing.T) {
+		_, err := repo.UpdateBranchStock(ctx, &models.BranchStock{BookId: 1, BranchId: 100})
+		assert.Equal(t, ErrBranchNotFound, err)
+	})
+}
+
+func TestBranchRepository_GetBranch(t *testing.T) {
+	repo := NewBranchRepository()
+	ctx := context.Background()
+
+	t.Run("Changing a branch got doesn't change the stored branch", func(t *testing.T) {
+		branch, err := repo.GetBranch(ctx, models.DefaultBranchId)
+		assert.NoError(t, err)
+		branch.TimeZone = "Asia/Tokyo"
+
+		stored, err := repo.GetBranch(ctx, models.DefaultBranchId)
+		assert.NoError(t, err)
+		assert.Equal(t, "UTC", stored.TimeZone)
+	})
+
+	t.Run("Get non-existent branch", func(t *testing.T) {
+		_, err := repo.GetBranch(ctx, 100)
+		assert.Equal(t, ErrBranchNotFound, err)
+	})
+}
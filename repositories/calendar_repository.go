@@ -0,0 +1,124 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"github.com/aftaab60/e-library-api/models"
+	"sort"
+	"sync"
+)
+
+// ICalendarRepository stores the opening hours and closures of branches
+type ICalendarRepository interface {
+	// ListOpeningHours returns the opening hours of a branch, Sunday first
+	ListOpeningHours(ctx context.Context, branchId int) ([]models.OpeningHours, error)
+	// ReplaceOpeningHours replaces every opening hours of a branch
+	ReplaceOpeningHours(ctx context.Context, branchId int, hours []models.OpeningHours) error
+	// CreateClosure adds a closure, it fails with ErrExistingClosure when the branch already closes on the date
+	CreateClosure(ctx context.Context, closure *models.Closure) (*models.Closure, error)
+	GetClosureById(ctx context.Context, id int) (*models.Closure, error)
+	DeleteClosure(ctx context.Context, id int) error
+	// ListClosures returns the closures of a branch along with the closures of every branch, by date
+	ListClosures(ctx context.Context, branchId int) ([]models.Closure, error)
+}
+
+type CalendarRepository struct {
+	hours    map[int][]models.OpeningHours
+	closures []models.Closure
+	nextId   int
+	mutex    sync.RWMutex
+}
+
+func NewCalendarRepository() *CalendarRepository {
+	return &CalendarRepository{
+		hours:    make(map[int][]models.OpeningHours),
+		closures: make([]models.Closure, 0),
+	}
+}
+
+var (
+	ErrClosureNotFound = errors.New("closure not found")
+	// ErrExistingClosure is returned when a branch already closes on a date
+	ErrExistingClosure = errors.New("existing closure")
+)
+
+func (cr *CalendarRepository) ListOpeningHours(ctx context.Context, branchId int) ([]models.OpeningHours, error) {
+	cr.mutex.RLock()
+	defer cr.mutex.RUnlock()
+
+	hours := make([]models.OpeningHours, 0, len(cr.hours[branchId]))
+	for _, weekday := range models.Weekdays {
+		for _, h := range cr.hours[branchId] {
+			if h.Weekday == weekday {
+				hours = append(hours, h)
+			}
+		}
+	}
+	return hours, nil
+}
+
+func (cr *CalendarRepository) ReplaceOpeningHours(ctx context.Context, branchId int, hours []models.OpeningHours) error {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+
+	cr.hours[branchId] = append([]models.OpeningHours(nil), hours...)
+	return nil
+}
+
+func (cr *CalendarRepository) CreateClosure(ctx context.Context, closure *models.Closure) (*models.Closure, error) {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+
+	for _, c := range cr.closures {
+		if c.BranchId == closure.BranchId && c.Date == closure.Date {
+			return nil, ErrExistingClosure
+		}
+	}
+	cr.nextId++
+	createdClosure := *closure
+	createdClosure.Id = cr.nextId
+	cr.closures = append(cr.closures, createdClosure)
+	return &createdClosure, nil
+}
+
+func (cr *CalendarRepository) GetClosureById(ctx context.Context, id int) (*models.Closure, error) {
+	cr.mutex.RLock()
+	defer cr.mutex.RUnlock()
+
+	for _, c := range cr.closures {
+		if c.Id == id {
+			closure := c
+			return &closure, nil
+		}
+	}
+	return nil, ErrClosureNotFound
+}
+
+func (cr *CalendarRepository) DeleteClosure(ctx context.Context, id int) error {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+
+	for i, c := range cr.closures {
+		if c.Id == id {
+			cr.closures = append(cr.closures[:i], cr.closures[i+1:]...)
+			return nil
+		}
+	}
+	return ErrClosureNotFound
+}
+
+func (cr *CalendarRepository) ListClosures(ctx context.Context, branchId int) ([]models.Closure, error) {
+	cr.mutex.RLock()
+	defer cr.mutex.RUnlock()
+
+	closures := make([]models.Closure, 0)
+	for _, c := range cr.closures {
+		if c.BranchId == branchId || c.BranchId == 0 {
+			closures = append(closures, c)
+		}
+	}
+	sort.SliceStable(closures, func(i, j int) bool {
+		return closures[i].Date < closures[j].Date
+	})
+	return closures, nil
+}
@@ -0,0 +1,143 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/aftaab60/e-library-api/internal/db_manager"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/lib/pq"
+	"time"
+)
+
+type CalendarRepositoryDB struct {
+	DB *db_manager.DB
+}
+
+func NewCalendarRepositoryDB(db *db_manager.DB) *CalendarRepositoryDB {
+	return &CalendarRepositoryDB{DB: db}
+}
+
+func (cr *CalendarRepositoryDB) ListOpeningHours(ctx context.Context, branchId int) ([]models.OpeningHours, error) {
+	query := `
+        SELECT weekday, to_char(opens, 'HH24:MI'), to_char(closes, 'HH24:MI')
+        FROM opening_hours
+        WHERE branch_id = $1
+        ORDER BY weekday
+    `
+	rows, err := cr.DB.GetRecords(ctx, query, branchId)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching opening hours of branch %d: %w", branchId, err)
+	}
+	defer rows.Close()
+
+	hours := make([]models.OpeningHours, 0)
+	for rows.Next() {
+		var weekday int
+		var h models.OpeningHours
+		if err := rows.Scan(&weekday, &h.Opens, &h.Closes); err != nil {
+			return nil, err
+		}
+		h.Weekday = models.Weekdays[weekday]
+		hours = append(hours, h)
+	}
+	return hours, rows.Err()
+}
+
+// ReplaceOpeningHours should run in a transaction, so that the branch is never seen without its hours
+func (cr *CalendarRepositoryDB) ReplaceOpeningHours(ctx context.Context, branchId int, hours []models.OpeningHours) error {
+	if _, err := cr.DB.DeleteRecord(ctx, "DELETE FROM opening_hours WHERE branch_id = $1", branchId); err != nil {
+		return fmt.Errorf("error deleting opening hours of branch %d: %w", branchId, err)
+	}
+	for _, h := range hours {
+		weekday := 0
+		for i, name := range models.Weekdays {
+			if name == h.Weekday {
+				weekday = i
+			}
+		}
+		insertQuery := "INSERT INTO opening_hours (branch_id, weekday, opens, closes) VALUES ($1, $2, $3, $4)"
+		if _, err := cr.DB.UpdateRecords(ctx, insertQuery, branchId, weekday, h.Opens, h.Closes); err != nil {
+			return fmt.Errorf("error creating opening hours of branch %d: %w", branchId, err)
+		}
+	}
+	return nil
+}
+
+func scanClosure(row scanner) (*models.Closure, error) {
+	var closure models.Closure
+	var branchId sql.NullInt64
+	var date time.Time
+	if err := row.Scan(&closure.Id, &branchId, &date, &closure.Name, &closure.Recurring); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrClosureNotFound
+		}
+		return nil, err
+	}
+	closure.BranchId = int(branchId.Int64)
+	closure.Date = date.Format(models.DateLayout)
+	return &closure, nil
+}
+
+func (cr *CalendarRepositoryDB) CreateClosure(ctx context.Context, closure *models.Closure) (*models.Closure, error) {
+	//closures of every branch have no branch
+	var branchId sql.NullInt64
+	if closure.BranchId != 0 {
+		branchId = sql.NullInt64{Int64: int64(closure.BranchId), Valid: true}
+	}
+	insertQuery := `
+        INSERT INTO closures (branch_id, date, name, recurring)
+        VALUES ($1, $2, $3, $4)
+        RETURNING id, branch_id, date, name, recurring
+    `
+	createdClosure, err := scanClosure(cr.DB.CreateRecord(ctx, insertQuery, branchId, closure.Date, closure.Name, closure.Recurring))
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" { //unique_violation
+			return nil, ErrExistingClosure
+		}
+		return nil, fmt.Errorf("error creating closure on %s: %w", closure.Date, err)
+	}
+	return createdClosure, nil
+}
+
+func (cr *CalendarRepositoryDB) GetClosureById(ctx context.Context, id int) (*models.Closure, error) {
+	query := "SELECT id, branch_id, date, name, recurring FROM closures WHERE id = $1"
+	return scanClosure(cr.DB.GetRecord(ctx, query, id))
+}
+
+func (cr *CalendarRepositoryDB) DeleteClosure(ctx context.Context, id int) error {
+	result, err := cr.DB.DeleteRecord(ctx, "DELETE FROM closures WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("error deleting closure %d: %w", id, err)
+	}
+	if deleted, err := result.RowsAffected(); err == nil && deleted == 0 {
+		return ErrClosureNotFound
+	}
+	return nil
+}
+
+func (cr *CalendarRepositoryDB) ListClosures(ctx context.Context, branchId int) ([]models.Closure, error) {
+	query := `
+        SELECT id, branch_id, date, name, recurring
+        FROM closures
+        WHERE branch_id = $1 OR branch_id IS NULL
+        ORDER BY date, id
+    `
+	rows, err := cr.DB.GetRecords(ctx, query, branchId)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching closures of branch %d: %w", branchId, err)
+	}
+	defer rows.Close()
+
+	closures := make([]models.Closure, 0)
+	for rows.Next() {
+		closure, err := scanClosure(rows)
+		if err != nil {
+			return nil, err
+		}
+		closures = append(closures, *closure)
+	}
+	return closures, rows.Err()
+}
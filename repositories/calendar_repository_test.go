@@ -0,0 +1,60 @@
+package repositories
+
+import (
+	"context"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCalendarRepository_Closures(t *testing.T) {
+	repo := NewCalendarRepository()
+	ctx := context.Background()
+
+	holiday, err := repo.CreateClosure(ctx, &models.Closure{Date: "2025-12-25", Name: "Christmas Day", Recurring: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, holiday.Id)
+	_, err = repo.CreateClosure(ctx, &models.Closure{BranchId: 2, Date: "2025-03-01", Name: "Stocktake"})
+	assert.NoError(t, err)
+
+	t.Run("One closure of a branch per date", func(t *testing.T) {
+		_, err := repo.CreateClosure(ctx, &models.Closure{Date: "2025-12-25", Name: "Holiday"})
+		assert.Equal(t, ErrExistingClosure, err)
+
+		_, err = repo.CreateClosure(ctx, &models.Closure{BranchId: 1, Date: "2025-12-25", Name: "Holiday"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Closures of every branch apply to each branch", func(t *testing.T) {
+		closures, err := repo.ListClosures(ctx, 2)
+		assert.NoError(t, err)
+		assert.Len(t, closures, 2)
+		assert.Equal(t, "2025-03-01", closures[0].Date)
+		assert.Equal(t, "Christmas Day", closures[1].Name)
+	})
+
+	t.Run("Delete closure", func(t *testing.T) {
+		assert.NoError(t, repo.DeleteClosure(ctx, holiday.Id))
+		assert.Equal(t, ErrClosureNotFound, repo.DeleteClosure(ctx, holiday.Id))
+		_, err := repo.GetClosureById(ctx, holiday.Id)
+		assert.Equal(t, ErrClosureNotFound, err)
+	})
+}
+
+func TestCalendarRepository_ReplaceOpeningHours(t *testing.T) {
+	repo := NewCalendarRepository()
+	ctx := context.Background()
+
+	assert.NoError(t, repo.ReplaceOpeningHours(ctx, 1, []models.OpeningHours{
+		{Weekday: "saturday", Opens: "10:00", Closes: "14:00"},
+		{Weekday: "monday", Opens: "09:00", Closes: "17:00"},
+	}))
+	hours, err := repo.ListOpeningHours(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"monday", "saturday"}, []string{hours[0].Weekday, hours[1].Weekday})
+
+	assert.NoError(t, repo.ReplaceOpeningHours(ctx, 1, nil))
+	hours, err = repo.ListOpeningHours(ctx, 1)
+	assert.NoError(t, err)
+	assert.Empty(t, hours)
+}
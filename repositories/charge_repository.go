@@ -0,0 +1,81 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"github.com/aftaab60/e-library-api/models"
+	"sync"
+)
+
+type IChargeRepository interface {
+	CreateCharge(ctx context.Context, charge *models.Charge) (*models.Charge, error)
+	GetChargesByLoan(ctx context.Context, loanId int) ([]models.Charge, error)
+	UpdateChargeStatus(ctx context.Context, id int, status models.ChargeStatus) (*models.Charge, error)
+	// GetOutstandingAmount sums the outstanding charges of a borrower
+	GetOutstandingAmount(ctx context.Context, borrowerName string) (int, error)
+}
+
+type ChargeRepository struct {
+	charges []models.Charge
+	mutex   sync.RWMutex
+}
+
+func NewChargeRepository() *ChargeRepository {
+	return &ChargeRepository{
+		charges: make([]models.Charge, 0),
+	}
+}
+
+// ErrChargeNotFound is returned when a charge is not found
+var ErrChargeNotFound = errors.New("charge not found")
+
+func (cr *ChargeRepository) CreateCharge(ctx context.Context, charge *models.Charge) (*models.Charge, error) {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+
+	charge.Id = len(cr.charges) + 1 //incremental id
+	cr.charges = append(cr.charges, *charge)
+
+	createdCharge := cr.charges[len(cr.charges)-1]
+	return &createdCharge, nil
+}
+
+func (cr *ChargeRepository) GetChargesByLoan(ctx context.Context, loanId int) ([]models.Charge, error) {
+	cr.mutex.RLock()
+	defer cr.mutex.RUnlock()
+
+	charges := make([]models.Charge, 0)
+	for _, charge := range cr.charges {
+		if charge.LoanId == loanId {
+			charges = append(charges, charge)
+		}
+	}
+	return charges, nil
+}
+
+func (cr *ChargeRepository) UpdateChargeStatus(ctx context.Context, id int, status models.ChargeStatus) (*models.Charge, error) {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+
+	for i := range cr.charges {
+		if cr.charges[i].Id == id {
+			cr.charges[i].Status = status
+			updatedCharge := cr.charges[i]
+			return &updatedCharge, nil
+		}
+	}
+	return nil, ErrChargeNotFound
+}
+
+func (cr *ChargeRepository) GetOutstandingAmount(ctx context.Context, borrowerName string) (int, error) {
+	cr.mutex.RLock()
+	defer cr.mutex.RUnlock()
+
+	amount := 0
+	for _, charge := range cr.charges {
+		if charge.BorrowerName == borrowerName && charge.Status == models.ChargeStatusOutstanding {
+			amount += charge.Amount
+		}
+	}
+	return amount, nil
+}
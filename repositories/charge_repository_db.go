@@ -0,0 +1,87 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/aftaab60/e-library-api/internal/db_manager"
+	"github.com/aftaab60/e-library-api/models"
+)
+
+type ChargeRepositoryDB struct {
+	DB *db_manager.DB
+}
+
+func NewChargeRepositoryDB(db *db_manager.DB) *ChargeRepositoryDB {
+	return &ChargeRepositoryDB{DB: db}
+}
+
+func (cr *ChargeRepositoryDB) CreateCharge(ctx context.Context, charge *models.Charge) (*models.Charge, error) {
+	insertQuery := `
+        INSERT INTO charges (loan_id, borrower_name, type, amount, status, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING id, loan_id, borrower_name, type, amount, status, created_at
+    `
+	row := cr.DB.CreateRecord(ctx, insertQuery, charge.LoanId, charge.BorrowerName, charge.Type, charge.Amount, charge.Status, charge.CreatedAt)
+
+	var createdCharge models.Charge
+	err := row.Scan(&createdCharge.Id, &createdCharge.LoanId, &createdCharge.BorrowerName, &createdCharge.Type, &createdCharge.Amount, &createdCharge.Status, &createdCharge.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("error creating charge for loan %d: %w", charge.LoanId, err)
+	}
+	return &createdCharge, nil
+}
+
+func (cr *ChargeRepositoryDB) GetChargesByLoan(ctx context.Context, loanId int) ([]models.Charge, error) {
+	query := `
+        SELECT id, loan_id, borrower_name, type, amount, status, created_at
+        FROM charges
+        WHERE loan_id = $1
+        ORDER BY id
+    `
+	rows, err := cr.DB.GetRecords(ctx, query, loanId)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching charges for loan %d: %w", loanId, err)
+	}
+	defer rows.Close()
+
+	charges := make([]models.Charge, 0)
+	for rows.Next() {
+		var charge models.Charge
+		if err := rows.Scan(&charge.Id, &charge.LoanId, &charge.BorrowerName, &charge.Type, &charge.Amount, &charge.Status, &charge.CreatedAt); err != nil {
+			return nil, err
+		}
+		charges = append(charges, charge)
+	}
+	return charges, rows.Err()
+}
+
+func (cr *ChargeRepositoryDB) UpdateChargeStatus(ctx context.Context, id int, status models.ChargeStatus) (*models.Charge, error) {
+	updateQuery := `
+        UPDATE charges
+        SET status = $1
+        WHERE id = $2
+        RETURNING id, loan_id, borrower_name, type, amount, status, created_at
+    `
+	row := cr.DB.UpdateRecord(ctx, updateQuery, status, id)
+
+	var updatedCharge models.Charge
+	err := row.Scan(&updatedCharge.Id, &updatedCharge.LoanId, &updatedCharge.BorrowerName, &updatedCharge.Type, &updatedCharge.Amount, &updatedCharge.Status, &updatedCharge.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrChargeNotFound
+		}
+		return nil, fmt.Errorf("error updating charge %d: %w", id, err)
+	}
+	return &updatedCharge, nil
+}
+
+func (cr *ChargeRepositoryDB) GetOutstandingAmount(ctx context.Context, borrowerName string) (int, error) {
+	query := "SELECT COALESCE(SUM(amount), 0) FROM charges WHERE borrower_name = $1 AND status = $2"
+	var amount int
+	if err := cr.DB.GetRecord(ctx, query, borrowerName, models.ChargeStatusOutstanding).Scan(&amount); err != nil {
+		return 0, fmt.Errorf("error summing outstanding charges of %s: %w", borrowerName, err)
+	}
+	return amount, nil
+}
@@ -0,0 +1,69 @@
+package repositories
+
+import (
+	"context"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestChargeRepository_CreateCharge(t *testing.T) {
+	repo := NewChargeRepository()
+	ctx := context.Background()
+
+	t.Run("Create new charge", func(t *testing.T) {
+		charge, err := repo.CreateCharge(ctx, &models.Charge{
+			LoanId:       1,
+			BorrowerName: "user1",
+			Type:         models.ChargeTypeReplacement,
+			Amount:       2500,
+			Status:       models.ChargeStatusOutstanding,
+			CreatedAt:    time.Now(),
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, charge.Id)
+
+		charges, err := repo.GetChargesByLoan(ctx, 1)
+		assert.NoError(t, err)
+		assert.Len(t, charges, 1)
+	})
+}
+
+func TestChargeRepository_UpdateChargeStatus(t *testing.T) {
+	repo := NewChargeRepository()
+	ctx := context.Background()
+
+	charge, err := repo.CreateCharge(ctx, &models.Charge{LoanId: 2, Type: models.ChargeTypeDamage, Status: models.ChargeStatusOutstanding})
+	assert.NoError(t, err)
+
+	t.Run("Update existing charge", func(t *testing.T) {
+		updatedCharge, err := repo.UpdateChargeStatus(ctx, charge.Id, models.ChargeStatusPaid)
+		assert.NoError(t, err)
+		assert.Equal(t, models.ChargeStatusPaid, updatedCharge.Status)
+	})
+
+	t.Run("Fail to update non-existent charge", func(t *testing.T) {
+		_, err := repo.UpdateChargeStatus(ctx, 100, models.ChargeStatusPaid)
+		assert.Equal(t, ErrChargeNotFound, err)
+	})
+}
+
+func TestChargeRepository_GetOutstandingAmount(t *testing.T) {
+	repo := NewChargeRepository()
+	ctx := context.Background()
+
+	for _, charge := range []models.Charge{
+		{LoanId: 1, BorrowerName: "user1", Type: models.ChargeTypeOverdue, Amount: 250, Status: models.ChargeStatusOutstanding},
+		{LoanId: 2, BorrowerName: "user1", Type: models.ChargeTypeDamage, Amount: 1500, Status: models.ChargeStatusOutstanding},
+		{LoanId: 3, BorrowerName: "user1", Type: models.ChargeTypeReplacement, Amount: 2500, Status: models.ChargeStatusPaid},
+		{LoanId: 4, BorrowerName: "user2", Type: models.ChargeTypeOverdue, Amount: 100, Status: models.ChargeStatusOutstanding},
+	} {
+		_, err := repo.CreateCharge(ctx, &charge)
+		assert.NoError(t, err)
+	}
+
+	amount, err := repo.GetOutstandingAmount(ctx, "user1")
+	assert.NoError(t, err)
+	assert.Equal(t, 1750, amount)
+}
@@ -0,0 +1,186 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"github.com/aftaab60/e-library-api/models"
+	"sort"
+	"sync"
+)
+
+// ICourseRepository stores terms, the courses taught in them and the books on reserve for the courses
+type ICourseRepository interface {
+	CreateTerm(ctx context.Context, term *models.Term) (*models.Term, error)
+	GetTermById(ctx context.Context, id int) (*models.Term, error)
+	// ListTerms returns every term, by first day
+	ListTerms(ctx context.Context) ([]models.Term, error)
+	// CreateCourse adds a course, it fails with ErrExistingCourse when the term already has a course of the code
+	CreateCourse(ctx context.Context, course *models.Course) (*models.Course, error)
+	GetCourseById(ctx context.Context, id int) (*models.Course, error)
+	// ListCourses returns the courses of a term, or of every term when termId is 0, by code
+	ListCourses(ctx context.Context, termId int) ([]models.Course, error)
+	// CreateReserve adds a reserve, it fails with ErrExistingReserve when the book is already on an active reserve
+	CreateReserve(ctx context.Context, reserve *models.Reserve) (*models.Reserve, error)
+	GetReserveById(ctx context.Context, id int) (*models.Reserve, error)
+	// UpdateReserve sets the status of a reserve and when it ended
+	UpdateReserve(ctx context.Context, reserve *models.Reserve) (*models.Reserve, error)
+	// ListReserves returns the reserves of a course, or the active reserves of every course when courseId is 0, by id
+	ListReserves(ctx context.Context, courseId int) ([]models.Reserve, error)
+}
+
+type CourseRepository struct {
+	terms    []models.Term
+	courses  []models.Course
+	reserves []models.Reserve
+	mutex    sync.RWMutex
+}
+
+func NewCourseRepository() *CourseRepository {
+	return &CourseRepository{
+		terms:    make([]models.Term, 0),
+		courses:  make([]models.Course, 0),
+		reserves: make([]models.Reserve, 0),
+	}
+}
+
+var (
+	ErrTermNotFound    = errors.New("term not found")
+	ErrCourseNotFound  = errors.New("course not found")
+	ErrReserveNotFound = errors.New("reserve not found")
+	// ErrExistingCourse is returned when a term already has a course of the code
+	ErrExistingCourse = errors.New("existing course")
+	// ErrExistingReserve is returned when a book is already on an active reserve
+	ErrExistingReserve = errors.New("existing reserve")
+)
+
+func (cr *CourseRepository) CreateTerm(ctx context.Context, term *models.Term) (*models.Term, error) {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+
+	createdTerm := *term
+	createdTerm.Id = len(cr.terms) + 1 //incremental id
+	cr.terms = append(cr.terms, createdTerm)
+	return &createdTerm, nil
+}
+
+func (cr *CourseRepository) GetTermById(ctx context.Context, id int) (*models.Term, error) {
+	cr.mutex.RLock()
+	defer cr.mutex.RUnlock()
+
+	for _, term := range cr.terms {
+		if term.Id == id {
+			return &term, nil
+		}
+	}
+	return nil, ErrTermNotFound
+}
+
+func (cr *CourseRepository) ListTerms(ctx context.Context) ([]models.Term, error) {
+	cr.mutex.RLock()
+	defer cr.mutex.RUnlock()
+
+	terms := append(make([]models.Term, 0, len(cr.terms)), cr.terms...)
+	sort.SliceStable(terms, func(i, j int) bool {
+		return terms[i].StartsOn < terms[j].StartsOn
+	})
+	return terms, nil
+}
+
+func (cr *CourseRepository) CreateCourse(ctx context.Context, course *models.Course) (*models.Course, error) {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+
+	for _, c := range cr.courses {
+		if c.TermId == course.TermId && c.Code == course.Code {
+			return nil, ErrExistingCourse
+		}
+	}
+	createdCourse := *course
+	createdCourse.Id = len(cr.courses) + 1 //incremental id
+	createdCourse.Instructors = append(make([]string, 0, len(course.Instructors)), course.Instructors...)
+	cr.courses = append(cr.courses, createdCourse)
+	return &createdCourse, nil
+}
+
+func (cr *CourseRepository) GetCourseById(ctx context.Context, id int) (*models.Course, error) {
+	cr.mutex.RLock()
+	defer cr.mutex.RUnlock()
+
+	for _, course := range cr.courses {
+		if course.Id == id {
+			return &course, nil
+		}
+	}
+	return nil, ErrCourseNotFound
+}
+
+func (cr *CourseRepository) ListCourses(ctx context.Context, termId int) ([]models.Course, error) {
+	cr.mutex.RLock()
+	defer cr.mutex.RUnlock()
+
+	courses := make([]models.Course, 0)
+	for _, course := range cr.courses {
+		if termId == 0 || course.TermId == termId {
+			courses = append(courses, course)
+		}
+	}
+	sort.SliceStable(courses, func(i, j int) bool {
+		return courses[i].Code < courses[j].Code
+	})
+	return courses, nil
+}
+
+func (cr *CourseRepository) CreateReserve(ctx context.Context, reserve *models.Reserve) (*models.Reserve, error) {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+
+	for _, r := range cr.reserves {
+		if r.BookId == reserve.BookId && r.Status == models.ReserveStatusActive {
+			return nil, ErrExistingReserve
+		}
+	}
+	createdReserve := *reserve
+	createdReserve.Id = len(cr.reserves) + 1 //incremental id
+	cr.reserves = append(cr.reserves, createdReserve)
+	return &createdReserve, nil
+}
+
+func (cr *CourseRepository) GetReserveById(ctx context.Context, id int) (*models.Reserve, error) {
+	cr.mutex.RLock()
+	defer cr.mutex.RUnlock()
+
+	for _, reserve := range cr.reserves {
+		if reserve.Id == id {
+			return &reserve, nil
+		}
+	}
+	return nil, ErrReserveNotFound
+}
+
+func (cr *CourseRepository) UpdateReserve(ctx context.Context, reserve *models.Reserve) (*models.Reserve, error) {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+
+	for i := range cr.reserves {
+		if cr.reserves[i].Id == reserve.Id {
+			cr.reserves[i].Status = reserve.Status
+			cr.reserves[i].EndedAt = reserve.EndedAt
+			updatedReserve := cr.reserves[i]
+			return &updatedReserve, nil
+		}
+	}
+	return nil, ErrReserveNotFound
+}
+
+func (cr *CourseRepository) ListReserves(ctx context.Context, courseId int) ([]models.Reserve, error) {
+	cr.mutex.RLock()
+	defer cr.mutex.RUnlock()
+
+	reserves := make([]models.Reserve, 0)
+	for _, reserve := range cr.reserves {
+		if reserve.CourseId == courseId || (courseId == 0 && reserve.Status == models.ReserveStatusActive) {
+			reserves = append(reserves, reserve)
+		}
+	}
+	return reserves, nil
+}
@@ -0,0 +1,200 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/aftaab60/e-library-api/internal/db_manager"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/lib/pq"
+	"time"
+)
+
+type CourseRepositoryDB struct {
+	DB *db_manager.DB
+}
+
+func NewCourseRepositoryDB(db *db_manager.DB) *CourseRepositoryDB {
+	return &CourseRepositoryDB{DB: db}
+}
+
+func scanTerm(row scanner) (*models.Term, error) {
+	var term models.Term
+	var startsOn, endsOn time.Time
+	if err := row.Scan(&term.Id, &term.Name, &startsOn, &endsOn); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTermNotFound
+		}
+		return nil, err
+	}
+	term.StartsOn = startsOn.Format(models.DateLayout)
+	term.EndsOn = endsOn.Format(models.DateLayout)
+	return &term, nil
+}
+
+func (cr *CourseRepositoryDB) CreateTerm(ctx context.Context, term *models.Term) (*models.Term, error) {
+	insertQuery := "INSERT INTO terms (name, starts_on, ends_on) VALUES ($1, $2, $3) RETURNING id, name, starts_on, ends_on"
+	createdTerm, err := scanTerm(cr.DB.CreateRecord(ctx, insertQuery, term.Name, term.StartsOn, term.EndsOn))
+	if err != nil {
+		return nil, fmt.Errorf("error creating term %s: %w", term.Name, err)
+	}
+	return createdTerm, nil
+}
+
+func (cr *CourseRepositoryDB) GetTermById(ctx context.Context, id int) (*models.Term, error) {
+	query := "SELECT id, name, starts_on, ends_on FROM terms WHERE id = $1"
+	return scanTerm(cr.DB.GetRecord(ctx, query, id))
+}
+
+func (cr *CourseRepositoryDB) ListTerms(ctx context.Context) ([]models.Term, error) {
+	rows, err := cr.DB.GetRecords(ctx, "SELECT id, name, starts_on, ends_on FROM terms ORDER BY starts_on, id")
+	if err != nil {
+		return nil, fmt.Errorf("error fetching terms: %w", err)
+	}
+	defer rows.Close()
+
+	terms := make([]models.Term, 0)
+	for rows.Next() {
+		term, err := scanTerm(rows)
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, *term)
+	}
+	return terms, rows.Err()
+}
+
+const courseColumns = "id, term_id, code, name, instructors"
+
+func scanCourse(row scanner) (*models.Course, error) {
+	var course models.Course
+	var instructors pq.StringArray
+	if err := row.Scan(&course.Id, &course.TermId, &course.Code, &course.Name, &instructors); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrCourseNotFound
+		}
+		return nil, err
+	}
+	course.Instructors = append(make([]string, 0, len(instructors)), instructors...)
+	return &course, nil
+}
+
+func (cr *CourseRepositoryDB) CreateCourse(ctx context.Context, course *models.Course) (*models.Course, error) {
+	insertQuery := "INSERT INTO courses (term_id, code, name, instructors) VALUES ($1, $2, $3, $4) RETURNING " + courseColumns
+	createdCourse, err := scanCourse(cr.DB.CreateRecord(ctx, insertQuery, course.TermId, course.Code, course.Name,
+		pq.StringArray(course.Instructors)))
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" { //unique_violation
+			return nil, ErrExistingCourse
+		}
+		return nil, fmt.Errorf("error creating course %s: %w", course.Code, err)
+	}
+	return createdCourse, nil
+}
+
+func (cr *CourseRepositoryDB) GetCourseById(ctx context.Context, id int) (*models.Course, error) {
+	query := "SELECT " + courseColumns + " FROM courses WHERE id = $1"
+	return scanCourse(cr.DB.GetRecord(ctx, query, id))
+}
+
+func (cr *CourseRepositoryDB) ListCourses(ctx context.Context, termId int) ([]models.Course, error) {
+	query := "SELECT " + courseColumns + " FROM courses WHERE $1 = 0 OR term_id = $1 ORDER BY code, id"
+	rows, err := cr.DB.GetRecords(ctx, query, termId)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching courses of term %d: %w", termId, err)
+	}
+	defer rows.Close()
+
+	courses := make([]models.Course, 0)
+	for rows.Next() {
+		course, err := scanCourse(rows)
+		if err != nil {
+			return nil, err
+		}
+		courses = append(courses, *course)
+	}
+	return courses, rows.Err()
+}
+
+// reserveColumns are selected from course_reserves as r joined with books as b, for the title of the book
+const reserveColumns = "r.id, r.course_id, r.book_id, b.title, r.loan_type, r.loan_hours, r.previous_loan_type, r.previous_loan_hours, r.status, r.created_at, r.ended_at"
+
+func scanReserve(row scanner) (*models.Reserve, error) {
+	var reserve models.Reserve
+	var endedAt sql.NullTime
+	err := row.Scan(&reserve.Id, &reserve.CourseId, &reserve.BookId, &reserve.Title, &reserve.LoanType, &reserve.LoanHours,
+		&reserve.PreviousLoanType, &reserve.PreviousLoanHours, &reserve.Status, &reserve.CreatedAt, &endedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrReserveNotFound
+		}
+		return nil, err
+	}
+	if endedAt.Valid {
+		reserve.EndedAt = &endedAt.Time
+	}
+	return &reserve, nil
+}
+
+func (cr *CourseRepositoryDB) CreateReserve(ctx context.Context, reserve *models.Reserve) (*models.Reserve, error) {
+	insertQuery := `
+        WITH r AS (
+            INSERT INTO course_reserves (course_id, book_id, loan_type, loan_hours, previous_loan_type, previous_loan_hours, status, created_at)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+            RETURNING *
+        )
+        SELECT ` + reserveColumns + ` FROM r JOIN books b ON b.id = r.book_id
+    `
+	createdReserve, err := scanReserve(cr.DB.CreateRecord(ctx, insertQuery, reserve.CourseId, reserve.BookId, reserve.LoanType,
+		reserve.LoanHours, reserve.PreviousLoanType, reserve.PreviousLoanHours, reserve.Status, reserve.CreatedAt))
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" { //unique_violation
+			return nil, ErrExistingReserve
+		}
+		return nil, fmt.Errorf("error creating reserve of book %d: %w", reserve.BookId, err)
+	}
+	return createdReserve, nil
+}
+
+func (cr *CourseRepositoryDB) GetReserveById(ctx context.Context, id int) (*models.Reserve, error) {
+	query := "SELECT " + reserveColumns + " FROM course_reserves r JOIN books b ON b.id = r.book_id WHERE r.id = $1"
+	return scanReserve(cr.DB.GetRecord(ctx, query, id))
+}
+
+func (cr *CourseRepositoryDB) UpdateReserve(ctx context.Context, reserve *models.Reserve) (*models.Reserve, error) {
+	updateQuery := `
+        WITH r AS (
+            UPDATE course_reserves SET status = $1, ended_at = $2 WHERE id = $3
+            RETURNING *
+        )
+        SELECT ` + reserveColumns + ` FROM r JOIN books b ON b.id = r.book_id
+    `
+	return scanReserve(cr.DB.UpdateRecord(ctx, updateQuery, reserve.Status, reserve.EndedAt, reserve.Id))
+}
+
+func (cr *CourseRepositoryDB) ListReserves(ctx context.Context, courseId int) ([]models.Reserve, error) {
+	query := `
+        SELECT ` + reserveColumns + `
+        FROM course_reserves r JOIN books b ON b.id = r.book_id
+        WHERE r.course_id = $1 OR ($1 = 0 AND r.status = 'active')
+        ORDER BY r.id
+    `
+	rows, err := cr.DB.GetRecords(ctx, query, courseId)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching reserves of course %d: %w", courseId, err)
+	}
+	defer rows.Close()
+
+	reserves := make([]models.Reserve, 0)
+	for rows.Next() {
+		reserve, err := scanReserve(rows)
+		if err != nil {
+			return nil, err
+		}
+		reserves = append(reserves, *reserve)
+	}
+	return reserves, rows.Err()
+}
@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"context"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCourseRepository_Courses(t *testing.T) {
+	repo := NewCourseRepository()
+	ctx := context.Background()
+
+	fall, err := repo.CreateTerm(ctx, &models.Term{Name: "Fall 2025", StartsOn: "2025-09-01", EndsOn: "2025-12-19"})
+	assert.NoError(t, err)
+	spring, err := repo.CreateTerm(ctx, &models.Term{Name: "Spring 2025", StartsOn: "2025-01-13", EndsOn: "2025-05-09"})
+	assert.NoError(t, err)
+
+	t.Run("List terms by first day", func(t *testing.T) {
+		terms, err := repo.ListTerms(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, terms, 2)
+		assert.Equal(t, spring.Id, terms[0].Id)
+	})
+
+	t.Run("A code is used once per term", func(t *testing.T) {
+		_, err := repo.CreateCourse(ctx, &models.Course{TermId: spring.Id, Code: "CS201", Name: "Algorithms", Instructors: []string{"ada"}})
+		assert.NoError(t, err)
+		_, err = repo.CreateCourse(ctx, &models.Course{TermId: spring.Id, Code: "CS101", Name: "Programming", Instructors: []string{}})
+		assert.NoError(t, err)
+		_, err = repo.CreateCourse(ctx, &models.Course{TermId: fall.Id, Code: "CS101", Name: "Programming", Instructors: []string{}})
+		assert.NoError(t, err)
+		_, err = repo.CreateCourse(ctx, &models.Course{TermId: spring.Id, Code: "CS101", Name: "Programming", Instructors: []string{}})
+		assert.ErrorIs(t, err, ErrExistingCourse)
+	})
+
+	t.Run("List courses of a term by code", func(t *testing.T) {
+		courses, err := repo.ListCourses(ctx, spring.Id)
+		assert.NoError(t, err)
+		assert.Len(t, courses, 2)
+		assert.Equal(t, "CS101", courses[0].Code)
+
+		courses, err = repo.ListCourses(ctx, 0)
+		assert.NoError(t, err)
+		assert.Len(t, courses, 3)
+	})
+
+	t.Run("A book is on one active reserve at most", func(t *testing.T) {
+		reserve, err := repo.CreateReserve(ctx, &models.Reserve{CourseId: 1, BookId: 1, Title: "book1", LoanType: models.LoanTypeShortLoan,
+			LoanHours: 4, PreviousLoanType: models.LoanTypeStandard, Status: models.ReserveStatusActive})
+		assert.NoError(t, err)
+		_, err = repo.CreateReserve(ctx, &models.Reserve{CourseId: 2, BookId: 1, Title: "book1", LoanType: models.LoanTypeReferenceOnly,
+			PreviousLoanType: models.LoanTypeStandard, Status: models.ReserveStatusActive})
+		assert.ErrorIs(t, err, ErrExistingReserve)
+
+		update := *reserve
+		update.Status = models.ReserveStatusEnded
+		update.LoanHours = 100
+		ended, err := repo.UpdateReserve(ctx, &update)
+		assert.NoError(t, err)
+		assert.Equal(t, models.ReserveStatusEnded, ended.Status)
+		assert.Equal(t, 4, ended.LoanHours)
+
+		_, err = repo.CreateReserve(ctx, &models.Reserve{CourseId: 2, BookId: 1, Title: "book1", LoanType: models.LoanTypeReferenceOnly,
+			PreviousLoanType: models.LoanTypeStandard, Status: models.ReserveStatusActive})
+		assert.NoError(t, err)
+	})
+
+	t.Run("List the reserves of a course, or every active reserve", func(t *testing.T) {
+		reserves, err := repo.ListReserves(ctx, 1)
+		assert.NoError(t, err)
+		assert.Len(t, reserves, 1)
+		assert.Equal(t, models.ReserveStatusEnded, reserves[0].Status)
+
+		reserves, err = repo.ListReserves(ctx, 0)
+		assert.NoError(t, err)
+		assert.Len(t, reserves, 1)
+		assert.Equal(t, 2, reserves[0].CourseId)
+	})
+}
@@ -0,0 +1,114 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"github.com/aftaab60/e-library-api/models"
+	"sync"
+	"time"
+)
+
+type ICredentialRepository interface {
+	GetCredential(ctx context.Context, memberId int) (*models.Credential, error)
+	SetPassword(ctx context.Context, memberId int, passwordHash string, updatedAt time.Time) (*models.Credential, error)
+	RecordFailedLogin(ctx context.Context, memberId int, maxFailedLogins int, lockedUntil time.Time) (*models.Credential, error)
+	ResetFailedLogins(ctx context.Context, memberId int) error
+	CreatePasswordReset(ctx context.Context, reset *models.PasswordReset) error
+	UsePasswordReset(ctx context.Context, tokenHash string, usedAt time.Time) (*models.PasswordReset, error)
+}
+
+type CredentialRepository struct {
+	credentials map[int]*models.Credential
+	resets      map[string]*models.PasswordReset
+	mutex       sync.RWMutex
+}
+
+func NewCredentialRepository() *CredentialRepository {
+	return &CredentialRepository{
+		credentials: make(map[int]*models.Credential),
+		resets:      make(map[string]*models.PasswordReset),
+	}
+}
+
+// ErrCredentialNotFound is returned when a member has no password login
+var ErrCredentialNotFound = errors.New("credential not found")
+
+// ErrPasswordResetNotFound is returned when a password reset token is unknown, expired or already used
+var ErrPasswordResetNotFound = errors.New("password reset token not found")
+
+func (cr *CredentialRepository) GetCredential(ctx context.Context, memberId int) (*models.Credential, error) {
+	cr.mutex.RLock()
+	defer cr.mutex.RUnlock()
+
+	credential, ok := cr.credentials[memberId]
+	if !ok {
+		return nil, ErrCredentialNotFound
+	}
+	credentialCopy := *credential
+	return &credentialCopy, nil
+}
+
+// SetPassword creates or replaces the password of a member, which also lifts any lockout
+func (cr *CredentialRepository) SetPassword(ctx context.Context, memberId int, passwordHash string, updatedAt time.Time) (*models.Credential, error) {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+
+	credential := &models.Credential{MemberId: memberId, PasswordHash: passwordHash, UpdatedAt: updatedAt}
+	cr.credentials[memberId] = credential
+	credentialCopy := *credential
+	return &credentialCopy, nil
+}
+
+// RecordFailedLogin counts a failed login, the account is locked until lockedUntil once maxFailedLogins is reached
+func (cr *CredentialRepository) RecordFailedLogin(ctx context.Context, memberId int, maxFailedLogins int, lockedUntil time.Time) (*models.Credential, error) {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+
+	credential, ok := cr.credentials[memberId]
+	if !ok {
+		return nil, ErrCredentialNotFound
+	}
+	credential.FailedLogins++
+	if credential.FailedLogins >= maxFailedLogins {
+		credential.FailedLogins = 0
+		credential.LockedUntil = &lockedUntil
+	}
+	credentialCopy := *credential
+	return &credentialCopy, nil
+}
+
+func (cr *CredentialRepository) ResetFailedLogins(ctx context.Context, memberId int) error {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+
+	credential, ok := cr.credentials[memberId]
+	if !ok {
+		return ErrCredentialNotFound
+	}
+	credential.FailedLogins = 0
+	credential.LockedUntil = nil
+	return nil
+}
+
+func (cr *CredentialRepository) CreatePasswordReset(ctx context.Context, reset *models.PasswordReset) error {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+
+	resetCopy := *reset
+	cr.resets[reset.TokenHash] = &resetCopy
+	return nil
+}
+
+// UsePasswordReset marks a reset token used, a token is only usable once and before it expires
+func (cr *CredentialRepository) UsePasswordReset(ctx context.Context, tokenHash string, usedAt time.Time) (*models.PasswordReset, error) {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+
+	reset, ok := cr.resets[tokenHash]
+	if !ok || reset.UsedAt != nil || !reset.ExpiresAt.After(usedAt) {
+		return nil, ErrPasswordResetNotFound
+	}
+	reset.UsedAt = &usedAt
+	resetCopy := *reset
+	return &resetCopy, nil
+}
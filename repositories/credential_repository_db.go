@@ -0,0 +1,111 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/aftaab60/e-library-api/internal/db_manager"
+	"github.com/aftaab60/e-library-api/models"
+	"time"
+)
+
+type CredentialRepositoryDB struct {
+	DB *db_manager.DB
+}
+
+func NewCredentialRepositoryDB(db *db_manager.DB) *CredentialRepositoryDB {
+	return &CredentialRepositoryDB{DB: db}
+}
+
+func scanCredential(row *sql.Row) (*models.Credential, error) {
+	var credential models.Credential
+	var lockedUntil sql.NullTime
+	if err := row.Scan(&credential.MemberId, &credential.PasswordHash, &credential.FailedLogins, &lockedUntil, &credential.UpdatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrCredentialNotFound
+		}
+		return nil, err
+	}
+	if lockedUntil.Valid {
+		credential.LockedUntil = &lockedUntil.Time
+	}
+	return &credential, nil
+}
+
+func (cr *CredentialRepositoryDB) GetCredential(ctx context.Context, memberId int) (*models.Credential, error) {
+	query := "SELECT member_id, password_hash, failed_logins, locked_until, updated_at FROM credentials WHERE member_id = $1"
+	return scanCredential(cr.DB.GetRecord(ctx, query, memberId))
+}
+
+func (cr *CredentialRepositoryDB) SetPassword(ctx context.Context, memberId int, passwordHash string, updatedAt time.Time) (*models.Credential, error) {
+	upsertQuery := `
+        INSERT INTO credentials (member_id, password_hash, failed_logins, locked_until, updated_at)
+        VALUES ($1, $2, 0, NULL, $3)
+        ON CONFLICT (member_id) DO UPDATE
+        SET password_hash = EXCLUDED.password_hash, failed_logins = 0, locked_until = NULL, updated_at = EXCLUDED.updated_at
+        RETURNING member_id, password_hash, failed_logins, locked_until, updated_at
+    `
+	credential, err := scanCredential(cr.DB.UpdateRecord(ctx, upsertQuery, memberId, passwordHash, updatedAt))
+	if err != nil {
+		return nil, fmt.Errorf("error setting password of member %d: %w", memberId, err)
+	}
+	return credential, nil
+}
+
+func (cr *CredentialRepositoryDB) RecordFailedLogin(ctx context.Context, memberId int, maxFailedLogins int, lockedUntil time.Time) (*models.Credential, error) {
+	//counting in the statement keeps concurrent failed logins from being lost
+	updateQuery := `
+        UPDATE credentials
+        SET failed_logins = CASE WHEN failed_logins + 1 >= $2 THEN 0 ELSE failed_logins + 1 END,
+            locked_until = CASE WHEN failed_logins + 1 >= $2 THEN $3 ELSE locked_until END
+        WHERE member_id = $1
+        RETURNING member_id, password_hash, failed_logins, locked_until, updated_at
+    `
+	return scanCredential(cr.DB.UpdateRecord(ctx, updateQuery, memberId, maxFailedLogins, lockedUntil))
+}
+
+func (cr *CredentialRepositoryDB) ResetFailedLogins(ctx context.Context, memberId int) error {
+	updateQuery := "UPDATE credentials SET failed_logins = 0, locked_until = NULL WHERE member_id = $1"
+	result, err := cr.DB.UpdateRecords(ctx, updateQuery, memberId)
+	if err != nil {
+		return fmt.Errorf("error resetting failed logins of member %d: %w", memberId, err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrCredentialNotFound
+	}
+	return nil
+}
+
+func (cr *CredentialRepositoryDB) CreatePasswordReset(ctx context.Context, reset *models.PasswordReset) error {
+	insertQuery := `
+        INSERT INTO password_resets (token_hash, member_id, expires_at)
+        VALUES ($1, $2, $3)
+        RETURNING token_hash
+    `
+	var tokenHash string
+	if err := cr.DB.CreateRecord(ctx, insertQuery, reset.TokenHash, reset.MemberId, reset.ExpiresAt).Scan(&tokenHash); err != nil {
+		return fmt.Errorf("error creating password reset for member %d: %w", reset.MemberId, err)
+	}
+	return nil
+}
+
+func (cr *CredentialRepositoryDB) UsePasswordReset(ctx context.Context, tokenHash string, usedAt time.Time) (*models.PasswordReset, error) {
+	updateQuery := `
+        UPDATE password_resets
+        SET used_at = $2
+        WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2
+        RETURNING token_hash, member_id, expires_at, used_at
+    `
+	var reset models.PasswordReset
+	var used time.Time
+	err := cr.DB.UpdateRecord(ctx, updateQuery, tokenHash, usedAt).Scan(&reset.TokenHash, &reset.MemberId, &reset.ExpiresAt, &used)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPasswordResetNotFound
+		}
+		return nil, fmt.Errorf("error using password reset: %w", err)
+	}
+	reset.UsedAt = &used
+	return &reset, nil
+}
@@ -0,0 +1,64 @@
+package repositories
+
+import (
+	"context"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCredentialRepository_RecordFailedLogin(t *testing.T) {
+	repo := NewCredentialRepository()
+	ctx := context.Background()
+	now := time.Now()
+
+	_, err := repo.SetPassword(ctx, 1, "hash", now)
+	assert.NoError(t, err)
+
+	t.Run("Lock after max failed logins", func(t *testing.T) {
+		credential, err := repo.RecordFailedLogin(ctx, 1, 2, now.Add(time.Minute))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, credential.FailedLogins)
+		assert.Nil(t, credential.LockedUntil)
+
+		credential, err = repo.RecordFailedLogin(ctx, 1, 2, now.Add(time.Minute))
+		assert.NoError(t, err)
+		assert.Equal(t, 0, credential.FailedLogins)
+		assert.Equal(t, now.Add(time.Minute), *credential.LockedUntil)
+	})
+
+	t.Run("Setting a password lifts the lock", func(t *testing.T) {
+		credential, err := repo.SetPassword(ctx, 1, "new-hash", now)
+		assert.NoError(t, err)
+		assert.Nil(t, credential.LockedUntil)
+	})
+
+	t.Run("Fail for member without password", func(t *testing.T) {
+		_, err := repo.RecordFailedLogin(ctx, 100, 2, now)
+		assert.Equal(t, ErrCredentialNotFound, err)
+	})
+}
+
+func TestCredentialRepository_UsePasswordReset(t *testing.T) {
+	repo := NewCredentialRepository()
+	ctx := context.Background()
+	now := time.Now()
+
+	assert.NoError(t, repo.CreatePasswordReset(ctx, &models.PasswordReset{TokenHash: "current", MemberId: 1, ExpiresAt: now.Add(time.Hour)}))
+	assert.NoError(t, repo.CreatePasswordReset(ctx, &models.PasswordReset{TokenHash: "expired", MemberId: 1, ExpiresAt: now.Add(-time.Hour)}))
+
+	t.Run("Use a token once", func(t *testing.T) {
+		reset, err := repo.UsePasswordReset(ctx, "current", now)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, reset.MemberId)
+
+		_, err = repo.UsePasswordReset(ctx, "current", now)
+		assert.Equal(t, ErrPasswordResetNotFound, err)
+	})
+
+	t.Run("Fail with an expired token", func(t *testing.T) {
+		_, err := repo.UsePasswordReset(ctx, "expired", now)
+		assert.Equal(t, ErrPasswordResetNotFound, err)
+	})
+}
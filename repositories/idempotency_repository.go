@@ -0,0 +1,88 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"github.com/aftaab60/e-library-api/models"
+	"sync"
+	"time"
+)
+
+type IIdempotencyRepository interface {
+	// CreateIdempotencyRecord claims a key, it fails with ErrExistingIdempotencyKey while an unexpired record holds the key
+	CreateIdempotencyRecord(ctx context.Context, record *models.IdempotencyRecord) (*models.IdempotencyRecord, error)
+	GetIdempotencyRecord(ctx context.Context, key string, now time.Time) (*models.IdempotencyRecord, error)
+	CompleteIdempotencyRecord(ctx context.Context, key string, statusCode int, contentType string, body []byte) (*models.IdempotencyRecord, error)
+	DeleteIdempotencyRecord(ctx context.Context, key string) error
+}
+
+type IdempotencyRepository struct {
+	records map[string]*models.IdempotencyRecord
+	mutex   sync.RWMutex
+}
+
+func NewIdempotencyRepository() *IdempotencyRepository {
+	return &IdempotencyRepository{
+		records: make(map[string]*models.IdempotencyRecord),
+	}
+}
+
+var (
+	// ErrIdempotencyRecordNotFound is returned when no unexpired record holds a key
+	ErrIdempotencyRecordNotFound = errors.New("idempotency record not found")
+	ErrExistingIdempotencyKey    = errors.New("idempotency key already used")
+)
+
+func (ir *IdempotencyRepository) CreateIdempotencyRecord(ctx context.Context, record *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+	ir.mutex.Lock()
+	defer ir.mutex.Unlock()
+
+	//expired records no longer hold their key
+	for key, r := range ir.records {
+		if !r.ExpiresAt.After(record.CreatedAt) {
+			delete(ir.records, key)
+		}
+	}
+	if _, ok := ir.records[record.Key]; ok {
+		return nil, ErrExistingIdempotencyKey
+	}
+	createdRecord := *record
+	ir.records[record.Key] = &createdRecord
+	recordCopy := createdRecord
+	return &recordCopy, nil
+}
+
+func (ir *IdempotencyRepository) GetIdempotencyRecord(ctx context.Context, key string, now time.Time) (*models.IdempotencyRecord, error) {
+	ir.mutex.RLock()
+	defer ir.mutex.RUnlock()
+
+	record, ok := ir.records[key]
+	if !ok || !record.ExpiresAt.After(now) {
+		return nil, ErrIdempotencyRecordNotFound
+	}
+	recordCopy := *record
+	return &recordCopy, nil
+}
+
+func (ir *IdempotencyRepository) CompleteIdempotencyRecord(ctx context.Context, key string, statusCode int, contentType string, body []byte) (*models.IdempotencyRecord, error) {
+	ir.mutex.Lock()
+	defer ir.mutex.Unlock()
+
+	record, ok := ir.records[key]
+	if !ok {
+		return nil, ErrIdempotencyRecordNotFound
+	}
+	record.StatusCode = statusCode
+	record.ContentType = contentType
+	record.Body = append([]byte(nil), body...)
+	recordCopy := *record
+	return &recordCopy, nil
+}
+
+func (ir *IdempotencyRepository) DeleteIdempotencyRecord(ctx context.Context, key string) error {
+	ir.mutex.Lock()
+	defer ir.mutex.Unlock()
+
+	delete(ir.records, key)
+	return nil
+}
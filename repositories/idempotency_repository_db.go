@@ -0,0 +1,77 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/aftaab60/e-library-api/internal/db_manager"
+	"github.com/aftaab60/e-library-api/models"
+	"time"
+)
+
+type IdempotencyRepositoryDB struct {
+	DB *db_manager.DB
+}
+
+func NewIdempotencyRepositoryDB(db *db_manager.DB) *IdempotencyRepositoryDB {
+	return &IdempotencyRepositoryDB{DB: db}
+}
+
+func scanIdempotencyRecord(row *sql.Row) (*models.IdempotencyRecord, error) {
+	var record models.IdempotencyRecord
+	if err := row.Scan(&record.Key, &record.RequestHash, &record.StatusCode, &record.ContentType, &record.Body, &record.CreatedAt, &record.ExpiresAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrIdempotencyRecordNotFound
+		}
+		return nil, err
+	}
+	return &record, nil
+}
+
+func (ir *IdempotencyRepositoryDB) CreateIdempotencyRecord(ctx context.Context, record *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+	//an expired record is taken over, an unexpired one makes the insert return no row
+	insertQuery := `
+        INSERT INTO idempotency_keys (key, request_hash, created_at, expires_at)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (tenant_id, key) DO UPDATE
+        SET request_hash = EXCLUDED.request_hash, status_code = 0, content_type = '', response_body = NULL,
+            created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at
+        WHERE idempotency_keys.expires_at <= EXCLUDED.created_at
+        RETURNING key, request_hash, status_code, content_type, COALESCE(response_body, ''::bytea), created_at, expires_at
+    `
+	createdRecord, err := scanIdempotencyRecord(ir.DB.CreateRecord(ctx, insertQuery, record.Key, record.RequestHash, record.CreatedAt, record.ExpiresAt))
+	if err != nil {
+		if errors.Is(err, ErrIdempotencyRecordNotFound) {
+			return nil, ErrExistingIdempotencyKey
+		}
+		return nil, fmt.Errorf("error creating idempotency record: %w", err)
+	}
+	return createdRecord, nil
+}
+
+func (ir *IdempotencyRepositoryDB) GetIdempotencyRecord(ctx context.Context, key string, now time.Time) (*models.IdempotencyRecord, error) {
+	query := `
+        SELECT key, request_hash, status_code, content_type, COALESCE(response_body, ''::bytea), created_at, expires_at
+        FROM idempotency_keys
+        WHERE key = $1 AND expires_at > $2
+    `
+	return scanIdempotencyRecord(ir.DB.GetRecord(ctx, query, key, now))
+}
+
+func (ir *IdempotencyRepositoryDB) CompleteIdempotencyRecord(ctx context.Context, key string, statusCode int, contentType string, body []byte) (*models.IdempotencyRecord, error) {
+	updateQuery := `
+        UPDATE idempotency_keys
+        SET status_code = $1, content_type = $2, response_body = $3
+        WHERE key = $4
+        RETURNING key, request_hash, status_code, content_type, COALESCE(response_body, ''::bytea), created_at, expires_at
+    `
+	return scanIdempotencyRecord(ir.DB.UpdateRecord(ctx, updateQuery, statusCode, contentType, body, key))
+}
+
+func (ir *IdempotencyRepositoryDB) DeleteIdempotencyRecord(ctx context.Context, key string) error {
+	if _, err := ir.DB.DeleteRecord(ctx, "DELETE FROM idempotency_keys WHERE key = $1", key); err != nil {
+		return fmt.Errorf("error deleting idempotency record: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package repositories
+
+import (
+	"context"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyRepository_CreateIdempotencyRecord(t *testing.T) {
+	repo := NewIdempotencyRepository()
+	ctx := context.Background()
+	now := time.Now()
+
+	_, err := repo.CreateIdempotencyRecord(ctx, &models.IdempotencyRecord{Key: "key1", RequestHash: "hash1", CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+	assert.NoError(t, err)
+
+	t.Run("Fail to claim a held key", func(t *testing.T) {
+		_, err := repo.CreateIdempotencyRecord(ctx, &models.IdempotencyRecord{Key: "key1", RequestHash: "hash2", CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+		assert.Equal(t, ErrExistingIdempotencyKey, err)
+	})
+
+	t.Run("Complete a record", func(t *testing.T) {
+		record, err := repo.CompleteIdempotencyRecord(ctx, "key1", 201, "application/json", []byte(`{}`))
+		assert.NoError(t, err)
+		assert.True(t, record.IsCompleted())
+
+		record, err = repo.GetIdempotencyRecord(ctx, "key1", now)
+		assert.NoError(t, err)
+		assert.Equal(t, "hash1", record.RequestHash)
+		assert.Equal(t, []byte(`{}`), record.Body)
+	})
+
+	t.Run("Expired record releases its key", func(t *testing.T) {
+		later := now.Add(2 * time.Hour)
+		_, err := repo.GetIdempotencyRecord(ctx, "key1", later)
+		assert.Equal(t, ErrIdempotencyRecordNotFound, err)
+
+		record, err := repo.CreateIdempotencyRecord(ctx, &models.IdempotencyRecord{Key: "key1", RequestHash: "hash2", CreatedAt: later, ExpiresAt: later.Add(time.Hour)})
+		assert.NoError(t, err)
+		assert.False(t, record.IsCompleted())
+	})
+
+	t.Run("Deleted record releases its key", func(t *testing.T) {
+		assert.NoError(t, repo.DeleteIdempotencyRecord(ctx, "key1"))
+		_, err := repo.GetIdempotencyRecord(ctx, "key1", now)
+		assert.Equal(t, ErrIdempotencyRecordNotFound, err)
+	})
+}
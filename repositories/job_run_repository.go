@@ -0,0 +1,110 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"github.com/aftaab60/e-library-api/models"
+	"sync"
+)
+
+// IJobRunRepository keeps the history of background job runs
+type IJobRunRepository interface {
+	// CreateJobRun records a run that started, it fails with ErrExistingJobRun when the job already has a run for the
+	// same scheduled time
+	CreateJobRun(ctx context.Context, run *models.JobRun) (*models.JobRun, error)
+	// UpdateJobRun saves the outcome of a run
+	UpdateJobRun(ctx context.Context, run *models.JobRun) (*models.JobRun, error)
+	// DeleteJobRun forgets a run, scheduled runs that had nothing to do aren't kept
+	DeleteJobRun(ctx context.Context, id int) error
+	// ListJobRuns returns a page of runs matching the filter, newest first, along with the total number of matching runs
+	ListJobRuns(ctx context.Context, filter *models.JobRunFilter) ([]models.JobRun, int, error)
+}
+
+type JobRunRepository struct {
+	runs   []models.JobRun
+	nextId int
+	mutex  sync.RWMutex
+}
+
+func NewJobRunRepository() *JobRunRepository {
+	return &JobRunRepository{
+		runs: make([]models.JobRun, 0),
+	}
+}
+
+var (
+	// ErrExistingJobRun is returned when a scheduled time of a job was already run, by this or another instance
+	ErrExistingJobRun = errors.New("existing job run")
+	ErrJobRunNotFound = errors.New("job run not found")
+)
+
+func (jr *JobRunRepository) CreateJobRun(ctx context.Context, run *models.JobRun) (*models.JobRun, error) {
+	jr.mutex.Lock()
+	defer jr.mutex.Unlock()
+
+	if run.ScheduledAt != nil {
+		for _, r := range jr.runs {
+			if r.Job == run.Job && r.ScheduledAt != nil && r.ScheduledAt.Equal(*run.ScheduledAt) {
+				return nil, ErrExistingJobRun
+			}
+		}
+	}
+	jr.nextId++
+	createdRun := *run
+	createdRun.Id = jr.nextId
+	jr.runs = append(jr.runs, createdRun)
+	return &createdRun, nil
+}
+
+func (jr *JobRunRepository) UpdateJobRun(ctx context.Context, run *models.JobRun) (*models.JobRun, error) {
+	jr.mutex.Lock()
+	defer jr.mutex.Unlock()
+
+	for i := range jr.runs {
+		if jr.runs[i].Id == run.Id {
+			stored := &jr.runs[i]
+			stored.Status = run.Status
+			stored.FinishedAt = run.FinishedAt
+			stored.Processed = run.Processed
+			stored.Error = run.Error
+			updatedRun := *stored
+			return &updatedRun, nil
+		}
+	}
+	return nil, ErrJobRunNotFound
+}
+
+func (jr *JobRunRepository) DeleteJobRun(ctx context.Context, id int) error {
+	jr.mutex.Lock()
+	defer jr.mutex.Unlock()
+
+	for i, r := range jr.runs {
+		if r.Id == id {
+			jr.runs = append(jr.runs[:i], jr.runs[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (jr *JobRunRepository) ListJobRuns(ctx context.Context, filter *models.JobRunFilter) ([]models.JobRun, int, error) {
+	jr.mutex.RLock()
+	defer jr.mutex.RUnlock()
+
+	matched := make([]models.JobRun, 0)
+	for i := len(jr.runs) - 1; i >= 0; i-- {
+		if filter.Matches(&jr.runs[i]) {
+			matched = append(matched, jr.runs[i])
+		}
+	}
+
+	total := len(matched)
+	if filter.Offset >= total {
+		return make([]models.JobRun, 0), total, nil
+	}
+	end := total
+	if filter.Limit > 0 && filter.Offset+filter.Limit < total {
+		end = filter.Offset + filter.Limit
+	}
+	return matched[filter.Offset:end], total, nil
+}
@@ -0,0 +1,120 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/aftaab60/e-library-api/internal/db_manager"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/lib/pq"
+	"strings"
+)
+
+type JobRunRepositoryDB struct {
+	DB *db_manager.DB
+}
+
+func NewJobRunRepositoryDB(db *db_manager.DB) *JobRunRepositoryDB {
+	return &JobRunRepositoryDB{DB: db}
+}
+
+const jobRunColumns = "id, job, trigger, status, scheduled_at, triggered_by, started_at, finished_at, processed, error"
+
+func scanJobRun(row scanner) (*models.JobRun, error) {
+	var run models.JobRun
+	err := row.Scan(&run.Id, &run.Job, &run.Trigger, &run.Status, &run.ScheduledAt, &run.TriggeredBy, &run.StartedAt,
+		&run.FinishedAt, &run.Processed, &run.Error)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrJobRunNotFound
+		}
+		return nil, err
+	}
+	return &run, nil
+}
+
+func (jr *JobRunRepositoryDB) CreateJobRun(ctx context.Context, run *models.JobRun) (*models.JobRun, error) {
+	insertQuery := `
+        INSERT INTO job_runs (job, trigger, status, scheduled_at, triggered_by, started_at, processed, error)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        RETURNING ` + jobRunColumns
+	createdRun, err := scanJobRun(jr.DB.CreateRecord(ctx, insertQuery, run.Job, run.Trigger, run.Status, run.ScheduledAt,
+		run.TriggeredBy, run.StartedAt, run.Processed, run.Error))
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" { //unique_violation
+			return nil, ErrExistingJobRun
+		}
+		return nil, fmt.Errorf("error creating run of job %s: %w", run.Job, err)
+	}
+	return createdRun, nil
+}
+
+func (jr *JobRunRepositoryDB) UpdateJobRun(ctx context.Context, run *models.JobRun) (*models.JobRun, error) {
+	updateQuery := `
+        UPDATE job_runs
+        SET status = $2, finished_at = $3, processed = $4, error = $5
+        WHERE id = $1
+        RETURNING ` + jobRunColumns
+	updatedRun, err := scanJobRun(jr.DB.UpdateRecord(ctx, updateQuery, run.Id, run.Status, run.FinishedAt, run.Processed, run.Error))
+	if err != nil && !errors.Is(err, ErrJobRunNotFound) {
+		return nil, fmt.Errorf("error updating job run %d: %w", run.Id, err)
+	}
+	return updatedRun, err
+}
+
+func (jr *JobRunRepositoryDB) DeleteJobRun(ctx context.Context, id int) error {
+	if _, err := jr.DB.DeleteRecord(ctx, "DELETE FROM job_runs WHERE id = $1", id); err != nil {
+		return fmt.Errorf("error deleting job run %d: %w", id, err)
+	}
+	return nil
+}
+
+func (jr *JobRunRepositoryDB) ListJobRuns(ctx context.Context, filter *models.JobRunFilter) ([]models.JobRun, int, error) {
+	conditions := make([]string, 0)
+	args := make([]interface{}, 0)
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if filter.Job != "" {
+		addCondition("job = $%d", filter.Job)
+	}
+	if filter.Status != "" {
+		addCondition("status = $%d", filter.Status)
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	countQuery := "SELECT COUNT(*) FROM job_runs " + where
+	if err := jr.DB.GetRecord(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("error counting job runs: %w", err)
+	}
+
+	query := fmt.Sprintf(`
+        SELECT %s
+        FROM job_runs
+        %s
+        ORDER BY id DESC
+        LIMIT $%d OFFSET $%d
+    `, jobRunColumns, where, len(args)+1, len(args)+2)
+	rows, err := jr.DB.GetRecords(ctx, query, append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error fetching job runs: %w", err)
+	}
+	defer rows.Close()
+
+	runs := make([]models.JobRun, 0)
+	for rows.Next() {
+		run, err := scanJobRun(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		runs = append(runs, *run)
+	}
+	return runs, total, rows.Err()
+}
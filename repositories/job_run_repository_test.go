@@ -0,0 +1,54 @@
+package repositories
+
+import (
+	"context"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestJobRunRepository(t *testing.T) {
+	repo := NewJobRunRepository()
+	ctx := context.Background()
+	scheduledAt := time.Date(2025, 2, 3, 10, 0, 0, 0, time.UTC)
+
+	run, err := repo.CreateJobRun(ctx, &models.JobRun{Job: "send-reminders", Trigger: models.JobTriggerSchedule, Status: models.JobRunRunning, ScheduledAt: &scheduledAt, StartedAt: scheduledAt})
+	assert.NoError(t, err)
+
+	t.Run("A scheduled time is run once", func(t *testing.T) {
+		_, err := repo.CreateJobRun(ctx, &models.JobRun{Job: "send-reminders", Trigger: models.JobTriggerSchedule, Status: models.JobRunRunning, ScheduledAt: &scheduledAt, StartedAt: scheduledAt})
+		assert.Equal(t, ErrExistingJobRun, err)
+
+		//manual runs and other jobs aren't tied to the time
+		_, err = repo.CreateJobRun(ctx, &models.JobRun{Job: "send-reminders", Trigger: models.JobTriggerManual, Status: models.JobRunRunning, StartedAt: scheduledAt})
+		assert.NoError(t, err)
+		_, err = repo.CreateJobRun(ctx, &models.JobRun{Job: "dispatch-webhooks", Trigger: models.JobTriggerSchedule, Status: models.JobRunRunning, ScheduledAt: &scheduledAt, StartedAt: scheduledAt})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Update and list runs", func(t *testing.T) {
+		finishedAt := scheduledAt.Add(time.Minute)
+		run.Status = models.JobRunFailed
+		run.FinishedAt = &finishedAt
+		run.Error = "smtp unavailable"
+		_, err := repo.UpdateJobRun(ctx, run)
+		assert.NoError(t, err)
+
+		runs, total, err := repo.ListJobRuns(ctx, &models.JobRunFilter{Job: "send-reminders", Limit: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, total)
+		assert.Equal(t, models.JobTriggerManual, runs[0].Trigger)
+		assert.Equal(t, "smtp unavailable", runs[1].Error)
+
+		_, err = repo.UpdateJobRun(ctx, &models.JobRun{Id: 10})
+		assert.Equal(t, ErrJobRunNotFound, err)
+	})
+
+	t.Run("Deleted runs are forgotten", func(t *testing.T) {
+		assert.NoError(t, repo.DeleteJobRun(ctx, run.Id))
+		_, total, err := repo.ListJobRuns(ctx, &models.JobRunFilter{Status: models.JobRunFailed, Limit: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, 0, total)
+	})
+}
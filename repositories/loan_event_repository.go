@@ -0,0 +1,107 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"github.com/aftaab60/e-library-api/models"
+	"sync"
+)
+
+// ILoanEventRepository is the event store of loans. Events are only appended, except that anonymization redacts borrower names.
+type ILoanEventRepository interface {
+	// AppendLoanEvent adds an event at the end of its loan's stream, it fails with ErrLoanEventConflict when the stream already has the event's version
+	AppendLoanEvent(ctx context.Context, event *models.LoanEvent) (*models.LoanEvent, error)
+	// GetLoanEvents returns the stream of a loan in version order, empty for loans without events
+	GetLoanEvents(ctx context.Context, loanId int) ([]models.LoanEvent, error)
+	// ListLoanEvents returns up to limit events of all loans after the event with id afterId, in the order they were appended
+	ListLoanEvents(ctx context.Context, afterId int, limit int) ([]models.LoanEvent, error)
+	// AnonymizeLoanEvents replaces the borrower name in the streams of the borrower's returned loans, as AnonymizeLoans does
+	// for loans. It returns the number of events redacted.
+	AnonymizeLoanEvents(ctx context.Context, borrowerName string) (int, error)
+}
+
+type LoanEventRepository struct {
+	events []models.LoanEvent
+	mutex  sync.RWMutex
+}
+
+func NewLoanEventRepository() *LoanEventRepository {
+	return &LoanEventRepository{
+		events: make([]models.LoanEvent, 0),
+	}
+}
+
+// ErrLoanEventConflict is returned when another change of the loan was recorded first
+var ErrLoanEventConflict = errors.New("loan was changed concurrently")
+
+func (lr *LoanEventRepository) AppendLoanEvent(ctx context.Context, event *models.LoanEvent) (*models.LoanEvent, error) {
+	lr.mutex.Lock()
+	defer lr.mutex.Unlock()
+
+	for _, e := range lr.events {
+		if e.LoanId == event.LoanId && e.Version == event.Version {
+			return nil, ErrLoanEventConflict
+		}
+	}
+	appendedEvent := *event
+	appendedEvent.Id = len(lr.events) + 1
+	lr.events = append(lr.events, appendedEvent)
+	return &appendedEvent, nil
+}
+
+func (lr *LoanEventRepository) GetLoanEvents(ctx context.Context, loanId int) ([]models.LoanEvent, error) {
+	lr.mutex.RLock()
+	defer lr.mutex.RUnlock()
+
+	events := make([]models.LoanEvent, 0)
+	for _, e := range lr.events {
+		if e.LoanId == loanId {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+func (lr *LoanEventRepository) ListLoanEvents(ctx context.Context, afterId int, limit int) ([]models.LoanEvent, error) {
+	lr.mutex.RLock()
+	defer lr.mutex.RUnlock()
+
+	events := make([]models.LoanEvent, 0)
+	//ids are positions in the slice, starting at 1
+	for i := afterId; i < len(lr.events) && len(events) < limit; i++ {
+		events = append(events, lr.events[i])
+	}
+	return events, nil
+}
+
+func (lr *LoanEventRepository) AnonymizeLoanEvents(ctx context.Context, borrowerName string) (int, error) {
+	lr.mutex.Lock()
+	defer lr.mutex.Unlock()
+
+	streams := make(map[int][]int)
+	for i, e := range lr.events {
+		streams[e.LoanId] = append(streams[e.LoanId], i)
+	}
+	count := 0
+	for _, indexes := range streams {
+		loan, err := models.ProjectLoan(lr.eventsAt(indexes))
+		if err != nil || loan.BorrowerName != borrowerName || loan.Status != models.LoanStatusReturned {
+			continue
+		}
+		for _, i := range indexes {
+			if lr.events[i].Data.BorrowerName == borrowerName {
+				lr.events[i].Data.BorrowerName = models.AnonymizedBorrower
+				count++
+			}
+		}
+	}
+	return count, nil
+}
+
+func (lr *LoanEventRepository) eventsAt(indexes []int) []models.LoanEvent {
+	events := make([]models.LoanEvent, 0, len(indexes))
+	for _, i := range indexes {
+		events = append(events, lr.events[i])
+	}
+	return events
+}
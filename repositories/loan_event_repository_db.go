@@ -0,0 +1,113 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aftaab60/e-library-api/internal/db_manager"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/lib/pq"
+)
+
+type LoanEventRepositoryDB struct {
+	DB *db_manager.DB
+}
+
+func NewLoanEventRepositoryDB(db *db_manager.DB) *LoanEventRepositoryDB {
+	return &LoanEventRepositoryDB{DB: db}
+}
+
+func (lr *LoanEventRepositoryDB) AppendLoanEvent(ctx context.Context, event *models.LoanEvent) (*models.LoanEvent, error) {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding loan event: %w", err)
+	}
+	insertQuery := `
+        INSERT INTO loan_events (loan_id, version, type, data, occurred_at)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id
+    `
+	appendedEvent := *event
+	if err := lr.DB.CreateRecord(ctx, insertQuery, event.LoanId, event.Version, event.Type, data, event.OccurredAt).Scan(&appendedEvent.Id); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return nil, ErrLoanEventConflict
+		}
+		return nil, fmt.Errorf("error appending %s event of loan %d: %w", event.Type, event.LoanId, err)
+	}
+	return &appendedEvent, nil
+}
+
+func scanLoanEvents(rows *sql.Rows) ([]models.LoanEvent, error) {
+	defer rows.Close()
+
+	events := make([]models.LoanEvent, 0)
+	for rows.Next() {
+		var event models.LoanEvent
+		var data []byte
+		if err := rows.Scan(&event.Id, &event.LoanId, &event.Version, &event.Type, &data, &event.OccurredAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &event.Data); err != nil {
+			return nil, fmt.Errorf("error decoding loan event %d: %w", event.Id, err)
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+func (lr *LoanEventRepositoryDB) GetLoanEvents(ctx context.Context, loanId int) ([]models.LoanEvent, error) {
+	query := `
+        SELECT id, loan_id, version, type, data, occurred_at
+        FROM loan_events
+        WHERE loan_id = $1
+        ORDER BY version
+    `
+	rows, err := lr.DB.GetRecords(ctx, query, loanId)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching events of loan %d: %w", loanId, err)
+	}
+	return scanLoanEvents(rows)
+}
+
+func (lr *LoanEventRepositoryDB) ListLoanEvents(ctx context.Context, afterId int, limit int) ([]models.LoanEvent, error) {
+	query := `
+        SELECT id, loan_id, version, type, data, occurred_at
+        FROM loan_events
+        WHERE id > $1
+        ORDER BY id
+        LIMIT $2
+    `
+	rows, err := lr.DB.GetRecords(ctx, query, afterId, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching loan events: %w", err)
+	}
+	return scanLoanEvents(rows)
+}
+
+func (lr *LoanEventRepositoryDB) AnonymizeLoanEvents(ctx context.Context, borrowerName string) (int, error) {
+	//a loan is returned when the last event of its stream is a return, a found lost copy, or an import of a returned loan
+	updateQuery := `
+        WITH last_events AS (
+            SELECT DISTINCT ON (loan_id) loan_id, type, data->>'status' AS status
+            FROM loan_events
+            ORDER BY loan_id, version DESC
+        )
+        UPDATE loan_events
+        SET data = jsonb_set(data, '{borrower_name}', to_jsonb($1::text))
+        WHERE data->>'borrower_name' = $2
+          AND loan_id IN (
+              SELECT loan_id FROM last_events
+              WHERE type IN ($3, $4) OR (type = $5 AND status = $6)
+          )
+    `
+	result, err := lr.DB.UpdateRecords(ctx, updateQuery, models.AnonymizedBorrower, borrowerName,
+		models.LoanEventReturned, models.LoanEventFound, models.LoanEventImported, models.LoanStatusReturned)
+	if err != nil {
+		return 0, fmt.Errorf("error anonymizing loan events of %s: %w", borrowerName, err)
+	}
+	count, err := result.RowsAffected()
+	return int(count), err
+}
@@ -0,0 +1,61 @@
+package repositories
+
+import (
+	"context"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestLoanEventRepository_AppendLoanEvent(t *testing.T) {
+	repo := NewLoanEventRepository()
+	ctx := context.Background()
+	now := time.Now()
+	dueDate := now.AddDate(0, 0, 28)
+
+	_, err := repo.AppendLoanEvent(ctx, &models.LoanEvent{LoanId: 1, Version: 1, Type: models.LoanEventCreated, OccurredAt: now,
+		Data: models.LoanEventData{Title: "book1", BookId: 1, BorrowerName: "user1", BranchId: 1, DueDate: &dueDate}})
+	assert.NoError(t, err)
+	_, err = repo.AppendLoanEvent(ctx, &models.LoanEvent{LoanId: 2, Version: 1, Type: models.LoanEventCreated, OccurredAt: now,
+		Data: models.LoanEventData{Title: "book2", BookId: 2, BorrowerName: "user1", BranchId: 1, DueDate: &dueDate}})
+	assert.NoError(t, err)
+	_, err = repo.AppendLoanEvent(ctx, &models.LoanEvent{LoanId: 1, Version: 2, Type: models.LoanEventReturned, OccurredAt: now,
+		Data: models.LoanEventData{ReturnedAt: &now}})
+	assert.NoError(t, err)
+
+	t.Run("Fail to append a version twice", func(t *testing.T) {
+		_, err := repo.AppendLoanEvent(ctx, &models.LoanEvent{LoanId: 1, Version: 2, Type: models.LoanEventExtended, OccurredAt: now,
+			Data: models.LoanEventData{DueDate: &dueDate}})
+		assert.Equal(t, ErrLoanEventConflict, err)
+	})
+
+	t.Run("Get the stream of a loan", func(t *testing.T) {
+		events, err := repo.GetLoanEvents(ctx, 1)
+		assert.NoError(t, err)
+		assert.Len(t, events, 2)
+		assert.Equal(t, models.LoanEventReturned, events[1].Type)
+	})
+
+	t.Run("List events after an id", func(t *testing.T) {
+		events, err := repo.ListLoanEvents(ctx, 1, 10)
+		assert.NoError(t, err)
+		assert.Len(t, events, 2)
+		assert.Equal(t, 2, events[0].Id)
+
+		events, err = repo.ListLoanEvents(ctx, 0, 1)
+		assert.NoError(t, err)
+		assert.Len(t, events, 1)
+	})
+
+	t.Run("Anonymize streams of returned loans", func(t *testing.T) {
+		count, err := repo.AnonymizeLoanEvents(ctx, "user1")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+
+		events, _ := repo.GetLoanEvents(ctx, 1)
+		assert.Equal(t, models.AnonymizedBorrower, events[0].Data.BorrowerName)
+		events, _ = repo.GetLoanEvents(ctx, 2)
+		assert.Equal(t, "user1", events[0].Data.BorrowerName)
+	})
+}
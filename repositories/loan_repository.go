@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"github.com/aftaab60/e-library-api/models"
+	"sort"
 	"sync"
 )
 
@@ -12,12 +13,19 @@ type ILoanRepository interface {
 	CreateLoan(ctx context.Context, title string, loanDetail *models.Loan) (*models.Loan, error)
 	UpdateLoan(ctx context.Context, title string, borrowerName string, loanUpdate *models.LoanUpdate) (*models.Loan, error)
 	DeleteLoan(ctx context.Context, title string, borrowerName string) error
+	GetLoanById(ctx context.Context, id int) (*models.Loan, error)
+	UpdateLoanById(ctx context.Context, id int, loanUpdate *models.LoanUpdate) (*models.Loan, error)
+	ListLoans(ctx context.Context, filter *models.LoanFilter) ([]models.Loan, int, error)
+	AnonymizeLoans(ctx context.Context, borrowerName string) (int, error)
+	// SaveLoan creates or replaces the loan with the id of loan, projections use it to rebuild loans from their events
+	SaveLoan(ctx context.Context, title string, loan *models.Loan) (*models.Loan, error)
 }
 
 type LoanRepository struct {
 	//book_id: All loans of this book title. Value can also be map[borrower]Loan but keeping slice for simplicity
-	loans map[string][]models.Loan
-	mutex sync.RWMutex
+	loans  map[string][]models.Loan
+	nextId int
+	mutex  sync.RWMutex
 }
 
 func NewLoanRepository() *LoanRepository {
@@ -60,7 +68,12 @@ func (l *LoanRepository) CreateLoan(ctx context.Context, title string, loanDetai
 		loanDetails = make([]models.Loan, 0)
 	}
 
-	loanDetail.Id = len(loanDetails) + 1 //incremental id
+	//incremental id, unique across all titles so that a loan can be looked up by id alone
+	l.nextId++
+	loanDetail.Id = l.nextId
+	if loanDetail.Status == "" {
+		loanDetail.Status = models.LoanStatusActive
+	}
 	loanDetails = append(loanDetails, *loanDetail)
 	l.loans[title] = loanDetails
 
@@ -86,6 +99,9 @@ func (l *LoanRepository) UpdateLoan(ctx context.Context, title string, borrowerN
 			if loanUpdate.IsReturn != nil {
 				loanDetails[i].IsReturn = *loanUpdate.IsReturn
 			}
+			if loanUpdate.Status != nil {
+				loanDetails[i].Status = *loanUpdate.Status
+			}
 			updatedLoan = &loanDetails[i]
 			break
 		}
@@ -121,3 +137,110 @@ func (l *LoanRepository) DeleteLoan(ctx context.Context, title string, borrowerN
 	}
 	return ErrLoanNotFound
 }
+
+func (l *LoanRepository) GetLoanById(ctx context.Context, id int) (*models.Loan, error) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	for _, loanDetails := range l.loans {
+		for _, loanDetail := range loanDetails {
+			if loanDetail.Id == id {
+				return &loanDetail, nil
+			}
+		}
+	}
+	return nil, ErrLoanNotFound
+}
+
+func (l *LoanRepository) UpdateLoanById(ctx context.Context, id int, loanUpdate *models.LoanUpdate) (*models.Loan, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if loanUpdate == nil {
+		return nil, ErrLoanNotFound
+	}
+	for _, loanDetails := range l.loans {
+		for i := range loanDetails {
+			if loanDetails[i].Id != id {
+				continue
+			}
+			//update values if not null
+			if loanUpdate.ReturnDate != nil {
+				loanDetails[i].ReturnDate = *loanUpdate.ReturnDate
+			}
+			if loanUpdate.IsReturn != nil {
+				loanDetails[i].IsReturn = *loanUpdate.IsReturn
+			}
+			if loanUpdate.Status != nil {
+				loanDetails[i].Status = *loanUpdate.Status
+			}
+			updatedLoan := loanDetails[i]
+			return &updatedLoan, nil
+		}
+	}
+	return nil, ErrLoanNotFound
+}
+
+// ListLoans returns a page of loans matching the filter ordered by id, along with the total number of matching loans
+func (l *LoanRepository) ListLoans(ctx context.Context, filter *models.LoanFilter) ([]models.Loan, int, error) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	matched := make([]models.Loan, 0)
+	for _, loanDetails := range l.loans {
+		for _, loanDetail := range loanDetails {
+			if filter.Matches(&loanDetail) {
+				matched = append(matched, loanDetail)
+			}
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].Id < matched[j].Id })
+
+	total := len(matched)
+	if filter.Offset >= total {
+		return make([]models.Loan, 0), total, nil
+	}
+	end := total
+	if filter.Limit > 0 && filter.Offset+filter.Limit < total {
+		end = filter.Offset + filter.Limit
+	}
+	return matched[filter.Offset:end], total, nil
+}
+
+// AnonymizeLoans detaches returned loans from the borrower. Active loans, and lost or damaged loans which may still carry charges, are kept.
+func (l *LoanRepository) AnonymizeLoans(ctx context.Context, borrowerName string) (int, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	count := 0
+	for _, loanDetails := range l.loans {
+		for i := range loanDetails {
+			if loanDetails[i].BorrowerName == borrowerName && loanDetails[i].Status == models.LoanStatusReturned {
+				loanDetails[i].BorrowerName = models.AnonymizedBorrower
+				count++
+			}
+		}
+	}
+	return count, nil
+}
+
+func (l *LoanRepository) SaveLoan(ctx context.Context, title string, loan *models.Loan) (*models.Loan, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for _, loanDetails := range l.loans {
+		for i := range loanDetails {
+			if loanDetails[i].Id == loan.Id {
+				loanDetails[i] = *loan
+				savedLoan := loanDetails[i]
+				return &savedLoan, nil
+			}
+		}
+	}
+	l.loans[title] = append(l.loans[title], *loan)
+	if loan.Id > l.nextId {
+		l.nextId = loan.Id
+	}
+	savedLoan := *loan
+	return &savedLoan, nil
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"github.com/aftaab60/e-library-api/internal/db_manager"
 	"github.com/aftaab60/e-library-api/models"
+	"strings"
 )
 
 type LoanRepositoryDB struct {
@@ -21,7 +22,7 @@ func NewLoanRepositoryDB(db *db_manager.DB) *LoanRepositoryDB {
 
 func (l *LoanRepositoryDB) GetLoan(ctx context.Context, title string, borrowerName string) (*models.Loan, error) {
 	query := `
-        SELECT l.id, l.book_id, l.borrower_name, l.loan_date, l.return_date, l.is_returned
+        SELECT l.id, l.book_id, l.borrower_name, l.loan_date, l.return_date, l.is_returned, l.status, l.branch_id
         FROM loans l
         JOIN books b ON l.book_id = b.id
         WHERE b.title = $1 AND l.borrower_name = $2 AND l.is_returned = FALSE
@@ -29,7 +30,7 @@ func (l *LoanRepositoryDB) GetLoan(ctx context.Context, title string, borrowerNa
 	row := l.DB.GetRecord(ctx, query, title, borrowerName)
 
 	var loan models.Loan
-	if err := row.Scan(&loan.Id, &loan.BookId, &loan.BorrowerName, &loan.LoanDate, &loan.ReturnDate, &loan.IsReturn); err != nil {
+	if err := row.Scan(&loan.Id, &loan.BookId, &loan.BorrowerName, &loan.LoanDate, &loan.ReturnDate, &loan.IsReturn, &loan.Status, &loan.BranchId); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrLoanNotFound
 		}
@@ -40,17 +41,17 @@ func (l *LoanRepositoryDB) GetLoan(ctx context.Context, title string, borrowerNa
 
 func (l *LoanRepositoryDB) CreateLoan(ctx context.Context, title string, loan *models.Loan) (*models.Loan, error) {
 	insertQuery := `
-        INSERT INTO loans (book_id, borrower_name, loan_date, return_date, is_returned)
-        VALUES ($1, $2, $3, $4, $5)
-        RETURNING id, book_id, borrower_name, loan_date, return_date, is_returned
+        INSERT INTO loans (book_id, borrower_name, loan_date, return_date, is_returned, status, branch_id)
+        VALUES ($1, $2, $3, $4, $5, COALESCE(NULLIF($6, ''), 'active'), $7)
+        RETURNING id, book_id, borrower_name, loan_date, return_date, is_returned, status, branch_id
     `
-	row := l.DB.CreateRecord(ctx, insertQuery, loan.BookId, loan.BorrowerName, loan.LoanDate, loan.ReturnDate, loan.IsReturn)
+	row := l.DB.CreateRecord(ctx, insertQuery, loan.BookId, loan.BorrowerName, loan.LoanDate, loan.ReturnDate, loan.IsReturn, loan.Status, loan.BranchId)
 	if row == nil {
 		return nil, sql.ErrNoRows
 	}
 
 	var insertedLoan models.Loan
-	err := row.Scan(&insertedLoan.Id, &insertedLoan.BookId, &insertedLoan.BorrowerName, &insertedLoan.LoanDate, &insertedLoan.ReturnDate, &insertedLoan.IsReturn)
+	err := row.Scan(&insertedLoan.Id, &insertedLoan.BookId, &insertedLoan.BorrowerName, &insertedLoan.LoanDate, &insertedLoan.ReturnDate, &insertedLoan.IsReturn, &insertedLoan.Status, &insertedLoan.BranchId)
 	if err != nil {
 		return nil, fmt.Errorf("error creating loan for title %s: %w", title, err)
 	}
@@ -71,17 +72,17 @@ func (l *LoanRepositoryDB) UpdateLoan(ctx context.Context, title string, borrowe
 
 	updateQuery := `
         UPDATE loans
-        SET return_date = $1, is_returned = $2
-        WHERE book_id = $3 AND borrower_name = $4 AND is_returned = FALSE
-        RETURNING id, book_id, borrower_name, loan_date, return_date, is_returned
+        SET return_date = COALESCE($1, return_date), is_returned = COALESCE($2, is_returned), status = COALESCE($3, status)
+        WHERE book_id = $4 AND borrower_name = $5 AND is_returned = FALSE
+        RETURNING id, book_id, borrower_name, loan_date, return_date, is_returned, status, branch_id
     `
-	row = l.DB.UpdateRecord(ctx, updateQuery, loanUpdate.ReturnDate, loanUpdate.IsReturn, bookID, borrowerName)
+	row = l.DB.UpdateRecord(ctx, updateQuery, loanUpdate.ReturnDate, loanUpdate.IsReturn, loanUpdate.Status, bookID, borrowerName)
 	if row == nil {
 		return nil, sql.ErrNoRows
 	}
 
 	var updatedLoan models.Loan
-	err = row.Scan(&updatedLoan.Id, &updatedLoan.BookId, &updatedLoan.BorrowerName, &updatedLoan.LoanDate, &updatedLoan.ReturnDate, &updatedLoan.IsReturn)
+	err = row.Scan(&updatedLoan.Id, &updatedLoan.BookId, &updatedLoan.BorrowerName, &updatedLoan.LoanDate, &updatedLoan.ReturnDate, &updatedLoan.IsReturn, &updatedLoan.Status, &updatedLoan.BranchId)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrLoanNotFound
@@ -129,3 +130,133 @@ func (l *LoanRepositoryDB) DeleteLoan(ctx context.Context, title string, borrowe
 
 	return nil
 }
+
+func (l *LoanRepositoryDB) GetLoanById(ctx context.Context, id int) (*models.Loan, error) {
+	query := `
+        SELECT id, book_id, borrower_name, loan_date, return_date, is_returned, status, branch_id
+        FROM loans
+        WHERE id = $1
+    `
+	row := l.DB.GetRecord(ctx, query, id)
+
+	var loan models.Loan
+	if err := row.Scan(&loan.Id, &loan.BookId, &loan.BorrowerName, &loan.LoanDate, &loan.ReturnDate, &loan.IsReturn, &loan.Status, &loan.BranchId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrLoanNotFound
+		}
+		return nil, err
+	}
+	return &loan, nil
+}
+
+func (l *LoanRepositoryDB) UpdateLoanById(ctx context.Context, id int, loanUpdate *models.LoanUpdate) (*models.Loan, error) {
+	updateQuery := `
+        UPDATE loans
+        SET return_date = COALESCE($1, return_date), is_returned = COALESCE($2, is_returned), status = COALESCE($3, status)
+        WHERE id = $4
+        RETURNING id, book_id, borrower_name, loan_date, return_date, is_returned, status, branch_id
+    `
+	row := l.DB.UpdateRecord(ctx, updateQuery, loanUpdate.ReturnDate, loanUpdate.IsReturn, loanUpdate.Status, id)
+
+	var updatedLoan models.Loan
+	err := row.Scan(&updatedLoan.Id, &updatedLoan.BookId, &updatedLoan.BorrowerName, &updatedLoan.LoanDate, &updatedLoan.ReturnDate, &updatedLoan.IsReturn, &updatedLoan.Status, &updatedLoan.BranchId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrLoanNotFound
+		}
+		return nil, fmt.Errorf("error updating loan %d: %w", id, err)
+	}
+	return &updatedLoan, nil
+}
+
+func (l *LoanRepositoryDB) ListLoans(ctx context.Context, filter *models.LoanFilter) ([]models.Loan, int, error) {
+	conditions := make([]string, 0)
+	args := make([]interface{}, 0)
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if filter.BorrowerName != "" {
+		addCondition("borrower_name = $%d", filter.BorrowerName)
+	}
+	if filter.BookId != 0 {
+		addCondition("book_id = $%d", filter.BookId)
+	}
+	if filter.Status != "" {
+		addCondition("status = $%d", filter.Status)
+	}
+	if filter.Overdue {
+		addCondition("is_returned = FALSE AND return_date < $%d", filter.AsOf)
+	}
+	if !filter.LoanDateFrom.IsZero() {
+		addCondition("loan_date >= $%d", filter.LoanDateFrom)
+	}
+	if !filter.LoanDateTo.IsZero() {
+		addCondition("loan_date < $%d", filter.LoanDateTo.AddDate(0, 0, 1))
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	countQuery := "SELECT COUNT(*) FROM loans " + where
+	if err := l.DB.GetRecord(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("error counting loans: %w", err)
+	}
+
+	query := fmt.Sprintf(`
+        SELECT id, book_id, borrower_name, loan_date, return_date, is_returned, status, branch_id
+        FROM loans
+        %s
+        ORDER BY id
+        LIMIT $%d OFFSET $%d
+    `, where, len(args)+1, len(args)+2)
+	rows, err := l.DB.GetRecords(ctx, query, append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error fetching loans: %w", err)
+	}
+	defer rows.Close()
+
+	loans := make([]models.Loan, 0)
+	for rows.Next() {
+		var loan models.Loan
+		if err := rows.Scan(&loan.Id, &loan.BookId, &loan.BorrowerName, &loan.LoanDate, &loan.ReturnDate, &loan.IsReturn, &loan.Status, &loan.BranchId); err != nil {
+			return nil, 0, err
+		}
+		loans = append(loans, loan)
+	}
+	return loans, total, rows.Err()
+}
+
+func (l *LoanRepositoryDB) AnonymizeLoans(ctx context.Context, borrowerName string) (int, error) {
+	updateQuery := "UPDATE loans SET borrower_name = $1 WHERE borrower_name = $2 AND status = $3"
+	result, err := l.DB.UpdateRecords(ctx, updateQuery, models.AnonymizedBorrower, borrowerName, models.LoanStatusReturned)
+	if err != nil {
+		return 0, fmt.Errorf("error anonymizing loans of %s: %w", borrowerName, err)
+	}
+	count, err := result.RowsAffected()
+	return int(count), err
+}
+
+func (l *LoanRepositoryDB) SaveLoan(ctx context.Context, title string, loan *models.Loan) (*models.Loan, error) {
+	upsertQuery := `
+        INSERT INTO loans (id, book_id, borrower_name, loan_date, return_date, is_returned, status, branch_id)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        ON CONFLICT (id) DO UPDATE
+        SET book_id = EXCLUDED.book_id, borrower_name = EXCLUDED.borrower_name, loan_date = EXCLUDED.loan_date,
+            return_date = EXCLUDED.return_date, is_returned = EXCLUDED.is_returned, status = EXCLUDED.status, branch_id = EXCLUDED.branch_id
+        RETURNING id, book_id, borrower_name, loan_date, return_date, is_returned, status, branch_id
+    `
+	row := l.DB.CreateRecord(ctx, upsertQuery, loan.Id, loan.BookId, loan.BorrowerName, loan.LoanDate, loan.ReturnDate, loan.IsReturn, loan.Status, loan.BranchId)
+	var savedLoan models.Loan
+	if err := row.Scan(&savedLoan.Id, &savedLoan.BookId, &savedLoan.BorrowerName, &savedLoan.LoanDate, &savedLoan.ReturnDate, &savedLoan.IsReturn, &savedLoan.Status, &savedLoan.BranchId); err != nil {
+		return nil, fmt.Errorf("error saving loan %d: %w", loan.Id, err)
+	}
+
+	//a loan inserted with its id must not be handed out again by the sequence, which is shared by all tenants
+	if err := l.DB.GetRecord(ctx, "SELECT setval('loans_id_seq', GREATEST(last_value, $1)) FROM loans_id_seq", loan.Id).Scan(new(int64)); err != nil {
+		return nil, fmt.Errorf("error advancing loan ids past %d: %w", loan.Id, err)
+	}
+	return &savedLoan, nil
+}
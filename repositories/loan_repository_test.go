@@ -126,3 +126,78 @@ func TestLoanRepository_DeleteLoan(t *testing.T) {
 		assert.Equal(t, ErrLoanNotFound, err)
 	})
 }
+
+func TestLoanRepository_GetLoanById(t *testing.T) {
+	repo := NewLoanRepository()
+	ctx := context.Background()
+
+	first, err := repo.CreateLoan(ctx, "book1", &models.Loan{BorrowerName: "user5", ReturnDate: time.Now()})
+	assert.NoError(t, err)
+	second, err := repo.CreateLoan(ctx, "book2", &models.Loan{BorrowerName: "user5", ReturnDate: time.Now()})
+	assert.NoError(t, err)
+
+	t.Run("Loan ids are unique across titles", func(t *testing.T) {
+		assert.NotEqual(t, first.Id, second.Id)
+
+		loan, err := repo.GetLoanById(ctx, second.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, models.LoanStatusActive, loan.Status)
+	})
+
+	t.Run("Update loan by id", func(t *testing.T) {
+		status := models.LoanStatusLost
+		loan, err := repo.UpdateLoanById(ctx, first.Id, &models.LoanUpdate{Status: &status})
+		assert.NoError(t, err)
+		assert.Equal(t, models.LoanStatusLost, loan.Status)
+	})
+
+	t.Run("Get non-existent loan", func(t *testing.T) {
+		_, err := repo.GetLoanById(ctx, 100)
+		assert.Equal(t, ErrLoanNotFound, err)
+	})
+}
+
+func TestLoanRepository_ListLoans(t *testing.T) {
+	repo := NewLoanRepository()
+	ctx := context.Background()
+
+	currTime := time.Now()
+	_, _ = repo.CreateLoan(ctx, "book1", &models.Loan{BookId: 1, BorrowerName: "user1", LoanDate: currTime.AddDate(0, 0, -40), ReturnDate: currTime.AddDate(0, 0, -12)})
+	_, _ = repo.CreateLoan(ctx, "book2", &models.Loan{BookId: 2, BorrowerName: "user1", LoanDate: currTime, ReturnDate: currTime.AddDate(0, 0, 28)})
+	_, _ = repo.CreateLoan(ctx, "book2", &models.Loan{BookId: 2, BorrowerName: "user2", LoanDate: currTime, ReturnDate: currTime.AddDate(0, 0, 28), IsReturn: true, Status: models.LoanStatusReturned})
+
+	t.Run("Filter by borrower", func(t *testing.T) {
+		loans, total, err := repo.ListLoans(ctx, &models.LoanFilter{BorrowerName: "user1", Limit: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, total)
+		assert.Len(t, loans, 2)
+	})
+
+	t.Run("Filter by book and status", func(t *testing.T) {
+		loans, total, err := repo.ListLoans(ctx, &models.LoanFilter{BookId: 2, Status: models.LoanStatusReturned, Limit: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, total)
+		assert.Equal(t, "user2", loans[0].BorrowerName)
+	})
+
+	t.Run("Filter overdue loans", func(t *testing.T) {
+		loans, total, err := repo.ListLoans(ctx, &models.LoanFilter{Overdue: true, Limit: 10, AsOf: currTime})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, total)
+		assert.Equal(t, 1, loans[0].BookId)
+	})
+
+	t.Run("Filter by loan date range", func(t *testing.T) {
+		_, total, err := repo.ListLoans(ctx, &models.LoanFilter{LoanDateFrom: currTime.AddDate(0, 0, -1), LoanDateTo: currTime, Limit: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, total)
+	})
+
+	t.Run("Paginate", func(t *testing.T) {
+		loans, total, err := repo.ListLoans(ctx, &models.LoanFilter{Limit: 2, Offset: 2})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, total)
+		assert.Len(t, loans, 1)
+		assert.Equal(t, 3, loans[0].Id)
+	})
+}
@@ -0,0 +1,137 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"github.com/aftaab60/e-library-api/models"
+	"strings"
+	"sync"
+)
+
+type IMemberRepository interface {
+	GetMember(ctx context.Context, id int) (*models.Member, error)
+	GetMemberByName(ctx context.Context, name string) (*models.Member, error)
+	GetMemberByEmail(ctx context.Context, email string) (*models.Member, error)
+	CreateMember(ctx context.Context, member *models.Member) (*models.Member, error)
+	// UpdateMemberPreferences sets the preferences that are set, the others are kept
+	UpdateMemberPreferences(ctx context.Context, id int, preferences *models.MemberPreferences) (*models.Member, error)
+	// UpdateMemberStanding sets the standing that is set, the rest is kept
+	UpdateMemberStanding(ctx context.Context, id int, standing *models.MemberStanding) (*models.Member, error)
+}
+
+type MemberRepository struct {
+	members map[int]*models.Member
+	mutex   sync.RWMutex
+}
+
+func NewMemberRepository() *MemberRepository {
+	repo := &MemberRepository{
+		members: make(map[int]*models.Member),
+	}
+	repo.initMemberRepository()
+	return repo
+}
+
+// initialise some members by default at launch
+func (mr *MemberRepository) initMemberRepository() {
+	mr.mutex.Lock()
+	defer mr.mutex.Unlock()
+
+	members := []models.Member{
+		{Id: 1, Name: "user1", RetainHistory: true, DueReminders: true, OverdueNotices: true},
+		{Id: 2, Name: "user2", RetainHistory: true, DueReminders: true, OverdueNotices: true},
+	}
+	for _, member := range members {
+		mr.members[member.Id] = &member
+	}
+}
+
+// ErrMemberNotFound is returned when a member is not found
+var ErrMemberNotFound = errors.New("member not found")
+
+// ErrExistingMember is returned when a member with the same name exists
+var ErrExistingMember = errors.New("existing member")
+
+func (mr *MemberRepository) GetMember(ctx context.Context, id int) (*models.Member, error) {
+	mr.mutex.RLock()
+	defer mr.mutex.RUnlock()
+
+	member, ok := mr.members[id]
+	if !ok {
+		return nil, ErrMemberNotFound
+	}
+	memberCopy := *member
+	return &memberCopy, nil
+}
+
+func (mr *MemberRepository) GetMemberByName(ctx context.Context, name string) (*models.Member, error) {
+	mr.mutex.RLock()
+	defer mr.mutex.RUnlock()
+
+	for _, member := range mr.members {
+		if member.Name == name {
+			memberCopy := *member
+			return &memberCopy, nil
+		}
+	}
+	return nil, ErrMemberNotFound
+}
+
+func (mr *MemberRepository) GetMemberByEmail(ctx context.Context, email string) (*models.Member, error) {
+	mr.mutex.RLock()
+	defer mr.mutex.RUnlock()
+
+	for _, member := range mr.members {
+		if member.Email != "" && strings.EqualFold(member.Email, email) {
+			memberCopy := *member
+			return &memberCopy, nil
+		}
+	}
+	return nil, ErrMemberNotFound
+}
+
+func (mr *MemberRepository) CreateMember(ctx context.Context, member *models.Member) (*models.Member, error) {
+	mr.mutex.Lock()
+	defer mr.mutex.Unlock()
+
+	maxId := 0
+	for id, m := range mr.members {
+		if m.Name == member.Name || (member.Email != "" && strings.EqualFold(m.Email, member.Email)) ||
+			(member.CardNumber != "" && m.CardNumber == member.CardNumber) {
+			return nil, ErrExistingMember
+		}
+		if id > maxId {
+			maxId = id
+		}
+	}
+	member.Id = maxId + 1 //incremental id
+	createdMember := *member
+	mr.members[member.Id] = &createdMember
+	return member, nil
+}
+
+func (mr *MemberRepository) UpdateMemberPreferences(ctx context.Context, id int, preferences *models.MemberPreferences) (*models.Member, error) {
+	mr.mutex.Lock()
+	defer mr.mutex.Unlock()
+
+	member, ok := mr.members[id]
+	if !ok {
+		return nil, ErrMemberNotFound
+	}
+	preferences.Apply(member)
+	memberCopy := *member
+	return &memberCopy, nil
+}
+
+func (mr *MemberRepository) UpdateMemberStanding(ctx context.Context, id int, standing *models.MemberStanding) (*models.Member, error) {
+	mr.mutex.Lock()
+	defer mr.mutex.Unlock()
+
+	member, ok := mr.members[id]
+	if !ok {
+		return nil, ErrMemberNotFound
+	}
+	standing.Apply(member)
+	memberCopy := *member
+	return &memberCopy, nil
+}
@@ -0,0 +1,88 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/aftaab60/e-library-api/internal/db_manager"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/lib/pq"
+)
+
+type MemberRepositoryDB struct {
+	DB *db_manager.DB
+}
+
+func NewMemberRepositoryDB(db *db_manager.DB) *MemberRepositoryDB {
+	return &MemberRepositoryDB{DB: db}
+}
+
+func scanMember(row *sql.Row) (*models.Member, error) {
+	var member models.Member
+	if err := row.Scan(&member.Id, &member.Name, &member.Email, &member.RetainHistory, &member.DueReminders, &member.OverdueNotices,
+		&member.Suspended, &member.ExpiresOn, &member.CardNumber); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrMemberNotFound
+		}
+		return nil, err
+	}
+	return &member, nil
+}
+
+func (mr *MemberRepositoryDB) GetMember(ctx context.Context, id int) (*models.Member, error) {
+	query := "SELECT id, name, COALESCE(email, ''), retain_history, due_reminders, overdue_notices, suspended, COALESCE(to_char(expires_on, 'YYYY-MM-DD'), ''), COALESCE(card_number, '') FROM members WHERE id = $1"
+	return scanMember(mr.DB.GetRecord(ctx, query, id))
+}
+
+func (mr *MemberRepositoryDB) GetMemberByName(ctx context.Context, name string) (*models.Member, error) {
+	query := "SELECT id, name, COALESCE(email, ''), retain_history, due_reminders, overdue_notices, suspended, COALESCE(to_char(expires_on, 'YYYY-MM-DD'), ''), COALESCE(card_number, '') FROM members WHERE name = $1"
+	return scanMember(mr.DB.GetRecord(ctx, query, name))
+}
+
+func (mr *MemberRepositoryDB) GetMemberByEmail(ctx context.Context, email string) (*models.Member, error) {
+	query := "SELECT id, name, COALESCE(email, ''), retain_history, due_reminders, overdue_notices, suspended, COALESCE(to_char(expires_on, 'YYYY-MM-DD'), ''), COALESCE(card_number, '') FROM members WHERE lower(email) = lower($1)"
+	return scanMember(mr.DB.GetRecord(ctx, query, email))
+}
+
+func (mr *MemberRepositoryDB) CreateMember(ctx context.Context, member *models.Member) (*models.Member, error) {
+	insertQuery := `
+        INSERT INTO members (name, email, retain_history, due_reminders, overdue_notices, card_number)
+        VALUES ($1, NULLIF($2, ''), $3, $4, $5, NULLIF($6, ''))
+        RETURNING id, name, COALESCE(email, ''), retain_history, due_reminders, overdue_notices, suspended, COALESCE(to_char(expires_on, 'YYYY-MM-DD'), ''), COALESCE(card_number, '')
+    `
+	createdMember, err := scanMember(mr.DB.CreateRecord(ctx, insertQuery, member.Name, member.Email, member.RetainHistory, member.DueReminders, member.OverdueNotices,
+		member.CardNumber))
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" { //unique_violation
+			return nil, ErrExistingMember
+		}
+		return nil, fmt.Errorf("error creating member %s: %w", member.Name, err)
+	}
+	return createdMember, nil
+}
+
+func (mr *MemberRepositoryDB) UpdateMemberPreferences(ctx context.Context, id int, preferences *models.MemberPreferences) (*models.Member, error) {
+	updateQuery := `
+        UPDATE members
+        SET retain_history = COALESCE($1, retain_history),
+            due_reminders = COALESCE($2, due_reminders),
+            overdue_notices = COALESCE($3, overdue_notices)
+        WHERE id = $4
+        RETURNING id, name, COALESCE(email, ''), retain_history, due_reminders, overdue_notices, suspended, COALESCE(to_char(expires_on, 'YYYY-MM-DD'), ''), COALESCE(card_number, '')
+    `
+	return scanMember(mr.DB.UpdateRecord(ctx, updateQuery, preferences.RetainHistory, preferences.DueReminders, preferences.OverdueNotices, id))
+}
+
+func (mr *MemberRepositoryDB) UpdateMemberStanding(ctx context.Context, id int, standing *models.MemberStanding) (*models.Member, error) {
+	//an empty expires_on removes the expiry, a NULL one keeps it
+	updateQuery := `
+        UPDATE members
+        SET suspended = COALESCE($1, suspended),
+            expires_on = CASE WHEN $2::text IS NULL THEN expires_on ELSE NULLIF($2, '')::date END
+        WHERE id = $3
+        RETURNING id, name, COALESCE(email, ''), retain_history, due_reminders, overdue_notices, suspended, COALESCE(to_char(expires_on, 'YYYY-MM-DD'), ''), COALESCE(card_number, '')
+    `
+	return scanMember(mr.DB.UpdateRecord(ctx, updateQuery, standing.Suspended, standing.ExpiresOn, id))
+}
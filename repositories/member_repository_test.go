@@ -0,0 +1,83 @@
+package repositories
+
+import (
+	"context"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMemberRepository_CreateMember(t *testing.T) {
+	repo := NewMemberRepository()
+	ctx := context.Background()
+
+	t.Run("Create new member", func(t *testing.T) {
+		member, err := repo.CreateMember(ctx, &models.Member{Name: "user3", RetainHistory: true})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, member.Id)
+
+		found, err := repo.GetMemberByName(ctx, "user3")
+		assert.NoError(t, err)
+		assert.Equal(t, member.Id, found.Id)
+	})
+
+	t.Run("Fail to create existing member", func(t *testing.T) {
+		_, err := repo.CreateMember(ctx, &models.Member{Name: "user1"})
+		assert.Equal(t, ErrExistingMember, err)
+	})
+}
+
+func TestMemberRepository_UpdateMemberPreferences(t *testing.T) {
+	repo := NewMemberRepository()
+	ctx := context.Background()
+
+	retainHistory, dueReminders := false, false
+	t.Run("Update existing member", func(t *testing.T) {
+		member, err := repo.UpdateMemberPreferences(ctx, 1, &models.MemberPreferences{RetainHistory: &retainHistory})
+		assert.NoError(t, err)
+		assert.False(t, member.RetainHistory)
+
+		member, _ = repo.GetMember(ctx, 1)
+		assert.False(t, member.RetainHistory)
+	})
+
+	t.Run("Preferences not set are kept", func(t *testing.T) {
+		member, err := repo.UpdateMemberPreferences(ctx, 1, &models.MemberPreferences{DueReminders: &dueReminders})
+		assert.NoError(t, err)
+		assert.False(t, member.RetainHistory)
+		assert.False(t, member.DueReminders)
+		assert.True(t, member.OverdueNotices)
+	})
+
+	t.Run("Fail to update non-existent member", func(t *testing.T) {
+		_, err := repo.UpdateMemberPreferences(ctx, 100, &models.MemberPreferences{RetainHistory: &retainHistory})
+		assert.Equal(t, ErrMemberNotFound, err)
+	})
+}
+
+func TestMemberRepository_UpdateMemberStanding(t *testing.T) {
+	repo := NewMemberRepository()
+	ctx := context.Background()
+
+	suspended, expiresOn, noExpiry := true, "2030-06-30", ""
+	t.Run("Suspend a member, the expiry is kept", func(t *testing.T) {
+		_, err := repo.UpdateMemberStanding(ctx, 1, &models.MemberStanding{ExpiresOn: &expiresOn})
+		assert.NoError(t, err)
+		member, err := repo.UpdateMemberStanding(ctx, 1, &models.MemberStanding{Suspended: &suspended})
+		assert.NoError(t, err)
+		assert.True(t, member.Suspended)
+		assert.Equal(t, expiresOn, member.ExpiresOn)
+	})
+
+	t.Run("An empty expiry removes it", func(t *testing.T) {
+		member, err := repo.UpdateMemberStanding(ctx, 1, &models.MemberStanding{ExpiresOn: &noExpiry})
+		assert.NoError(t, err)
+		assert.Empty(t, member.ExpiresOn)
+		assert.True(t, member.Suspended)
+	})
+
+	t.Run("Fail to update non-existent member", func(t *testing.T) {
+		_, err := repo.UpdateMemberStanding(ctx, 100, &models.MemberStanding{Suspended: &suspended})
+		assert.Equal(t, ErrMemberNotFound, err)
+	})
+}
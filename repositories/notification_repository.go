@@ -0,0 +1,76 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"github.com/aftaab60/e-library-api/models"
+	"sync"
+)
+
+// INotificationRepository tracks the notifications sent, so that a member is never told twice about the same due date
+type INotificationRepository interface {
+	// CreateNotification records a notification, it fails with ErrExistingNotification when the loan already has one of
+	// the kind for the due date
+	CreateNotification(ctx context.Context, notification *models.Notification) (*models.Notification, error)
+	// DeleteNotification forgets a notification that couldn't be sent, so that it is tried again
+	DeleteNotification(ctx context.Context, id int) error
+	// ListNotifications returns the notifications of a loan, oldest first
+	ListNotifications(ctx context.Context, loanId int) ([]models.Notification, error)
+}
+
+type NotificationRepository struct {
+	notifications []models.Notification
+	nextId        int
+	mutex         sync.RWMutex
+}
+
+func NewNotificationRepository() *NotificationRepository {
+	return &NotificationRepository{
+		notifications: make([]models.Notification, 0),
+	}
+}
+
+// ErrExistingNotification is returned when a loan was already notified of for the due date
+var ErrExistingNotification = errors.New("existing notification")
+
+func (nr *NotificationRepository) CreateNotification(ctx context.Context, notification *models.Notification) (*models.Notification, error) {
+	nr.mutex.Lock()
+	defer nr.mutex.Unlock()
+
+	for _, n := range nr.notifications {
+		if n.LoanId == notification.LoanId && n.Kind == notification.Kind && n.DueDate.Equal(notification.DueDate) {
+			return nil, ErrExistingNotification
+		}
+	}
+	nr.nextId++
+	createdNotification := *notification
+	createdNotification.Id = nr.nextId
+	nr.notifications = append(nr.notifications, createdNotification)
+	return &createdNotification, nil
+}
+
+func (nr *NotificationRepository) DeleteNotification(ctx context.Context, id int) error {
+	nr.mutex.Lock()
+	defer nr.mutex.Unlock()
+
+	for i, n := range nr.notifications {
+		if n.Id == id {
+			nr.notifications = append(nr.notifications[:i], nr.notifications[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (nr *NotificationRepository) ListNotifications(ctx context.Context, loanId int) ([]models.Notification, error) {
+	nr.mutex.RLock()
+	defer nr.mutex.RUnlock()
+
+	notifications := make([]models.Notification, 0)
+	for _, n := range nr.notifications {
+		if n.LoanId == loanId {
+			notifications = append(notifications, n)
+		}
+	}
+	return notifications, nil
+}
@@ -0,0 +1,68 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aftaab60/e-library-api/internal/db_manager"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/lib/pq"
+)
+
+type NotificationRepositoryDB struct {
+	DB *db_manager.DB
+}
+
+func NewNotificationRepositoryDB(db *db_manager.DB) *NotificationRepositoryDB {
+	return &NotificationRepositoryDB{DB: db}
+}
+
+func (nr *NotificationRepositoryDB) CreateNotification(ctx context.Context, notification *models.Notification) (*models.Notification, error) {
+	insertQuery := `
+        INSERT INTO notifications (loan_id, member_id, kind, due_date, recipient, sent_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING id
+    `
+	createdNotification := *notification
+	row := nr.DB.CreateRecord(ctx, insertQuery, notification.LoanId, notification.MemberId, notification.Kind, notification.DueDate,
+		notification.Recipient, notification.SentAt)
+	if err := row.Scan(&createdNotification.Id); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" { //unique_violation
+			return nil, ErrExistingNotification
+		}
+		return nil, fmt.Errorf("error creating %s notification of loan %d: %w", notification.Kind, notification.LoanId, err)
+	}
+	return &createdNotification, nil
+}
+
+func (nr *NotificationRepositoryDB) DeleteNotification(ctx context.Context, id int) error {
+	if _, err := nr.DB.DeleteRecord(ctx, "DELETE FROM notifications WHERE id = $1", id); err != nil {
+		return fmt.Errorf("error deleting notification %d: %w", id, err)
+	}
+	return nil
+}
+
+func (nr *NotificationRepositoryDB) ListNotifications(ctx context.Context, loanId int) ([]models.Notification, error) {
+	query := `
+        SELECT id, loan_id, member_id, kind, due_date, recipient, sent_at
+        FROM notifications
+        WHERE loan_id = $1
+        ORDER BY id
+    `
+	rows, err := nr.DB.GetRecords(ctx, query, loanId)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching notifications of loan %d: %w", loanId, err)
+	}
+	defer rows.Close()
+
+	notifications := make([]models.Notification, 0)
+	for rows.Next() {
+		var n models.Notification
+		if err := rows.Scan(&n.Id, &n.LoanId, &n.MemberId, &n.Kind, &n.DueDate, &n.Recipient, &n.SentAt); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
@@ -0,0 +1,40 @@
+package repositories
+
+import (
+	"context"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestNotificationRepository_CreateNotification(t *testing.T) {
+	repo := NewNotificationRepository()
+	ctx := context.Background()
+	dueDate := time.Now().AddDate(0, 0, 2)
+
+	notification, err := repo.CreateNotification(ctx, &models.Notification{LoanId: 1, MemberId: 1, Kind: models.NotificationDueSoon, DueDate: dueDate, SentAt: time.Now()})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, notification.Id)
+
+	t.Run("One notification of a kind per due date", func(t *testing.T) {
+		_, err := repo.CreateNotification(ctx, &models.Notification{LoanId: 1, MemberId: 1, Kind: models.NotificationDueSoon, DueDate: dueDate, SentAt: time.Now()})
+		assert.Equal(t, ErrExistingNotification, err)
+
+		_, err = repo.CreateNotification(ctx, &models.Notification{LoanId: 1, MemberId: 1, Kind: models.NotificationOverdue, DueDate: dueDate, SentAt: time.Now()})
+		assert.NoError(t, err)
+		//an extended loan is due soon again
+		_, err = repo.CreateNotification(ctx, &models.Notification{LoanId: 1, MemberId: 1, Kind: models.NotificationDueSoon, DueDate: dueDate.AddDate(0, 0, 21), SentAt: time.Now()})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Deleted notifications can be created again", func(t *testing.T) {
+		assert.NoError(t, repo.DeleteNotification(ctx, notification.Id))
+		_, err := repo.CreateNotification(ctx, &models.Notification{LoanId: 1, MemberId: 1, Kind: models.NotificationDueSoon, DueDate: dueDate, SentAt: time.Now()})
+		assert.NoError(t, err)
+
+		notifications, err := repo.ListNotifications(ctx, 1)
+		assert.NoError(t, err)
+		assert.Len(t, notifications, 3)
+	})
+}
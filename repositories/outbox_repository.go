@@ -0,0 +1,66 @@
+package repositories
+
+import (
+	"context"
+	"github.com/aftaab60/e-library-api/models"
+	"sync"
+	"time"
+)
+
+// IOutboxRepository stores domain events until they are dispatched to webhooks
+type IOutboxRepository interface {
+	CreateOutboxMessage(ctx context.Context, message *models.OutboxMessage) (*models.OutboxMessage, error)
+	// ListPendingOutboxMessages returns up to limit messages not dispatched yet, oldest first.
+	// Within a transaction the messages are locked, concurrent dispatchers skip them.
+	ListPendingOutboxMessages(ctx context.Context, limit int) ([]models.OutboxMessage, error)
+	MarkOutboxMessageDispatched(ctx context.Context, id int, dispatchedAt time.Time) error
+}
+
+type OutboxRepository struct {
+	messages []models.OutboxMessage
+	mutex    sync.RWMutex
+}
+
+func NewOutboxRepository() *OutboxRepository {
+	return &OutboxRepository{
+		messages: make([]models.OutboxMessage, 0),
+	}
+}
+
+func (or *OutboxRepository) CreateOutboxMessage(ctx context.Context, message *models.OutboxMessage) (*models.OutboxMessage, error) {
+	or.mutex.Lock()
+	defer or.mutex.Unlock()
+
+	createdMessage := *message
+	createdMessage.Id = len(or.messages) + 1
+	or.messages = append(or.messages, createdMessage)
+	return &createdMessage, nil
+}
+
+func (or *OutboxRepository) ListPendingOutboxMessages(ctx context.Context, limit int) ([]models.OutboxMessage, error) {
+	or.mutex.RLock()
+	defer or.mutex.RUnlock()
+
+	messages := make([]models.OutboxMessage, 0)
+	for _, message := range or.messages {
+		if len(messages) == limit {
+			break
+		}
+		if message.DispatchedAt == nil {
+			messages = append(messages, message)
+		}
+	}
+	return messages, nil
+}
+
+func (or *OutboxRepository) MarkOutboxMessageDispatched(ctx context.Context, id int, dispatchedAt time.Time) error {
+	or.mutex.Lock()
+	defer or.mutex.Unlock()
+
+	//ids are positions in the slice, starting at 1
+	if id < 1 || id > len(or.messages) {
+		return nil
+	}
+	or.messages[id-1].DispatchedAt = &dispatchedAt
+	return nil
+}
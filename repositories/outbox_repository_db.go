@@ -0,0 +1,66 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"github.com/aftaab60/e-library-api/internal/db_manager"
+	"github.com/aftaab60/e-library-api/models"
+	"time"
+)
+
+type OutboxRepositoryDB struct {
+	DB *db_manager.DB
+}
+
+func NewOutboxRepositoryDB(db *db_manager.DB) *OutboxRepositoryDB {
+	return &OutboxRepositoryDB{DB: db}
+}
+
+func (or *OutboxRepositoryDB) CreateOutboxMessage(ctx context.Context, message *models.OutboxMessage) (*models.OutboxMessage, error) {
+	insertQuery := `
+        INSERT INTO outbox_messages (event_type, payload, created_at)
+        VALUES ($1, $2, $3)
+        RETURNING id
+    `
+	createdMessage := *message
+	if err := or.DB.CreateRecord(ctx, insertQuery, message.EventType, []byte(message.Payload), message.CreatedAt).Scan(&createdMessage.Id); err != nil {
+		return nil, fmt.Errorf("error creating %s outbox message: %w", message.EventType, err)
+	}
+	return &createdMessage, nil
+}
+
+func (or *OutboxRepositoryDB) ListPendingOutboxMessages(ctx context.Context, limit int) ([]models.OutboxMessage, error) {
+	query := `
+        SELECT id, event_type, payload, created_at
+        FROM outbox_messages
+        WHERE dispatched_at IS NULL
+        ORDER BY id
+        LIMIT $1
+        FOR UPDATE SKIP LOCKED
+    `
+	rows, err := or.DB.GetRecords(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching pending outbox messages: %w", err)
+	}
+	defer rows.Close()
+
+	messages := make([]models.OutboxMessage, 0)
+	for rows.Next() {
+		var message models.OutboxMessage
+		var payload []byte
+		if err := rows.Scan(&message.Id, &message.EventType, &payload, &message.CreatedAt); err != nil {
+			return nil, err
+		}
+		message.Payload = payload
+		messages = append(messages, message)
+	}
+	return messages, rows.Err()
+}
+
+func (or *OutboxRepositoryDB) MarkOutboxMessageDispatched(ctx context.Context, id int, dispatchedAt time.Time) error {
+	updateQuery := "UPDATE outbox_messages SET dispatched_at = $1 WHERE id = $2"
+	if _, err := or.DB.UpdateRecords(ctx, updateQuery, dispatchedAt, id); err != nil {
+		return fmt.Errorf("error marking outbox message %d dispatched: %w", id, err)
+	}
+	return nil
+}
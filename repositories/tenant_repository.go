@@ -0,0 +1,107 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"github.com/aftaab60/e-library-api/internal/tenant"
+	"github.com/aftaab60/e-library-api/models"
+	"sort"
+	"sync"
+)
+
+type ITenantRepository interface {
+	GetTenant(ctx context.Context, id int) (*models.Tenant, error)
+	GetTenantBySlug(ctx context.Context, slug string) (*models.Tenant, error)
+	GetTenantByApiKeyHash(ctx context.Context, apiKeyHash string) (*models.Tenant, error)
+	UpdateTenantPolicy(ctx context.Context, id int, policy models.LoanPolicy) (*models.Tenant, error)
+	// ListTenants returns every tenant ordered by id, background jobs use it to work through each tenant in turn
+	ListTenants(ctx context.Context) ([]models.Tenant, error)
+}
+
+type TenantRepository struct {
+	tenants map[int]*models.Tenant
+	mutex   sync.RWMutex
+}
+
+func NewTenantRepository() *TenantRepository {
+	repo := &TenantRepository{
+		tenants: make(map[int]*models.Tenant),
+	}
+	repo.initTenantRepository()
+	return repo
+}
+
+// initialise some tenants by default at launch
+func (tr *TenantRepository) initTenantRepository() {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+
+	tenants := []models.Tenant{
+		{Id: 1, Slug: "default", Name: "Default Library", ApiKeyHash: tenant.HashApiKey("default-library-key"), DefaultBranchId: models.DefaultBranchId, Policy: models.DefaultLoanPolicy},
+		{Id: 2, Slug: "city", Name: "City Library", ApiKeyHash: tenant.HashApiKey("city-library-key"), DefaultBranchId: models.DefaultBranchId, Policy: models.DefaultLoanPolicy},
+	}
+	for _, t := range tenants {
+		tr.tenants[t.Id] = &t
+	}
+}
+
+// ErrTenantNotFound is returned when a tenant is not found
+var ErrTenantNotFound = errors.New("tenant not found")
+
+func (tr *TenantRepository) GetTenant(ctx context.Context, id int) (*models.Tenant, error) {
+	tr.mutex.RLock()
+	defer tr.mutex.RUnlock()
+
+	t, ok := tr.tenants[id]
+	if !ok {
+		return nil, ErrTenantNotFound
+	}
+	tenantCopy := *t
+	return &tenantCopy, nil
+}
+
+func (tr *TenantRepository) GetTenantBySlug(ctx context.Context, slug string) (*models.Tenant, error) {
+	return tr.find(func(t *models.Tenant) bool { return t.Slug == slug })
+}
+
+func (tr *TenantRepository) GetTenantByApiKeyHash(ctx context.Context, apiKeyHash string) (*models.Tenant, error) {
+	return tr.find(func(t *models.Tenant) bool { return t.ApiKeyHash != "" && t.ApiKeyHash == apiKeyHash })
+}
+
+func (tr *TenantRepository) UpdateTenantPolicy(ctx context.Context, id int, policy models.LoanPolicy) (*models.Tenant, error) {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+
+	t, ok := tr.tenants[id]
+	if !ok {
+		return nil, ErrTenantNotFound
+	}
+	t.Policy = policy
+	tenantCopy := *t
+	return &tenantCopy, nil
+}
+
+func (tr *TenantRepository) ListTenants(ctx context.Context) ([]models.Tenant, error) {
+	tr.mutex.RLock()
+	defer tr.mutex.RUnlock()
+
+	tenants := make([]models.Tenant, 0, len(tr.tenants))
+	for _, t := range tr.tenants {
+		tenants = append(tenants, *t)
+	}
+	sort.Slice(tenants, func(i, j int) bool { return tenants[i].Id < tenants[j].Id })
+	return tenants, nil
+}
+
+func (tr *TenantRepository) find(match func(t *models.Tenant) bool) (*models.Tenant, error) {
+	tr.mutex.RLock()
+	defer tr.mutex.RUnlock()
+
+	for _, t := range tr.tenants {
+		if match(t) {
+			tenantCopy := *t
+			return &tenantCopy, nil
+		}
+	}
+	return nil, ErrTenantNotFound
+}
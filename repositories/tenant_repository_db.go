@@ -0,0 +1,84 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/aftaab60/e-library-api/internal/db_manager"
+	"github.com/aftaab60/e-library-api/models"
+)
+
+// TenantRepositoryDB reads the tenants table, which is the only table not protected by row level security
+type TenantRepositoryDB struct {
+	DB *db_manager.DB
+}
+
+func NewTenantRepositoryDB(db *db_manager.DB) *TenantRepositoryDB {
+	return &TenantRepositoryDB{DB: db}
+}
+
+const tenantColumns = "id, slug, name, COALESCE(api_key_hash, ''), default_branch_id, loan_period_days, extension_days, max_renewals, replacement_fee, damage_fee, overdue_fine, max_loans, fine_limit"
+
+func scanTenant(row scanner) (*models.Tenant, error) {
+	var t models.Tenant
+	err := row.Scan(&t.Id, &t.Slug, &t.Name, &t.ApiKeyHash, &t.DefaultBranchId,
+		&t.Policy.LoanPeriodDays, &t.Policy.ExtensionDays, &t.Policy.MaxRenewals, &t.Policy.ReplacementFee, &t.Policy.DamageFee, &t.Policy.OverdueFine,
+		&t.Policy.MaxLoans, &t.Policy.FineLimit)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTenantNotFound
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (tr *TenantRepositoryDB) GetTenant(ctx context.Context, id int) (*models.Tenant, error) {
+	query := "SELECT " + tenantColumns + " FROM tenants WHERE id = $1"
+	return scanTenant(tr.DB.GetRecord(ctx, query, id))
+}
+
+func (tr *TenantRepositoryDB) GetTenantBySlug(ctx context.Context, slug string) (*models.Tenant, error) {
+	query := "SELECT " + tenantColumns + " FROM tenants WHERE slug = $1"
+	return scanTenant(tr.DB.GetRecord(ctx, query, slug))
+}
+
+func (tr *TenantRepositoryDB) GetTenantByApiKeyHash(ctx context.Context, apiKeyHash string) (*models.Tenant, error) {
+	query := "SELECT " + tenantColumns + " FROM tenants WHERE api_key_hash = $1"
+	return scanTenant(tr.DB.GetRecord(ctx, query, apiKeyHash))
+}
+
+func (tr *TenantRepositoryDB) UpdateTenantPolicy(ctx context.Context, id int, policy models.LoanPolicy) (*models.Tenant, error) {
+	updateQuery := `
+        UPDATE tenants
+        SET loan_period_days = $1, extension_days = $2, max_renewals = $3, replacement_fee = $4, damage_fee = $5, overdue_fine = $6,
+            max_loans = $7, fine_limit = $8
+        WHERE id = $9
+        RETURNING ` + tenantColumns
+	t, err := scanTenant(tr.DB.UpdateRecord(ctx, updateQuery, policy.LoanPeriodDays, policy.ExtensionDays, policy.MaxRenewals, policy.ReplacementFee, policy.DamageFee, policy.OverdueFine,
+		policy.MaxLoans, policy.FineLimit, id))
+	if err != nil && !errors.Is(err, ErrTenantNotFound) {
+		return nil, fmt.Errorf("error updating policy of tenant %d: %w", id, err)
+	}
+	return t, err
+}
+
+func (tr *TenantRepositoryDB) ListTenants(ctx context.Context) ([]models.Tenant, error) {
+	query := "SELECT " + tenantColumns + " FROM tenants ORDER BY id"
+	rows, err := tr.DB.GetRecords(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching tenants: %w", err)
+	}
+	defer rows.Close()
+
+	tenants := make([]models.Tenant, 0)
+	for rows.Next() {
+		t, err := scanTenant(rows)
+		if err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, *t)
+	}
+	return tenants, rows.Err()
+}
@@ -0,0 +1,594 @@
+package repositories
+
+import (
+	"context"
+	"github.com/aftaab60/e-library-api/internal/tenant"
+	"github.com/aftaab60/e-library-api/models"
+	"sync"
+	"time"
+)
+
+// tenantScoped keeps a separate in-memory repository per tenant, so that data of one tenant is never visible to another.
+// pgsql repositories don't need this, isolation is enforced by row level security on tenant_id.
+type tenantScoped[T any] struct {
+	newRepository func() T
+	repositories  map[int]T
+	mutex         sync.Mutex
+}
+
+func newTenantScoped[T any](newRepository func() T) *tenantScoped[T] {
+	return &tenantScoped[T]{
+		newRepository: newRepository,
+		repositories:  make(map[int]T),
+	}
+}
+
+// get returns the repository of the tenant in context, created on first use
+func (ts *tenantScoped[T]) get(ctx context.Context) T {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	tenantId := tenant.Id(ctx)
+	repo, ok := ts.repositories[tenantId]
+	if !ok {
+		repo = ts.newRepository()
+		ts.repositories[tenantId] = repo
+	}
+	return repo
+}
+
+type TenantBookRepository struct {
+	scope *tenantScoped[*BookRepository]
+}
+
+func NewTenantBookRepository() *TenantBookRepository {
+	return &TenantBookRepository{scope: newTenantScoped(NewBookRepository)}
+}
+
+func (r *TenantBookRepository) GetBook(ctx context.Context, title string) (*models.Book, error) {
+	return r.scope.get(ctx).GetBook(ctx, title)
+}
+
+func (r *TenantBookRepository) UpdateBook(ctx context.Context, title string, availableCopies int) (*models.Book, error) {
+	return r.scope.get(ctx).UpdateBook(ctx, title, availableCopies)
+}
+
+func (r *TenantBookRepository) GetBookById(ctx context.Context, id int) (*models.Book, error) {
+	return r.scope.get(ctx).GetBookById(ctx, id)
+}
+
+func (r *TenantBookRepository) UpdateBookLoanType(ctx context.Context, title string, loanType models.LoanType, loanHours int) (*models.Book, error) {
+	return r.scope.get(ctx).UpdateBookLoanType(ctx, title, loanType, loanHours)
+}
+
+func (r *TenantBookRepository) UpdateBookIsbn(ctx context.Context, title string, isbn string) (*models.Book, error) {
+	return r.scope.get(ctx).UpdateBookIsbn(ctx, title, isbn)
+}
+
+type TenantLoanRepository struct {
+	scope *tenantScoped[*LoanRepository]
+}
+
+func NewTenantLoanRepository() *TenantLoanRepository {
+	return &TenantLoanRepository{scope: newTenantScoped(NewLoanRepository)}
+}
+
+func (r *TenantLoanRepository) GetLoan(ctx context.Context, title string, borrowerName string) (*models.Loan, error) {
+	return r.scope.get(ctx).GetLoan(ctx, title, borrowerName)
+}
+
+func (r *TenantLoanRepository) CreateLoan(ctx context.Context, title string, loanDetail *models.Loan) (*models.Loan, error) {
+	return r.scope.get(ctx).CreateLoan(ctx, title, loanDetail)
+}
+
+func (r *TenantLoanRepository) UpdateLoan(ctx context.Context, title string, borrowerName string, loanUpdate *models.LoanUpdate) (*models.Loan, error) {
+	return r.scope.get(ctx).UpdateLoan(ctx, title, borrowerName, loanUpdate)
+}
+
+func (r *TenantLoanRepository) DeleteLoan(ctx context.Context, title string, borrowerName string) error {
+	return r.scope.get(ctx).DeleteLoan(ctx, title, borrowerName)
+}
+
+func (r *TenantLoanRepository) GetLoanById(ctx context.Context, id int) (*models.Loan, error) {
+	return r.scope.get(ctx).GetLoanById(ctx, id)
+}
+
+func (r *TenantLoanRepository) UpdateLoanById(ctx context.Context, id int, loanUpdate *models.LoanUpdate) (*models.Loan, error) {
+	return r.scope.get(ctx).UpdateLoanById(ctx, id, loanUpdate)
+}
+
+func (r *TenantLoanRepository) ListLoans(ctx context.Context, filter *models.LoanFilter) ([]models.Loan, int, error) {
+	return r.scope.get(ctx).ListLoans(ctx, filter)
+}
+
+func (r *TenantLoanRepository) AnonymizeLoans(ctx context.Context, borrowerName string) (int, error) {
+	return r.scope.get(ctx).AnonymizeLoans(ctx, borrowerName)
+}
+
+func (r *TenantLoanRepository) SaveLoan(ctx context.Context, title string, loan *models.Loan) (*models.Loan, error) {
+	return r.scope.get(ctx).SaveLoan(ctx, title, loan)
+}
+
+type TenantChargeRepository struct {
+	scope *tenantScoped[*ChargeRepository]
+}
+
+func NewTenantChargeRepository() *TenantChargeRepository {
+	return &TenantChargeRepository{scope: newTenantScoped(NewChargeRepository)}
+}
+
+func (r *TenantChargeRepository) CreateCharge(ctx context.Context, charge *models.Charge) (*models.Charge, error) {
+	return r.scope.get(ctx).CreateCharge(ctx, charge)
+}
+
+func (r *TenantChargeRepository) GetChargesByLoan(ctx context.Context, loanId int) ([]models.Charge, error) {
+	return r.scope.get(ctx).GetChargesByLoan(ctx, loanId)
+}
+
+func (r *TenantChargeRepository) UpdateChargeStatus(ctx context.Context, id int, status models.ChargeStatus) (*models.Charge, error) {
+	return r.scope.get(ctx).UpdateChargeStatus(ctx, id, status)
+}
+
+func (r *TenantChargeRepository) GetOutstandingAmount(ctx context.Context, borrowerName string) (int, error) {
+	return r.scope.get(ctx).GetOutstandingAmount(ctx, borrowerName)
+}
+
+type TenantBranchRepository struct {
+	scope *tenantScoped[*BranchRepository]
+}
+
+func NewTenantBranchRepository() *TenantBranchRepository {
+	return &TenantBranchRepository{scope: newTenantScoped(NewBranchRepository)}
+}
+
+func (r *TenantBranchRepository) GetBranch(ctx context.Context, id int) (*models.Branch, error) {
+	return r.scope.get(ctx).GetBranch(ctx, id)
+}
+
+func (r *TenantBranchRepository) ListBranches(ctx context.Context) ([]models.Branch, error) {
+	return r.scope.get(ctx).ListBranches(ctx)
+}
+
+func (r *TenantBranchRepository) CreateBranch(ctx context.Context, branch *models.Branch) (*models.Branch, error) {
+	return r.scope.get(ctx).CreateBranch(ctx, branch)
+}
+
+func (r *TenantBranchRepository) UpdateBranch(ctx context.Context, branch *models.Branch) (*models.Branch, error) {
+	return r.scope.get(ctx).UpdateBranch(ctx, branch)
+}
+
+func (r *TenantBranchRepository) GetStock(ctx context.Context, bookId int) ([]models.BranchStock, error) {
+	return r.scope.get(ctx).GetStock(ctx, bookId)
+}
+
+func (r *TenantBranchRepository) GetBranchStock(ctx context.Context, bookId int, branchId int) (*models.BranchStock, error) {
+	return r.scope.get(ctx).GetBranchStock(ctx, bookId, branchId)
+}
+
+func (r *TenantBranchRepository) UpdateBranchStock(ctx context.Context, stock *models.BranchStock) (*models.BranchStock, error) {
+	return r.scope.get(ctx).UpdateBranchStock(ctx, stock)
+}
+
+type TenantTransferRepository struct {
+	scope *tenantScoped[*TransferRepository]
+}
+
+func NewTenantTransferRepository() *TenantTransferRepository {
+	return &TenantTransferRepository{scope: newTenantScoped(NewTransferRepository)}
+}
+
+func (r *TenantTransferRepository) CreateTransfer(ctx context.Context, transfer *models.Transfer) (*models.Transfer, error) {
+	return r.scope.get(ctx).CreateTransfer(ctx, transfer)
+}
+
+func (r *TenantTransferRepository) GetTransfer(ctx context.Context, id int) (*models.Transfer, error) {
+	return r.scope.get(ctx).GetTransfer(ctx, id)
+}
+
+func (r *TenantTransferRepository) UpdateTransferStatus(ctx context.Context, id int, status models.TransferStatus, updatedAt time.Time) (*models.Transfer, error) {
+	return r.scope.get(ctx).UpdateTransferStatus(ctx, id, status, updatedAt)
+}
+
+func (r *TenantTransferRepository) ListTransfers(ctx context.Context, status models.TransferStatus) ([]models.Transfer, error) {
+	return r.scope.get(ctx).ListTransfers(ctx, status)
+}
+
+type TenantMemberRepository struct {
+	scope *tenantScoped[*MemberRepository]
+}
+
+func NewTenantMemberRepository() *TenantMemberRepository {
+	return &TenantMemberRepository{scope: newTenantScoped(NewMemberRepository)}
+}
+
+func (r *TenantMemberRepository) GetMember(ctx context.Context, id int) (*models.Member, error) {
+	return r.scope.get(ctx).GetMember(ctx, id)
+}
+
+func (r *TenantMemberRepository) GetMemberByName(ctx context.Context, name string) (*models.Member, error) {
+	return r.scope.get(ctx).GetMemberByName(ctx, name)
+}
+
+func (r *TenantMemberRepository) GetMemberByEmail(ctx context.Context, email string) (*models.Member, error) {
+	return r.scope.get(ctx).GetMemberByEmail(ctx, email)
+}
+
+func (r *TenantMemberRepository) CreateMember(ctx context.Context, member *models.Member) (*models.Member, error) {
+	return r.scope.get(ctx).CreateMember(ctx, member)
+}
+
+func (r *TenantMemberRepository) UpdateMemberPreferences(ctx context.Context, id int, preferences *models.MemberPreferences) (*models.Member, error) {
+	return r.scope.get(ctx).UpdateMemberPreferences(ctx, id, preferences)
+}
+
+func (r *TenantMemberRepository) UpdateMemberStanding(ctx context.Context, id int, standing *models.MemberStanding) (*models.Member, error) {
+	return r.scope.get(ctx).UpdateMemberStanding(ctx, id, standing)
+}
+
+type TenantApiKeyRepository struct {
+	scope *tenantScoped[*ApiKeyRepository]
+}
+
+func NewTenantApiKeyRepository() *TenantApiKeyRepository {
+	return &TenantApiKeyRepository{scope: newTenantScoped(NewApiKeyRepository)}
+}
+
+func (r *TenantApiKeyRepository) CreateApiKey(ctx context.Context, apiKey *models.ApiKey) (*models.ApiKey, error) {
+	return r.scope.get(ctx).CreateApiKey(ctx, apiKey)
+}
+
+func (r *TenantApiKeyRepository) GetApiKeyByHash(ctx context.Context, keyHash string) (*models.ApiKey, error) {
+	return r.scope.get(ctx).GetApiKeyByHash(ctx, keyHash)
+}
+
+func (r *TenantApiKeyRepository) ListApiKeys(ctx context.Context) ([]models.ApiKey, error) {
+	return r.scope.get(ctx).ListApiKeys(ctx)
+}
+
+func (r *TenantApiKeyRepository) RevokeApiKey(ctx context.Context, id int, revokedAt time.Time) (*models.ApiKey, error) {
+	return r.scope.get(ctx).RevokeApiKey(ctx, id, revokedAt)
+}
+
+type TenantTokenRepository struct {
+	scope *tenantScoped[*TokenRepository]
+}
+
+func NewTenantTokenRepository() *TenantTokenRepository {
+	return &TenantTokenRepository{scope: newTenantScoped(NewTokenRepository)}
+}
+
+func (r *TenantTokenRepository) CreateToken(ctx context.Context, token *models.Token) (*models.Token, error) {
+	return r.scope.get(ctx).CreateToken(ctx, token)
+}
+
+func (r *TenantTokenRepository) GetToken(ctx context.Context, id string) (*models.Token, error) {
+	return r.scope.get(ctx).GetToken(ctx, id)
+}
+
+func (r *TenantTokenRepository) RevokeToken(ctx context.Context, id string, revokedAt time.Time) (*models.Token, error) {
+	return r.scope.get(ctx).RevokeToken(ctx, id, revokedAt)
+}
+
+type TenantCredentialRepository struct {
+	scope *tenantScoped[*CredentialRepository]
+}
+
+func NewTenantCredentialRepository() *TenantCredentialRepository {
+	return &TenantCredentialRepository{scope: newTenantScoped(NewCredentialRepository)}
+}
+
+func (r *TenantCredentialRepository) GetCredential(ctx context.Context, memberId int) (*models.Credential, error) {
+	return r.scope.get(ctx).GetCredential(ctx, memberId)
+}
+
+func (r *TenantCredentialRepository) SetPassword(ctx context.Context, memberId int, passwordHash string, updatedAt time.Time) (*models.Credential, error) {
+	return r.scope.get(ctx).SetPassword(ctx, memberId, passwordHash, updatedAt)
+}
+
+func (r *TenantCredentialRepository) RecordFailedLogin(ctx context.Context, memberId int, maxFailedLogins int, lockedUntil time.Time) (*models.Credential, error) {
+	return r.scope.get(ctx).RecordFailedLogin(ctx, memberId, maxFailedLogins, lockedUntil)
+}
+
+func (r *TenantCredentialRepository) ResetFailedLogins(ctx context.Context, memberId int) error {
+	return r.scope.get(ctx).ResetFailedLogins(ctx, memberId)
+}
+
+func (r *TenantCredentialRepository) CreatePasswordReset(ctx context.Context, reset *models.PasswordReset) error {
+	return r.scope.get(ctx).CreatePasswordReset(ctx, reset)
+}
+
+func (r *TenantCredentialRepository) UsePasswordReset(ctx context.Context, tokenHash string, usedAt time.Time) (*models.PasswordReset, error) {
+	return r.scope.get(ctx).UsePasswordReset(ctx, tokenHash, usedAt)
+}
+
+type TenantIdempotencyRepository struct {
+	scope *tenantScoped[*IdempotencyRepository]
+}
+
+func NewTenantIdempotencyRepository() *TenantIdempotencyRepository {
+	return &TenantIdempotencyRepository{scope: newTenantScoped(NewIdempotencyRepository)}
+}
+
+func (r *TenantIdempotencyRepository) CreateIdempotencyRecord(ctx context.Context, record *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+	return r.scope.get(ctx).CreateIdempotencyRecord(ctx, record)
+}
+
+func (r *TenantIdempotencyRepository) GetIdempotencyRecord(ctx context.Context, key string, now time.Time) (*models.IdempotencyRecord, error) {
+	return r.scope.get(ctx).GetIdempotencyRecord(ctx, key, now)
+}
+
+func (r *TenantIdempotencyRepository) CompleteIdempotencyRecord(ctx context.Context, key string, statusCode int, contentType string, body []byte) (*models.IdempotencyRecord, error) {
+	return r.scope.get(ctx).CompleteIdempotencyRecord(ctx, key, statusCode, contentType, body)
+}
+
+func (r *TenantIdempotencyRepository) DeleteIdempotencyRecord(ctx context.Context, key string) error {
+	return r.scope.get(ctx).DeleteIdempotencyRecord(ctx, key)
+}
+
+type TenantAuditRepository struct {
+	scope *tenantScoped[*AuditRepository]
+}
+
+func NewTenantAuditRepository() *TenantAuditRepository {
+	return &TenantAuditRepository{scope: newTenantScoped(NewAuditRepository)}
+}
+
+func (r *TenantAuditRepository) CreateAuditEntry(ctx context.Context, entry *models.AuditEntry) (*models.AuditEntry, error) {
+	return r.scope.get(ctx).CreateAuditEntry(ctx, entry)
+}
+
+func (r *TenantAuditRepository) ListAuditEntries(ctx context.Context, filter *models.AuditFilter) ([]models.AuditEntry, int, error) {
+	return r.scope.get(ctx).ListAuditEntries(ctx, filter)
+}
+
+type TenantLoanEventRepository struct {
+	scope *tenantScoped[*LoanEventRepository]
+}
+
+func NewTenantLoanEventRepository() *TenantLoanEventRepository {
+	return &TenantLoanEventRepository{scope: newTenantScoped(NewLoanEventRepository)}
+}
+
+func (r *TenantLoanEventRepository) AppendLoanEvent(ctx context.Context, event *models.LoanEvent) (*models.LoanEvent, error) {
+	return r.scope.get(ctx).AppendLoanEvent(ctx, event)
+}
+
+func (r *TenantLoanEventRepository) GetLoanEvents(ctx context.Context, loanId int) ([]models.LoanEvent, error) {
+	return r.scope.get(ctx).GetLoanEvents(ctx, loanId)
+}
+
+func (r *TenantLoanEventRepository) ListLoanEvents(ctx context.Context, afterId int, limit int) ([]models.LoanEvent, error) {
+	return r.scope.get(ctx).ListLoanEvents(ctx, afterId, limit)
+}
+
+func (r *TenantLoanEventRepository) AnonymizeLoanEvents(ctx context.Context, borrowerName string) (int, error) {
+	return r.scope.get(ctx).AnonymizeLoanEvents(ctx, borrowerName)
+}
+
+type TenantOutboxRepository struct {
+	scope *tenantScoped[*OutboxRepository]
+}
+
+func NewTenantOutboxRepository() *TenantOutboxRepository {
+	return &TenantOutboxRepository{scope: newTenantScoped(NewOutboxRepository)}
+}
+
+func (r *TenantOutboxRepository) CreateOutboxMessage(ctx context.Context, message *models.OutboxMessage) (*models.OutboxMessage, error) {
+	return r.scope.get(ctx).CreateOutboxMessage(ctx, message)
+}
+
+func (r *TenantOutboxRepository) ListPendingOutboxMessages(ctx context.Context, limit int) ([]models.OutboxMessage, error) {
+	return r.scope.get(ctx).ListPendingOutboxMessages(ctx, limit)
+}
+
+func (r *TenantOutboxRepository) MarkOutboxMessageDispatched(ctx context.Context, id int, dispatchedAt time.Time) error {
+	return r.scope.get(ctx).MarkOutboxMessageDispatched(ctx, id, dispatchedAt)
+}
+
+type TenantWebhookRepository struct {
+	scope *tenantScoped[*WebhookRepository]
+}
+
+func NewTenantWebhookRepository() *TenantWebhookRepository {
+	return &TenantWebhookRepository{scope: newTenantScoped(NewWebhookRepository)}
+}
+
+func (r *TenantWebhookRepository) CreateWebhookSubscription(ctx context.Context, subscription *models.WebhookSubscription) (*models.WebhookSubscription, error) {
+	return r.scope.get(ctx).CreateWebhookSubscription(ctx, subscription)
+}
+
+func (r *TenantWebhookRepository) GetWebhookSubscriptionById(ctx context.Context, id int) (*models.WebhookSubscription, error) {
+	return r.scope.get(ctx).GetWebhookSubscriptionById(ctx, id)
+}
+
+func (r *TenantWebhookRepository) ListWebhookSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error) {
+	return r.scope.get(ctx).ListWebhookSubscriptions(ctx)
+}
+
+func (r *TenantWebhookRepository) DeleteWebhookSubscription(ctx context.Context, id int) error {
+	return r.scope.get(ctx).DeleteWebhookSubscription(ctx, id)
+}
+
+func (r *TenantWebhookRepository) CreateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) (*models.WebhookDelivery, error) {
+	return r.scope.get(ctx).CreateWebhookDelivery(ctx, delivery)
+}
+
+func (r *TenantWebhookRepository) GetWebhookDeliveryById(ctx context.Context, id int) (*models.WebhookDelivery, error) {
+	return r.scope.get(ctx).GetWebhookDeliveryById(ctx, id)
+}
+
+func (r *TenantWebhookRepository) UpdateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) (*models.WebhookDelivery, error) {
+	return r.scope.get(ctx).UpdateWebhookDelivery(ctx, delivery)
+}
+
+func (r *TenantWebhookRepository) ClaimDueWebhookDeliveries(ctx context.Context, now time.Time, until time.Time, limit int) ([]models.WebhookDelivery, error) {
+	return r.scope.get(ctx).ClaimDueWebhookDeliveries(ctx, now, until, limit)
+}
+
+func (r *TenantWebhookRepository) ListWebhookDeliveries(ctx context.Context, filter *models.WebhookDeliveryFilter) ([]models.WebhookDelivery, int, error) {
+	return r.scope.get(ctx).ListWebhookDeliveries(ctx, filter)
+}
+
+type TenantNotificationRepository struct {
+	scope *tenantScoped[*NotificationRepository]
+}
+
+func NewTenantNotificationRepository() *TenantNotificationRepository {
+	return &TenantNotificationRepository{scope: newTenantScoped(NewNotificationRepository)}
+}
+
+func (r *TenantNotificationRepository) CreateNotification(ctx context.Context, notification *models.Notification) (*models.Notification, error) {
+	return r.scope.get(ctx).CreateNotification(ctx, notification)
+}
+
+func (r *TenantNotificationRepository) DeleteNotification(ctx context.Context, id int) error {
+	return r.scope.get(ctx).DeleteNotification(ctx, id)
+}
+
+func (r *TenantNotificationRepository) ListNotifications(ctx context.Context, loanId int) ([]models.Notification, error) {
+	return r.scope.get(ctx).ListNotifications(ctx, loanId)
+}
+
+type TenantJobRunRepository struct {
+	scope *tenantScoped[*JobRunRepository]
+}
+
+func NewTenantJobRunRepository() *TenantJobRunRepository {
+	return &TenantJobRunRepository{scope: newTenantScoped(NewJobRunRepository)}
+}
+
+func (r *TenantJobRunRepository) CreateJobRun(ctx context.Context, run *models.JobRun) (*models.JobRun, error) {
+	return r.scope.get(ctx).CreateJobRun(ctx, run)
+}
+
+func (r *TenantJobRunRepository) UpdateJobRun(ctx context.Context, run *models.JobRun) (*models.JobRun, error) {
+	return r.scope.get(ctx).UpdateJobRun(ctx, run)
+}
+
+func (r *TenantJobRunRepository) DeleteJobRun(ctx context.Context, id int) error {
+	return r.scope.get(ctx).DeleteJobRun(ctx, id)
+}
+
+func (r *TenantJobRunRepository) ListJobRuns(ctx context.Context, filter *models.JobRunFilter) ([]models.JobRun, int, error) {
+	return r.scope.get(ctx).ListJobRuns(ctx, filter)
+}
+
+type TenantAutoRenewalRepository struct {
+	scope *tenantScoped[*AutoRenewalRepository]
+}
+
+func NewTenantAutoRenewalRepository() *TenantAutoRenewalRepository {
+	return &TenantAutoRenewalRepository{scope: newTenantScoped(NewAutoRenewalRepository)}
+}
+
+func (r *TenantAutoRenewalRepository) CreateAutoRenewal(ctx context.Context, renewal *models.AutoRenewal) (*models.AutoRenewal, error) {
+	return r.scope.get(ctx).CreateAutoRenewal(ctx, renewal)
+}
+
+func (r *TenantAutoRenewalRepository) ListAutoRenewals(ctx context.Context, loanId int) ([]models.AutoRenewal, error) {
+	return r.scope.get(ctx).ListAutoRenewals(ctx, loanId)
+}
+
+type TenantCalendarRepository struct {
+	scope *tenantScoped[*CalendarRepository]
+}
+
+func NewTenantCalendarRepository() *TenantCalendarRepository {
+	return &TenantCalendarRepository{scope: newTenantScoped(NewCalendarRepository)}
+}
+
+func (r *TenantCalendarRepository) ListOpeningHours(ctx context.Context, branchId int) ([]models.OpeningHours, error) {
+	return r.scope.get(ctx).ListOpeningHours(ctx, branchId)
+}
+
+func (r *TenantCalendarRepository) ReplaceOpeningHours(ctx context.Context, branchId int, hours []models.OpeningHours) error {
+	return r.scope.get(ctx).ReplaceOpeningHours(ctx, branchId, hours)
+}
+
+func (r *TenantCalendarRepository) CreateClosure(ctx context.Context, closure *models.Closure) (*models.Closure, error) {
+	return r.scope.get(ctx).CreateClosure(ctx, closure)
+}
+
+func (r *TenantCalendarRepository) GetClosureById(ctx context.Context, id int) (*models.Closure, error) {
+	return r.scope.get(ctx).GetClosureById(ctx, id)
+}
+
+func (r *TenantCalendarRepository) DeleteClosure(ctx context.Context, id int) error {
+	return r.scope.get(ctx).DeleteClosure(ctx, id)
+}
+
+func (r *TenantCalendarRepository) ListClosures(ctx context.Context, branchId int) ([]models.Closure, error) {
+	return r.scope.get(ctx).ListClosures(ctx, branchId)
+}
+
+type TenantBookingRepository struct {
+	scope *tenantScoped[*BookingRepository]
+}
+
+func NewTenantBookingRepository() *TenantBookingRepository {
+	return &TenantBookingRepository{scope: newTenantScoped(NewBookingRepository)}
+}
+
+func (r *TenantBookingRepository) CreateBooking(ctx context.Context, booking *models.Booking) (*models.Booking, error) {
+	return r.scope.get(ctx).CreateBooking(ctx, booking)
+}
+
+func (r *TenantBookingRepository) GetBookingById(ctx context.Context, id int) (*models.Booking, error) {
+	return r.scope.get(ctx).GetBookingById(ctx, id)
+}
+
+func (r *TenantBookingRepository) UpdateBooking(ctx context.Context, booking *models.Booking) (*models.Booking, error) {
+	return r.scope.get(ctx).UpdateBooking(ctx, booking)
+}
+
+func (r *TenantBookingRepository) ListBookings(ctx context.Context, bookId int, branchId int, from string, to string) ([]models.Booking, error) {
+	return r.scope.get(ctx).ListBookings(ctx, bookId, branchId, from, to)
+}
+
+type TenantCourseRepository struct {
+	scope *tenantScoped[*CourseRepository]
+}
+
+func NewTenantCourseRepository() *TenantCourseRepository {
+	return &TenantCourseRepository{scope: newTenantScoped(NewCourseRepository)}
+}
+
+func (r *TenantCourseRepository) CreateTerm(ctx context.Context, term *models.Term) (*models.Term, error) {
+	return r.scope.get(ctx).CreateTerm(ctx, term)
+}
+
+func (r *TenantCourseRepository) GetTermById(ctx context.Context, id int) (*models.Term, error) {
+	return r.scope.get(ctx).GetTermById(ctx, id)
+}
+
+func (r *TenantCourseRepository) ListTerms(ctx context.Context) ([]models.Term, error) {
+	return r.scope.get(ctx).ListTerms(ctx)
+}
+
+func (r *TenantCourseRepository) CreateCourse(ctx context.Context, course *models.Course) (*models.Course, error) {
+	return r.scope.get(ctx).CreateCourse(ctx, course)
+}
+
+func (r *TenantCourseRepository) GetCourseById(ctx context.Context, id int) (*models.Course, error) {
+	return r.scope.get(ctx).GetCourseById(ctx, id)
+}
+
+func (r *TenantCourseRepository) ListCourses(ctx context.Context, termId int) ([]models.Course, error) {
+	return r.scope.get(ctx).ListCourses(ctx, termId)
+}
+
+func (r *TenantCourseRepository) CreateReserve(ctx context.Context, reserve *models.Reserve) (*models.Reserve, error) {
+	return r.scope.get(ctx).CreateReserve(ctx, reserve)
+}
+
+func (r *TenantCourseRepository) GetReserveById(ctx context.Context, id int) (*models.Reserve, error) {
+	return r.scope.get(ctx).GetReserveById(ctx, id)
+}
+
+func (r *TenantCourseRepository) UpdateReserve(ctx context.Context, reserve *models.Reserve) (*models.Reserve, error) {
+	return r.scope.get(ctx).UpdateReserve(ctx, reserve)
+}
+
+func (r *TenantCourseRepository) ListReserves(ctx context.Context, courseId int) ([]models.Reserve, error) {
+	return r.scope.get(ctx).ListReserves(ctx, courseId)
+}
@@ -0,0 +1,42 @@
+package repositories
+
+import (
+	"context"
+	"github.com/aftaab60/e-library-api/internal/tenant"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestTenantScopedRepository_Isolation(t *testing.T) {
+	bookRepo := NewTenantBookRepository()
+	loanRepo := NewTenantLoanRepository()
+	defaultCtx := tenant.NewContext(context.Background(), &models.Tenant{Id: 1, Slug: "default"})
+	cityCtx := tenant.NewContext(context.Background(), &models.Tenant{Id: 2, Slug: "city"})
+
+	t.Run("Book update is only visible to its tenant", func(t *testing.T) {
+		_, err := bookRepo.UpdateBook(defaultCtx, "book1", 1)
+		assert.NoError(t, err)
+
+		book, err := bookRepo.GetBook(defaultCtx, "book1")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, book.AvailableCopies)
+
+		book, err = bookRepo.GetBook(cityCtx, "book1")
+		assert.NoError(t, err)
+		assert.Equal(t, 5, book.AvailableCopies)
+	})
+
+	t.Run("Loan is only visible to its tenant", func(t *testing.T) {
+		loan, err := loanRepo.CreateLoan(cityCtx, "book1", &models.Loan{BookId: 1, BorrowerName: "user1", ReturnDate: time.Now()})
+		assert.NoError(t, err)
+
+		_, err = loanRepo.GetLoan(cityCtx, "book1", "user1")
+		assert.NoError(t, err)
+		_, err = loanRepo.GetLoan(defaultCtx, "book1", "user1")
+		assert.Equal(t, ErrLoanNotFound, err)
+		_, err = loanRepo.GetLoanById(defaultCtx, loan.Id)
+		assert.Equal(t, ErrLoanNotFound, err)
+	})
+}
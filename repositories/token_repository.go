@@ -0,0 +1,71 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"github.com/aftaab60/e-library-api/models"
+	"sync"
+	"time"
+)
+
+type ITokenRepository interface {
+	CreateToken(ctx context.Context, token *models.Token) (*models.Token, error)
+	GetToken(ctx context.Context, id string) (*models.Token, error)
+	RevokeToken(ctx context.Context, id string, revokedAt time.Time) (*models.Token, error)
+}
+
+type TokenRepository struct {
+	tokens map[string]*models.Token
+	mutex  sync.RWMutex
+}
+
+func NewTokenRepository() *TokenRepository {
+	return &TokenRepository{
+		tokens: make(map[string]*models.Token),
+	}
+}
+
+// ErrTokenNotFound is returned when a token is not found
+var ErrTokenNotFound = errors.New("token not found")
+
+func (tr *TokenRepository) CreateToken(ctx context.Context, token *models.Token) (*models.Token, error) {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+
+	//expired tokens are rejected by their signature anyway, no need to keep them
+	for id, t := range tr.tokens {
+		if !t.ExpiresAt.After(token.IssuedAt) {
+			delete(tr.tokens, id)
+		}
+	}
+	createdToken := *token
+	tr.tokens[token.Id] = &createdToken
+	return token, nil
+}
+
+func (tr *TokenRepository) GetToken(ctx context.Context, id string) (*models.Token, error) {
+	tr.mutex.RLock()
+	defer tr.mutex.RUnlock()
+
+	token, ok := tr.tokens[id]
+	if !ok {
+		return nil, ErrTokenNotFound
+	}
+	tokenCopy := *token
+	return &tokenCopy, nil
+}
+
+func (tr *TokenRepository) RevokeToken(ctx context.Context, id string, revokedAt time.Time) (*models.Token, error) {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+
+	token, ok := tr.tokens[id]
+	if !ok {
+		return nil, ErrTokenNotFound
+	}
+	if token.RevokedAt == nil {
+		token.RevokedAt = &revokedAt
+	}
+	tokenCopy := *token
+	return &tokenCopy, nil
+}
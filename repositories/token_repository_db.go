@@ -0,0 +1,64 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/aftaab60/e-library-api/internal/db_manager"
+	"github.com/aftaab60/e-library-api/models"
+	"time"
+)
+
+type TokenRepositoryDB struct {
+	DB *db_manager.DB
+}
+
+func NewTokenRepositoryDB(db *db_manager.DB) *TokenRepositoryDB {
+	return &TokenRepositoryDB{DB: db}
+}
+
+func scanToken(row *sql.Row) (*models.Token, error) {
+	var token models.Token
+	var memberId sql.NullInt64
+	var revokedAt sql.NullTime
+	if err := row.Scan(&token.Id, &token.Subject, &memberId, &token.Role, &token.IssuedAt, &token.ExpiresAt, &revokedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTokenNotFound
+		}
+		return nil, err
+	}
+	token.MemberId = int(memberId.Int64)
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+	return &token, nil
+}
+
+func (tr *TokenRepositoryDB) CreateToken(ctx context.Context, token *models.Token) (*models.Token, error) {
+	insertQuery := `
+        INSERT INTO auth_tokens (id, subject, member_id, role, issued_at, expires_at)
+        VALUES ($1, $2, NULLIF($3, 0), $4, $5, $6)
+        RETURNING id, subject, member_id, role, issued_at, expires_at, revoked_at
+    `
+	createdToken, err := scanToken(tr.DB.CreateRecord(ctx, insertQuery, token.Id, token.Subject, token.MemberId, token.Role, token.IssuedAt, token.ExpiresAt))
+	if err != nil {
+		return nil, fmt.Errorf("error creating token for %s: %w", token.Subject, err)
+	}
+	return createdToken, nil
+}
+
+func (tr *TokenRepositoryDB) GetToken(ctx context.Context, id string) (*models.Token, error) {
+	query := "SELECT id, subject, member_id, role, issued_at, expires_at, revoked_at FROM auth_tokens WHERE id = $1"
+	return scanToken(tr.DB.GetRecord(ctx, query, id))
+}
+
+func (tr *TokenRepositoryDB) RevokeToken(ctx context.Context, id string, revokedAt time.Time) (*models.Token, error) {
+	updateQuery := `
+        UPDATE auth_tokens
+        SET revoked_at = COALESCE(revoked_at, $1)
+        WHERE id = $2
+        RETURNING id, subject, member_id, role, issued_at, expires_at, revoked_at
+    `
+	return scanToken(tr.DB.UpdateRecord(ctx, updateQuery, revokedAt, id))
+}
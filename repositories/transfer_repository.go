@@ -0,0 +1,83 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"github.com/aftaab60/e-library-api/models"
+	"sync"
+	"time"
+)
+
+type ITransferRepository interface {
+	CreateTransfer(ctx context.Context, transfer *models.Transfer) (*models.Transfer, error)
+	GetTransfer(ctx context.Context, id int) (*models.Transfer, error)
+	// UpdateTransferStatus sets the status of a transfer, updatedAt being the time of the change
+	UpdateTransferStatus(ctx context.Context, id int, status models.TransferStatus, updatedAt time.Time) (*models.Transfer, error)
+	ListTransfers(ctx context.Context, status models.TransferStatus) ([]models.Transfer, error)
+}
+
+type TransferRepository struct {
+	transfers []models.Transfer
+	mutex     sync.RWMutex
+}
+
+func NewTransferRepository() *TransferRepository {
+	return &TransferRepository{
+		transfers: make([]models.Transfer, 0),
+	}
+}
+
+// ErrTransferNotFound is returned when a transfer is not found
+var ErrTransferNotFound = errors.New("transfer not found")
+
+func (tr *TransferRepository) CreateTransfer(ctx context.Context, transfer *models.Transfer) (*models.Transfer, error) {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+
+	transfer.Id = len(tr.transfers) + 1 //incremental id
+	tr.transfers = append(tr.transfers, *transfer)
+
+	createdTransfer := tr.transfers[len(tr.transfers)-1]
+	return &createdTransfer, nil
+}
+
+func (tr *TransferRepository) GetTransfer(ctx context.Context, id int) (*models.Transfer, error) {
+	tr.mutex.RLock()
+	defer tr.mutex.RUnlock()
+
+	for _, transfer := range tr.transfers {
+		if transfer.Id == id {
+			return &transfer, nil
+		}
+	}
+	return nil, ErrTransferNotFound
+}
+
+func (tr *TransferRepository) UpdateTransferStatus(ctx context.Context, id int, status models.TransferStatus, updatedAt time.Time) (*models.Transfer, error) {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+
+	for i := range tr.transfers {
+		if tr.transfers[i].Id == id {
+			tr.transfers[i].Status = status
+			tr.transfers[i].UpdatedAt = updatedAt
+			updatedTransfer := tr.transfers[i]
+			return &updatedTransfer, nil
+		}
+	}
+	return nil, ErrTransferNotFound
+}
+
+// ListTransfers returns all transfers when status is empty
+func (tr *TransferRepository) ListTransfers(ctx context.Context, status models.TransferStatus) ([]models.Transfer, error) {
+	tr.mutex.RLock()
+	defer tr.mutex.RUnlock()
+
+	transfers := make([]models.Transfer, 0)
+	for _, transfer := range tr.transfers {
+		if status == "" || transfer.Status == status {
+			transfers = append(transfers, transfer)
+		}
+	}
+	return transfers, nil
+}
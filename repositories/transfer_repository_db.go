@@ -0,0 +1,90 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/aftaab60/e-library-api/internal/db_manager"
+	"github.com/aftaab60/e-library-api/models"
+	"time"
+)
+
+type TransferRepositoryDB struct {
+	DB *db_manager.DB
+}
+
+func NewTransferRepositoryDB(db *db_manager.DB) *TransferRepositoryDB {
+	return &TransferRepositoryDB{DB: db}
+}
+
+const transferColumns = "id, book_id, from_branch_id, to_branch_id, COALESCE(loan_id, 0), status, created_at, updated_at"
+
+func scanTransfer(row interface{ Scan(dest ...any) error }) (*models.Transfer, error) {
+	var transfer models.Transfer
+	if err := row.Scan(&transfer.Id, &transfer.BookId, &transfer.FromBranchId, &transfer.ToBranchId, &transfer.LoanId, &transfer.Status, &transfer.CreatedAt, &transfer.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+func (tr *TransferRepositoryDB) CreateTransfer(ctx context.Context, transfer *models.Transfer) (*models.Transfer, error) {
+	insertQuery := `
+        INSERT INTO transfers (book_id, from_branch_id, to_branch_id, loan_id, status, created_at, updated_at)
+        VALUES ($1, $2, $3, NULLIF($4, 0), $5, $6, $7)
+        RETURNING ` + transferColumns
+	row := tr.DB.CreateRecord(ctx, insertQuery, transfer.BookId, transfer.FromBranchId, transfer.ToBranchId, transfer.LoanId, transfer.Status, transfer.CreatedAt, transfer.UpdatedAt)
+
+	createdTransfer, err := scanTransfer(row)
+	if err != nil {
+		return nil, fmt.Errorf("error creating transfer for book %d: %w", transfer.BookId, err)
+	}
+	return createdTransfer, nil
+}
+
+func (tr *TransferRepositoryDB) GetTransfer(ctx context.Context, id int) (*models.Transfer, error) {
+	query := "SELECT " + transferColumns + " FROM transfers WHERE id = $1"
+	transfer, err := scanTransfer(tr.DB.GetRecord(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTransferNotFound
+		}
+		return nil, err
+	}
+	return transfer, nil
+}
+
+func (tr *TransferRepositoryDB) UpdateTransferStatus(ctx context.Context, id int, status models.TransferStatus, updatedAt time.Time) (*models.Transfer, error) {
+	updateQuery := `
+        UPDATE transfers
+        SET status = $1, updated_at = $2
+        WHERE id = $3
+        RETURNING ` + transferColumns
+	transfer, err := scanTransfer(tr.DB.UpdateRecord(ctx, updateQuery, status, updatedAt, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTransferNotFound
+		}
+		return nil, fmt.Errorf("error updating transfer %d: %w", id, err)
+	}
+	return transfer, nil
+}
+
+func (tr *TransferRepositoryDB) ListTransfers(ctx context.Context, status models.TransferStatus) ([]models.Transfer, error) {
+	query := "SELECT " + transferColumns + " FROM transfers WHERE $1 = '' OR status = $1 ORDER BY id"
+	rows, err := tr.DB.GetRecords(ctx, query, status)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching transfers: %w", err)
+	}
+	defer rows.Close()
+
+	transfers := make([]models.Transfer, 0)
+	for rows.Next() {
+		transfer, err := scanTransfer(rows)
+		if err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, *transfer)
+	}
+	return transfers, rows.Err()
+}
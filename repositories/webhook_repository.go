@@ -0,0 +1,181 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"github.com/aftaab60/e-library-api/models"
+	"sync"
+	"time"
+)
+
+// IWebhookRepository stores webhook subscriptions and the deliveries of events to them
+type IWebhookRepository interface {
+	CreateWebhookSubscription(ctx context.Context, subscription *models.WebhookSubscription) (*models.WebhookSubscription, error)
+	GetWebhookSubscriptionById(ctx context.Context, id int) (*models.WebhookSubscription, error)
+	ListWebhookSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error)
+	// DeleteWebhookSubscription removes a subscription along with its deliveries
+	DeleteWebhookSubscription(ctx context.Context, id int) error
+	CreateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) (*models.WebhookDelivery, error)
+	GetWebhookDeliveryById(ctx context.Context, id int) (*models.WebhookDelivery, error)
+	// UpdateWebhookDelivery saves the status, attempts and outcome of the last attempt of a delivery
+	UpdateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) (*models.WebhookDelivery, error)
+	// ClaimDueWebhookDeliveries returns up to limit pending deliveries whose next attempt is due at now, oldest first,
+	// and puts their next attempt off until the given time so that concurrent dispatchers skip them while they are sent.
+	ClaimDueWebhookDeliveries(ctx context.Context, now time.Time, until time.Time, limit int) ([]models.WebhookDelivery, error)
+	// ListWebhookDeliveries returns a page of deliveries matching the filter, newest first, along with the total number of matching deliveries
+	ListWebhookDeliveries(ctx context.Context, filter *models.WebhookDeliveryFilter) ([]models.WebhookDelivery, int, error)
+}
+
+type WebhookRepository struct {
+	subscriptions      []models.WebhookSubscription
+	deliveries         []models.WebhookDelivery
+	nextSubscriptionId int
+	mutex              sync.RWMutex
+}
+
+func NewWebhookRepository() *WebhookRepository {
+	return &WebhookRepository{
+		subscriptions: make([]models.WebhookSubscription, 0),
+		deliveries:    make([]models.WebhookDelivery, 0),
+	}
+}
+
+// ErrWebhookSubscriptionNotFound is returned when a webhook subscription is not found
+var ErrWebhookSubscriptionNotFound = errors.New("webhook subscription not found")
+
+// ErrWebhookDeliveryNotFound is returned when a webhook delivery is not found
+var ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+
+func (wr *WebhookRepository) CreateWebhookSubscription(ctx context.Context, subscription *models.WebhookSubscription) (*models.WebhookSubscription, error) {
+	wr.mutex.Lock()
+	defer wr.mutex.Unlock()
+
+	//ids aren't reused after subscriptions are deleted
+	wr.nextSubscriptionId++
+	createdSubscription := *subscription
+	createdSubscription.Id = wr.nextSubscriptionId
+	createdSubscription.EventTypes = append([]models.LoanEventType{}, subscription.EventTypes...)
+	wr.subscriptions = append(wr.subscriptions, createdSubscription)
+	return &createdSubscription, nil
+}
+
+func (wr *WebhookRepository) GetWebhookSubscriptionById(ctx context.Context, id int) (*models.WebhookSubscription, error) {
+	wr.mutex.RLock()
+	defer wr.mutex.RUnlock()
+
+	for _, subscription := range wr.subscriptions {
+		if subscription.Id == id {
+			return &subscription, nil
+		}
+	}
+	return nil, ErrWebhookSubscriptionNotFound
+}
+
+func (wr *WebhookRepository) ListWebhookSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error) {
+	wr.mutex.RLock()
+	defer wr.mutex.RUnlock()
+
+	subscriptions := make([]models.WebhookSubscription, len(wr.subscriptions))
+	copy(subscriptions, wr.subscriptions)
+	return subscriptions, nil
+}
+
+func (wr *WebhookRepository) DeleteWebhookSubscription(ctx context.Context, id int) error {
+	wr.mutex.Lock()
+	defer wr.mutex.Unlock()
+
+	for i, subscription := range wr.subscriptions {
+		if subscription.Id != id {
+			continue
+		}
+		wr.subscriptions = append(wr.subscriptions[:i], wr.subscriptions[i+1:]...)
+		//deliveries keep their slot, ids are positions in the slice. Removed deliveries are never listed again.
+		for j := range wr.deliveries {
+			if wr.deliveries[j].SubscriptionId == id {
+				wr.deliveries[j].SubscriptionId = 0
+			}
+		}
+		return nil
+	}
+	return ErrWebhookSubscriptionNotFound
+}
+
+func (wr *WebhookRepository) CreateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) (*models.WebhookDelivery, error) {
+	wr.mutex.Lock()
+	defer wr.mutex.Unlock()
+
+	createdDelivery := *delivery
+	createdDelivery.Id = len(wr.deliveries) + 1
+	wr.deliveries = append(wr.deliveries, createdDelivery)
+	return &createdDelivery, nil
+}
+
+func (wr *WebhookRepository) GetWebhookDeliveryById(ctx context.Context, id int) (*models.WebhookDelivery, error) {
+	wr.mutex.RLock()
+	defer wr.mutex.RUnlock()
+
+	if id < 1 || id > len(wr.deliveries) || wr.deliveries[id-1].SubscriptionId == 0 {
+		return nil, ErrWebhookDeliveryNotFound
+	}
+	delivery := wr.deliveries[id-1]
+	return &delivery, nil
+}
+
+func (wr *WebhookRepository) UpdateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) (*models.WebhookDelivery, error) {
+	wr.mutex.Lock()
+	defer wr.mutex.Unlock()
+
+	id := delivery.Id
+	if id < 1 || id > len(wr.deliveries) || wr.deliveries[id-1].SubscriptionId == 0 {
+		return nil, ErrWebhookDeliveryNotFound
+	}
+	stored := &wr.deliveries[id-1]
+	stored.Status = delivery.Status
+	stored.Attempts = delivery.Attempts
+	stored.NextAttemptAt = delivery.NextAttemptAt
+	stored.LastStatusCode = delivery.LastStatusCode
+	stored.LastError = delivery.LastError
+	stored.DeliveredAt = delivery.DeliveredAt
+	updatedDelivery := *stored
+	return &updatedDelivery, nil
+}
+
+func (wr *WebhookRepository) ClaimDueWebhookDeliveries(ctx context.Context, now time.Time, until time.Time, limit int) ([]models.WebhookDelivery, error) {
+	wr.mutex.Lock()
+	defer wr.mutex.Unlock()
+
+	deliveries := make([]models.WebhookDelivery, 0)
+	for i := range wr.deliveries {
+		if len(deliveries) == limit {
+			break
+		}
+		delivery := &wr.deliveries[i]
+		if delivery.SubscriptionId != 0 && delivery.Status == models.WebhookDeliveryPending && !delivery.NextAttemptAt.After(now) {
+			delivery.NextAttemptAt = until
+			deliveries = append(deliveries, *delivery)
+		}
+	}
+	return deliveries, nil
+}
+
+func (wr *WebhookRepository) ListWebhookDeliveries(ctx context.Context, filter *models.WebhookDeliveryFilter) ([]models.WebhookDelivery, int, error) {
+	wr.mutex.RLock()
+	defer wr.mutex.RUnlock()
+
+	matched := make([]models.WebhookDelivery, 0)
+	for i := len(wr.deliveries) - 1; i >= 0; i-- {
+		if wr.deliveries[i].SubscriptionId != 0 && filter.Matches(&wr.deliveries[i]) {
+			matched = append(matched, wr.deliveries[i])
+		}
+	}
+
+	total := len(matched)
+	if filter.Offset >= total {
+		return make([]models.WebhookDelivery, 0), total, nil
+	}
+	end := total
+	if filter.Limit > 0 && filter.Offset+filter.Limit < total {
+		end = filter.Offset + filter.Limit
+	}
+	return matched[filter.Offset:end], total, nil
+}
@@ -0,0 +1,218 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/aftaab60/e-library-api/internal/db_manager"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/lib/pq"
+	"sort"
+	"strings"
+	"time"
+)
+
+type WebhookRepositoryDB struct {
+	DB *db_manager.DB
+}
+
+func NewWebhookRepositoryDB(db *db_manager.DB) *WebhookRepositoryDB {
+	return &WebhookRepositoryDB{DB: db}
+}
+
+const webhookSubscriptionColumns = "id, url, event_types, secret, created_at"
+
+func scanWebhookSubscription(row scanner) (*models.WebhookSubscription, error) {
+	var subscription models.WebhookSubscription
+	var eventTypes pq.StringArray
+	if err := row.Scan(&subscription.Id, &subscription.Url, &eventTypes, &subscription.Secret, &subscription.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrWebhookSubscriptionNotFound
+		}
+		return nil, err
+	}
+	subscription.EventTypes = make([]models.LoanEventType, len(eventTypes))
+	for i, eventType := range eventTypes {
+		subscription.EventTypes[i] = models.LoanEventType(eventType)
+	}
+	return &subscription, nil
+}
+
+func (wr *WebhookRepositoryDB) CreateWebhookSubscription(ctx context.Context, subscription *models.WebhookSubscription) (*models.WebhookSubscription, error) {
+	eventTypes := make(pq.StringArray, len(subscription.EventTypes))
+	for i, eventType := range subscription.EventTypes {
+		eventTypes[i] = string(eventType)
+	}
+	insertQuery := `
+        INSERT INTO webhook_subscriptions (url, event_types, secret, created_at)
+        VALUES ($1, $2, $3, $4)
+        RETURNING ` + webhookSubscriptionColumns
+	row := wr.DB.CreateRecord(ctx, insertQuery, subscription.Url, eventTypes, subscription.Secret, subscription.CreatedAt)
+	createdSubscription, err := scanWebhookSubscription(row)
+	if err != nil {
+		return nil, fmt.Errorf("error creating webhook subscription: %w", err)
+	}
+	return createdSubscription, nil
+}
+
+func (wr *WebhookRepositoryDB) GetWebhookSubscriptionById(ctx context.Context, id int) (*models.WebhookSubscription, error) {
+	query := "SELECT " + webhookSubscriptionColumns + " FROM webhook_subscriptions WHERE id = $1"
+	return scanWebhookSubscription(wr.DB.GetRecord(ctx, query, id))
+}
+
+func (wr *WebhookRepositoryDB) ListWebhookSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error) {
+	query := "SELECT " + webhookSubscriptionColumns + " FROM webhook_subscriptions ORDER BY id"
+	rows, err := wr.DB.GetRecords(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching webhook subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	subscriptions := make([]models.WebhookSubscription, 0)
+	for rows.Next() {
+		subscription, err := scanWebhookSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, *subscription)
+	}
+	return subscriptions, rows.Err()
+}
+
+func (wr *WebhookRepositoryDB) DeleteWebhookSubscription(ctx context.Context, id int) error {
+	//deliveries are deleted by ON DELETE CASCADE
+	result, err := wr.DB.DeleteRecord(ctx, "DELETE FROM webhook_subscriptions WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("error deleting webhook subscription %d: %w", id, err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrWebhookSubscriptionNotFound
+	}
+	return nil
+}
+
+const webhookDeliveryColumns = "id, subscription_id, message_id, event_type, status, body, attempts, next_attempt_at, last_status_code, last_error, created_at, delivered_at"
+
+func scanWebhookDelivery(row scanner) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	var body []byte
+	err := row.Scan(&delivery.Id, &delivery.SubscriptionId, &delivery.MessageId, &delivery.EventType, &delivery.Status, &body,
+		&delivery.Attempts, &delivery.NextAttemptAt, &delivery.LastStatusCode, &delivery.LastError, &delivery.CreatedAt, &delivery.DeliveredAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrWebhookDeliveryNotFound
+		}
+		return nil, err
+	}
+	delivery.Body = body
+	return &delivery, nil
+}
+
+func scanWebhookDeliveries(rows *sql.Rows) ([]models.WebhookDelivery, error) {
+	defer rows.Close()
+
+	deliveries := make([]models.WebhookDelivery, 0)
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, *delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+func (wr *WebhookRepositoryDB) CreateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) (*models.WebhookDelivery, error) {
+	insertQuery := `
+        INSERT INTO webhook_deliveries (subscription_id, message_id, event_type, status, body, attempts, next_attempt_at, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        RETURNING ` + webhookDeliveryColumns
+	row := wr.DB.CreateRecord(ctx, insertQuery, delivery.SubscriptionId, delivery.MessageId, delivery.EventType, delivery.Status,
+		[]byte(delivery.Body), delivery.Attempts, delivery.NextAttemptAt, delivery.CreatedAt)
+	createdDelivery, err := scanWebhookDelivery(row)
+	if err != nil {
+		return nil, fmt.Errorf("error creating delivery of message %d to webhook %d: %w", delivery.MessageId, delivery.SubscriptionId, err)
+	}
+	return createdDelivery, nil
+}
+
+func (wr *WebhookRepositoryDB) GetWebhookDeliveryById(ctx context.Context, id int) (*models.WebhookDelivery, error) {
+	query := "SELECT " + webhookDeliveryColumns + " FROM webhook_deliveries WHERE id = $1"
+	return scanWebhookDelivery(wr.DB.GetRecord(ctx, query, id))
+}
+
+func (wr *WebhookRepositoryDB) UpdateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) (*models.WebhookDelivery, error) {
+	updateQuery := `
+        UPDATE webhook_deliveries
+        SET status = $1, attempts = $2, next_attempt_at = $3, last_status_code = $4, last_error = $5, delivered_at = $6
+        WHERE id = $7
+        RETURNING ` + webhookDeliveryColumns
+	return scanWebhookDelivery(wr.DB.UpdateRecord(ctx, updateQuery, delivery.Status, delivery.Attempts, delivery.NextAttemptAt,
+		delivery.LastStatusCode, delivery.LastError, delivery.DeliveredAt, delivery.Id))
+}
+
+func (wr *WebhookRepositoryDB) ClaimDueWebhookDeliveries(ctx context.Context, now time.Time, until time.Time, limit int) ([]models.WebhookDelivery, error) {
+	//deliveries claimed by a dispatcher that has yet to commit are skipped rather than waited for
+	query := `
+        UPDATE webhook_deliveries
+        SET next_attempt_at = $3
+        WHERE id IN (
+            SELECT id
+            FROM webhook_deliveries
+            WHERE status = $1 AND next_attempt_at <= $2
+            ORDER BY id
+            LIMIT $4
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING ` + webhookDeliveryColumns
+	rows, err := wr.DB.GetRecords(ctx, query, models.WebhookDeliveryPending, now, until, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error claiming due webhook deliveries: %w", err)
+	}
+	deliveries, err := scanWebhookDeliveries(rows)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].Id < deliveries[j].Id })
+	return deliveries, nil
+}
+
+func (wr *WebhookRepositoryDB) ListWebhookDeliveries(ctx context.Context, filter *models.WebhookDeliveryFilter) ([]models.WebhookDelivery, int, error) {
+	conditions := make([]string, 0)
+	args := make([]interface{}, 0)
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if filter.Status != "" {
+		addCondition("status = $%d", filter.Status)
+	}
+	if filter.SubscriptionId != 0 {
+		addCondition("subscription_id = $%d", filter.SubscriptionId)
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	countQuery := "SELECT COUNT(*) FROM webhook_deliveries " + where
+	if err := wr.DB.GetRecord(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("error counting webhook deliveries: %w", err)
+	}
+
+	query := fmt.Sprintf(`
+        SELECT %s
+        FROM webhook_deliveries
+        %s
+        ORDER BY id DESC
+        LIMIT $%d OFFSET $%d
+    `, webhookDeliveryColumns, where, len(args)+1, len(args)+2)
+	rows, err := wr.DB.GetRecords(ctx, query, append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error fetching webhook deliveries: %w", err)
+	}
+	deliveries, err := scanWebhookDeliveries(rows)
+	return deliveries, total, err
+}
@@ -0,0 +1,93 @@
+package repositories
+
+import (
+	"context"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestWebhookRepository_Deliveries(t *testing.T) {
+	repo := NewWebhookRepository()
+	ctx := context.Background()
+	now := time.Now()
+
+	subscription, err := repo.CreateWebhookSubscription(ctx, &models.WebhookSubscription{Url: "https://example.com/hook", Secret: "secret", CreatedAt: now})
+	assert.NoError(t, err)
+	other, err := repo.CreateWebhookSubscription(ctx, &models.WebhookSubscription{Url: "https://example.com/other", Secret: "secret", CreatedAt: now})
+	assert.NoError(t, err)
+
+	for i, subscriptionId := range []int{subscription.Id, subscription.Id, other.Id} {
+		_, err := repo.CreateWebhookDelivery(ctx, &models.WebhookDelivery{
+			SubscriptionId: subscriptionId,
+			MessageId:      i + 1,
+			EventType:      models.LoanEventCreated,
+			Status:         models.WebhookDeliveryPending,
+			NextAttemptAt:  now.Add(time.Duration(i) * time.Minute),
+			CreatedAt:      now,
+		})
+		assert.NoError(t, err)
+	}
+
+	t.Run("Claim deliveries due, oldest first", func(t *testing.T) {
+		deliveries, err := repo.ClaimDueWebhookDeliveries(ctx, now.Add(time.Minute), now.Add(2*time.Minute), 10)
+		assert.NoError(t, err)
+		assert.Len(t, deliveries, 2)
+		assert.Equal(t, 1, deliveries[0].Id)
+
+		//claimed deliveries aren't due again until the claim runs out
+		deliveries, err = repo.ClaimDueWebhookDeliveries(ctx, now.Add(time.Minute), now.Add(2*time.Minute), 10)
+		assert.NoError(t, err)
+		assert.Empty(t, deliveries)
+	})
+
+	t.Run("Dead deliveries are not due and make the dead letter queue", func(t *testing.T) {
+		delivery, err := repo.GetWebhookDeliveryById(ctx, 1)
+		assert.NoError(t, err)
+		delivery.Status = models.WebhookDeliveryDead
+		delivery.Attempts = 8
+		delivery.LastError = "500 Internal Server Error"
+		_, err = repo.UpdateWebhookDelivery(ctx, delivery)
+		assert.NoError(t, err)
+
+		deliveries, err := repo.ClaimDueWebhookDeliveries(ctx, now.Add(time.Hour), now.Add(2*time.Hour), 10)
+		assert.NoError(t, err)
+		assert.Len(t, deliveries, 2)
+
+		dead, total, err := repo.ListWebhookDeliveries(ctx, &models.WebhookDeliveryFilter{Status: models.WebhookDeliveryDead, Limit: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, total)
+		assert.Equal(t, 8, dead[0].Attempts)
+		assert.Equal(t, "500 Internal Server Error", dead[0].LastError)
+	})
+
+	t.Run("Deleting a subscription drops its deliveries", func(t *testing.T) {
+		assert.NoError(t, repo.DeleteWebhookSubscription(ctx, subscription.Id))
+		assert.Equal(t, ErrWebhookSubscriptionNotFound, repo.DeleteWebhookSubscription(ctx, subscription.Id))
+
+		deliveries, total, err := repo.ListWebhookDeliveries(ctx, &models.WebhookDeliveryFilter{Limit: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, total)
+		assert.Equal(t, other.Id, deliveries[0].SubscriptionId)
+
+		_, err = repo.GetWebhookDeliveryById(ctx, 1)
+		assert.Equal(t, ErrWebhookDeliveryNotFound, err)
+	})
+}
+
+func TestOutboxRepository_PendingMessages(t *testing.T) {
+	repo := NewOutboxRepository()
+	ctx := context.Background()
+
+	for _, eventType := range []models.LoanEventType{models.LoanEventCreated, models.LoanEventReturned} {
+		_, err := repo.CreateOutboxMessage(ctx, &models.OutboxMessage{EventType: eventType, Payload: []byte(`{}`), CreatedAt: time.Now()})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, repo.MarkOutboxMessageDispatched(ctx, 1, time.Now()))
+
+	messages, err := repo.ListPendingOutboxMessages(ctx, 10)
+	assert.NoError(t, err)
+	assert.Len(t, messages, 1)
+	assert.Equal(t, models.LoanEventReturned, messages[0].EventType)
+}
@@ -0,0 +1,114 @@
+package routes
+
+import (
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/services"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+)
+
+type AccountRoute struct {
+	AccountService services.AccountService
+}
+
+func NewAccountRoute(accountService services.AccountService) *AccountRoute {
+	return &AccountRoute{accountService}
+}
+
+func (r *AccountRoute) Register(c *gin.Context) {
+	var request models.RegisterRequest
+	if err := bindJSON(c, &request); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+	request.Name = strings.TrimSpace(request.Name)
+	request.Email = strings.TrimSpace(request.Email)
+	if err := request.Validate(); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	member, err := r.AccountService.Register(c.Request.Context(), &request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, member)
+}
+
+func (r *AccountRoute) Login(c *gin.Context) {
+	var request models.LoginRequest
+	if err := bindJSON(c, &request); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+	request.Name = strings.TrimSpace(request.Name)
+	if err := request.Validate(); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	token, err := r.AccountService.Login(c.Request.Context(), request.Name, request.Password)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, token)
+}
+
+func (r *AccountRoute) ChangePassword(c *gin.Context) {
+	var request models.ChangePasswordRequest
+	if err := bindJSON(c, &request); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+	if err := request.Validate(); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	if err := r.AccountService.ChangePassword(c.Request.Context(), request.CurrentPassword, request.NewPassword); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "password changed"})
+}
+
+func (r *AccountRoute) RequestPasswordReset(c *gin.Context) {
+	var request models.PasswordResetRequest
+	if err := bindJSON(c, &request); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+	request.Email = strings.TrimSpace(request.Email)
+	if err := request.Validate(); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	if err := r.AccountService.RequestPasswordReset(c.Request.Context(), request.Email); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "if the email belongs to a member, a reset token has been sent to it"})
+}
+
+func (r *AccountRoute) ConfirmPasswordReset(c *gin.Context) {
+	var request models.ConfirmPasswordResetRequest
+	if err := bindJSON(c, &request); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+	request.Token = strings.TrimSpace(request.Token)
+	if err := request.Validate(); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	if err := r.AccountService.ConfirmPasswordReset(c.Request.Context(), request.Token, request.Password); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "password changed"})
+}
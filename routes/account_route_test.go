@@ -0,0 +1,84 @@
+package routes
+
+import (
+	"encoding/json"
+	"github.com/aftaab60/e-library-api/internal/mail"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"github.com/aftaab60/e-library-api/services"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAccountRoute_Accounts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorMiddleware())
+
+	// Register the route
+	memberRepo := repositories.NewMemberRepository()
+	authService := services.NewAuthService(repositories.NewApiKeyRepository(), repositories.NewTokenRepository(), memberRepo, []byte("test-secret"))
+	accountService := services.NewAccountService(memberRepo, repositories.NewCredentialRepository(), authService, mail.NewFileSender(t.TempDir(), "library@example.com"))
+	accountService.BcryptCost = bcrypt.MinCost
+	accountService.MaxFailedLogins = 2
+	accountRoute := NewAccountRoute(accountService)
+	authRoute := NewAuthRoute(authService)
+	tenantRoute := NewTenantRoute(services.NewTenantService(repositories.NewTenantRepository()))
+	router.Use(tenantRoute.TenantMiddleware(nil, "default"))
+	router.POST("/accounts/register", accountRoute.Register)
+	router.POST("/accounts/login", accountRoute.Login)
+	router.POST("/accounts/password-reset", accountRoute.RequestPasswordReset)
+	api := router.Group("", authRoute.AuthMiddleware())
+	api.PUT("/accounts/password", authRoute.Require(models.PermissionMemberOwn), accountRoute.ChangePassword)
+
+	serve := func(method string, path string, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("invalid registration", func(t *testing.T) {
+		rec := serve(http.MethodPost, "/accounts/register", `{"name": "user3", "email": "not-an-email", "password": "correct horse"}`, nil)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		rec = serve(http.MethodPost, "/accounts/register", `{"name": "user3", "email": "user3@example.com", "password": "short"}`, nil)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("register, login and change password", func(t *testing.T) {
+		rec := serve(http.MethodPost, "/accounts/register", `{"name": "user3", "email": "user3@example.com", "password": "correct horse"}`, nil)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "correct horse")
+
+		rec = serve(http.MethodPost, "/accounts/login", `{"name": "user3", "password": "correct horse"}`, nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var issued models.IssuedToken
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &issued))
+
+		rec = serve(http.MethodPut, "/accounts/password", `{"current_password": "correct horse", "new_password": "battery staple"}`,
+			map[string]string{AuthorizationHeader: "Bearer " + issued.Token})
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("password reset is accepted for any email", func(t *testing.T) {
+		rec := serve(http.MethodPost, "/accounts/password-reset", `{"email": "nobody@example.com"}`, nil)
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+	})
+
+	t.Run("lockout after failed logins", func(t *testing.T) {
+		rec := serve(http.MethodPost, "/accounts/login", `{"name": "user3", "password": "wrong password"}`, nil)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		rec = serve(http.MethodPost, "/accounts/login", `{"name": "user3", "password": "wrong password"}`, nil)
+		assert.Equal(t, http.StatusLocked, rec.Code)
+	})
+}
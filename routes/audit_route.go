@@ -0,0 +1,39 @@
+package routes
+
+import (
+	"fmt"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/services"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+type AuditRoute struct {
+	AuditService *services.AuditService
+}
+
+func NewAuditRoute(auditService *services.AuditService) *AuditRoute {
+	return &AuditRoute{auditService}
+}
+
+func (r *AuditRoute) ListAuditEntries(c *gin.Context) {
+	var filter models.AuditFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.Error(invalidRequest(fmt.Errorf("invalid query parameters: %w", err)))
+		return
+	}
+	if filter.Limit == 0 {
+		filter.Limit = models.DefaultAuditPageSize
+	}
+	if err := filter.Validate(); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	page, err := r.AuditService.ListAuditEntries(c.Request.Context(), &filter)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
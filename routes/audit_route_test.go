@@ -0,0 +1,76 @@
+package routes
+
+import (
+	"encoding/json"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"github.com/aftaab60/e-library-api/services"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAuditRoute_ListAuditEntries(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorMiddleware())
+	router.Use(RequestIdMiddleware())
+
+	// Register the route
+	auditService := services.NewAuditService(repositories.NewAuditRepository())
+	loanService := services.NewLoanService(repositories.NewLoanRepository(), repositories.NewBookRepository(), repositories.NewChargeRepository(), repositories.NewBranchRepository(), repositories.NewTransferRepository(), repositories.NewMemberRepository(), repositories.NewLoanEventRepository())
+	loanService.Auditor = auditService
+	loanRoute := NewLoanRoute(loanService)
+	auditRoute := NewAuditRoute(auditService)
+	router.POST("/borrow", loanRoute.BorrowBook)
+	router.GET("/admin/audit", auditRoute.ListAuditEntries)
+
+	t.Run("request id is echoed and generated when missing", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/borrow", strings.NewReader(`{"title": "book1", "borrower_name": "borrower1"}`))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(RequestIdHeader, "borrow-1")
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, "borrow-1", rec.Header().Get(RequestIdHeader))
+
+		req, err = http.NewRequest(http.MethodGet, "/admin/audit", nil)
+		assert.NoError(t, err)
+		req.Header.Set(RequestIdHeader, "not a valid id")
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Len(t, rec.Header().Get(RequestIdHeader), 32)
+	})
+
+	t.Run("search entries by request id", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/admin/audit?request_id=borrow-1&entity_type=loan", nil)
+		assert.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var page models.AuditPage
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+		assert.Equal(t, 1, page.Total)
+		assert.Equal(t, models.AuditActionLoanBorrowed, page.Entries[0].Action)
+		assert.Equal(t, models.PrincipalTypeSystem, page.Entries[0].ActorType)
+		assert.NotEmpty(t, page.Entries[0].Changes)
+	})
+
+	t.Run("invalid filter", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/admin/audit?from=2024-02-01&to=2024-01-01&limit=1000", nil)
+		assert.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"field":"to"`)
+		assert.Contains(t, rec.Body.String(), `"field":"limit"`)
+	})
+}
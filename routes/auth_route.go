@@ -0,0 +1,159 @@
+package routes
+
+import (
+	"errors"
+	"github.com/aftaab60/e-library-api/internal/auth"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/services"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const AuthorizationHeader = "Authorization"
+
+type AuthRoute struct {
+	AuthService services.AuthService
+}
+
+func NewAuthRoute(authService services.AuthService) *AuthRoute {
+	return &AuthRoute{authService}
+}
+
+var ErrInvalidApiKeyId = errors.New("invalid api key id")
+
+// credentials reads the Authorization header of a request: "Bearer <token>" for patrons and staff, "ApiKey <key>" for
+// integrations. The X-API-Key header only carries the tenant API key, which identifies the tenant, see TenantMiddleware.
+func credentials(c *gin.Context) (apiKey string, bearerToken string) {
+	scheme, value, _ := strings.Cut(strings.TrimSpace(c.GetHeader(AuthorizationHeader)), " ")
+	value = strings.TrimSpace(value)
+	switch strings.ToLower(scheme) {
+	case "bearer":
+		return "", value
+	case "apikey":
+		return value, ""
+	}
+	return "", ""
+}
+
+// AuthMiddleware authenticates every request and puts the principal in the request context.
+// It must run after TenantMiddleware, credentials are only valid for the tenant they were issued by.
+func (r *AuthRoute) AuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKey, bearerToken := credentials(c)
+		var principal *models.Principal
+		var err error
+		if tenantKey := strings.TrimSpace(c.GetHeader(ApiKeyHeader)); apiKey == "" && bearerToken == "" && tenantKey != "" {
+			principal, err = r.AuthService.AuthenticateTenantKey(c.Request.Context(), tenantKey)
+		} else {
+			principal, err = r.AuthService.Authenticate(c.Request.Context(), apiKey, bearerToken)
+		}
+		if err != nil {
+			if errors.Is(err, services.ErrUnauthenticated) || errors.Is(err, services.ErrInvalidCredentials) {
+				c.Header("WWW-Authenticate", `Bearer realm="e-library"`)
+			}
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		c.Request = c.Request.WithContext(auth.NewContext(c.Request.Context(), principal))
+		c.Next()
+	}
+}
+
+// Require only lets principals granted the permission through. Services check ownership of loans and accounts on top.
+func (r *AuthRoute) Require(permission models.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := auth.Authorize(c.Request.Context(), permission); err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func (r *AuthRoute) GetPrincipal(c *gin.Context) {
+	principal := auth.FromContext(c.Request.Context())
+	if principal == nil {
+		c.Error(services.ErrUnauthenticated)
+		return
+	}
+	c.JSON(http.StatusOK, principal)
+}
+
+func (r *AuthRoute) IssueToken(c *gin.Context) {
+	var request models.TokenRequest
+	if err := bindJSON(c, &request); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+	if err := request.Validate(); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	token, err := r.AuthService.IssueToken(c.Request.Context(), request.MemberId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, token)
+}
+
+func (r *AuthRoute) RevokeToken(c *gin.Context) {
+	token, err := r.AuthService.RevokeToken(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, token)
+}
+
+func (r *AuthRoute) CreateApiKey(c *gin.Context) {
+	var request models.ApiKeyRequest
+	if err := bindJSON(c, &request); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+	request.Name = strings.TrimSpace(request.Name)
+	if request.Role == "" {
+		request.Role = models.RoleLibrarian
+	}
+	if err := request.Validate(); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	apiKey, err := r.AuthService.CreateApiKey(c.Request.Context(), request.Name, request.Role)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, apiKey)
+}
+
+func (r *AuthRoute) ListApiKeys(c *gin.Context) {
+	apiKeys, err := r.AuthService.ListApiKeys(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, apiKeys)
+}
+
+func (r *AuthRoute) RevokeApiKey(c *gin.Context) {
+	apiKeyId, err := strconv.Atoi(c.Param("id"))
+	if err != nil || apiKeyId <= 0 {
+		c.Error(invalidRequest(ErrInvalidApiKeyId))
+		return
+	}
+
+	apiKey, err := r.AuthService.RevokeApiKey(c.Request.Context(), apiKeyId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, apiKey)
+}
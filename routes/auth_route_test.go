@@ -0,0 +1,131 @@
+package routes
+
+import (
+	"encoding/json"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"github.com/aftaab60/e-library-api/services"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAuthRoute_AuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorMiddleware())
+
+	// Register the route
+	memberRepo := repositories.NewMemberRepository()
+	bookRepo := repositories.NewBookRepository()
+	loanRepo := repositories.NewLoanRepository()
+	authRoute := NewAuthRoute(services.NewAuthService(repositories.NewApiKeyRepository(), repositories.NewTokenRepository(), memberRepo, []byte("test-secret")))
+	tenantRoute := NewTenantRoute(services.NewTenantService(repositories.NewTenantRepository()))
+	loanRoute := NewLoanRoute(services.NewLoanService(loanRepo, bookRepo, repositories.NewChargeRepository(), repositories.NewBranchRepository(), repositories.NewTransferRepository(), memberRepo, repositories.NewLoanEventRepository()))
+	router.Use(tenantRoute.TenantMiddleware(nil, "default"), authRoute.AuthMiddleware())
+	router.GET("/auth/me", authRoute.GetPrincipal)
+	router.POST("/auth/tokens", authRoute.Require(models.PermissionMemberManage), authRoute.IssueToken)
+	router.DELETE("/auth/tokens/:id", authRoute.RevokeToken)
+	router.POST("/auth/api-keys", authRoute.Require(models.PermissionConfigManage), authRoute.CreateApiKey)
+	router.POST("/borrow", loanRoute.BorrowBook)
+	router.GET("/loans/:id", authRoute.Require(models.PermissionLoanOwn), loanRoute.GetLoan)
+	router.POST("/loans/:id/lost", authRoute.Require(models.PermissionLoanManage), loanRoute.MarkLoanLost)
+
+	serve := func(method string, path string, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	tenantKey := map[string]string{ApiKeyHeader: "default-library-key"}
+
+	t.Run("anonymous request", func(t *testing.T) {
+		rec := serve(http.MethodGet, "/auth/me", "", nil)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("invalid bearer token", func(t *testing.T) {
+		rec := serve(http.MethodGet, "/auth/me", "", map[string]string{AuthorizationHeader: "Bearer abc.def.ghi"})
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("issued api key in authorization header", func(t *testing.T) {
+		rec := serve(http.MethodPost, "/auth/api-keys", `{"name": "kiosk"}`, tenantKey)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		var issued models.IssuedApiKey
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &issued))
+
+		rec = serve(http.MethodGet, "/auth/me", "", map[string]string{AuthorizationHeader: "ApiKey " + issued.Key})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"name":"kiosk"`)
+
+		// alongside the tenant named by its header
+		rec = serve(http.MethodGet, "/auth/me", "", map[string]string{AuthorizationHeader: "ApiKey " + issued.Key, TenantHeader: "default"})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"name":"kiosk"`)
+
+		// X-API-Key only carries the tenant API key
+		rec = serve(http.MethodGet, "/auth/me", "", map[string]string{ApiKeyHeader: issued.Key})
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"code":"tenant_not_found"`)
+		rec = serve(http.MethodGet, "/auth/me", "", map[string]string{ApiKeyHeader: issued.Key, TenantHeader: "default"})
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("tenant api key in either header", func(t *testing.T) {
+		for _, headers := range []map[string]string{tenantKey, {AuthorizationHeader: "ApiKey default-library-key"}} {
+			rec := serve(http.MethodGet, "/auth/me", "", headers)
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Contains(t, rec.Body.String(), `"role":"admin"`)
+		}
+	})
+
+	t.Run("patron borrows on its own behalf", func(t *testing.T) {
+		rec := serve(http.MethodPost, "/auth/tokens", `{"member_id": 2}`, tenantKey)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		var issued models.IssuedToken
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &issued))
+		bearer := map[string]string{AuthorizationHeader: "Bearer " + issued.Token}
+
+		// patrons can't issue tokens
+		rec = serve(http.MethodPost, "/auth/tokens", `{"member_id": 1}`, bearer)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		rec = serve(http.MethodPost, "/borrow", `{"title": "book1", "borrower_name": "user1"}`, bearer)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		var loanDetail models.LoanDetail
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &loanDetail))
+		assert.Equal(t, "user2", loanDetail.NameOfBorrower)
+
+		// patrons can't manage loans, and only see their own
+		rec = serve(http.MethodPost, "/loans/1/lost", "", bearer)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"reason":"role patron lacks permission loan:manage"`)
+
+		rec = serve(http.MethodPost, "/borrow", `{"title": "book3", "borrower_name": "user1"}`, tenantKey)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		rec = serve(http.MethodGet, "/loans/2", "", bearer)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"reason":"patrons may only act on their own loans"`)
+
+		// revoked token no longer authenticates
+		rec = serve(http.MethodDelete, "/auth/tokens/"+issued.TokenId, "", bearer)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		rec = serve(http.MethodGet, "/auth/me", "", bearer)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("integration names the borrower", func(t *testing.T) {
+		rec := serve(http.MethodPost, "/borrow", `{"title": "book2", "borrower_name": "user1"}`, tenantKey)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"user1"`)
+	})
+}
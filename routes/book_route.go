@@ -2,7 +2,7 @@ package routes
 
 import (
 	"errors"
-	"github.com/aftaab60/e-library-api/repositories"
+	"github.com/aftaab60/e-library-api/models"
 	"github.com/aftaab60/e-library-api/services"
 	"github.com/gin-gonic/gin"
 	"net/http"
@@ -21,16 +21,63 @@ func (r *BookRoute) GetBookByTitle(c *gin.Context) {
 	ctx := c.Request.Context()
 	title := strings.TrimSpace(c.Param("title"))
 	if err := r.validateTitle(title); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		c.Error(invalidRequest(err))
 		return
 	}
 	book, err := r.BookService.GetBookByTitle(ctx, title)
 	if err != nil {
-		if errors.Is(err, repositories.ErrBookNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
-		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, book)
+}
+
+// UpdateLoanType sets how the copies of a book are lent
+func (r *BookRoute) UpdateLoanType(c *gin.Context) {
+	title := strings.TrimSpace(c.Param("title"))
+	if err := r.validateTitle(title); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+	var request models.LoanTypeRequest
+	if err := bindJSON(c, &request); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+	if err := request.Validate(); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	book, err := r.BookService.UpdateLoanType(c.Request.Context(), title, &request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, book)
+}
+
+// UpdateIsbn sets the ISBN of a book
+func (r *BookRoute) UpdateIsbn(c *gin.Context) {
+	title := strings.TrimSpace(c.Param("title"))
+	if err := r.validateTitle(title); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+	var request models.IsbnRequest
+	if err := bindJSON(c, &request); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+	request.Isbn = strings.TrimSpace(request.Isbn)
+	if err := request.Validate(); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	book, err := r.BookService.UpdateIsbn(c.Request.Context(), title, &request)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, book)
@@ -7,14 +7,16 @@ import (
 	"github.com/stretchr/testify/assert"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
 func TestGetBookByTitle(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
+	router.Use(ErrorMiddleware())
 	// Register the route
-	bookRoute := NewBookRoute(services.NewBookService(repositories.NewBookRepository()))
+	bookRoute := NewBookRoute(services.NewBookService(repositories.NewBookRepository(), repositories.NewBranchRepository()))
 	router.GET("/book/:title", bookRoute.GetBookByTitle)
 
 	t.Run("success", func(t *testing.T) {
@@ -27,7 +29,7 @@ func TestGetBookByTitle(t *testing.T) {
 		router.ServeHTTP(rec, req)
 
 		assert.Equal(t, http.StatusOK, rec.Code)
-		expectedBody := `{"title": "book1", "available_copies": 5}`
+		expectedBody := `{"title": "book1", "available_copies": 5, "loan_type": "standard", "branches": [{"branch_id": 1, "branch_name": "main", "available_copies": 5, "in_transit_copies": 0}]}`
 		assert.JSONEq(t, expectedBody, rec.Body.String())
 	})
 
@@ -41,8 +43,9 @@ func TestGetBookByTitle(t *testing.T) {
 		router.ServeHTTP(rec, req)
 
 		assert.Equal(t, http.StatusBadRequest, rec.Code)
-		expectedBody := `{"message": "title is empty"}`
+		expectedBody := `{"type": "/problems/invalid_request", "title": "Invalid request", "status": 400, "detail": "title is empty", "instance": "/book/ ", "code": "invalid_request"}`
 		assert.JSONEq(t, expectedBody, rec.Body.String())
+		assert.Equal(t, ProblemContentType, rec.Header().Get("Content-Type"))
 	})
 
 	t.Run("book not found", func(t *testing.T) {
@@ -55,7 +58,77 @@ func TestGetBookByTitle(t *testing.T) {
 		router.ServeHTTP(rec, req)
 
 		assert.Equal(t, http.StatusNotFound, rec.Code)
-		expectedBody := `{"message":"book not found"}`
+		expectedBody := `{"type": "/problems/book_not_found", "title": "Book not found", "status": 404, "detail": "book not found", "instance": "/book/book100", "code": "book_not_found"}`
 		assert.JSONEq(t, expectedBody, rec.Body.String())
 	})
 }
+
+func TestBookRoute_UpdateLoanType(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorMiddleware())
+	// Register the routes
+	bookRepo, branchRepo := repositories.NewBookRepository(), repositories.NewBranchRepository()
+	bookRoute := NewBookRoute(services.NewBookService(bookRepo, branchRepo))
+	loanService := services.NewLoanService(repositories.NewLoanRepository(), bookRepo, repositories.NewChargeRepository(), branchRepo,
+		repositories.NewTransferRepository(), repositories.NewMemberRepository(), repositories.NewLoanEventRepository())
+	loanRoute := NewLoanRoute(loanService)
+	router.GET("/book/:title", bookRoute.GetBookByTitle)
+	router.PUT("/book/:title/loan-type", bookRoute.UpdateLoanType)
+	router.PUT("/book/:title/isbn", bookRoute.UpdateIsbn)
+	router.POST("/borrow", loanRoute.BorrowBook)
+
+	serve := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("reference-only books are not lent", func(t *testing.T) {
+		rec := serve(http.MethodPut, "/book/book2/loan-type", `{"loan_type": "reference_only"}`)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"loan_type":"reference_only"`)
+
+		rec = serve(http.MethodPost, "/borrow", `{"title": "book2", "borrower_name": "user1"}`)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"code":"reference_only"`)
+	})
+
+	t.Run("short loans show their hours", func(t *testing.T) {
+		rec := serve(http.MethodPut, "/book/book1/loan-type", `{"loan_type": "short_loan", "loan_hours": 4}`)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		rec = serve(http.MethodGet, "/book/book1", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"loan_type":"short_loan","loan_hours":4`)
+	})
+
+	t.Run("reject invalid loan types", func(t *testing.T) {
+		rec := serve(http.MethodPut, "/book/book1/loan-type", `{"loan_type": "overnight"}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"field":"loan_type"`)
+		rec = serve(http.MethodPut, "/book/book1/loan-type", `{"loan_type": "short_loan"}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"field":"loan_hours"`)
+		rec = serve(http.MethodPut, "/book/book100/loan-type", `{"loan_type": "standard"}`)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("set the ISBN of a book", func(t *testing.T) {
+		rec := serve(http.MethodPut, "/book/book3/isbn", `{"isbn": "978-0-306-40615-7"}`)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		rec = serve(http.MethodGet, "/book/book3", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"isbn":"978-0-306-40615-7"`)
+	})
+
+	t.Run("reject an invalid ISBN", func(t *testing.T) {
+		rec := serve(http.MethodPut, "/book/book3/isbn", `{"isbn": "978-0-306-40615-8"}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"code":"invalid_isbn"`)
+	})
+}
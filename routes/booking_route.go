@@ -0,0 +1,128 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/services"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type BookingRoute struct {
+	BookingService *services.BookingService
+	//LoanService lends the copies held by bookings
+	LoanService *services.LoanService
+}
+
+func NewBookingRoute(bookingService *services.BookingService, loanService *services.LoanService) *BookingRoute {
+	return &BookingRoute{bookingService, loanService}
+}
+
+var ErrInvalidBookingId = errors.New("invalid booking id")
+
+func (r *BookingRoute) bookingIdParam(c *gin.Context) (int, bool) {
+	bookingId, err := strconv.Atoi(c.Param("id"))
+	if err != nil || bookingId <= 0 {
+		c.Error(invalidRequest(ErrInvalidBookingId))
+		return 0, false
+	}
+	return bookingId, true
+}
+
+func (r *BookingRoute) CreateBooking(c *gin.Context) {
+	ctx := c.Request.Context()
+	var request models.BookingRequest
+	if err := bindJSON(c, &request); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+	request.Title = strings.TrimSpace(request.Title)
+	request.BorrowerName = borrowerOf(ctx, request.BorrowerName)
+	if err := request.Validate(r.BookingService.Clock.Now()); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	booking, err := r.BookingService.CreateBooking(ctx, &request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, booking)
+}
+
+func (r *BookingRoute) GetBooking(c *gin.Context) {
+	bookingId, ok := r.bookingIdParam(c)
+	if !ok {
+		return
+	}
+	booking, err := r.BookingService.GetBookingById(c.Request.Context(), bookingId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, booking)
+}
+
+func (r *BookingRoute) CancelBooking(c *gin.Context) {
+	bookingId, ok := r.bookingIdParam(c)
+	if !ok {
+		return
+	}
+	booking, err := r.BookingService.CancelBooking(c.Request.Context(), bookingId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, booking)
+}
+
+// BorrowBooking lends a copy held by a booking to the borrower named in the body
+func (r *BookingRoute) BorrowBooking(c *gin.Context) {
+	ctx := c.Request.Context()
+	bookingId, ok := r.bookingIdParam(c)
+	if !ok {
+		return
+	}
+	var request models.BookingLoanRequest
+	if err := bindJSON(c, &request); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+	request.BorrowerName = borrowerOf(ctx, request.BorrowerName)
+	if err := request.Validate(); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	loanDetail, err := r.LoanService.BorrowBooking(ctx, bookingId, request.BorrowerName)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, loanDetail)
+}
+
+// GetBookingCalendar returns the bookings of a title, day by day over ?from= and ?to=, at ?branch_id= or every branch
+func (r *BookingRoute) GetBookingCalendar(c *gin.Context) {
+	title := strings.TrimSpace(c.Param("title"))
+	var filter models.BookingFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.Error(invalidRequest(fmt.Errorf("invalid query parameters: %w", err)))
+		return
+	}
+	if err := filter.Validate(r.BookingService.Clock.Now()); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	calendar, err := r.BookingService.GetBookingCalendar(c.Request.Context(), title, &filter)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, calendar)
+}
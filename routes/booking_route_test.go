@@ -0,0 +1,98 @@
+package routes
+
+import (
+	"encoding/json"
+	"github.com/aftaab60/e-library-api/internal/clock"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"github.com/aftaab60/e-library-api/services"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBookingRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorMiddleware())
+
+	travelClock := clock.NewAdjustable(clock.System{})
+	travelClock.Freeze()
+	travelClock.Set(time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC))
+	loanRepo, bookRepo, branchRepo := repositories.NewLoanRepository(), repositories.NewBookRepository(), repositories.NewBranchRepository()
+	bookingService := services.NewBookingService(repositories.NewBookingRepository(), bookRepo, branchRepo, loanRepo)
+	bookingService.Clock = travelClock
+	loanService := services.NewLoanService(loanRepo, bookRepo, repositories.NewChargeRepository(), branchRepo,
+		repositories.NewTransferRepository(), repositories.NewMemberRepository(), repositories.NewLoanEventRepository())
+	loanService.Clock = travelClock
+	loanService.Bookings = bookingService
+
+	// Register the routes
+	bookingRoute := NewBookingRoute(bookingService, &loanService)
+	router.GET("/book/:title/bookings", bookingRoute.GetBookingCalendar)
+	router.POST("/bookings", bookingRoute.CreateBooking)
+	router.GET("/bookings/:id", bookingRoute.GetBooking)
+	router.POST("/bookings/:id/cancel", bookingRoute.CancelBooking)
+	router.POST("/bookings/:id/borrow", bookingRoute.BorrowBooking)
+
+	serve := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("book copies for a class and borrow them once it starts", func(t *testing.T) {
+		rec := serve(http.MethodPost, "/bookings", `{"title": "book2", "borrower_name": "teacher", "starts_on": "2025-04-01", "ends_on": "2025-04-30", "copies": 2}`)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		var booking models.Booking
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &booking))
+		assert.Equal(t, models.BookingStatusActive, booking.Status)
+
+		rec = serve(http.MethodPost, "/bookings", `{"title": "book2", "borrower_name": "teacher", "starts_on": "2025-04-20", "ends_on": "2025-05-10", "copies": 2}`)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Contains(t, rec.Body.String(), "booking_conflict")
+		rec = serve(http.MethodPost, "/bookings/1/borrow", `{"borrower_name": "student1"}`)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Contains(t, rec.Body.String(), "booking_not_open")
+
+		rec = serve(http.MethodGet, "/book/book2/bookings?from=2025-03-31&to=2025-04-01", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var calendar models.BookingCalendar
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &calendar))
+		assert.Equal(t, []models.BookingDay{{Date: "2025-03-31", BookedCopies: 0}, {Date: "2025-04-01", BookedCopies: 2}}, calendar.Days)
+
+		travelClock.Set(time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC))
+		rec = serve(http.MethodPost, "/bookings/1/borrow", `{"borrower_name": "student1"}`)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		var loanDetail models.LoanDetail
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &loanDetail))
+		assert.Equal(t, "2025-04-30", loanDetail.ReturnDate.Format(models.DateLayout))
+	})
+
+	t.Run("cancel a booking", func(t *testing.T) {
+		rec := serve(http.MethodPost, "/bookings/1/cancel", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"status":"cancelled"`)
+		rec = serve(http.MethodPost, "/bookings/1/cancel", "")
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Contains(t, rec.Body.String(), "booking_not_active")
+	})
+
+	t.Run("reject invalid bookings", func(t *testing.T) {
+		rec := serve(http.MethodPost, "/bookings", `{"title": "book2", "borrower_name": "teacher", "starts_on": "2025-03-01", "ends_on": "2025-02-01"}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"field":"starts_on"`)
+		rec = serve(http.MethodGet, "/bookings/0", "")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		rec = serve(http.MethodGet, "/bookings/100", "")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Contains(t, rec.Body.String(), "booking_not_found")
+	})
+}
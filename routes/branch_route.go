@@ -0,0 +1,137 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/services"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type BranchRoute struct {
+	BranchService services.BranchService
+}
+
+func NewBranchRoute(branchService services.BranchService) *BranchRoute {
+	return &BranchRoute{branchService}
+}
+
+func (r *BranchRoute) ListBranches(c *gin.Context) {
+	branches, err := r.BranchService.ListBranches(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, branches)
+}
+
+func (r *BranchRoute) CreateBranch(c *gin.Context) {
+	var request models.BranchRequest
+	if err := bindJSON(c, &request); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+	request.Name = strings.TrimSpace(request.Name)
+	request.TimeZone = strings.TrimSpace(request.TimeZone)
+	if err := request.Validate(); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	branch, err := r.BranchService.CreateBranch(c.Request.Context(), &request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, branch)
+}
+
+func (r *BranchRoute) UpdateBranch(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.Error(invalidRequest(ErrInvalidBranchId))
+		return
+	}
+	var request models.BranchRequest
+	if err := bindJSON(c, &request); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+	request.Name = strings.TrimSpace(request.Name)
+	request.TimeZone = strings.TrimSpace(request.TimeZone)
+	if err := request.Validate(); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	branch, err := r.BranchService.UpdateBranch(c.Request.Context(), id, &request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, branch)
+}
+
+func (r *BranchRoute) ListTransfers(c *gin.Context) {
+	status := models.TransferStatus(strings.TrimSpace(c.Query("status")))
+	transfers, err := r.BranchService.ListTransfers(c.Request.Context(), status)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, transfers)
+}
+
+func (r *BranchRoute) RequestTransfer(c *gin.Context) {
+	ctx := c.Request.Context()
+	var request models.TransferRequest
+	if err := bindJSON(c, &request); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+	request.Title = strings.TrimSpace(request.Title)
+
+	//validate request body for certain parameters
+	if err := request.Validate(); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	transfer, err := r.BranchService.RequestTransfer(ctx, &request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, transfer)
+}
+
+func (r *BranchRoute) DispatchTransfer(c *gin.Context) {
+	r.updateTransfer(c, r.BranchService.DispatchTransfer)
+}
+
+func (r *BranchRoute) ReceiveTransfer(c *gin.Context) {
+	r.updateTransfer(c, r.BranchService.ReceiveTransfer)
+}
+
+func (r *BranchRoute) CancelTransfer(c *gin.Context) {
+	r.updateTransfer(c, r.BranchService.CancelTransfer)
+}
+
+var ErrInvalidTransferId = errors.New("invalid transfer id")
+
+func (r *BranchRoute) updateTransfer(c *gin.Context, update func(ctx context.Context, id int) (*models.Transfer, error)) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.Error(invalidRequest(ErrInvalidTransferId))
+		return
+	}
+	transfer, err := update(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, transfer)
+}
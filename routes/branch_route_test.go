@@ -0,0 +1,110 @@
+package routes
+
+import (
+	"encoding/json"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"github.com/aftaab60/e-library-api/services"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBranchRoute_RequestTransfer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorMiddleware())
+
+	// Register the route
+	branchRoute := NewBranchRoute(services.NewBranchService(repositories.NewBookRepository(), repositories.NewBranchRepository(), repositories.NewTransferRepository()))
+	router.POST("/transfers", branchRoute.RequestTransfer)
+	router.POST("/transfers/:id/dispatch", branchRoute.DispatchTransfer)
+
+	t.Run("invalid request body", func(t *testing.T) {
+		requestBody := `{"title": "book1", "from_branch_id": 1, "to_branch_id": 1}`
+		req, err := http.NewRequest(http.MethodPost, "/transfers", strings.NewReader(requestBody))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("successfully request and dispatch a transfer", func(t *testing.T) {
+		requestBody := `{"title": "book1", "from_branch_id": 1, "to_branch_id": 2}`
+		req, err := http.NewRequest(http.MethodPost, "/transfers", strings.NewReader(requestBody))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		var transfer models.Transfer
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &transfer))
+		assert.Equal(t, models.TransferStatusRequested, transfer.Status)
+
+		req, err = http.NewRequest(http.MethodPost, "/transfers/1/dispatch", nil)
+		assert.NoError(t, err)
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		// already dispatched
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+}
+
+func TestBranchRoute_UpdateBranch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorMiddleware())
+
+	branchRoute := NewBranchRoute(services.NewBranchService(repositories.NewBookRepository(), repositories.NewBranchRepository(), repositories.NewTransferRepository()))
+	router.POST("/branches", branchRoute.CreateBranch)
+	router.PUT("/branches/:id", branchRoute.UpdateBranch)
+
+	t.Run("unknown time zone", func(t *testing.T) {
+		requestBody := `{"name": "west", "time_zone": "Europe/Atlantis"}`
+		req, err := http.NewRequest(http.MethodPost, "/branches", strings.NewReader(requestBody))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "time_zone")
+	})
+
+	t.Run("successfully create a branch", func(t *testing.T) {
+		requestBody := `{"name": "west", "time_zone": "Europe/London"}`
+		req, err := http.NewRequest(http.MethodPost, "/branches", strings.NewReader(requestBody))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		var branch models.Branch
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &branch))
+		assert.Equal(t, "Europe/London", branch.TimeZone)
+	})
+
+	t.Run("rename a branch to an existing name", func(t *testing.T) {
+		requestBody := `{"name": "main", "time_zone": "UTC"}`
+		req, err := http.NewRequest(http.MethodPut, "/branches/2", strings.NewReader(requestBody))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+}
@@ -0,0 +1,108 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/services"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type CalendarRoute struct {
+	CalendarService *services.CalendarService
+}
+
+func NewCalendarRoute(calendarService *services.CalendarService) *CalendarRoute {
+	return &CalendarRoute{calendarService}
+}
+
+var ErrInvalidBranchId = errors.New("invalid branch id")
+
+var ErrInvalidClosureId = errors.New("invalid closure id")
+
+// GetCalendar returns when a branch is open, day by day over ?from= and ?to=
+func (r *CalendarRoute) GetCalendar(c *gin.Context) {
+	branchId, err := strconv.Atoi(c.Param("id"))
+	if err != nil || branchId <= 0 {
+		c.Error(invalidRequest(ErrInvalidBranchId))
+		return
+	}
+	var filter models.CalendarFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.Error(invalidRequest(fmt.Errorf("invalid query parameters: %w", err)))
+		return
+	}
+	if err := filter.Validate(r.CalendarService.Clock.Now()); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	calendar, err := r.CalendarService.GetCalendar(c.Request.Context(), branchId, &filter)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, calendar)
+}
+
+func (r *CalendarRoute) SetOpeningHours(c *gin.Context) {
+	branchId, err := strconv.Atoi(c.Param("id"))
+	if err != nil || branchId <= 0 {
+		c.Error(invalidRequest(ErrInvalidBranchId))
+		return
+	}
+	var request models.OpeningHoursRequest
+	if err := bindJSON(c, &request); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+	if err := request.Validate(); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	calendar, err := r.CalendarService.SetOpeningHours(c.Request.Context(), branchId, &request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, calendar)
+}
+
+func (r *CalendarRoute) CreateClosure(c *gin.Context) {
+	var request models.ClosureRequest
+	if err := bindJSON(c, &request); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+	request.Date = strings.TrimSpace(request.Date)
+	request.Name = strings.TrimSpace(request.Name)
+	if err := request.Validate(); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	closure, err := r.CalendarService.CreateClosure(c.Request.Context(), &request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, closure)
+}
+
+func (r *CalendarRoute) DeleteClosure(c *gin.Context) {
+	closureId, err := strconv.Atoi(c.Param("id"))
+	if err != nil || closureId <= 0 {
+		c.Error(invalidRequest(ErrInvalidClosureId))
+		return
+	}
+
+	if err := r.CalendarService.DeleteClosure(c.Request.Context(), closureId); err != nil {
+		c.Error(err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
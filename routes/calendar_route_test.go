@@ -0,0 +1,76 @@
+package routes
+
+import (
+	"encoding/json"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"github.com/aftaab60/e-library-api/services"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCalendarRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorMiddleware())
+
+	// Register the routes
+	calendarRoute := NewCalendarRoute(services.NewCalendarService(repositories.NewCalendarRepository(), repositories.NewBranchRepository()))
+	router.GET("/branches/:id/calendar", calendarRoute.GetCalendar)
+	router.PUT("/branches/:id/hours", calendarRoute.SetOpeningHours)
+	router.POST("/closures", calendarRoute.CreateClosure)
+	router.DELETE("/closures/:id", calendarRoute.DeleteClosure)
+
+	serve := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("set opening hours and close on a holiday", func(t *testing.T) {
+		rec := serve(http.MethodPut, "/branches/1/hours", `{"hours": [{"weekday": "Monday", "opens": "09:00", "closes": "17:00"}]}`)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		rec = serve(http.MethodPost, "/closures", `{"date": "2029-01-01", "name": "New Year's Day", "recurring": true}`)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		rec = serve(http.MethodPost, "/closures", `{"date": "2029-01-01", "name": "Holiday"}`)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Contains(t, rec.Body.String(), "existing_closure")
+
+		rec = serve(http.MethodGet, "/branches/1/calendar?from=2030-01-01&to=2030-01-08", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var calendar models.BranchCalendar
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &calendar))
+		assert.Len(t, calendar.Days, 8)
+		//7 January 2030 is a Monday, 1 January is a holiday
+		assert.Equal(t, "New Year's Day", calendar.Days[0].Reason)
+		assert.True(t, calendar.Days[6].Open)
+		assert.Equal(t, "monday", calendar.Hours[0].Weekday)
+	})
+
+	t.Run("reject invalid calendars", func(t *testing.T) {
+		rec := serve(http.MethodPut, "/branches/1/hours", `{"hours": [{"weekday": "someday", "opens": "17:00", "closes": "09:00"}]}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"field":"hours[0].weekday"`)
+		assert.Contains(t, rec.Body.String(), `"field":"hours[0].closes"`)
+
+		rec = serve(http.MethodGet, "/branches/1/calendar?from=2030-01-08&to=2030-01-01", "")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		rec = serve(http.MethodGet, "/branches/100/calendar", "")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("delete a closure", func(t *testing.T) {
+		rec := serve(http.MethodDelete, "/closures/1", "")
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		rec = serve(http.MethodDelete, "/closures/1", "")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Contains(t, rec.Body.String(), "closure_not_found")
+	})
+}
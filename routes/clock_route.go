@@ -0,0 +1,54 @@
+package routes
+
+import (
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/services"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+type ClockRoute struct {
+	ClockService *services.ClockService
+}
+
+func NewClockRoute(clockService *services.ClockService) *ClockRoute {
+	return &ClockRoute{clockService}
+}
+
+func (r *ClockRoute) GetClock(c *gin.Context) {
+	state, err := r.ClockService.GetClock(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, state)
+}
+
+// AdjustClock travels in time: sets, advances, freezes or unfreezes the clock
+func (r *ClockRoute) AdjustClock(c *gin.Context) {
+	var request models.ClockRequest
+	if err := bindJSON(c, &request); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+	if err := request.Validate(); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	state, err := r.ClockService.AdjustClock(c.Request.Context(), &request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, state)
+}
+
+func (r *ClockRoute) ResetClock(c *gin.Context) {
+	state, err := r.ClockService.ResetClock(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, state)
+}
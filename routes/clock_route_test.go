@@ -0,0 +1,59 @@
+package routes
+
+import (
+	"encoding/json"
+	"github.com/aftaab60/e-library-api/internal/clock"
+	"github.com/aftaab60/e-library-api/services"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClockRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorMiddleware())
+
+	// Register the routes
+	clockRoute := NewClockRoute(services.NewClockService(clock.NewAdjustable(clock.System{}), "default"))
+	router.GET("/admin/clock", clockRoute.GetClock)
+	router.PUT("/admin/clock", clockRoute.AdjustClock)
+	router.DELETE("/admin/clock", clockRoute.ResetClock)
+
+	serve := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("set and freeze the clock", func(t *testing.T) {
+		rec := serve(http.MethodPut, "/admin/clock", `{"now": "2025-03-03T12:00:00Z", "advance": "-36h", "frozen": true}`)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var state clock.State
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &state))
+		assert.Equal(t, "2025-03-02T00:00:00Z", state.Now.Format("2006-01-02T15:04:05Z07:00"))
+		assert.True(t, state.Frozen)
+
+		rec = serve(http.MethodGet, "/admin/clock", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"now":"2025-03-02T00:00:00Z"`)
+	})
+
+	t.Run("reject invalid adjustments", func(t *testing.T) {
+		rec := serve(http.MethodPut, "/admin/clock", `{"advance": "two weeks"}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"field":"advance"`)
+	})
+
+	t.Run("reset the clock", func(t *testing.T) {
+		rec := serve(http.MethodDelete, "/admin/clock", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"frozen":false`)
+	})
+}
@@ -0,0 +1,170 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/services"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type CourseRoute struct {
+	CourseService *services.CourseService
+}
+
+func NewCourseRoute(courseService *services.CourseService) *CourseRoute {
+	return &CourseRoute{courseService}
+}
+
+var ErrInvalidCourseId = errors.New("invalid course id")
+
+var ErrInvalidReserveId = errors.New("invalid reserve id")
+
+// idParam reads the id path parameter, failing the request with invalid when it is not a positive number
+func (r *CourseRoute) idParam(c *gin.Context, invalid error) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.Error(invalidRequest(invalid))
+		return 0, false
+	}
+	return id, true
+}
+
+func (r *CourseRoute) CreateTerm(c *gin.Context) {
+	var request models.TermRequest
+	if err := bindJSON(c, &request); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+	request.Name = strings.TrimSpace(request.Name)
+	if err := request.Validate(); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	term, err := r.CourseService.CreateTerm(c.Request.Context(), &request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, term)
+}
+
+func (r *CourseRoute) ListTerms(c *gin.Context) {
+	terms, err := r.CourseService.ListTerms(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, terms)
+}
+
+func (r *CourseRoute) CreateCourse(c *gin.Context) {
+	var request models.CourseRequest
+	if err := bindJSON(c, &request); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+	request.Code = strings.TrimSpace(request.Code)
+	request.Name = strings.TrimSpace(request.Name)
+	for i := range request.Instructors {
+		request.Instructors[i] = strings.TrimSpace(request.Instructors[i])
+	}
+	if err := request.Validate(); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	course, err := r.CourseService.CreateCourse(c.Request.Context(), &request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, course)
+}
+
+// ListCourses lists the courses of ?term_id=, or of every term
+func (r *CourseRoute) ListCourses(c *gin.Context) {
+	var filter models.CourseFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.Error(invalidRequest(fmt.Errorf("invalid query parameters: %w", err)))
+		return
+	}
+	if err := filter.Validate(); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	courses, err := r.CourseService.ListCourses(c.Request.Context(), &filter)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, courses)
+}
+
+func (r *CourseRoute) GetCourse(c *gin.Context) {
+	courseId, ok := r.idParam(c, ErrInvalidCourseId)
+	if !ok {
+		return
+	}
+	course, err := r.CourseService.GetCourseById(c.Request.Context(), courseId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, course)
+}
+
+func (r *CourseRoute) GetCourseReserves(c *gin.Context) {
+	courseId, ok := r.idParam(c, ErrInvalidCourseId)
+	if !ok {
+		return
+	}
+	reserves, err := r.CourseService.GetCourseReserves(c.Request.Context(), courseId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, reserves)
+}
+
+func (r *CourseRoute) AddReserve(c *gin.Context) {
+	courseId, ok := r.idParam(c, ErrInvalidCourseId)
+	if !ok {
+		return
+	}
+	var request models.ReserveRequest
+	if err := bindJSON(c, &request); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+	request.Title = strings.TrimSpace(request.Title)
+	if err := request.Validate(); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	reserve, err := r.CourseService.AddReserve(c.Request.Context(), courseId, &request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, reserve)
+}
+
+func (r *CourseRoute) EndReserve(c *gin.Context) {
+	reserveId, ok := r.idParam(c, ErrInvalidReserveId)
+	if !ok {
+		return
+	}
+	reserve, err := r.CourseService.EndReserve(c.Request.Context(), reserveId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, reserve)
+}
@@ -0,0 +1,90 @@
+package routes
+
+import (
+	"encoding/json"
+	"github.com/aftaab60/e-library-api/internal/clock"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"github.com/aftaab60/e-library-api/services"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCourseRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorMiddleware())
+
+	travelClock := clock.NewAdjustable(clock.System{})
+	travelClock.Freeze()
+	travelClock.Set(time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC))
+	courseService := services.NewCourseService(repositories.NewCourseRepository(), repositories.NewBookRepository(),
+		repositories.NewBranchRepository())
+	courseService.Clock = travelClock
+
+	// Register the routes
+	courseRoute := NewCourseRoute(courseService)
+	router.POST("/terms", courseRoute.CreateTerm)
+	router.GET("/courses", courseRoute.ListCourses)
+	router.POST("/courses", courseRoute.CreateCourse)
+	router.GET("/courses/:id/reserves", courseRoute.GetCourseReserves)
+	router.POST("/courses/:id/reserves", courseRoute.AddReserve)
+	router.POST("/reserves/:id/end", courseRoute.EndReserve)
+
+	serve := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("put a book on reserve for a course and end it", func(t *testing.T) {
+		rec := serve(http.MethodPost, "/terms", `{"name": "Spring 2025", "starts_on": "2025-01-13", "ends_on": "2025-05-09"}`)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		rec = serve(http.MethodPost, "/courses", `{"term_id": 1, "code": "CS101", "name": "Programming", "instructors": ["ada"]}`)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		rec = serve(http.MethodPost, "/courses", `{"term_id": 1, "code": "CS101", "name": "Programming"}`)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Contains(t, rec.Body.String(), "existing_course")
+
+		rec = serve(http.MethodPost, "/courses/1/reserves", `{"title": "book1", "loan_type": "short_loan", "loan_hours": 2}`)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		var reserve models.Reserve
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &reserve))
+		assert.Equal(t, models.ReserveStatusActive, reserve.Status)
+		assert.Equal(t, models.LoanTypeStandard, reserve.PreviousLoanType)
+
+		rec = serve(http.MethodPost, "/reserves/1/end", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		rec = serve(http.MethodPost, "/reserves/1/end", "")
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Contains(t, rec.Body.String(), "reserve_not_active")
+
+		rec = serve(http.MethodGet, "/courses/1/reserves", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var reserves models.CourseReserves
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &reserves))
+		assert.Equal(t, "Spring 2025", reserves.Term.Name)
+		assert.Len(t, reserves.Reserves, 1)
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		rec := serve(http.MethodPost, "/terms", `{"name": "Long", "starts_on": "2025-01-01", "ends_on": "2026-06-01"}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		rec = serve(http.MethodPost, "/courses/1/reserves", `{"title": "book1", "loan_type": "standard", "loan_hours": 2}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		rec = serve(http.MethodGet, "/courses?term_id=-1", "")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		rec = serve(http.MethodGet, "/courses/abc/reserves", "")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		rec = serve(http.MethodGet, "/courses/9/reserves", "")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
@@ -0,0 +1,125 @@
+package routes
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/aftaab60/e-library-api/services"
+	"github.com/gin-gonic/gin"
+	"io"
+	"log"
+	"net/http"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+type IdempotencyRoute struct {
+	IdempotencyService services.IdempotencyService
+}
+
+func NewIdempotencyRoute(idempotencyService services.IdempotencyService) *IdempotencyRoute {
+	return &IdempotencyRoute{idempotencyService}
+}
+
+var ErrInvalidIdempotencyKey = errors.New("invalid Idempotency-Key header, must be 1 to 255 characters")
+
+// requestHash identifies a request by method, path and body. JSON bodies are compared by value,
+// so a retry that encodes the same body differently is still the same request.
+func requestHash(c *gin.Context, body []byte) string {
+	var value any
+	if err := json.Unmarshal(body, &value); err == nil {
+		if normalized, err := json.Marshal(value); err == nil {
+			body = normalized
+		}
+	}
+	hash := sha256.New()
+	hash.Write([]byte(c.Request.Method + " " + c.Request.URL.Path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// responseRecorder keeps a copy of the response body written by the handler
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// IdempotencyMiddleware replays the first response to requests retried with the same Idempotency-Key header.
+// Requests without the header are processed as usual. It must run after AuthMiddleware, keys are scoped to the caller.
+// Server errors aren't stored, a retry processes the request again.
+func (r *IdempotencyRoute) IdempotencyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.Error(ErrInvalidIdempotencyKey)
+			c.Abort()
+			return
+		}
+
+		ctx := c.Request.Context()
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.Error(invalidRequest(err))
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		record, err := r.IdempotencyService.Begin(ctx, key, requestHash(c, body))
+		if err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		if record != nil {
+			c.Header(IdempotentReplayedHeader, "true")
+			c.Data(record.StatusCode, record.ContentType, record.Body)
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		completed := false
+		//a panicking handler releases the key as well
+		defer func() {
+			if !completed {
+				if err := r.IdempotencyService.Release(ctx, key); err != nil {
+					log.Printf("error releasing idempotency key: %v", err)
+				}
+			}
+		}()
+
+		c.Next()
+		//errors are written by the error middleware after this one, the response has to be written to be stored
+		writeProblem(c)
+
+		if recorder.Status() >= http.StatusInternalServerError {
+			return
+		}
+		if err := r.IdempotencyService.Complete(ctx, key, recorder.Status(), recorder.Header().Get("Content-Type"), recorder.body.Bytes()); err != nil {
+			return
+		}
+		completed = true
+	}
+}
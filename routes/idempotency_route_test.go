@@ -0,0 +1,110 @@
+package routes
+
+import (
+	"encoding/json"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"github.com/aftaab60/e-library-api/services"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIdempotencyRoute_IdempotencyMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorMiddleware())
+
+	// Register the route
+	loanRoute := NewLoanRoute(services.NewLoanService(repositories.NewLoanRepository(), repositories.NewBookRepository(), repositories.NewChargeRepository(), repositories.NewBranchRepository(), repositories.NewTransferRepository(), repositories.NewMemberRepository(), repositories.NewLoanEventRepository()))
+	idempotencyRoute := NewIdempotencyRoute(services.NewIdempotencyService(repositories.NewIdempotencyRepository()))
+	router.POST("/borrow", idempotencyRoute.IdempotencyMiddleware(), loanRoute.BorrowBook)
+	router.POST("/extend", idempotencyRoute.IdempotencyMiddleware(), loanRoute.ExtendLoan)
+	router.POST("/loans/:id/extend", idempotencyRoute.IdempotencyMiddleware(), loanRoute.ExtendLoanById)
+
+	serve := func(path string, body string, key string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("retried borrow replays the first response", func(t *testing.T) {
+		first := serve("/borrow", `{"title": "book1", "borrower_name": "borrower1"}`, "borrow-1")
+		assert.Equal(t, http.StatusCreated, first.Code)
+
+		retry := serve("/borrow", `{"borrower_name":"borrower1","title":"book1"}`, "borrow-1")
+		assert.Equal(t, http.StatusCreated, retry.Code)
+		assert.Equal(t, "true", retry.Header().Get(IdempotentReplayedHeader))
+		assert.Equal(t, first.Body.String(), retry.Body.String())
+
+		// without a key the duplicate is a conflict
+		assert.Equal(t, http.StatusConflict, serve("/borrow", `{"title": "book1", "borrower_name": "borrower1"}`, "").Code)
+	})
+
+	t.Run("retried extend extends once", func(t *testing.T) {
+		first := serve("/extend", `{"title": "book1", "borrower_name": "borrower1"}`, "extend-1")
+		assert.Equal(t, http.StatusOK, first.Code)
+		retry := serve("/extend", `{"title": "book1", "borrower_name": "borrower1"}`, "extend-1")
+		assert.Equal(t, http.StatusOK, retry.Code)
+
+		var firstLoan, retriedLoan models.LoanDetail
+		assert.NoError(t, json.Unmarshal(first.Body.Bytes(), &firstLoan))
+		assert.NoError(t, json.Unmarshal(retry.Body.Bytes(), &retriedLoan))
+		assert.Equal(t, firstLoan.ReturnDate, retriedLoan.ReturnDate)
+
+		second := serve("/extend", `{"title": "book1", "borrower_name": "borrower1"}`, "extend-2")
+		var secondLoan models.LoanDetail
+		assert.NoError(t, json.Unmarshal(second.Body.Bytes(), &secondLoan))
+		assert.True(t, secondLoan.ReturnDate.After(firstLoan.ReturnDate))
+	})
+
+	t.Run("retried extend by id extends once", func(t *testing.T) {
+		// loan 1 is the borrow of book1 above
+		first := serve("/loans/1/extend", "", "extend-by-id-1")
+		assert.Equal(t, http.StatusOK, first.Code)
+		retry := serve("/loans/1/extend", "", "extend-by-id-1")
+		assert.Equal(t, http.StatusOK, retry.Code)
+		assert.Equal(t, "true", retry.Header().Get(IdempotentReplayedHeader))
+
+		var firstLoan, retriedLoan models.LoanDetail
+		assert.NoError(t, json.Unmarshal(first.Body.Bytes(), &firstLoan))
+		assert.NoError(t, json.Unmarshal(retry.Body.Bytes(), &retriedLoan))
+		assert.Equal(t, firstLoan.ReturnDate, retriedLoan.ReturnDate)
+
+		second := serve("/loans/1/extend", "", "extend-by-id-2")
+		var secondLoan models.LoanDetail
+		assert.NoError(t, json.Unmarshal(second.Body.Bytes(), &secondLoan))
+		assert.True(t, secondLoan.ReturnDate.After(firstLoan.ReturnDate))
+	})
+
+	t.Run("key reused with a different body", func(t *testing.T) {
+		rec := serve("/borrow", `{"title": "book2", "borrower_name": "borrower1"}`, "borrow-1")
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	})
+
+	t.Run("key reused on a different route", func(t *testing.T) {
+		rec := serve("/extend", `{"title": "book1", "borrower_name": "borrower1"}`, "borrow-1")
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	})
+
+	t.Run("client errors are replayed", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, serve("/borrow", `{"title": "book10", "borrower_name": "borrower1"}`, "borrow-2").Code)
+		rec := serve("/borrow", `{"title": "book10", "borrower_name": "borrower1"}`, "borrow-2")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, "true", rec.Header().Get(IdempotentReplayedHeader))
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		rec := serve("/borrow", `{"title": "book1", "borrower_name": "borrower1"}`, strings.Repeat("k", 256))
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
@@ -0,0 +1,59 @@
+package routes
+
+import (
+	"fmt"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/services"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+type JobRoute struct {
+	JobService *services.JobService
+}
+
+func NewJobRoute(jobService *services.JobService) *JobRoute {
+	return &JobRoute{jobService}
+}
+
+func (r *JobRoute) ListJobs(c *gin.Context) {
+	jobs, err := r.JobService.ListJobs(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, jobs)
+}
+
+// ListRuns lists the history of job runs, newest first
+func (r *JobRoute) ListRuns(c *gin.Context) {
+	var filter models.JobRunFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.Error(invalidRequest(fmt.Errorf("invalid query parameters: %w", err)))
+		return
+	}
+	if filter.Limit == 0 {
+		filter.Limit = models.DefaultJobRunPageSize
+	}
+	if err := filter.Validate(); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	page, err := r.JobService.ListRuns(c.Request.Context(), &filter)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// TriggerJob starts a run of the job now, the run goes on after the response
+func (r *JobRoute) TriggerJob(c *gin.Context) {
+	run, err := r.JobService.TriggerJob(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusAccepted, run)
+}
@@ -0,0 +1,67 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"github.com/aftaab60/e-library-api/services"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestJobRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorMiddleware())
+
+	jobService := services.NewJobService(repositories.NewJobRunRepository(), repositories.NewTenantRepository())
+	assert.NoError(t, jobService.Register("send-reminders", "@hourly", "Mails members about their loans", func(ctx context.Context) (int, error) {
+		return 2, nil
+	}))
+
+	// Register the routes
+	jobRoute := NewJobRoute(jobService)
+	router.GET("/admin/jobs", jobRoute.ListJobs)
+	router.GET("/admin/jobs/runs", jobRoute.ListRuns)
+	router.POST("/admin/jobs/:name/run", jobRoute.TriggerJob)
+
+	serve := func(method string, path string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, nil)
+		assert.NoError(t, err)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("trigger a job and find its run", func(t *testing.T) {
+		rec := serve(http.MethodPost, "/admin/jobs/send-reminders/run")
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+		jobService.Wait()
+
+		rec = serve(http.MethodGet, "/admin/jobs/runs?job=send-reminders")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var page models.JobRunPage
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+		assert.Equal(t, 1, page.Total)
+		assert.Equal(t, models.JobTriggerManual, page.Runs[0].Trigger)
+		assert.Equal(t, 2, page.Runs[0].Processed)
+
+		rec = serve(http.MethodGet, "/admin/jobs")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"schedule":"@hourly"`)
+		assert.Contains(t, rec.Body.String(), `"last_run"`)
+	})
+
+	t.Run("unknown job and invalid filter", func(t *testing.T) {
+		rec := serve(http.MethodPost, "/admin/jobs/sweep/run")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Contains(t, rec.Body.String(), "job_not_found")
+
+		rec = serve(http.MethodGet, "/admin/jobs/runs?status=stuck")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
@@ -1,14 +1,15 @@
 package routes
 
 import (
-	"database/sql"
+	"context"
 	"errors"
 	"fmt"
+	"github.com/aftaab60/e-library-api/internal/auth"
 	"github.com/aftaab60/e-library-api/models"
-	"github.com/aftaab60/e-library-api/repositories"
 	"github.com/aftaab60/e-library-api/services"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"strconv"
 	"strings"
 )
 
@@ -20,31 +21,34 @@ func NewLoanRoute(LoanService services.LoanService) *LoanRoute {
 	return &LoanRoute{LoanService}
 }
 
+// borrowerOf returns the borrower a request acts for. Patrons always act for themselves, whatever the body says,
+// integrations and unauthenticated callers name the borrower in the body.
+func borrowerOf(ctx context.Context, requested string) string {
+	if principal := auth.FromContext(ctx); principal.IsPatron() {
+		return principal.Name
+	}
+	return strings.TrimSpace(requested)
+}
+
 func (r *LoanRoute) BorrowBook(c *gin.Context) {
 	ctx := c.Request.Context()
 	var request models.LoanRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid request body, err: %s", err.Error())})
+	if err := bindJSON(c, &request); err != nil {
+		c.Error(invalidRequest(err))
 		return
 	}
 	request.Title = strings.TrimSpace(request.Title)
-	request.BorrowerName = strings.TrimSpace(request.BorrowerName)
+	request.BorrowerName = borrowerOf(ctx, request.BorrowerName)
 
 	//validate request body for certain parameters
 	if err := request.Validate(); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(invalidRequest(err))
 		return
 	}
 
-	LoanDetail, err := r.LoanService.BorrowBook(ctx, request.Title, request.BorrowerName)
+	LoanDetail, err := r.LoanService.BorrowBookAtBranch(ctx, request.Title, request.BorrowerName, request.BranchId, request.Override)
 	if err != nil {
-		if errors.Is(err, repositories.ErrBookNotFound) || errors.Is(err, sql.ErrNoRows) {
-			c.JSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		} else if errors.Is(err, services.ErrNoAvailableCopiesFound) || errors.Is(err, services.ErrExistingLoanFound) {
-			c.JSON(http.StatusConflict, gin.H{"message": err.Error()})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
-		}
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusCreated, LoanDetail)
@@ -53,26 +57,22 @@ func (r *LoanRoute) BorrowBook(c *gin.Context) {
 func (r *LoanRoute) ExtendLoan(c *gin.Context) {
 	ctx := c.Request.Context()
 	var request models.LoanRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid request body, err: %s", err.Error())})
+	if err := bindJSON(c, &request); err != nil {
+		c.Error(invalidRequest(err))
 		return
 	}
 	request.Title = strings.TrimSpace(request.Title)
-	request.BorrowerName = strings.TrimSpace(request.BorrowerName)
+	request.BorrowerName = borrowerOf(ctx, request.BorrowerName)
 
 	//validate request body for certain parameters
 	if err := request.Validate(); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(invalidRequest(err))
 		return
 	}
 
 	LoanDetail, err := r.LoanService.ExtendLoan(ctx, request.Title, request.BorrowerName)
 	if err != nil {
-		if errors.Is(err, repositories.ErrLoanNotFound) || errors.Is(err, sql.ErrNoRows) {
-			c.JSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
-		}
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, LoanDetail)
@@ -81,25 +81,148 @@ func (r *LoanRoute) ExtendLoan(c *gin.Context) {
 func (r *LoanRoute) ReturnBook(c *gin.Context) {
 	ctx := c.Request.Context()
 	var request models.LoanRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid request body, err: %s", err.Error())})
+	if err := bindJSON(c, &request); err != nil {
+		c.Error(invalidRequest(err))
 		return
 	}
 	request.Title = strings.TrimSpace(request.Title)
-	request.BorrowerName = strings.TrimSpace(request.BorrowerName)
+	request.BorrowerName = borrowerOf(ctx, request.BorrowerName)
 
 	//validate request body for certain parameters
 	if err := request.Validate(); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	if err := r.LoanService.ReturnBookAtBranch(ctx, request.Title, request.BorrowerName, request.BranchId); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "book returned"})
+}
+
+func (r *LoanRoute) MarkLoanLost(c *gin.Context) {
+	r.resolveLoan(c, r.LoanService.MarkLoanLost)
+}
+
+func (r *LoanRoute) MarkLoanDamaged(c *gin.Context) {
+	r.resolveLoan(c, r.LoanService.MarkLoanDamaged)
+}
+
+func (r *LoanRoute) MarkLoanFound(c *gin.Context) {
+	r.resolveLoan(c, r.LoanService.MarkLoanFound)
+}
+
+func (r *LoanRoute) resolveLoan(c *gin.Context, resolve func(ctx context.Context, loanId int) (*models.LoanResolution, error)) {
+	ctx := c.Request.Context()
+	loanId, ok := r.loanIdParam(c)
+	if !ok {
 		return
 	}
 
-	if err := r.LoanService.ReturnBook(ctx, request.Title, request.BorrowerName); err != nil {
-		if errors.Is(err, repositories.ErrLoanNotFound) || errors.Is(err, sql.ErrNoRows) {
-			c.JSON(http.StatusNotFound, gin.H{"message": err.Error()})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+	resolution, err := resolve(ctx, loanId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, resolution)
+}
+
+var ErrInvalidLoanId = errors.New("invalid loan id")
+
+// loanIdParam parses the :id path parameter, responding with bad request when it is not a valid id
+func (r *LoanRoute) loanIdParam(c *gin.Context) (int, bool) {
+	loanId, err := strconv.Atoi(c.Param("id"))
+	if err != nil || loanId <= 0 {
+		c.Error(invalidRequest(ErrInvalidLoanId))
+		return 0, false
+	}
+	return loanId, true
+}
+
+func (r *LoanRoute) GetLoan(c *gin.Context) {
+	loanId, ok := r.loanIdParam(c)
+	if !ok {
+		return
+	}
+	loan, err := r.LoanService.GetLoanById(c.Request.Context(), loanId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, loan)
+}
+
+func (r *LoanRoute) GetLoanEvents(c *gin.Context) {
+	loanId, ok := r.loanIdParam(c)
+	if !ok {
+		return
+	}
+	events, err := r.LoanService.GetLoanEvents(c.Request.Context(), loanId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, events)
+}
+
+func (r *LoanRoute) ListLoans(c *gin.Context) {
+	var filter models.LoanFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.Error(invalidRequest(fmt.Errorf("invalid query parameters: %w", err)))
+		return
+	}
+	filter.BorrowerName = strings.TrimSpace(filter.BorrowerName)
+	filter.Title = strings.TrimSpace(filter.Title)
+	if filter.Limit == 0 {
+		filter.Limit = models.DefaultLoanPageSize
+	}
+	if err := filter.Validate(); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	page, err := r.LoanService.ListLoans(c.Request.Context(), &filter)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+func (r *LoanRoute) ExtendLoanById(c *gin.Context) {
+	loanId, ok := r.loanIdParam(c)
+	if !ok {
+		return
+	}
+	LoanDetail, err := r.LoanService.ExtendLoanById(c.Request.Context(), loanId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, LoanDetail)
+}
+
+func (r *LoanRoute) ReturnLoanById(c *gin.Context) {
+	loanId, ok := r.loanIdParam(c)
+	if !ok {
+		return
+	}
+	//body is optional, it only names the branch the book is handed in at
+	var request models.ReturnLoanRequest
+	if c.Request.ContentLength > 0 {
+		if err := bindJSON(c, &request); err != nil {
+			c.Error(invalidRequest(err))
+			return
+		}
+		if err := request.Validate(); err != nil {
+			c.Error(invalidRequest(err))
+			return
 		}
+	}
+
+	if err := r.LoanService.ReturnLoanById(c.Request.Context(), loanId, request.BranchId); err != nil {
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "book returned"})
@@ -3,6 +3,8 @@ package routes
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"github.com/aftaab60/e-library-api/internal/validate"
 	"github.com/aftaab60/e-library-api/models"
 	"github.com/aftaab60/e-library-api/repositories"
 	"github.com/aftaab60/e-library-api/services"
@@ -18,9 +20,10 @@ import (
 func TestLoanRoute_BorrowBook(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
+	router.Use(ErrorMiddleware())
 
 	// Register the route
-	loanRoute := NewLoanRoute(services.NewLoanService(repositories.NewLoanRepository(), repositories.NewBookRepository()))
+	loanRoute := NewLoanRoute(services.NewLoanService(repositories.NewLoanRepository(), repositories.NewBookRepository(), repositories.NewChargeRepository(), repositories.NewBranchRepository(), repositories.NewTransferRepository(), repositories.NewMemberRepository(), repositories.NewLoanEventRepository()))
 	router.POST("/borrow", loanRoute.BorrowBook)
 
 	t.Run("Successfully borrow a book", func(t *testing.T) {
@@ -55,15 +58,90 @@ func TestLoanRoute_BorrowBook(t *testing.T) {
 		router.ServeHTTP(rec, req)
 		assert.Equal(t, http.StatusBadRequest, rec.Code)
 	})
+
+	t.Run("every invalid field is reported", func(t *testing.T) {
+		requestBody := `{"title": "", "borrower_name": "<script>", "branch_id": -1}`
+		req, err := http.NewRequest(http.MethodPost, "/borrow", strings.NewReader(requestBody))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var problem Problem
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+		assert.Equal(t, "validation_failed", problem.Code)
+		assert.Equal(t, validate.Errors{
+			{Field: "title", Code: validate.CodeRequired, Message: "is required"},
+			{Field: "borrower_name", Code: validate.CodeInvalidCharacters, Message: "may only contain " + validate.NameCharacters},
+			{Field: "branch_id", Code: validate.CodeOutOfRange, Message: "must be at least 0"},
+		}, problem.Errors)
+	})
+
+	t.Run("unknown fields are rejected", func(t *testing.T) {
+		requestBody := `{"title": "book1", "borrower_name": "borrower1", "borower": "borrower2", "branch_id": "main"}`
+		req, err := http.NewRequest(http.MethodPost, "/borrow", strings.NewReader(requestBody))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var problem Problem
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+		assert.Equal(t, validate.Errors{
+			{Field: "borower", Code: validate.CodeUnknownField, Message: "is not a known field"},
+			{Field: "branch_id", Code: validate.CodeInvalidType, Message: "must be a whole number"},
+		}, problem.Errors)
+	})
+}
+
+func TestLoanRoute_BorrowingBlocked(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorMiddleware())
+
+	// Register the route
+	memberRepo := repositories.NewMemberRepository()
+	loanRoute := NewLoanRoute(services.NewLoanService(repositories.NewLoanRepository(), repositories.NewBookRepository(), repositories.NewChargeRepository(), repositories.NewBranchRepository(), repositories.NewTransferRepository(), memberRepo, repositories.NewLoanEventRepository()))
+	router.POST("/borrow", loanRoute.BorrowBook)
+	suspended := true
+	_, err := memberRepo.UpdateMemberStanding(context.Background(), 1, &models.MemberStanding{Suspended: &suspended})
+	assert.NoError(t, err)
+
+	t.Run("suspended member is refused with the reason", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/borrow", strings.NewReader(`{"title": "book1", "borrower_name": "user1"}`))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		var problem Problem
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+		assert.Equal(t, "borrowing_blocked", problem.Code)
+		assert.Equal(t, models.BlockReasonSuspended, problem.Reason)
+	})
+
+	t.Run("override lends anyway", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/borrow", strings.NewReader(`{"title": "book1", "borrower_name": "user1", "override": true}`))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+	})
 }
 
 func TestLoanRoute_ExtendLoan(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
+	router.Use(ErrorMiddleware())
 
 	// Register the route
 	loanRepository := repositories.NewLoanRepository()
-	loanRoute := NewLoanRoute(services.NewLoanService(loanRepository, repositories.NewBookRepository()))
+	loanRoute := NewLoanRoute(services.NewLoanService(loanRepository, repositories.NewBookRepository(), repositories.NewChargeRepository(), repositories.NewBranchRepository(), repositories.NewTransferRepository(), repositories.NewMemberRepository(), repositories.NewLoanEventRepository()))
 	router.POST("/extend", loanRoute.ExtendLoan)
 
 	t.Run("Extend a loan where book doesn't exist", func(t *testing.T) {
@@ -101,7 +179,9 @@ func TestLoanRoute_ExtendLoan(t *testing.T) {
 		var response models.LoanDetail
 		err = json.Unmarshal(rec.Body.Bytes(), &response)
 		assert.NoError(t, err)
-		assert.Equal(t, response.ReturnDate, currTime.AddDate(0, 0, 21))
+		//due at the end of the day in the time zone of the branch
+		assert.True(t, models.EndOfDay(currTime.UTC().AddDate(0, 0, 21)).Equal(response.ReturnDate))
+		assert.Equal(t, "UTC", response.TimeZone)
 
 	})
 }
@@ -109,10 +189,11 @@ func TestLoanRoute_ExtendLoan(t *testing.T) {
 func TestLoanRoute_ReturnBook(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
+	router.Use(ErrorMiddleware())
 
 	// Register the route
 	loanRepository := repositories.NewLoanRepository()
-	loanRoute := NewLoanRoute(services.NewLoanService(loanRepository, repositories.NewBookRepository()))
+	loanRoute := NewLoanRoute(services.NewLoanService(loanRepository, repositories.NewBookRepository(), repositories.NewChargeRepository(), repositories.NewBranchRepository(), repositories.NewTransferRepository(), repositories.NewMemberRepository(), repositories.NewLoanEventRepository()))
 	router.POST("/return", loanRoute.ReturnBook)
 
 	t.Run("Return an invalid loan", func(t *testing.T) {
@@ -149,3 +230,156 @@ func TestLoanRoute_ReturnBook(t *testing.T) {
 	})
 
 }
+
+func TestLoanRoute_MarkLoanLost(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorMiddleware())
+
+	// Register the route
+	loanRepository := repositories.NewLoanRepository()
+	loanRoute := NewLoanRoute(services.NewLoanService(loanRepository, repositories.NewBookRepository(), repositories.NewChargeRepository(), repositories.NewBranchRepository(), repositories.NewTransferRepository(), repositories.NewMemberRepository(), repositories.NewLoanEventRepository()))
+	router.POST("/loans/:id/lost", loanRoute.MarkLoanLost)
+
+	t.Run("invalid loan id", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/loans/abc/lost", nil)
+		assert.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("loan not found", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/loans/100/lost", nil)
+		assert.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("successfully mark a loan lost", func(t *testing.T) {
+		currTime := time.Now()
+		loan, err := loanRepository.CreateLoan(context.Background(), "book1", &models.Loan{
+			BookId:       1,
+			BorrowerName: "user3",
+			LoanDate:     currTime,
+			ReturnDate:   currTime,
+			IsReturn:     false,
+		})
+		assert.NoError(t, err)
+
+		req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("/loans/%d/lost", loan.Id), nil)
+		assert.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response models.LoanResolution
+		err = json.Unmarshal(rec.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, models.LoanStatusLost, response.Status)
+		assert.Equal(t, models.DefaultLoanPolicy.ReplacementFee, response.Charge.Amount)
+
+		// a closed loan can't be lost again
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+}
+
+func TestLoanRoute_LoansById(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorMiddleware())
+
+	// Register the route
+	loanRepository := repositories.NewLoanRepository()
+	loanRoute := NewLoanRoute(services.NewLoanService(loanRepository, repositories.NewBookRepository(), repositories.NewChargeRepository(), repositories.NewBranchRepository(), repositories.NewTransferRepository(), repositories.NewMemberRepository(), repositories.NewLoanEventRepository()))
+	router.GET("/loans", loanRoute.ListLoans)
+	router.GET("/loans/:id", loanRoute.GetLoan)
+	router.POST("/loans/:id/extend", loanRoute.ExtendLoanById)
+	router.POST("/loans/:id/return", loanRoute.ReturnLoanById)
+	router.GET("/loans/:id/events", loanRoute.GetLoanEvents)
+
+	currTime := time.Now()
+	loan, err := loanRepository.CreateLoan(context.Background(), "book1", &models.Loan{
+		BookId:       1,
+		BorrowerName: "user4",
+		LoanDate:     currTime,
+		ReturnDate:   currTime,
+	})
+	assert.NoError(t, err)
+
+	t.Run("get loan by id", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/loans/%d", loan.Id), nil)
+		assert.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response models.Loan
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, "user4", response.BorrowerName)
+	})
+
+	t.Run("list loans", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/loans?borrower=user4&status=active&from="+currTime.Format("2006-01-02"), nil)
+		assert.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response models.LoanPage
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, 1, response.Total)
+		assert.Equal(t, models.DefaultLoanPageSize, response.Limit)
+	})
+
+	t.Run("list loans with invalid filter", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/loans?limit=1000", nil)
+		assert.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("extend and return loan by id", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("/loans/%d/extend", loan.Id), nil)
+		assert.NoError(t, err)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		req, err = http.NewRequest(http.MethodPost, fmt.Sprintf("/loans/%d/return", loan.Id), nil)
+		assert.NoError(t, err)
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+
+	t.Run("loan history", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/loans/%d/events", loan.Id), nil)
+		assert.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response []models.LoanEvent
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Len(t, response, 3)
+		assert.Equal(t, models.LoanEventImported, response[0].Type)
+		assert.Equal(t, models.LoanEventExtended, response[1].Type)
+		assert.Equal(t, models.LoanEventReturned, response[2].Type)
+	})
+}
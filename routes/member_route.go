@@ -0,0 +1,138 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/services"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type MemberRoute struct {
+	MemberService services.MemberService
+}
+
+func NewMemberRoute(memberService services.MemberService) *MemberRoute {
+	return &MemberRoute{memberService}
+}
+
+var ErrInvalidMemberId = errors.New("invalid member id")
+
+// memberIdParam parses the :id path parameter, responding with bad request when it is not a valid id
+func (r *MemberRoute) memberIdParam(c *gin.Context) (int, bool) {
+	memberId, err := strconv.Atoi(c.Param("id"))
+	if err != nil || memberId <= 0 {
+		c.Error(invalidRequest(ErrInvalidMemberId))
+		return 0, false
+	}
+	return memberId, true
+}
+
+func (r *MemberRoute) CreateMember(c *gin.Context) {
+	var request models.MemberRequest
+	if err := bindJSON(c, &request); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+	request.Name = strings.TrimSpace(request.Name)
+	request.CardNumber = strings.TrimSpace(request.CardNumber)
+	if err := request.Validate(); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	member, err := r.MemberService.CreateMember(c.Request.Context(), &request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, member)
+}
+
+func (r *MemberRoute) GetMember(c *gin.Context) {
+	memberId, ok := r.memberIdParam(c)
+	if !ok {
+		return
+	}
+	member, err := r.MemberService.GetMember(c.Request.Context(), memberId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, member)
+}
+
+func (r *MemberRoute) UpdatePreferences(c *gin.Context) {
+	memberId, ok := r.memberIdParam(c)
+	if !ok {
+		return
+	}
+	var preferences models.MemberPreferences
+	if err := bindJSON(c, &preferences); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+	if err := preferences.Validate(); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	member, err := r.MemberService.UpdatePreferences(c.Request.Context(), memberId, &preferences)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, member)
+}
+
+func (r *MemberRoute) UpdateStanding(c *gin.Context) {
+	memberId, ok := r.memberIdParam(c)
+	if !ok {
+		return
+	}
+	var standing models.MemberStanding
+	if err := bindJSON(c, &standing); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+	if err := standing.Validate(); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	member, err := r.MemberService.UpdateStanding(c.Request.Context(), memberId, &standing)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, member)
+}
+
+func (r *MemberRoute) GetMemberLoans(c *gin.Context) {
+	memberId, ok := r.memberIdParam(c)
+	if !ok {
+		return
+	}
+	var filter models.LoanFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.Error(invalidRequest(fmt.Errorf("invalid query parameters: %w", err)))
+		return
+	}
+	if filter.Limit == 0 {
+		filter.Limit = models.DefaultLoanPageSize
+	}
+	if err := filter.Validate(); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	page, err := r.MemberService.GetMemberLoans(c.Request.Context(), memberId, &filter)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
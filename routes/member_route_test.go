@@ -0,0 +1,167 @@
+package routes
+
+import (
+	"encoding/json"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"github.com/aftaab60/e-library-api/services"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMemberRoute_Members(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorMiddleware())
+
+	// Register the route
+	memberRoute := NewMemberRoute(services.NewMemberService(repositories.NewMemberRepository(), repositories.NewLoanRepository(), repositories.NewBookRepository(), repositories.NewBranchRepository(), repositories.NewLoanEventRepository()))
+	router.POST("/members", memberRoute.CreateMember)
+	router.GET("/members/:id", memberRoute.GetMember)
+	router.GET("/members/:id/loans", memberRoute.GetMemberLoans)
+	router.PUT("/members/:id/preferences", memberRoute.UpdatePreferences)
+	router.PUT("/members/:id/standing", memberRoute.UpdateStanding)
+
+	t.Run("successfully create a member", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/members", strings.NewReader(`{"name": "user3"}`))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		// same name again
+		req, _ = http.NewRequest(http.MethodPost, "/members", strings.NewReader(`{"name": "user3"}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+
+	t.Run("create a member with a library card", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPost, "/members", strings.NewReader(`{"name": "user4", "card_number": "21234000012344"}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"card_number":"21234000012344"`)
+
+		// the check digit is wrong
+		req, _ = http.NewRequest(http.MethodPost, "/members", strings.NewReader(`{"name": "user5", "card_number": "21234000012347"}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"code":"invalid_card_number"`)
+
+		// the card belongs to another member
+		req, _ = http.NewRequest(http.MethodPost, "/members", strings.NewReader(`{"name": "user5", "card_number": "21234000012344"}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+
+	t.Run("member not found", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/members/100", nil)
+		assert.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("invalid member id", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/members/abc/loans", nil)
+		assert.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("successfully list member loans", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/members/1/loans?limit=5", nil)
+		assert.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var page models.MemberLoanPage
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+		assert.Equal(t, "user1", page.Member.Name)
+		assert.Equal(t, 5, page.Limit)
+	})
+
+	t.Run("missing preference", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, "/members/1/preferences", strings.NewReader(`{}`))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("successfully opt out of history", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, "/members/1/preferences", strings.NewReader(`{"retain_history": false}`))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var member models.Member
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &member))
+		assert.False(t, member.RetainHistory)
+	})
+
+	t.Run("turn off due reminders, other preferences are kept", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, "/members/1/preferences", strings.NewReader(`{"due_reminders": false}`))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var member models.Member
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &member))
+		assert.False(t, member.DueReminders)
+		assert.True(t, member.OverdueNotices)
+		assert.False(t, member.RetainHistory)
+	})
+
+	t.Run("suspend a member until the membership expires", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, "/members/1/standing", strings.NewReader(`{"suspended": true, "expires_on": "2030-06-30"}`))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var member models.Member
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &member))
+		assert.True(t, member.Suspended)
+		assert.Equal(t, "2030-06-30", member.ExpiresOn)
+	})
+
+	t.Run("invalid expiry", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, "/members/1/standing", strings.NewReader(`{"expires_on": "30/06/2030"}`))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"field":"expires_on"`)
+	})
+}
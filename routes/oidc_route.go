@@ -0,0 +1,49 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aftaab60/e-library-api/services"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// OidcStateCookie remembers the login state in the staff member's browser until the identity provider redirects back
+const OidcStateCookie = "oidc_state"
+
+type OidcRoute struct {
+	OidcService services.OidcService
+}
+
+func NewOidcRoute(oidcService services.OidcService) *OidcRoute {
+	return &OidcRoute{oidcService}
+}
+
+// Login redirects a staff member to the identity provider
+func (r *OidcRoute) Login(c *gin.Context) {
+	loginURL, state, err := r.OidcService.LoginURL(c.Request.Context())
+	if err != nil {
+		c.Error(errors.Join(ErrIdentityProviderUnavailable, err))
+		return
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(OidcStateCookie, state, int(r.OidcService.StateTTL.Seconds()), "/auth/oidc", "", c.Request.TLS != nil, true)
+	c.Redirect(http.StatusFound, loginURL)
+}
+
+// Callback is where the identity provider redirects back to, it responds with a staff bearer token
+func (r *OidcRoute) Callback(c *gin.Context) {
+	expectedState, _ := c.Cookie(OidcStateCookie)
+	c.SetCookie(OidcStateCookie, "", -1, "/auth/oidc", "", c.Request.TLS != nil, true)
+	if providerErr := c.Query("error"); providerErr != "" {
+		c.Error(fmt.Errorf("%w: %s %s", services.ErrOidcLoginFailed, providerErr, c.Query("error_description")))
+		return
+	}
+
+	token, err := r.OidcService.Callback(c.Request.Context(), c.Query("code"), c.Query("state"), expectedState)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, token)
+}
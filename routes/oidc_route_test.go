@@ -0,0 +1,101 @@
+package routes
+
+import (
+	"encoding/json"
+	"github.com/aftaab60/e-library-api/internal/oidc"
+	"github.com/aftaab60/e-library-api/internal/oidc/oidctest"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"github.com/aftaab60/e-library-api/services"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestOidcRoute_Login(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorMiddleware())
+
+	idp := oidctest.NewServer("e-library", "client-secret")
+	defer idp.Close()
+
+	// Register the route
+	provider := oidc.NewProvider(oidc.Config{Issuer: idp.URL, ClientId: "e-library", ClientSecret: "client-secret", RedirectURL: "http://localhost/auth/oidc/callback"}, idp.Client())
+	authService := services.NewAuthService(repositories.NewApiKeyRepository(), repositories.NewTokenRepository(), repositories.NewMemberRepository(), []byte("test-secret"))
+	oidcRoute := NewOidcRoute(services.NewOidcService(provider, authService, "groups", map[string]models.Role{"library-staff": models.RoleLibrarian}, "tenants"))
+	authRoute := NewAuthRoute(authService)
+	tenantRoute := NewTenantRoute(services.NewTenantService(repositories.NewTenantRepository()))
+	router.Use(tenantRoute.TenantMiddleware(nil, "default"))
+	router.GET("/auth/oidc/login", oidcRoute.Login)
+	router.GET("/auth/oidc/callback", oidcRoute.Callback)
+	router.GET("/auth/me", authRoute.AuthMiddleware(), authRoute.GetPrincipal)
+
+	// startLogin follows the redirect to the identity provider, where the staff member logs in with claims
+	startLogin := func(claims map[string]any) (query url.Values, cookie *http.Cookie) {
+		req, err := http.NewRequest(http.MethodGet, "/auth/oidc/login", nil)
+		assert.NoError(t, err)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusFound, rec.Code)
+		cookies := rec.Result().Cookies()
+		assert.Len(t, cookies, 1)
+		assert.Equal(t, OidcStateCookie, cookies[0].Name)
+		assert.True(t, cookies[0].HttpOnly)
+
+		code, state, err := idp.Login(rec.Header().Get("Location"), claims)
+		assert.NoError(t, err)
+		return url.Values{"code": {code}, "state": {state}}, cookies[0]
+	}
+	callback := func(query url.Values, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, "/auth/oidc/callback?"+query.Encode(), nil)
+		assert.NoError(t, err)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("staff member logs in", func(t *testing.T) {
+		rec := callback(startLogin(map[string]any{"sub": "alice", "name": "Alice", "groups": []any{"library-staff"}, "tenants": "default"}))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var token models.IssuedToken
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &token))
+
+		req, err := http.NewRequest(http.MethodGet, "/auth/me", nil)
+		assert.NoError(t, err)
+		req.Header.Set(AuthorizationHeader, "Bearer "+token.Token)
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var principal models.Principal
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &principal))
+		assert.Equal(t, models.PrincipalTypeStaff, principal.Type)
+		assert.Equal(t, models.RoleLibrarian, principal.Role)
+	})
+
+	t.Run("missing state cookie", func(t *testing.T) {
+		query, _ := startLogin(map[string]any{"sub": "alice", "groups": []any{"library-staff"}, "tenants": "default"})
+		assert.Equal(t, http.StatusBadRequest, callback(query, nil).Code)
+	})
+
+	t.Run("not working for the tenant", func(t *testing.T) {
+		rec := callback(startLogin(map[string]any{"sub": "alice", "groups": []any{"library-staff"}, "tenants": "other"}))
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("no library role", func(t *testing.T) {
+		rec := callback(startLogin(map[string]any{"sub": "carol", "groups": []any{"everyone"}, "tenants": "default"}))
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("login denied at the identity provider", func(t *testing.T) {
+		rec := callback(url.Values{"error": {"access_denied"}}, nil)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
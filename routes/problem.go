@@ -0,0 +1,235 @@
+package routes
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/aftaab60/e-library-api/internal/auth"
+	"github.com/aftaab60/e-library-api/internal/validate"
+	"github.com/aftaab60/e-library-api/repositories"
+	"github.com/aftaab60/e-library-api/services"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// ProblemContentType is the media type of error responses, see RFC 7807
+const ProblemContentType = "application/problem+json"
+
+// Problem is the body of every error response, RFC 7807 problem details extended with a stable error code
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	//Code identifies the error for clients, it doesn't change when the title or detail are reworded
+	Code string `json:"code"`
+	//Reason tells why a forbidden request was denied, or why a borrower is blocked
+	Reason string `json:"reason,omitempty"`
+	//Errors are the invalid fields of a request that failed validation
+	Errors validate.Errors `json:"errors,omitempty"`
+}
+
+// ProblemType is an entry of the error registry
+type ProblemType struct {
+	Code   string `json:"code"`
+	Status int    `json:"status"`
+	Title  string `json:"title"`
+	err    error
+	//hideDetail leaves the error message out of the response, it would tell clients about internals
+	hideDetail bool
+}
+
+var (
+	// ErrInvalidRequest is matched by errors about the request itself: malformed bodies, invalid parameters and failed validation
+	ErrInvalidRequest              = errors.New("invalid request")
+	ErrRouteNotFound               = errors.New("route not found")
+	ErrIdentityProviderUnavailable = errors.New("identity provider unavailable")
+)
+
+// requestError marks an error as a problem with the request, the client has to fix the request before retrying it
+type requestError struct {
+	err error
+}
+
+func (e *requestError) Error() string {
+	return e.err.Error()
+}
+
+func (e *requestError) Unwrap() error {
+	return e.err
+}
+
+func (e *requestError) Is(target error) bool {
+	return target == ErrInvalidRequest
+}
+
+func invalidRequest(err error) error {
+	return &requestError{err}
+}
+
+// bindJSON decodes the JSON body of a request into request. Unknown and mistyped fields are all reported at once,
+// as validate.Errors like the ones of the request's Validate.
+func bindJSON(c *gin.Context, request any) error {
+	body, err := c.GetRawData()
+	if err != nil {
+		return err
+	}
+	return validate.DecodeJSON(body, request)
+}
+
+// internalProblem is the problem of errors missing from the registry
+var internalProblem = ProblemType{Code: "internal_error", Status: http.StatusInternalServerError, Title: "Internal server error", hideDetail: true}
+
+// problemTypes is the error registry, every error the API responds with and its code and status.
+// Errors are matched with errors.Is in order, the first match wins.
+var problemTypes = []ProblemType{
+	{Code: "validation_failed", Status: http.StatusBadRequest, Title: "Validation failed", err: validate.ErrInvalid},
+	{Code: "invalid_request", Status: http.StatusBadRequest, Title: "Invalid request", err: ErrInvalidRequest},
+	{Code: "invalid_idempotency_key", Status: http.StatusBadRequest, Title: "Invalid idempotency key", err: ErrInvalidIdempotencyKey},
+	{Code: "tenant_not_resolved", Status: http.StatusBadRequest, Title: "Tenant not resolved", err: services.ErrTenantNotResolved},
+	{Code: "invalid_reset_token", Status: http.StatusBadRequest, Title: "Invalid password reset token", err: repositories.ErrPasswordResetNotFound},
+	{Code: "invalid_login_state", Status: http.StatusBadRequest, Title: "Invalid login state", err: services.ErrInvalidOidcState},
+	{Code: "unauthenticated", Status: http.StatusUnauthorized, Title: "Authentication required", err: services.ErrUnauthenticated},
+	{Code: "invalid_credentials", Status: http.StatusUnauthorized, Title: "Invalid credentials", err: services.ErrInvalidCredentials},
+	{Code: "identity_provider_login_failed", Status: http.StatusUnauthorized, Title: "Identity provider login failed", err: services.ErrOidcLoginFailed},
+	{Code: "forbidden", Status: http.StatusForbidden, Title: "Forbidden", err: auth.ErrForbidden},
+	{Code: "borrowing_blocked", Status: http.StatusForbidden, Title: "Borrowing blocked", err: services.ErrBorrowingBlocked},
+	{Code: "route_not_found", Status: http.StatusNotFound, Title: "Route not found", err: ErrRouteNotFound},
+	{Code: "tenant_not_found", Status: http.StatusNotFound, Title: "Tenant not found", err: repositories.ErrTenantNotFound},
+	{Code: "book_not_found", Status: http.StatusNotFound, Title: "Book not found", err: repositories.ErrBookNotFound},
+	{Code: "branch_not_found", Status: http.StatusNotFound, Title: "Branch not found", err: repositories.ErrBranchNotFound},
+	{Code: "loan_not_found", Status: http.StatusNotFound, Title: "Loan not found", err: repositories.ErrLoanNotFound},
+	{Code: "charge_not_found", Status: http.StatusNotFound, Title: "Charge not found", err: repositories.ErrChargeNotFound},
+	{Code: "transfer_not_found", Status: http.StatusNotFound, Title: "Transfer not found", err: repositories.ErrTransferNotFound},
+	{Code: "member_not_found", Status: http.StatusNotFound, Title: "Member not found", err: repositories.ErrMemberNotFound},
+	{Code: "credential_not_found", Status: http.StatusNotFound, Title: "Member has no password", err: repositories.ErrCredentialNotFound},
+	{Code: "token_not_found", Status: http.StatusNotFound, Title: "Token not found", err: repositories.ErrTokenNotFound},
+	{Code: "api_key_not_found", Status: http.StatusNotFound, Title: "API key not found", err: repositories.ErrApiKeyNotFound},
+	{Code: "webhook_subscription_not_found", Status: http.StatusNotFound, Title: "Webhook subscription not found", err: repositories.ErrWebhookSubscriptionNotFound},
+	{Code: "webhook_delivery_not_found", Status: http.StatusNotFound, Title: "Webhook delivery not found", err: repositories.ErrWebhookDeliveryNotFound},
+	{Code: "job_not_found", Status: http.StatusNotFound, Title: "Job not found", err: services.ErrJobNotFound},
+	{Code: "closure_not_found", Status: http.StatusNotFound, Title: "Closure not found", err: repositories.ErrClosureNotFound},
+	{Code: "booking_not_found", Status: http.StatusNotFound, Title: "Booking not found", err: repositories.ErrBookingNotFound},
+	{Code: "term_not_found", Status: http.StatusNotFound, Title: "Term not found", err: repositories.ErrTermNotFound},
+	{Code: "course_not_found", Status: http.StatusNotFound, Title: "Course not found", err: repositories.ErrCourseNotFound},
+	{Code: "reserve_not_found", Status: http.StatusNotFound, Title: "Reserve not found", err: repositories.ErrReserveNotFound},
+	{Code: "not_found", Status: http.StatusNotFound, Title: "Not found", err: sql.ErrNoRows, hideDetail: true},
+	{Code: "existing_loan", Status: http.StatusConflict, Title: "Existing loan", err: services.ErrExistingLoanFound},
+	{Code: "existing_active_loan", Status: http.StatusConflict, Title: "Existing active loan", err: repositories.ErrExistingActiveLoan},
+	{Code: "no_available_copies", Status: http.StatusConflict, Title: "No available copies", err: services.ErrNoAvailableCopiesFound},
+	{Code: "loan_not_active", Status: http.StatusConflict, Title: "Loan not active", err: services.ErrLoanNotActive},
+	{Code: "loan_not_lost", Status: http.StatusConflict, Title: "Loan not lost", err: services.ErrLoanNotLost},
+	{Code: "loan_changed_concurrently", Status: http.StatusConflict, Title: "Loan changed concurrently", err: repositories.ErrLoanEventConflict},
+	{Code: "invalid_transfer_status", Status: http.StatusConflict, Title: "Invalid transfer status", err: services.ErrInvalidTransferStatus},
+	{Code: "existing_member", Status: http.StatusConflict, Title: "Existing member", err: repositories.ErrExistingMember},
+	{Code: "webhook_delivery_not_dead", Status: http.StatusConflict, Title: "Webhook delivery not dead", err: services.ErrWebhookDeliveryNotDead},
+	{Code: "job_running", Status: http.StatusConflict, Title: "Job running", err: services.ErrJobRunning},
+	{Code: "existing_closure", Status: http.StatusConflict, Title: "Existing closure", err: repositories.ErrExistingClosure},
+	{Code: "existing_branch", Status: http.StatusConflict, Title: "Existing branch", err: repositories.ErrExistingBranch},
+	{Code: "booking_conflict", Status: http.StatusConflict, Title: "Copies booked", err: services.ErrBookingConflict},
+	{Code: "booking_not_active", Status: http.StatusConflict, Title: "Booking not active", err: services.ErrBookingNotActive},
+	{Code: "booking_not_open", Status: http.StatusConflict, Title: "Booking not open", err: services.ErrBookingNotOpen},
+	{Code: "reference_only", Status: http.StatusConflict, Title: "Reference only", err: services.ErrReferenceOnly},
+	{Code: "non_circulating", Status: http.StatusConflict, Title: "Non-circulating", err: services.ErrNonCirculating},
+	{Code: "existing_course", Status: http.StatusConflict, Title: "Existing course", err: repositories.ErrExistingCourse},
+	{Code: "existing_reserve", Status: http.StatusConflict, Title: "Existing reserve", err: repositories.ErrExistingReserve},
+	{Code: "term_ended", Status: http.StatusConflict, Title: "Term ended", err: services.ErrTermEnded},
+	{Code: "reserve_not_active", Status: http.StatusConflict, Title: "Reserve not active", err: services.ErrReserveNotActive},
+	{Code: "idempotent_request_in_progress", Status: http.StatusConflict, Title: "Idempotent request in progress", err: services.ErrIdempotentRequestInProgress},
+	{Code: "idempotency_key_reused", Status: http.StatusUnprocessableEntity, Title: "Idempotency key reused", err: services.ErrIdempotencyKeyReused},
+	{Code: "account_locked", Status: http.StatusLocked, Title: "Account locked", err: services.ErrAccountLocked},
+	{Code: "identity_provider_unavailable", Status: http.StatusBadGateway, Title: "Identity provider unavailable", err: ErrIdentityProviderUnavailable, hideDetail: true},
+}
+
+// problemTypeOf looks err up in the error registry
+func problemTypeOf(err error) ProblemType {
+	for _, problemType := range problemTypes {
+		if errors.Is(err, problemType.err) {
+			return problemType
+		}
+	}
+	return internalProblem
+}
+
+func problemTypeURI(code string) string {
+	return "/problems/" + code
+}
+
+func newProblem(c *gin.Context, err error) *Problem {
+	problemType := problemTypeOf(err)
+	problem := &Problem{
+		Type:     problemTypeURI(problemType.Code),
+		Title:    problemType.Title,
+		Status:   problemType.Status,
+		Instance: c.Request.URL.Path,
+		Code:     problemType.Code,
+	}
+	if problemType == internalProblem {
+		log.Printf("error handling %s %s: %v", c.Request.Method, c.Request.URL.Path, err)
+	}
+	if !problemType.hideDetail {
+		//joined errors are one per line, a detail reads better on a single line
+		problem.Detail = strings.ReplaceAll(err.Error(), "\n", ": ")
+	}
+	var forbiddenErr *auth.ForbiddenError
+	if errors.As(err, &forbiddenErr) {
+		problem.Reason = forbiddenErr.Reason
+	}
+	var blockedErr *services.BorrowingBlockedError
+	if errors.As(err, &blockedErr) {
+		problem.Reason = blockedErr.Reason
+	}
+	var fieldErrs validate.Errors
+	if errors.As(err, &fieldErrs) {
+		problem.Errors = fieldErrs
+	}
+	return problem
+}
+
+// writeProblem responds with the last error added to the request, unless a response was written already.
+// Middlewares that read the response after the handlers call it, the error middleware runs last.
+func writeProblem(c *gin.Context) {
+	if len(c.Errors) == 0 || c.Writer.Written() {
+		return
+	}
+	problem := newProblem(c, c.Errors.Last().Err)
+	body, err := json.Marshal(problem)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.Data(problem.Status, ProblemContentType, body)
+}
+
+// ErrorMiddleware turns errors into problem details responses. Handlers and middlewares only add the error with
+// c.Error and return, the status and body come from the error registry. It must be the first middleware.
+func ErrorMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		writeProblem(c)
+	}
+}
+
+// NoRoute responds to requests for unknown routes
+func NoRoute(c *gin.Context) {
+	c.Error(ErrRouteNotFound)
+}
+
+// ListProblemTypes documents the error codes, the type of a problem links to its entry
+func ListProblemTypes(c *gin.Context) {
+	c.JSON(http.StatusOK, append(problemTypes, internalProblem))
+}
+
+func GetProblemType(c *gin.Context) {
+	code := c.Param("code")
+	for _, problemType := range append(problemTypes, internalProblem) {
+		if problemType.Code == code {
+			c.JSON(http.StatusOK, problemType)
+			return
+		}
+	}
+	c.Error(ErrRouteNotFound)
+}
@@ -0,0 +1,91 @@
+package routes
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aftaab60/e-library-api/internal/auth"
+	"github.com/aftaab60/e-library-api/repositories"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestErrorMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorMiddleware())
+	router.NoRoute(NoRoute)
+	router.GET("/problems/:code", GetProblemType)
+
+	// Register the route, it fails with the error named by the path
+	failures := map[string]error{
+		"wrapped":   fmt.Errorf("error getting loan 7: %w", repositories.ErrLoanNotFound),
+		"forbidden": &auth.ForbiddenError{Reason: "patrons may only act on their own loans"},
+		"no-rows":   sql.ErrNoRows,
+		"internal":  errors.New("pq: connection refused"),
+	}
+	router.GET("/fail/:name", func(c *gin.Context) {
+		c.Error(failures[c.Param("name")])
+	})
+
+	serve := func(path string) (*httptest.ResponseRecorder, Problem) {
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		assert.NoError(t, err)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		var problem Problem
+		_ = json.Unmarshal(rec.Body.Bytes(), &problem)
+		return rec, problem
+	}
+
+	t.Run("registered error", func(t *testing.T) {
+		rec, problem := serve("/fail/wrapped")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, ProblemContentType, rec.Header().Get("Content-Type"))
+		assert.Equal(t, Problem{Type: "/problems/loan_not_found", Title: "Loan not found", Status: http.StatusNotFound,
+			Detail: "error getting loan 7: loan not found", Instance: "/fail/wrapped", Code: "loan_not_found"}, problem)
+	})
+
+	t.Run("forbidden with reason", func(t *testing.T) {
+		rec, problem := serve("/fail/forbidden")
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Equal(t, "forbidden", problem.Code)
+		assert.Equal(t, "patrons may only act on their own loans", problem.Reason)
+	})
+
+	t.Run("internals are not leaked", func(t *testing.T) {
+		rec, problem := serve("/fail/no-rows")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, "not_found", problem.Code)
+		assert.Empty(t, problem.Detail)
+
+		rec, problem = serve("/fail/internal")
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Equal(t, "internal_error", problem.Code)
+		assert.NotContains(t, rec.Body.String(), "pq:")
+	})
+
+	t.Run("unknown route", func(t *testing.T) {
+		rec, problem := serve("/nowhere")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, "route_not_found", problem.Code)
+	})
+
+	t.Run("problem type is documented", func(t *testing.T) {
+		rec, _ := serve("/problems/loan_not_found")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"code": "loan_not_found", "status": 404, "title": "Loan not found"}`, rec.Body.String())
+	})
+}
+
+func TestProblemTypes_UniqueCodes(t *testing.T) {
+	codes := make(map[string]bool)
+	for _, problemType := range append(problemTypes, internalProblem) {
+		assert.False(t, codes[problemType.Code], "duplicate code %s", problemType.Code)
+		codes[problemType.Code] = true
+	}
+}
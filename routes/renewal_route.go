@@ -0,0 +1,32 @@
+package routes
+
+import (
+	"github.com/aftaab60/e-library-api/services"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+type RenewalRoute struct {
+	RenewalService *services.RenewalService
+}
+
+func NewRenewalRoute(renewalService *services.RenewalService) *RenewalRoute {
+	return &RenewalRoute{renewalService}
+}
+
+// ListAutoRenewals lists the automatic renewal attempts of a loan and their outcome
+func (r *RenewalRoute) ListAutoRenewals(c *gin.Context) {
+	loanId, err := strconv.Atoi(c.Param("id"))
+	if err != nil || loanId <= 0 {
+		c.Error(invalidRequest(ErrInvalidLoanId))
+		return
+	}
+
+	renewals, err := r.RenewalService.ListAutoRenewals(c.Request.Context(), loanId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, renewals)
+}
@@ -0,0 +1,37 @@
+package routes
+
+import (
+	"context"
+	"github.com/aftaab60/e-library-api/repositories"
+	"github.com/aftaab60/e-library-api/services"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRenewalRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorMiddleware())
+
+	// Register the routes
+	loanService := services.NewLoanService(repositories.NewLoanRepository(), repositories.NewBookRepository(), repositories.NewChargeRepository(), repositories.NewBranchRepository(), repositories.NewTransferRepository(), repositories.NewMemberRepository(), repositories.NewLoanEventRepository())
+	renewalRoute := NewRenewalRoute(services.NewRenewalService(&loanService, loanService.BookRepository, loanService.MemberRepository, repositories.NewAutoRenewalRepository(), nil))
+	router.GET("/loans/:id/renewals", renewalRoute.ListAutoRenewals)
+	_, err := loanService.BorrowBook(context.Background(), "book1", "user1")
+	assert.NoError(t, err)
+
+	for path, status := range map[string]int{
+		"/loans/1/renewals":   http.StatusOK,
+		"/loans/100/renewals": http.StatusNotFound,
+		"/loans/one/renewals": http.StatusBadRequest,
+	} {
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		assert.NoError(t, err)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, status, rec.Code, path)
+	}
+}
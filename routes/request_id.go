@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"github.com/aftaab60/e-library-api/internal/requestid"
+	"github.com/gin-gonic/gin"
+	"regexp"
+)
+
+const RequestIdHeader = "X-Request-ID"
+
+// requestIdPattern keeps ids taken from clients short and printable, others are replaced with a generated id
+var requestIdPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestIdMiddleware tags every request with the X-Request-ID sent by the client, or a generated one, and echoes it
+// on the response. Audit entries carry it, so that changes are traced back to the request which made them.
+func RequestIdMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestId := c.GetHeader(RequestIdHeader)
+		if !requestIdPattern.MatchString(requestId) {
+			requestId = requestid.New()
+		}
+		c.Header(RequestIdHeader, requestId)
+		c.Request = c.Request.WithContext(requestid.NewContext(c.Request.Context(), requestId))
+		c.Next()
+	}
+}
@@ -0,0 +1,89 @@
+package routes
+
+import (
+	"context"
+	"github.com/aftaab60/e-library-api/internal/tenant"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/services"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+)
+
+const (
+	TenantHeader = "X-Tenant-ID"
+	ApiKeyHeader = "X-API-Key"
+)
+
+// TenantBinder scopes a request context to a tenant in the storage layer. db_manager.DB implements it for pgsql.
+type TenantBinder interface {
+	BindTenant(ctx context.Context, tenantId int) (context.Context, func(), error)
+}
+
+type TenantRoute struct {
+	TenantService services.TenantService
+}
+
+func NewTenantRoute(tenantService services.TenantService) *TenantRoute {
+	return &TenantRoute{tenantService}
+}
+
+// TenantMiddleware resolves the tenant of every request and scopes the request context to it.
+// binder may be nil when repositories are in-memory. fallbackSlug is used when the request doesn't identify a tenant, leave empty to reject such requests.
+func (r *TenantRoute) TenantMiddleware(binder TenantBinder, fallbackSlug string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		t, err := r.TenantService.ResolveTenant(ctx,
+			strings.TrimSpace(c.GetHeader(TenantHeader)),
+			strings.TrimSpace(c.GetHeader(ApiKeyHeader)),
+			c.Request.Host,
+			fallbackSlug)
+		if err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		ctx = tenant.NewContext(ctx, t)
+		if binder != nil {
+			var release func()
+			ctx, release, err = binder.BindTenant(ctx, t.Id)
+			if err != nil {
+				c.Error(err)
+				c.Abort()
+				return
+			}
+			defer release()
+		}
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+func (r *TenantRoute) GetTenant(c *gin.Context) {
+	t, err := r.TenantService.GetTenant(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+func (r *TenantRoute) UpdateLoanPolicy(c *gin.Context) {
+	var policy models.LoanPolicy
+	if err := bindJSON(c, &policy); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+	if err := policy.Validate(); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	t, err := r.TenantService.UpdateLoanPolicy(c.Request.Context(), policy)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
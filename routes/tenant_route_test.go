@@ -0,0 +1,74 @@
+package routes
+
+import (
+	"encoding/json"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"github.com/aftaab60/e-library-api/services"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTenantRoute_TenantMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorMiddleware())
+
+	// Register the route
+	tenantRoute := NewTenantRoute(services.NewTenantService(repositories.NewTenantRepository()))
+	router.Use(tenantRoute.TenantMiddleware(nil, ""))
+	router.GET("/tenant", tenantRoute.GetTenant)
+	router.PUT("/tenant/policy", tenantRoute.UpdateLoanPolicy)
+
+	t.Run("tenant not resolved", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/tenant", nil)
+		assert.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("tenant not found", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/tenant", nil)
+		assert.NoError(t, err)
+		req.Header.Set(TenantHeader, "unknown")
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("successfully update tenant loan policy", func(t *testing.T) {
+		requestBody := `{"loan_period_days": 14, "extension_days": 7, "replacement_fee": 3000, "damage_fee": 1000}`
+		req, err := http.NewRequest(http.MethodPut, "/tenant/policy", strings.NewReader(requestBody))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(ApiKeyHeader, "city-library-key")
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response models.Tenant
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, "city", response.Slug)
+		assert.Equal(t, 14, response.Policy.LoanPeriodDays)
+	})
+
+	t.Run("invalid loan policy", func(t *testing.T) {
+		requestBody := `{"loan_period_days": 0, "extension_days": 7}`
+		req, err := http.NewRequest(http.MethodPut, "/tenant/policy", strings.NewReader(requestBody))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(TenantHeader, "city")
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
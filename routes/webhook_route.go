@@ -0,0 +1,105 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/services"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type WebhookRoute struct {
+	WebhookService *services.WebhookService
+}
+
+func NewWebhookRoute(webhookService *services.WebhookService) *WebhookRoute {
+	return &WebhookRoute{webhookService}
+}
+
+var ErrInvalidWebhookSubscriptionId = errors.New("invalid webhook subscription id")
+
+var ErrInvalidWebhookDeliveryId = errors.New("invalid webhook delivery id")
+
+func (r *WebhookRoute) CreateSubscription(c *gin.Context) {
+	var request models.WebhookSubscriptionRequest
+	if err := bindJSON(c, &request); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+	request.Url = strings.TrimSpace(request.Url)
+	if err := request.Validate(); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	subscription, err := r.WebhookService.CreateSubscription(c.Request.Context(), &request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, subscription)
+}
+
+func (r *WebhookRoute) ListSubscriptions(c *gin.Context) {
+	subscriptions, err := r.WebhookService.ListSubscriptions(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, subscriptions)
+}
+
+func (r *WebhookRoute) DeleteSubscription(c *gin.Context) {
+	subscriptionId, err := strconv.Atoi(c.Param("id"))
+	if err != nil || subscriptionId <= 0 {
+		c.Error(invalidRequest(ErrInvalidWebhookSubscriptionId))
+		return
+	}
+
+	if err := r.WebhookService.DeleteSubscription(c.Request.Context(), subscriptionId); err != nil {
+		c.Error(err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListDeliveries lists deliveries of events to webhooks, ?status=dead is the dead letter queue
+func (r *WebhookRoute) ListDeliveries(c *gin.Context) {
+	var filter models.WebhookDeliveryFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.Error(invalidRequest(fmt.Errorf("invalid query parameters: %w", err)))
+		return
+	}
+	if filter.Limit == 0 {
+		filter.Limit = models.DefaultWebhookDeliveryPageSize
+	}
+	if err := filter.Validate(); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	page, err := r.WebhookService.ListDeliveries(c.Request.Context(), &filter)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+func (r *WebhookRoute) RetryDelivery(c *gin.Context) {
+	deliveryId, err := strconv.Atoi(c.Param("id"))
+	if err != nil || deliveryId <= 0 {
+		c.Error(invalidRequest(ErrInvalidWebhookDeliveryId))
+		return
+	}
+
+	delivery, err := r.WebhookService.RetryDelivery(c.Request.Context(), deliveryId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, delivery)
+}
@@ -0,0 +1,87 @@
+package routes
+
+import (
+	"encoding/json"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"github.com/aftaab60/e-library-api/services"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebhookRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorMiddleware())
+
+	// Register the routes
+	webhookRoute := NewWebhookRoute(services.NewWebhookService(repositories.NewWebhookRepository(), repositories.NewOutboxRepository()))
+	router.GET("/webhooks", webhookRoute.ListSubscriptions)
+	router.POST("/webhooks", webhookRoute.CreateSubscription)
+	router.DELETE("/webhooks/:id", webhookRoute.DeleteSubscription)
+	router.GET("/webhooks/deliveries", webhookRoute.ListDeliveries)
+	router.POST("/webhooks/deliveries/:id/retry", webhookRoute.RetryDelivery)
+
+	serve := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("create a subscription, the secret is only shown once", func(t *testing.T) {
+		rec := serve(http.MethodPost, "/webhooks", `{"url": "https://ils.example.com/hooks", "event_types": ["LoanCreated", "LoanReturned"]}`)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		var subscription models.IssuedWebhookSubscription
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &subscription))
+		assert.True(t, strings.HasPrefix(subscription.Secret, "whsec_"))
+
+		rec = serve(http.MethodGet, "/webhooks", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "https://ils.example.com/hooks")
+		assert.NotContains(t, rec.Body.String(), subscription.Secret)
+	})
+
+	t.Run("reject invalid subscriptions", func(t *testing.T) {
+		rec := serve(http.MethodPost, "/webhooks", `{"url": "ftp://ils.example.com", "event_types": ["LoanImported"]}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"field":"url"`)
+		assert.Contains(t, rec.Body.String(), `"field":"event_types"`)
+	})
+
+	t.Run("reject subscriptions to private hosts", func(t *testing.T) {
+		for _, url := range []string{"http://localhost:8080/hooks", "http://127.0.0.1/hooks", "http://169.254.169.254/latest", "https://10.0.0.5/hooks", "http://[::1]/hooks"} {
+			rec := serve(http.MethodPost, "/webhooks", `{"url": "`+url+`"}`)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, url)
+			assert.Contains(t, rec.Body.String(), "private host", url)
+		}
+	})
+
+	t.Run("list the dead letter queue", func(t *testing.T) {
+		rec := serve(http.MethodGet, "/webhooks/deliveries?status=dead", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var page models.WebhookDeliveryPage
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+		assert.Equal(t, 0, page.Total)
+
+		rec = serve(http.MethodGet, "/webhooks/deliveries?status=lost", "")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("retry and delete unknown ids", func(t *testing.T) {
+		rec := serve(http.MethodPost, "/webhooks/deliveries/5/retry", "")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Contains(t, rec.Body.String(), "webhook_delivery_not_found")
+
+		rec = serve(http.MethodDelete, "/webhooks/1", "")
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		rec = serve(http.MethodDelete, "/webhooks/1", "")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aftaab60/e-library-api/internal/auth"
+	"github.com/aftaab60/e-library-api/internal/db_manager"
+	"github.com/aftaab60/e-library-api/internal/mail"
+	"github.com/aftaab60/e-library-api/internal/tenant"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"golang.org/x/crypto/bcrypt"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxFailedLogins is how many consecutive failed logins lock an account
+	DefaultMaxFailedLogins = 5
+	// DefaultLockoutDuration is how long a locked account can't log in
+	DefaultLockoutDuration = 15 * time.Minute
+	// DefaultPasswordResetTTL is how long a mailed password reset token is valid
+	DefaultPasswordResetTTL = time.Hour
+)
+
+type AccountService struct {
+	MemberRepository     repositories.IMemberRepository
+	CredentialRepository repositories.ICredentialRepository
+	AuthService          AuthService
+	MailSender           mail.Sender
+	TxDB                 db_manager.ItxDB
+
+	BcryptCost       int
+	MaxFailedLogins  int
+	LockoutDuration  time.Duration
+	PasswordResetTTL time.Duration
+}
+
+// NewAccountService uses interface so that we can switch between in-memory and actual pgsql repo data easily.
+// authService issues the bearer token of a successful login, mailSender delivers password reset tokens.
+func NewAccountService(memberRepository repositories.IMemberRepository, credentialRepository repositories.ICredentialRepository, authService AuthService, mailSender mail.Sender) AccountService {
+	return AccountService{
+		MemberRepository:     memberRepository,
+		CredentialRepository: credentialRepository,
+		AuthService:          authService,
+		MailSender:           mailSender,
+		BcryptCost:           bcrypt.DefaultCost,
+		MaxFailedLogins:      DefaultMaxFailedLogins,
+		LockoutDuration:      DefaultLockoutDuration,
+		PasswordResetTTL:     DefaultPasswordResetTTL,
+	}
+}
+
+var ErrAccountLocked = errors.New("account locked after too many failed logins, try again later")
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     []byte
+)
+
+// compareDummyHash spends as long as a real password check, so that unknown names can't be told apart by response time
+func compareDummyHash(password string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not-a-password"), bcrypt.DefaultCost)
+	})
+	_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+}
+
+// Register creates a member with a password login
+func (s *AccountService) Register(ctx context.Context, request *models.RegisterRequest) (*models.Member, error) {
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(request.Password), s.BcryptCost)
+	if err != nil {
+		return nil, err
+	}
+
+	var member *models.Member
+	if err = db_manager.WrapInTransaction(ctx, s.TxDB, func(ctx context.Context) error {
+		member, err = s.MemberRepository.CreateMember(ctx, models.NewMember(request.Name, request.Email))
+		if err != nil {
+			return err
+		}
+		_, err = s.CredentialRepository.SetPassword(ctx, member.Id, string(passwordHash), time.Now())
+		return err
+	}, nil); err != nil {
+		if !errors.Is(err, repositories.ErrExistingMember) {
+			log.Printf("error registering member: %v", err)
+		}
+		return nil, err
+	}
+	log.Printf("member %d registered\n", member.Id)
+	return member, nil
+}
+
+// Login checks the password of a member and issues a bearer token.
+// Unknown names and wrong passwords fail alike, repeated failures lock the account for a while.
+func (s *AccountService) Login(ctx context.Context, name string, password string) (*models.IssuedToken, error) {
+	member, err := s.MemberRepository.GetMemberByName(ctx, name)
+	if err != nil {
+		if errors.Is(err, repositories.ErrMemberNotFound) {
+			compareDummyHash(password)
+			return nil, ErrInvalidCredentials
+		}
+		log.Printf("error getting member from repository: %v", err)
+		return nil, err
+	}
+	if err := s.checkPassword(ctx, member.Id, password); err != nil {
+		return nil, err
+	}
+	return s.AuthService.IssueToken(ctx, member.Id)
+}
+
+// checkPassword verifies the password of a member, counting failures towards a lockout
+func (s *AccountService) checkPassword(ctx context.Context, memberId int, password string) error {
+	credential, err := s.CredentialRepository.GetCredential(ctx, memberId)
+	if err != nil {
+		if errors.Is(err, repositories.ErrCredentialNotFound) {
+			compareDummyHash(password)
+			return ErrInvalidCredentials
+		}
+		log.Printf("error getting credential from repository: %v", err)
+		return err
+	}
+
+	now := time.Now()
+	if credential.LockedUntil != nil && credential.LockedUntil.After(now) {
+		return ErrAccountLocked
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(credential.PasswordHash), []byte(password)); err != nil {
+		credential, err = s.CredentialRepository.RecordFailedLogin(ctx, memberId, s.MaxFailedLogins, now.Add(s.LockoutDuration))
+		if err != nil {
+			log.Printf("error recording failed login from repository: %v", err)
+			return err
+		}
+		if credential.LockedUntil != nil && credential.LockedUntil.After(now) {
+			log.Printf("member %d locked out after %d failed logins\n", memberId, s.MaxFailedLogins)
+			return ErrAccountLocked
+		}
+		return ErrInvalidCredentials
+	}
+
+	if credential.FailedLogins > 0 || credential.LockedUntil != nil {
+		if err := s.CredentialRepository.ResetFailedLogins(ctx, memberId); err != nil {
+			log.Printf("error resetting failed logins from repository: %v", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// ChangePassword replaces the password of the patron making the request, given its current password
+func (s *AccountService) ChangePassword(ctx context.Context, currentPassword string, newPassword string) error {
+	principal := auth.FromContext(ctx)
+	if !principal.IsPatron() {
+		return &auth.ForbiddenError{Reason: "only patrons have a password"}
+	}
+	if err := s.checkPassword(ctx, principal.Id, currentPassword); err != nil {
+		return err
+	}
+	return s.setPassword(ctx, principal.Id, newPassword)
+}
+
+func (s *AccountService) setPassword(ctx context.Context, memberId int, password string) error {
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), s.BcryptCost)
+	if err != nil {
+		return err
+	}
+	return s.storePassword(ctx, memberId, passwordHash)
+}
+
+func (s *AccountService) storePassword(ctx context.Context, memberId int, passwordHash []byte) error {
+	if _, err := s.CredentialRepository.SetPassword(ctx, memberId, string(passwordHash), time.Now()); err != nil {
+		log.Printf("error setting password from repository: %v", err)
+		return err
+	}
+	return nil
+}
+
+// RequestPasswordReset mails a single use reset token to the member with the email.
+// It succeeds whether or not the email belongs to a member, so that it can't be used to find out members' emails.
+func (s *AccountService) RequestPasswordReset(ctx context.Context, email string) error {
+	member, err := s.MemberRepository.GetMemberByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, repositories.ErrMemberNotFound) {
+			log.Printf("password reset requested for unknown email")
+			return nil
+		}
+		log.Printf("error getting member from repository: %v", err)
+		return err
+	}
+
+	token, err := auth.RandomId(32)
+	if err != nil {
+		return err
+	}
+	expiresAt := time.Now().Add(s.PasswordResetTTL)
+	if err := s.CredentialRepository.CreatePasswordReset(ctx, &models.PasswordReset{
+		TokenHash: tenant.HashApiKey(token),
+		MemberId:  member.Id,
+		ExpiresAt: expiresAt,
+	}); err != nil {
+		log.Printf("error creating password reset from repository: %v", err)
+		return err
+	}
+
+	msg := mail.Message{
+		To:      member.Email,
+		Subject: "Reset your library password",
+		Body: fmt.Sprintf("Hello %s,\n\nUse this token to choose a new password, it is valid until %s:\n\n%s\n\nIf you didn't ask to reset your password, ignore this mail.\n",
+			member.Name, expiresAt.Format(time.RFC1123), token),
+	}
+	if err := s.MailSender.Send(ctx, msg); err != nil {
+		log.Printf("error sending password reset mail to member %d: %v", member.Id, err)
+		return err
+	}
+	return nil
+}
+
+// ConfirmPasswordReset sets a new password with a mailed reset token, which also lifts any lockout. The token is used
+// up in the transaction setting the password, so it is neither spent without a new password nor usable twice.
+func (s *AccountService) ConfirmPasswordReset(ctx context.Context, token string, password string) error {
+	//hashed before the transaction, which isn't held open while bcrypt runs
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), s.BcryptCost)
+	if err != nil {
+		return err
+	}
+	return db_manager.WrapInTransaction(ctx, s.TxDB, func(ctx context.Context) error {
+		reset, err := s.CredentialRepository.UsePasswordReset(ctx, tenant.HashApiKey(token), time.Now())
+		if err != nil {
+			if !errors.Is(err, repositories.ErrPasswordResetNotFound) {
+				log.Printf("error using password reset from repository: %v", err)
+			}
+			return err
+		}
+		return s.storePassword(ctx, reset.MemberId, passwordHash)
+	}, nil)
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/aftaab60/e-library-api/internal/auth"
+	"github.com/aftaab60/e-library-api/internal/mail"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newTestAccountService(mailDir string) AccountService {
+	memberRepo := repositories.NewMemberRepository()
+	authService := NewAuthService(repositories.NewApiKeyRepository(), repositories.NewTokenRepository(), memberRepo, []byte("test-secret"))
+	accountService := NewAccountService(memberRepo, repositories.NewCredentialRepository(), authService, mail.NewFileSender(mailDir, "library@example.com"))
+	accountService.BcryptCost = bcrypt.MinCost
+	accountService.MaxFailedLogins = 3
+	return accountService
+}
+
+func TestAccountService_Login(t *testing.T) {
+	accountService := newTestAccountService(t.TempDir())
+	ctx := context.Background()
+
+	member, err := accountService.Register(ctx, &models.RegisterRequest{Name: "user3", Email: "user3@example.com", Password: "correct horse"})
+	assert.NoError(t, err)
+
+	t.Run("Fail to register an existing member", func(t *testing.T) {
+		_, err := accountService.Register(ctx, &models.RegisterRequest{Name: "user4", Email: "USER3@example.com", Password: "correct horse"})
+		assert.Equal(t, repositories.ErrExistingMember, err)
+	})
+
+	t.Run("Login issues a patron token", func(t *testing.T) {
+		token, err := accountService.Login(ctx, "user3", "correct horse")
+		assert.NoError(t, err)
+
+		principal, err := accountService.AuthService.Authenticate(ctx, "", token.Token)
+		assert.NoError(t, err)
+		assert.Equal(t, member.Id, principal.Id)
+	})
+
+	t.Run("Unknown member and wrong password fail alike", func(t *testing.T) {
+		_, err := accountService.Login(ctx, "nobody", "correct horse")
+		assert.Equal(t, ErrInvalidCredentials, err)
+		_, err = accountService.Login(ctx, "user1", "correct horse")
+		assert.Equal(t, ErrInvalidCredentials, err)
+		_, err = accountService.Login(ctx, "user3", "wrong password")
+		assert.Equal(t, ErrInvalidCredentials, err)
+	})
+
+	t.Run("Lock after repeated failures", func(t *testing.T) {
+		_, err := accountService.Login(ctx, "user3", "wrong password")
+		assert.Equal(t, ErrInvalidCredentials, err)
+		_, err = accountService.Login(ctx, "user3", "wrong password")
+		assert.Equal(t, ErrAccountLocked, err)
+
+		// even the right password is refused while locked
+		_, err = accountService.Login(ctx, "user3", "correct horse")
+		assert.Equal(t, ErrAccountLocked, err)
+	})
+}
+
+func TestAccountService_ChangePassword(t *testing.T) {
+	accountService := newTestAccountService(t.TempDir())
+	ctx := context.Background()
+
+	member, err := accountService.Register(ctx, &models.RegisterRequest{Name: "user3", Email: "user3@example.com", Password: "correct horse"})
+	assert.NoError(t, err)
+	patron := auth.NewContext(ctx, &models.Principal{Type: models.PrincipalTypePatron, Role: models.RolePatron, Id: member.Id, Name: member.Name})
+
+	t.Run("Fail with a wrong current password", func(t *testing.T) {
+		err := accountService.ChangePassword(patron, "wrong password", "battery staple")
+		assert.Equal(t, ErrInvalidCredentials, err)
+	})
+
+	t.Run("Change the password", func(t *testing.T) {
+		err := accountService.ChangePassword(patron, "correct horse", "battery staple")
+		assert.NoError(t, err)
+		_, err = accountService.Login(ctx, "user3", "battery staple")
+		assert.NoError(t, err)
+	})
+
+	t.Run("Fail without a patron", func(t *testing.T) {
+		err := accountService.ChangePassword(ctx, "battery staple", "correct horse")
+		assert.ErrorIs(t, err, auth.ErrForbidden)
+	})
+}
+
+func TestAccountService_PasswordReset(t *testing.T) {
+	mailDir := t.TempDir()
+	accountService := newTestAccountService(mailDir)
+	ctx := context.Background()
+
+	_, err := accountService.Register(ctx, &models.RegisterRequest{Name: "user3", Email: "user3@example.com", Password: "correct horse"})
+	assert.NoError(t, err)
+
+	t.Run("Unknown email sends nothing", func(t *testing.T) {
+		assert.NoError(t, accountService.RequestPasswordReset(ctx, "nobody@example.com"))
+		files, _ := os.ReadDir(mailDir)
+		assert.Len(t, files, 0)
+	})
+
+	t.Run("Reset the password with the mailed token", func(t *testing.T) {
+		// lock the account first, a reset lifts the lock
+		for i := 0; i < accountService.MaxFailedLogins; i++ {
+			_, _ = accountService.Login(ctx, "user3", "wrong password")
+		}
+
+		assert.NoError(t, accountService.RequestPasswordReset(ctx, "user3@example.com"))
+		files, err := os.ReadDir(mailDir)
+		assert.NoError(t, err)
+		assert.Len(t, files, 1)
+		content, err := os.ReadFile(filepath.Join(mailDir, files[0].Name()))
+		assert.NoError(t, err)
+		assert.Contains(t, string(content), "To: user3@example.com")
+		token := regexp.MustCompile(`(?m)^[0-9a-f]{64}$`).FindString(string(content))
+		assert.NotEmpty(t, token)
+
+		assert.NoError(t, accountService.ConfirmPasswordReset(ctx, token, "battery staple"))
+		_, err = accountService.Login(ctx, "user3", "battery staple")
+		assert.NoError(t, err)
+
+		// a token is single use
+		err = accountService.ConfirmPasswordReset(ctx, token, "another password")
+		assert.Equal(t, repositories.ErrPasswordResetNotFound, err)
+	})
+
+	t.Run("Fail with an expired token", func(t *testing.T) {
+		accountService.PasswordResetTTL = -time.Minute
+		assert.NoError(t, accountService.RequestPasswordReset(ctx, "user3@example.com"))
+		files, _ := os.ReadDir(mailDir)
+		content, _ := os.ReadFile(filepath.Join(mailDir, files[len(files)-1].Name()))
+		token := regexp.MustCompile(`(?m)^[0-9a-f]{64}$`).FindString(string(content))
+
+		err := accountService.ConfirmPasswordReset(ctx, token, "another password")
+		assert.Equal(t, repositories.ErrPasswordResetNotFound, err)
+	})
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/aftaab60/e-library-api/internal/auth"
+	"github.com/aftaab60/e-library-api/internal/requestid"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"time"
+)
+
+type AuditService struct {
+	AuditRepository repositories.IAuditRepository
+}
+
+func NewAuditService(auditRepository repositories.IAuditRepository) *AuditService {
+	return &AuditService{AuditRepository: auditRepository}
+}
+
+// Record appends an entry for a state change, attributed to the principal and request in context.
+// It is called within the transaction making the change, so that a change is never made without its entry.
+// A nil AuditService records nothing, services built without one don't audit.
+func (s *AuditService) Record(ctx context.Context, action models.AuditAction, entityType string, entityId int, changes []models.AuditChange) error {
+	if s == nil {
+		return nil
+	}
+	entry := &models.AuditEntry{
+		Action:     action,
+		ActorType:  models.PrincipalTypeSystem,
+		RequestId:  requestid.FromContext(ctx),
+		EntityType: entityType,
+		EntityId:   entityId,
+		Changes:    changes,
+		//entries tell when a change was really made, time travel aside
+		CreatedAt: time.Now(),
+	}
+	if entry.Changes == nil {
+		entry.Changes = make([]models.AuditChange, 0)
+	}
+	if principal := auth.FromContext(ctx); principal != nil {
+		entry.ActorType = principal.Type
+		entry.ActorId = principal.Id
+		entry.ActorName = principal.Name
+	}
+	if _, err := s.AuditRepository.CreateAuditEntry(ctx, entry); err != nil {
+		return fmt.Errorf("error recording %s of %s %d: %w", action, entityType, entityId, err)
+	}
+	return nil
+}
+
+func (s *AuditService) ListAuditEntries(ctx context.Context, filter *models.AuditFilter) (*models.AuditPage, error) {
+	if err := auth.Authorize(ctx, models.PermissionAuditRead); err != nil {
+		return nil, err
+	}
+	entries, total, err := s.AuditRepository.ListAuditEntries(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return &models.AuditPage{Entries: entries, Total: total, Limit: filter.Limit, Offset: filter.Offset}, nil
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aftaab60/e-library-api/internal/auth"
+	"github.com/aftaab60/e-library-api/internal/requestid"
+	"github.com/aftaab60/e-library-api/internal/tenant"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditService_LoanChanges(t *testing.T) {
+	auditService := NewAuditService(repositories.NewAuditRepository())
+	bookRepo := repositories.NewBookRepository()
+	loanService := NewLoanService(repositories.NewLoanRepository(), bookRepo, repositories.NewChargeRepository(), repositories.NewBranchRepository(), repositories.NewTransferRepository(), repositories.NewMemberRepository(), repositories.NewLoanEventRepository())
+	loanService.Auditor = auditService
+
+	patron := auth.NewContext(context.Background(), &models.Principal{Type: models.PrincipalTypePatron, Role: models.RolePatron, Id: 1, Name: "user1"})
+	admin := auth.NewContext(context.Background(), &models.Principal{Type: models.PrincipalTypeStaff, Role: models.RoleAdmin, Name: "admin1"})
+
+	_, err := loanService.BorrowBook(requestid.NewContext(patron, "request1"), "book1", "user1")
+	assert.NoError(t, err)
+	assert.NoError(t, loanService.ReturnBook(requestid.NewContext(patron, "request2"), "book1", "user1"))
+
+	t.Run("Borrow is recorded with actor, request and diff", func(t *testing.T) {
+		page, err := auditService.ListAuditEntries(admin, &models.AuditFilter{RequestId: "request1", Limit: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, page.Total)
+
+		entry := page.Entries[0]
+		assert.Equal(t, models.AuditActionLoanBorrowed, entry.Action)
+		assert.Equal(t, models.PrincipalTypePatron, entry.ActorType)
+		assert.Equal(t, "user1", entry.ActorName)
+		assert.Equal(t, models.AuditEntityLoan, entry.EntityType)
+		assert.Contains(t, entry.Changes, models.AuditChange{Entity: "book:1", Field: "available_copies", Before: float64(5), After: float64(4)})
+		assert.Contains(t, entry.Changes, models.AuditChange{Entity: "branch_stock:1:1", Field: "available_copies", Before: float64(5), After: float64(4)})
+		assert.Contains(t, entry.Changes, models.AuditChange{Entity: models.AuditEntity(models.AuditEntityLoan, entry.EntityId), Field: "borrower_name", After: "user1"})
+	})
+
+	t.Run("Return is recorded", func(t *testing.T) {
+		page, err := auditService.ListAuditEntries(admin, &models.AuditFilter{Action: models.AuditActionLoanReturned, Limit: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, page.Total)
+		assert.Equal(t, "request2", page.Entries[0].RequestId)
+		assert.Contains(t, page.Entries[0].Changes, models.AuditChange{Entity: "book:1", Field: "available_copies", Before: float64(4), After: float64(5)})
+		assert.Contains(t, page.Entries[0].Changes, models.AuditChange{Entity: models.AuditEntity(models.AuditEntityLoan, page.Entries[0].EntityId), Field: "status", Before: "active", After: "returned"})
+	})
+
+	t.Run("Patron may not read the audit log", func(t *testing.T) {
+		_, err := auditService.ListAuditEntries(patron, &models.AuditFilter{Limit: 10})
+		assert.ErrorIs(t, err, auth.ErrForbidden)
+	})
+
+	t.Run("Service without auditor records nothing", func(t *testing.T) {
+		var noAuditor *AuditService
+		assert.NoError(t, noAuditor.Record(patron, models.AuditActionLoanBorrowed, models.AuditEntityLoan, 1, nil))
+	})
+}
+
+func TestAuditService_PolicyUpdate(t *testing.T) {
+	auditService := NewAuditService(repositories.NewAuditRepository())
+	tenantRepo := repositories.NewTenantRepository()
+	tenantService := NewTenantService(tenantRepo)
+	tenantService.Auditor = auditService
+
+	city, err := tenantRepo.GetTenantBySlug(context.Background(), "city")
+	assert.NoError(t, err)
+	admin := auth.NewContext(tenant.NewContext(context.Background(), city), &models.Principal{Type: models.PrincipalTypeStaff, Role: models.RoleAdmin, Name: "admin1"})
+
+	policy := city.Policy
+	policy.LoanPeriodDays = 14
+	_, err = tenantService.UpdateLoanPolicy(admin, policy)
+	assert.NoError(t, err)
+
+	page, err := auditService.ListAuditEntries(admin, &models.AuditFilter{Action: models.AuditActionPolicyUpdated, Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, page.Total)
+	assert.Equal(t, "admin1", page.Entries[0].ActorName)
+	assert.Len(t, page.Entries[0].Changes, 1)
+	assert.Equal(t, "loan_period_days", page.Entries[0].Changes[0].Field)
+	assert.Equal(t, float64(14), page.Entries[0].Changes[0].After)
+}
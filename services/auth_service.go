@@ -0,0 +1,267 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"github.com/aftaab60/e-library-api/internal/auth"
+	"github.com/aftaab60/e-library-api/internal/db_manager"
+	"github.com/aftaab60/e-library-api/internal/tenant"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"log"
+	"strconv"
+	"time"
+)
+
+// DefaultTokenTTL is how long a patron bearer token is valid
+const DefaultTokenTTL = 24 * time.Hour
+
+type AuthService struct {
+	ApiKeyRepository repositories.IApiKeyRepository
+	TokenRepository  repositories.ITokenRepository
+	MemberRepository repositories.IMemberRepository
+	//Secret signs patron bearer tokens, tokens don't survive a restart when it is generated at startup
+	Secret   []byte
+	TokenTTL time.Duration
+	TxDB     db_manager.ItxDB
+	//Auditor records API key changes, nil records nothing
+	Auditor *AuditService
+}
+
+// NewAuthService uses interface so that we can switch between in-memory and actual pgsql repo data easily.
+// A random secret is generated when secret is empty.
+func NewAuthService(apiKeyRepository repositories.IApiKeyRepository, tokenRepository repositories.ITokenRepository, memberRepository repositories.IMemberRepository, secret []byte) AuthService {
+	if len(secret) == 0 {
+		generated, err := auth.RandomId(32)
+		if err != nil {
+			log.Fatalf("error generating token secret: %v", err)
+		}
+		log.Println("no token secret configured, patron tokens are signed with a random secret")
+		secret = []byte(generated)
+	}
+	return AuthService{
+		ApiKeyRepository: apiKeyRepository,
+		TokenRepository:  tokenRepository,
+		MemberRepository: memberRepository,
+		Secret:           secret,
+		TokenTTL:         DefaultTokenTTL,
+	}
+}
+
+var (
+	ErrUnauthenticated    = errors.New("authentication required")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Authenticate identifies the caller from either a bearer token or an API key, the bearer token takes precedence.
+// The API key is either the tenant's own key or a key issued to an integration.
+func (s *AuthService) Authenticate(ctx context.Context, apiKey string, bearerToken string) (*models.Principal, error) {
+	if bearerToken != "" {
+		return s.authenticateToken(ctx, bearerToken)
+	}
+	if apiKey != "" {
+		return s.authenticateApiKey(ctx, apiKey)
+	}
+	return nil, ErrUnauthenticated
+}
+
+func (s *AuthService) authenticateToken(ctx context.Context, bearerToken string) (*models.Principal, error) {
+	claims, err := auth.ParseToken(bearerToken, s.Secret, time.Now())
+	if err != nil {
+		return nil, errors.Join(ErrInvalidCredentials, err)
+	}
+	//a token is only valid for the tenant it was issued by
+	if claims.TenantId != tenant.Id(ctx) {
+		return nil, ErrInvalidCredentials
+	}
+	token, err := s.TokenRepository.GetToken(ctx, claims.TokenId)
+	if err != nil {
+		if errors.Is(err, repositories.ErrTokenNotFound) {
+			return nil, ErrInvalidCredentials
+		}
+		log.Printf("error getting token from repository: %v", err)
+		return nil, err
+	}
+	//the role comes from the stored token, the claim only has to agree with it
+	if token.RevokedAt != nil || token.Subject != claims.Subject || token.Role != claims.Role {
+		return nil, ErrInvalidCredentials
+	}
+
+	principal := &models.Principal{
+		Type:     models.PrincipalTypePatron,
+		Role:     token.Role,
+		Id:       token.MemberId,
+		Name:     claims.Name,
+		TokenId:  claims.TokenId,
+		TenantId: claims.TenantId,
+	}
+	if token.Role != models.RolePatron {
+		principal.Type = models.PrincipalTypeStaff
+	}
+	return principal, nil
+}
+
+// AuthenticateTenantKey authenticates the API key of the tenant in context, the tenant's own key administers the tenant.
+// Keys issued to integrations aren't accepted.
+func (s *AuthService) AuthenticateTenantKey(ctx context.Context, apiKey string) (*models.Principal, error) {
+	t := tenant.FromContext(ctx)
+	if t == nil || subtle.ConstantTimeCompare([]byte(tenant.HashApiKey(apiKey)), []byte(t.ApiKeyHash)) != 1 {
+		return nil, ErrInvalidCredentials
+	}
+	return &models.Principal{Type: models.PrincipalTypeApiKey, Role: models.RoleAdmin, Name: t.Slug, TenantId: t.Id}, nil
+}
+
+func (s *AuthService) authenticateApiKey(ctx context.Context, apiKey string) (*models.Principal, error) {
+	if principal, err := s.AuthenticateTenantKey(ctx, apiKey); err == nil {
+		return principal, nil
+	}
+	keyHash := tenant.HashApiKey(apiKey)
+	t := tenant.FromContext(ctx)
+	if t == nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	issued, err := s.ApiKeyRepository.GetApiKeyByHash(ctx, keyHash)
+	if err != nil {
+		if errors.Is(err, repositories.ErrApiKeyNotFound) {
+			return nil, ErrInvalidCredentials
+		}
+		log.Printf("error getting api key from repository: %v", err)
+		return nil, err
+	}
+	if issued.RevokedAt != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return &models.Principal{Type: models.PrincipalTypeApiKey, Role: issued.Role, Id: issued.Id, Name: issued.Name, TenantId: t.Id}, nil
+}
+
+// IssueToken issues a bearer token to a member, the member then borrows and returns on its own behalf
+func (s *AuthService) IssueToken(ctx context.Context, memberId int) (*models.IssuedToken, error) {
+	if err := auth.Authorize(ctx, models.PermissionMemberManage); err != nil {
+		return nil, err
+	}
+	member, err := s.MemberRepository.GetMember(ctx, memberId)
+	if err != nil {
+		log.Printf("error getting member from repository: %v", err)
+		return nil, err
+	}
+	return s.issueToken(ctx, &models.Token{
+		Subject:  strconv.Itoa(member.Id),
+		MemberId: member.Id,
+		Role:     models.RolePatron,
+	}, member.Name)
+}
+
+// IssueStaffToken issues a session token to a staff member authenticated by the identity provider.
+// It is not authorized, callers must have verified the staff identity and role.
+func (s *AuthService) IssueStaffToken(ctx context.Context, subject string, name string, role models.Role) (*models.IssuedToken, error) {
+	return s.issueToken(ctx, &models.Token{Subject: subject, Role: role}, name)
+}
+
+func (s *AuthService) issueToken(ctx context.Context, token *models.Token, name string) (*models.IssuedToken, error) {
+	tokenId, err := auth.RandomId(16)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	token.Id = tokenId
+	token.IssuedAt = now
+	token.ExpiresAt = now.Add(s.TokenTTL)
+	token, err = s.TokenRepository.CreateToken(ctx, token)
+	if err != nil {
+		log.Printf("error creating token from repository: %v", err)
+		return nil, err
+	}
+
+	signed, err := auth.SignToken(&auth.Claims{
+		Subject:   token.Subject,
+		Name:      name,
+		Role:      token.Role,
+		TenantId:  tenant.Id(ctx),
+		TokenId:   token.Id,
+		IssuedAt:  token.IssuedAt.Unix(),
+		ExpiresAt: token.ExpiresAt.Unix(),
+	}, s.Secret)
+	if err != nil {
+		return nil, err
+	}
+	return &models.IssuedToken{Token: signed, TokenId: token.Id, ExpiresAt: token.ExpiresAt}, nil
+}
+
+// RevokeToken revokes a bearer token before it expires. Patrons may only revoke their own tokens.
+func (s *AuthService) RevokeToken(ctx context.Context, tokenId string) (*models.Token, error) {
+	token, err := s.TokenRepository.GetToken(ctx, tokenId)
+	if err != nil {
+		log.Printf("error getting token from repository: %v", err)
+		return nil, err
+	}
+	if err := auth.AuthorizeMember(ctx, token.MemberId); err != nil {
+		return nil, err
+	}
+	return s.TokenRepository.RevokeToken(ctx, tokenId, time.Now())
+}
+
+// CreateApiKey issues an API key for an integration. The plain key is returned only here, the repository keeps its hash.
+func (s *AuthService) CreateApiKey(ctx context.Context, name string, role models.Role) (*models.IssuedApiKey, error) {
+	if err := auth.Authorize(ctx, models.PermissionConfigManage); err != nil {
+		return nil, err
+	}
+	secret, err := auth.RandomId(24)
+	if err != nil {
+		return nil, err
+	}
+	key := "lib_" + secret
+	var apiKey *models.ApiKey
+	if err = db_manager.WrapInTransaction(ctx, s.TxDB, func(ctx context.Context) error {
+		apiKey, err = s.ApiKeyRepository.CreateApiKey(ctx, &models.ApiKey{
+			Name:      name,
+			Role:      role,
+			Prefix:    key[:8],
+			KeyHash:   tenant.HashApiKey(key),
+			CreatedAt: time.Now(),
+		})
+		if err != nil {
+			log.Printf("error creating api key from repository: %v", err)
+			return err
+		}
+		changes := models.Diff(models.AuditEntity(models.AuditEntityApiKey, apiKey.Id), nil, apiKey)
+		return s.Auditor.Record(ctx, models.AuditActionApiKeyCreated, models.AuditEntityApiKey, apiKey.Id, changes)
+	}, nil); err != nil {
+		return nil, err
+	}
+	return &models.IssuedApiKey{ApiKey: *apiKey, Key: key}, nil
+}
+
+func (s *AuthService) ListApiKeys(ctx context.Context) ([]models.ApiKey, error) {
+	if err := auth.Authorize(ctx, models.PermissionConfigManage); err != nil {
+		return nil, err
+	}
+	apiKeys, err := s.ApiKeyRepository.ListApiKeys(ctx)
+	if err != nil {
+		log.Printf("error listing api keys from repository: %v", err)
+		return nil, err
+	}
+	return apiKeys, nil
+}
+
+func (s *AuthService) RevokeApiKey(ctx context.Context, id int) (*models.ApiKey, error) {
+	if err := auth.Authorize(ctx, models.PermissionConfigManage); err != nil {
+		return nil, err
+	}
+	var apiKey *models.ApiKey
+	if err := db_manager.WrapInTransaction(ctx, s.TxDB, func(ctx context.Context) error {
+		var err error
+		if apiKey, err = s.ApiKeyRepository.RevokeApiKey(ctx, id, time.Now()); err != nil {
+			log.Printf("error revoking api key from repository: %v", err)
+			return err
+		}
+		//revoked_at is the only field a revocation changes
+		changes := []models.AuditChange{{Entity: models.AuditEntity(models.AuditEntityApiKey, id), Field: "revoked_at", After: apiKey.RevokedAt}}
+		return s.Auditor.Record(ctx, models.AuditActionApiKeyRevoked, models.AuditEntityApiKey, id, changes)
+	}, nil); err != nil {
+		return nil, err
+	}
+	return apiKey, nil
+}
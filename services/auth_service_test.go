@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aftaab60/e-library-api/internal/auth"
+	"github.com/aftaab60/e-library-api/internal/tenant"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestAuthService() AuthService {
+	return NewAuthService(repositories.NewApiKeyRepository(), repositories.NewTokenRepository(), repositories.NewMemberRepository(), []byte("test-secret"))
+}
+
+func TestAuthService_ApiKeys(t *testing.T) {
+	authService := newTestAuthService()
+	ctx := tenant.NewContext(context.Background(), &models.Tenant{Id: 1, Slug: "default", ApiKeyHash: tenant.HashApiKey("default-library-key")})
+
+	t.Run("Authenticate with the tenant key", func(t *testing.T) {
+		principal, err := authService.Authenticate(ctx, "default-library-key", "")
+		assert.NoError(t, err)
+		assert.Equal(t, models.PrincipalTypeApiKey, principal.Type)
+		assert.Equal(t, 0, principal.Id)
+	})
+
+	t.Run("Authenticate with an issued key until revoked", func(t *testing.T) {
+		issued, err := authService.CreateApiKey(ctx, "kiosk", models.RoleLibrarian)
+		assert.NoError(t, err)
+		assert.Equal(t, issued.Key[:8], issued.Prefix)
+		assert.NotEqual(t, issued.Key, issued.KeyHash)
+
+		principal, err := authService.Authenticate(ctx, issued.Key, "")
+		assert.NoError(t, err)
+		assert.Equal(t, issued.Id, principal.Id)
+		assert.Equal(t, "kiosk", principal.Name)
+
+		_, err = authService.RevokeApiKey(ctx, issued.Id)
+		assert.NoError(t, err)
+		_, err = authService.Authenticate(ctx, issued.Key, "")
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	})
+
+	t.Run("Fail without credentials", func(t *testing.T) {
+		_, err := authService.Authenticate(ctx, "", "")
+		assert.ErrorIs(t, err, ErrUnauthenticated)
+	})
+
+	t.Run("Fail with an unknown key", func(t *testing.T) {
+		_, err := authService.Authenticate(ctx, "wrong-key", "")
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	})
+}
+
+func TestAuthService_Tokens(t *testing.T) {
+	authService := newTestAuthService()
+	ctx := tenant.NewContext(context.Background(), &models.Tenant{Id: 1, Slug: "default"})
+
+	issued, err := authService.IssueToken(ctx, 1)
+	assert.NoError(t, err)
+
+	t.Run("Authenticate a patron with its token", func(t *testing.T) {
+		principal, err := authService.Authenticate(ctx, "", issued.Token)
+		assert.NoError(t, err)
+		assert.Equal(t, models.PrincipalTypePatron, principal.Type)
+		assert.Equal(t, 1, principal.Id)
+		assert.Equal(t, "user1", principal.Name)
+	})
+
+	t.Run("Fail with a token of another tenant", func(t *testing.T) {
+		otherTenant := tenant.NewContext(context.Background(), &models.Tenant{Id: 2, Slug: "city"})
+		_, err := authService.Authenticate(otherTenant, "", issued.Token)
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	})
+
+	t.Run("Fail with a tampered token", func(t *testing.T) {
+		_, err := authService.Authenticate(ctx, "", issued.Token+"x")
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	})
+
+	t.Run("Fail with an expired token", func(t *testing.T) {
+		expiring := newTestAuthService()
+		expiring.TokenTTL = 0
+		expired, err := expiring.IssueToken(ctx, 1)
+		assert.NoError(t, err)
+		_, err = expiring.Authenticate(ctx, "", expired.Token)
+		assert.ErrorIs(t, err, auth.ErrTokenExpired)
+	})
+
+	t.Run("Fail to issue a token to an unknown member", func(t *testing.T) {
+		_, err := authService.IssueToken(ctx, 100)
+		assert.Equal(t, repositories.ErrMemberNotFound, err)
+	})
+
+	t.Run("Patrons only revoke their own tokens", func(t *testing.T) {
+		other, err := authService.IssueToken(ctx, 2)
+		assert.NoError(t, err)
+		patron := auth.NewContext(ctx, &models.Principal{Type: models.PrincipalTypePatron, Role: models.RolePatron, Id: 1})
+
+		_, err = authService.RevokeToken(patron, other.TokenId)
+		assert.ErrorIs(t, err, auth.ErrForbidden)
+
+		_, err = authService.RevokeToken(patron, issued.TokenId)
+		assert.NoError(t, err)
+		_, err = authService.Authenticate(ctx, "", issued.Token)
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	})
+}
@@ -3,18 +3,24 @@ package services
 import (
 	"context"
 	"errors"
+	"github.com/aftaab60/e-library-api/internal/auth"
+	"github.com/aftaab60/e-library-api/internal/db_manager"
 	"github.com/aftaab60/e-library-api/models"
 	"github.com/aftaab60/e-library-api/repositories"
 	"log"
 )
 
 type BookService struct {
-	bookRepository repositories.IBookRepository
+	bookRepository   repositories.IBookRepository
+	branchRepository repositories.IBranchRepository
+	TxDB             db_manager.ItxDB
+	//Auditor records changes of loan types, nil records nothing
+	Auditor *AuditService
 }
 
 // NewBookService uses interface so that we can switch between in-memory and actual pgsql repo data easily
-func NewBookService(bookRepository repositories.IBookRepository) BookService {
-	return BookService{bookRepository: bookRepository}
+func NewBookService(bookRepository repositories.IBookRepository, branchRepository repositories.IBranchRepository) BookService {
+	return BookService{bookRepository: bookRepository, branchRepository: branchRepository}
 }
 
 func (s *BookService) GetBookByTitle(ctx context.Context, title string) (*models.BookDetail, error) {
@@ -28,8 +34,82 @@ func (s *BookService) GetBookByTitle(ctx context.Context, title string) (*models
 		return nil, err
 	}
 
+	//availability broken down by branch
+	stock, err := s.branchRepository.GetStock(ctx, book.Id)
+	if err != nil {
+		log.Printf("error getting branch stock from repository: %v", err)
+		return nil, err
+	}
+	branches := make([]models.BranchAvailability, 0, len(stock))
+	for _, st := range stock {
+		branch, err := s.branchRepository.GetBranch(ctx, st.BranchId)
+		if err != nil {
+			log.Printf("error getting branch from repository: %v", err)
+			return nil, err
+		}
+		branches = append(branches, models.BranchAvailability{
+			BranchId:        branch.Id,
+			BranchName:      branch.Name,
+			AvailableCopies: st.AvailableCopies,
+			InTransitCopies: st.InTransitCopies,
+		})
+	}
+
 	return &models.BookDetail{
 		Title:           book.Title,
 		AvailableCopies: book.AvailableCopies,
+		LoanType:        book.LoanType,
+		LoanHours:       book.LoanHours,
+		Isbn:            book.Isbn,
+		Branches:        branches,
 	}, nil
 }
+
+// UpdateLoanType sets how the copies of a book are lent. Loans already made keep their due dates, but are only extended
+// when the book is still lent.
+func (s *BookService) UpdateLoanType(ctx context.Context, title string, request *models.LoanTypeRequest) (*models.BookDetail, error) {
+	if err := auth.Authorize(ctx, models.PermissionCatalogManage); err != nil {
+		return nil, err
+	}
+	if err := db_manager.WrapInTransaction(ctx, s.TxDB, func(ctx context.Context) error {
+		book, err := s.bookRepository.GetBook(ctx, title)
+		if err != nil {
+			return err
+		}
+		before := *book
+		updated, err := s.bookRepository.UpdateBookLoanType(ctx, title, request.LoanType, request.LoanHours)
+		if err != nil {
+			log.Printf("error updating loan type of book '%s' from repository: %v", title, err)
+			return err
+		}
+		changes := models.Diff(models.AuditEntity(models.AuditEntityBook, book.Id), &before, updated)
+		return s.Auditor.Record(ctx, models.AuditActionLoanTypeUpdated, models.AuditEntityBook, book.Id, changes)
+	}, nil); err != nil {
+		return nil, err
+	}
+	return s.GetBookByTitle(ctx, title)
+}
+
+// UpdateIsbn sets the ISBN of a book
+func (s *BookService) UpdateIsbn(ctx context.Context, title string, request *models.IsbnRequest) (*models.BookDetail, error) {
+	if err := auth.Authorize(ctx, models.PermissionCatalogManage); err != nil {
+		return nil, err
+	}
+	if err := db_manager.WrapInTransaction(ctx, s.TxDB, func(ctx context.Context) error {
+		book, err := s.bookRepository.GetBook(ctx, title)
+		if err != nil {
+			return err
+		}
+		before := *book
+		updated, err := s.bookRepository.UpdateBookIsbn(ctx, title, request.Isbn)
+		if err != nil {
+			log.Printf("error updating ISBN of book '%s' from repository: %v", title, err)
+			return err
+		}
+		changes := models.Diff(models.AuditEntity(models.AuditEntityBook, book.Id), &before, updated)
+		return s.Auditor.Record(ctx, models.AuditActionIsbnUpdated, models.AuditEntityBook, book.Id, changes)
+	}, nil); err != nil {
+		return nil, err
+	}
+	return s.GetBookByTitle(ctx, title)
+}
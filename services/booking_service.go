@@ -0,0 +1,255 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aftaab60/e-library-api/internal/auth"
+	"github.com/aftaab60/e-library-api/internal/clock"
+	"github.com/aftaab60/e-library-api/internal/db_manager"
+	"github.com/aftaab60/e-library-api/internal/tenant"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"log"
+	"time"
+)
+
+type BookingService struct {
+	BookingRepository repositories.IBookingRepository
+	BookRepository    repositories.IBookRepository
+	BranchRepository  repositories.IBranchRepository
+	//LoanRepository tells when the copies on loan are due back
+	LoanRepository repositories.ILoanRepository
+	TxDB           db_manager.ItxDB
+	//Auditor records bookings made and cancelled, nil records nothing
+	Auditor *AuditService
+	//Clock tells which day is today, the wall clock by default
+	Clock clock.Clock
+}
+
+// NewBookingService uses interface so that we can switch between in-memory and actual pgsql repo data easily
+func NewBookingService(bookingRepository repositories.IBookingRepository, bookRepository repositories.IBookRepository,
+	branchRepository repositories.IBranchRepository, loanRepository repositories.ILoanRepository) *BookingService {
+	return &BookingService{
+		BookingRepository: bookingRepository,
+		BookRepository:    bookRepository,
+		BranchRepository:  branchRepository,
+		LoanRepository:    loanRepository,
+		Clock:             clock.System{},
+	}
+}
+
+// ErrBookingConflict is returned when the copies of a branch can't cover a booking, or a loan would keep a copy
+// a booking holds
+var ErrBookingConflict = errors.New("copies are booked")
+
+var ErrBookingNotActive = errors.New("booking is not active")
+
+// ErrBookingNotOpen is returned when lending against a booking outside of its days
+var ErrBookingNotOpen = errors.New("booking is not open")
+
+// lastDay is after the last day of any booking
+const lastDay = "9999-12-31"
+
+// CreateBooking holds copies of a book at a branch for a range of days, as long as the copies of the branch cover it
+// along with the other bookings
+func (s *BookingService) CreateBooking(ctx context.Context, request *models.BookingRequest) (*models.Booking, error) {
+	if err := auth.AuthorizeBorrower(ctx, request.BorrowerName); err != nil {
+		return nil, err
+	}
+	if request.BranchId == 0 {
+		request.BranchId = tenant.DefaultBranchId(ctx)
+	}
+	book, err := s.BookRepository.GetBook(ctx, request.Title)
+	if err != nil {
+		log.Printf("error getting book: %v", err)
+		return nil, err
+	}
+	if err := lendable(book); err != nil {
+		return nil, err
+	}
+
+	booking := &models.Booking{
+		BookId:       book.Id,
+		BranchId:     request.BranchId,
+		BorrowerName: request.BorrowerName,
+		StartsOn:     request.StartsOn,
+		EndsOn:       request.EndsOn,
+		Copies:       request.Copies,
+		Status:       models.BookingStatusActive,
+		CreatedAt:    s.Clock.Now().UTC(),
+	}
+	if err = db_manager.WrapInTransaction(ctx, s.TxDB, func(ctx context.Context) error {
+		plan, _, err := s.copyPlan(ctx, book.Id, request.BranchId)
+		if err != nil {
+			return err
+		}
+		plan.Bookings = append(plan.Bookings, *booking)
+		if shortfall := plan.Shortfall(func(b *models.Booking) bool {
+			return b.Overlaps(booking.StartsOn, booking.EndsOn)
+		}); shortfall != nil {
+			return fmt.Errorf("%w: branch %d has too few copies of %s from %s to %s", ErrBookingConflict, booking.BranchId,
+				book.Title, booking.StartsOn, booking.EndsOn)
+		}
+		if booking, err = s.BookingRepository.CreateBooking(ctx, booking); err != nil {
+			log.Printf("error creating booking from repository: %v", err)
+			return err
+		}
+		changes := models.Diff(models.AuditEntity(models.AuditEntityBooking, booking.Id), nil, booking)
+		return s.Auditor.Record(ctx, models.AuditActionBookingCreated, models.AuditEntityBooking, booking.Id, changes)
+	}, nil); err != nil {
+		return nil, err
+	}
+	return booking, nil
+}
+
+func (s *BookingService) GetBookingById(ctx context.Context, id int) (*models.Booking, error) {
+	booking, err := s.BookingRepository.GetBookingById(ctx, id)
+	if err != nil {
+		if !errors.Is(err, repositories.ErrBookingNotFound) {
+			log.Printf("error getting booking from repository: %v", err)
+		}
+		return nil, err
+	}
+	if err := auth.AuthorizeBorrower(ctx, booking.BorrowerName); err != nil {
+		return nil, err
+	}
+	return booking, nil
+}
+
+// CancelBooking releases the copies an active booking holds
+func (s *BookingService) CancelBooking(ctx context.Context, id int) (*models.Booking, error) {
+	booking, err := s.GetBookingById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if booking.Status != models.BookingStatusActive {
+		return nil, ErrBookingNotActive
+	}
+	var cancelled *models.Booking
+	if err = db_manager.WrapInTransaction(ctx, s.TxDB, func(ctx context.Context) error {
+		update := *booking
+		update.Status = models.BookingStatusCancelled
+		if cancelled, err = s.BookingRepository.UpdateBooking(ctx, &update); err != nil {
+			log.Printf("error updating booking %d from repository: %v", id, err)
+			return err
+		}
+		changes := models.Diff(models.AuditEntity(models.AuditEntityBooking, id), booking, cancelled)
+		return s.Auditor.Record(ctx, models.AuditActionBookingCancelled, models.AuditEntityBooking, id, changes)
+	}, nil); err != nil {
+		return nil, err
+	}
+	return cancelled, nil
+}
+
+// GetBookingCalendar returns the bookings of a book over the days of the filter, and the copies booked each day.
+// Principals that may not act on any loan don't see who made the bookings of others.
+func (s *BookingService) GetBookingCalendar(ctx context.Context, title string, filter *models.BookingFilter) (*models.BookingCalendar, error) {
+	book, err := s.BookRepository.GetBook(ctx, title)
+	if err != nil {
+		log.Printf("error getting book: %v", err)
+		return nil, err
+	}
+	bookings, err := s.BookingRepository.ListBookings(ctx, book.Id, filter.BranchId, filter.From, filter.To)
+	if err != nil {
+		log.Printf("error listing bookings from repository: %v", err)
+		return nil, err
+	}
+
+	principal := auth.FromContext(ctx)
+	calendar := &models.BookingCalendar{BookId: book.Id, Title: book.Title, Bookings: make([]models.Booking, 0), Days: make([]models.BookingDay, 0)}
+	for _, booking := range bookings {
+		if booking.Status == models.BookingStatusCancelled {
+			continue
+		}
+		if principal != nil && !principal.Can(models.PermissionLoanAny) && principal.Name != booking.BorrowerName {
+			booking.BorrowerName = ""
+		}
+		calendar.Bookings = append(calendar.Bookings, booking)
+	}
+	from, _ := time.Parse(models.DateLayout, filter.From)
+	to, _ := time.Parse(models.DateLayout, filter.To)
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		date := day.Format(models.DateLayout)
+		booked := 0
+		for _, booking := range calendar.Bookings {
+			if booking.Overlaps(date, date) {
+				booked += booking.Copies
+			}
+		}
+		calendar.Days = append(calendar.Days, models.BookingDay{Date: date, BookedCopies: booked})
+	}
+	return calendar, nil
+}
+
+// CheckLoan refuses with ErrBookingConflict to keep a copy of a branch out until the day of dueDate, when the copy is
+// needed to cover a booking. loanId is the loan being extended, 0 lends a copy from the shelf. A nil service has no bookings.
+func (s *BookingService) CheckLoan(ctx context.Context, bookId int, branchId int, loanId int, dueDate time.Time) error {
+	if s == nil {
+		return nil
+	}
+	plan, loc, err := s.copyPlan(ctx, bookId, branchId)
+	if err != nil {
+		return err
+	}
+	due := dueDate.In(loc).Format(models.DateLayout)
+	//a copy lent from the shelf is out from today, an extended one from the day it was due
+	from := ""
+	if loanId == 0 {
+		plan.Copies--
+		plan.DueDays = append(plan.DueDays, due)
+	} else {
+		loan, err := s.LoanRepository.GetLoanById(ctx, loanId)
+		if err != nil {
+			return err
+		}
+		from = loan.ReturnDate.In(loc).Format(models.DateLayout)
+		for i := range plan.DueDays {
+			if plan.DueDays[i] == from {
+				plan.DueDays[i] = due
+				break
+			}
+		}
+	}
+	if shortfall := plan.Shortfall(func(b *models.Booking) bool {
+		return b.StartsOn > from && b.StartsOn <= due
+	}); shortfall != nil {
+		return fmt.Errorf("%w: booking %d holds the copies from %s", ErrBookingConflict, shortfall.Id, shortfall.StartsOn)
+	}
+	return nil
+}
+
+// copyPlan gathers the copies of a book a branch holds, the days its copies on loan are due and the bookings not over
+// yet, along with the time zone of the branch
+func (s *BookingService) copyPlan(ctx context.Context, bookId int, branchId int) (*models.CopyPlan, *time.Location, error) {
+	branch, err := s.BranchRepository.GetBranch(ctx, branchId)
+	if err != nil {
+		return nil, nil, err
+	}
+	loc := branch.Location()
+	stock, err := s.BranchRepository.GetBranchStock(ctx, bookId, branchId)
+	if err != nil {
+		log.Printf("error getting branch stock: %v", err)
+		return nil, nil, err
+	}
+	loans, _, err := s.LoanRepository.ListLoans(ctx, &models.LoanFilter{BookId: bookId, Status: models.LoanStatusActive})
+	if err != nil {
+		log.Printf("error listing loans from repository: %v", err)
+		return nil, nil, err
+	}
+	today := s.Clock.Now().In(loc).Format(models.DateLayout)
+	bookings, err := s.BookingRepository.ListBookings(ctx, bookId, branchId, today, lastDay)
+	if err != nil {
+		log.Printf("error listing bookings from repository: %v", err)
+		return nil, nil, err
+	}
+
+	plan := &models.CopyPlan{Copies: stock.AvailableCopies + stock.InTransitCopies, DueDays: make([]string, 0), Bookings: bookings}
+	for _, loan := range loans {
+		//loans made before branches belong to the default branch
+		if loan.BranchId == branchId || (loan.BranchId == 0 && branchId == tenant.DefaultBranchId(ctx)) {
+			plan.DueDays = append(plan.DueDays, loan.ReturnDate.In(loc).Format(models.DateLayout))
+		}
+	}
+	return plan, loc, nil
+}
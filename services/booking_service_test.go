@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aftaab60/e-library-api/internal/auth"
+	"github.com/aftaab60/e-library-api/internal/clock"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBookingService_Bookings(t *testing.T) {
+	travelClock := clock.NewAdjustable(clock.System{})
+	travelClock.Freeze()
+	travelClock.Set(time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC))
+	loanRepo, bookRepo, branchRepo := repositories.NewLoanRepository(), repositories.NewBookRepository(), repositories.NewBranchRepository()
+	bookingService := NewBookingService(repositories.NewBookingRepository(), bookRepo, branchRepo, loanRepo)
+	bookingService.Clock = travelClock
+	loanService := NewLoanService(loanRepo, bookRepo, repositories.NewChargeRepository(), branchRepo, repositories.NewTransferRepository(), repositories.NewMemberRepository(), repositories.NewLoanEventRepository())
+	loanService.Clock = travelClock
+	loanService.Bookings = bookingService
+	ctx := context.Background()
+
+	book := func(title string, startsOn string, endsOn string, copies int) (*models.Booking, error) {
+		return bookingService.CreateBooking(ctx, &models.BookingRequest{Title: title, BorrowerName: "teacher", StartsOn: startsOn, EndsOn: endsOn, Copies: copies})
+	}
+
+	t.Run("Loans are not extended past the day a booking needs the copy", func(t *testing.T) {
+		//of 3 copies, 2 stay on the shelf and the loan is due on 31 March
+		_, err := loanService.BorrowBook(ctx, "book2", "user2")
+		assert.NoError(t, err)
+		_, err = book("book2", "2025-04-10", "2025-04-12", 3)
+		assert.NoError(t, err)
+
+		_, err = loanService.ExtendLoan(ctx, "book2", "user2")
+		assert.ErrorIs(t, err, ErrBookingConflict)
+	})
+
+	booking, err := book("book3", "2025-03-20", "2025-03-25", 1)
+	assert.NoError(t, err)
+
+	t.Run("Bookings can't hold more copies than the branch has", func(t *testing.T) {
+		_, err := book("book3", "2025-03-24", "2025-03-26", 1)
+		assert.ErrorIs(t, err, ErrBookingConflict)
+		_, err = book("book3", "2025-03-26", "2025-03-27", 1)
+		assert.NoError(t, err)
+	})
+
+	t.Run("A copy a booking holds is not lent to others", func(t *testing.T) {
+		_, err := loanService.BorrowBook(ctx, "book3", "user1")
+		assert.ErrorIs(t, err, ErrBookingConflict)
+		_, err = loanService.BorrowBooking(ctx, booking.Id, "student1")
+		assert.ErrorIs(t, err, ErrBookingNotOpen)
+	})
+
+	t.Run("The calendar counts the copies booked each day", func(t *testing.T) {
+		patron := auth.NewContext(ctx, &models.Principal{Type: models.PrincipalTypePatron, Role: models.RolePatron, Id: 1, Name: "user1"})
+		calendar, err := bookingService.GetBookingCalendar(patron, "book3", &models.BookingFilter{CalendarFilter: models.CalendarFilter{From: "2025-03-19", To: "2025-03-26"}})
+		assert.NoError(t, err)
+		assert.Len(t, calendar.Bookings, 2)
+		assert.Empty(t, calendar.Bookings[0].BorrowerName)
+		assert.Len(t, calendar.Days, 8)
+		assert.Equal(t, models.BookingDay{Date: "2025-03-19", BookedCopies: 0}, calendar.Days[0])
+		assert.Equal(t, models.BookingDay{Date: "2025-03-20", BookedCopies: 1}, calendar.Days[1])
+		assert.Equal(t, models.BookingDay{Date: "2025-03-26", BookedCopies: 1}, calendar.Days[7])
+	})
+
+	t.Run("Copies are lent against the booking once it starts, until its last day", func(t *testing.T) {
+		travelClock.Advance(17 * 24 * time.Hour)
+		loan, err := loanService.BorrowBooking(ctx, booking.Id, "student1")
+		assert.NoError(t, err)
+		assert.Equal(t, time.Date(2025, 3, 25, 23, 59, 59, 0, time.UTC), loan.ReturnDate)
+
+		fulfilled, err := bookingService.GetBookingById(ctx, booking.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, models.BookingStatusFulfilled, fulfilled.Status)
+		assert.Equal(t, 1, fulfilled.LentCopies)
+		_, err = bookingService.CancelBooking(ctx, booking.Id)
+		assert.ErrorIs(t, err, ErrBookingNotActive)
+	})
+
+	t.Run("Cancelled bookings release their copies", func(t *testing.T) {
+		cancelled, err := bookingService.CancelBooking(ctx, 3)
+		assert.NoError(t, err)
+		assert.Equal(t, models.BookingStatusCancelled, cancelled.Status)
+		_, err = book("book3", "2025-03-26", "2025-03-27", 1)
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,281 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/aftaab60/e-library-api/internal/auth"
+	"github.com/aftaab60/e-library-api/internal/clock"
+	"github.com/aftaab60/e-library-api/internal/db_manager"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"log"
+)
+
+type BranchService struct {
+	BookRepository     repositories.IBookRepository
+	BranchRepository   repositories.IBranchRepository
+	TransferRepository repositories.ITransferRepository
+	TxDB               db_manager.ItxDB
+	//Auditor records inventory changes, nil records nothing
+	Auditor *AuditService
+	//Clock tells the time of transfers, the wall clock by default
+	Clock clock.Clock
+}
+
+// NewBranchService uses interface so that we can switch between in-memory and actual pgsql repo data easily
+func NewBranchService(bookRepository repositories.IBookRepository, branchRepository repositories.IBranchRepository, transferRepository repositories.ITransferRepository) BranchService {
+	return BranchService{
+		BookRepository:     bookRepository,
+		BranchRepository:   branchRepository,
+		TransferRepository: transferRepository,
+		Clock:              clock.System{},
+	}
+}
+
+var ErrInvalidTransferStatus = errors.New("transfer is not in a valid status for this action")
+
+func (s *BranchService) ListBranches(ctx context.Context) ([]models.Branch, error) {
+	branches, err := s.BranchRepository.ListBranches(ctx)
+	if err != nil {
+		log.Printf("error getting branches from repository: %v", err)
+		return nil, err
+	}
+	return branches, nil
+}
+
+// CreateBranch opens a branch, loans of the branch are due at the end of a day in its time zone
+func (s *BranchService) CreateBranch(ctx context.Context, request *models.BranchRequest) (*models.Branch, error) {
+	if err := auth.Authorize(ctx, models.PermissionConfigManage); err != nil {
+		return nil, err
+	}
+	var branch *models.Branch
+	if err := db_manager.WrapInTransaction(ctx, s.TxDB, func(ctx context.Context) error {
+		var err error
+		if branch, err = s.BranchRepository.CreateBranch(ctx, &models.Branch{Name: request.Name, TimeZone: request.TimeZone}); err != nil {
+			if !errors.Is(err, repositories.ErrExistingBranch) {
+				log.Printf("error creating branch from repository: %v", err)
+			}
+			return err
+		}
+		changes := models.Diff(models.AuditEntity(models.AuditEntityBranch, branch.Id), nil, branch)
+		return s.Auditor.Record(ctx, models.AuditActionBranchCreated, models.AuditEntityBranch, branch.Id, changes)
+	}, nil); err != nil {
+		return nil, err
+	}
+	return branch, nil
+}
+
+// UpdateBranch renames a branch and sets its time zone. Loans already made keep their due date, later loans, extensions
+// and the branch calendar follow the new time zone.
+func (s *BranchService) UpdateBranch(ctx context.Context, id int, request *models.BranchRequest) (*models.Branch, error) {
+	if err := auth.Authorize(ctx, models.PermissionConfigManage); err != nil {
+		return nil, err
+	}
+	var branch *models.Branch
+	if err := db_manager.WrapInTransaction(ctx, s.TxDB, func(ctx context.Context) error {
+		before, err := s.BranchRepository.GetBranch(ctx, id)
+		if err != nil {
+			return err
+		}
+		if branch, err = s.BranchRepository.UpdateBranch(ctx, &models.Branch{Id: id, Name: request.Name, TimeZone: request.TimeZone}); err != nil {
+			if !errors.Is(err, repositories.ErrExistingBranch) {
+				log.Printf("error updating branch %d from repository: %v", id, err)
+			}
+			return err
+		}
+		changes := models.Diff(models.AuditEntity(models.AuditEntityBranch, id), before, branch)
+		return s.Auditor.Record(ctx, models.AuditActionBranchUpdated, models.AuditEntityBranch, id, changes)
+	}, nil); err != nil {
+		return nil, err
+	}
+	return branch, nil
+}
+
+func (s *BranchService) ListTransfers(ctx context.Context, status models.TransferStatus) ([]models.Transfer, error) {
+	if err := auth.Authorize(ctx, models.PermissionInventoryManage); err != nil {
+		return nil, err
+	}
+	transfers, err := s.TransferRepository.ListTransfers(ctx, status)
+	if err != nil {
+		log.Printf("error getting transfers from repository: %v", err)
+		return nil, err
+	}
+	return transfers, nil
+}
+
+// RequestTransfer asks the source branch to send one copy of a book to the destination branch
+func (s *BranchService) RequestTransfer(ctx context.Context, request *models.TransferRequest) (*models.Transfer, error) {
+	if err := auth.Authorize(ctx, models.PermissionInventoryManage); err != nil {
+		return nil, err
+	}
+	book, err := s.BookRepository.GetBook(ctx, request.Title)
+	if err != nil {
+		log.Printf("error getting book: %v", err)
+		return nil, err
+	}
+	for _, branchId := range []int{request.FromBranchId, request.ToBranchId} {
+		if _, err := s.BranchRepository.GetBranch(ctx, branchId); err != nil {
+			log.Printf("error getting branch %d: %v", branchId, err)
+			return nil, err
+		}
+	}
+
+	t := s.Clock.Now()
+	var transfer *models.Transfer
+	if err = db_manager.WrapInTransaction(ctx, s.TxDB, func(ctx context.Context) error {
+		transfer, err = s.TransferRepository.CreateTransfer(ctx, &models.Transfer{
+			BookId:       book.Id,
+			FromBranchId: request.FromBranchId,
+			ToBranchId:   request.ToBranchId,
+			Status:       models.TransferStatusRequested,
+			CreatedAt:    t,
+			UpdatedAt:    t,
+		})
+		if err != nil {
+			log.Printf("error creating transfer from repository: %v", err)
+			return err
+		}
+		changes := models.Diff(models.AuditEntity(models.AuditEntityTransfer, transfer.Id), nil, transfer)
+		return s.Auditor.Record(ctx, models.AuditActionTransferRequested, models.AuditEntityTransfer, transfer.Id, changes)
+	}, nil); err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// DispatchTransfer takes a copy off the source branch shelf and puts it in transit to the destination branch
+func (s *BranchService) DispatchTransfer(ctx context.Context, id int) (*models.Transfer, error) {
+	if err := auth.Authorize(ctx, models.PermissionInventoryManage); err != nil {
+		return nil, err
+	}
+	transfer, book, err := s.getTransferAndBook(ctx, id, models.TransferStatusRequested)
+	if err != nil {
+		return nil, err
+	}
+	source, err := s.BranchRepository.GetBranchStock(ctx, transfer.BookId, transfer.FromBranchId)
+	if err != nil {
+		return nil, err
+	}
+	if source.AvailableCopies == 0 || book.AvailableCopies == 0 {
+		return nil, ErrNoAvailableCopiesFound
+	}
+	destination, err := s.BranchRepository.GetBranchStock(ctx, transfer.BookId, transfer.ToBranchId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = db_manager.WrapInTransaction(ctx, s.TxDB, func(ctx context.Context) error {
+		transferBefore, bookBefore, sourceBefore, destinationBefore := *transfer, *book, *source, *destination
+		source.AvailableCopies--
+		destination.InTransitCopies++
+		if _, err = s.BranchRepository.UpdateBranchStock(ctx, source); err != nil {
+			return err
+		}
+		if _, err = s.BranchRepository.UpdateBranchStock(ctx, destination); err != nil {
+			return err
+		}
+		updatedBook, err := s.BookRepository.UpdateBook(ctx, book.Title, book.AvailableCopies-1)
+		if err != nil {
+			return err
+		}
+		if transfer, err = s.TransferRepository.UpdateTransferStatus(ctx, id, models.TransferStatusInTransit, s.Clock.Now().UTC()); err != nil {
+			return err
+		}
+
+		changes := models.Diff(models.AuditEntity(models.AuditEntityTransfer, id), &transferBefore, transfer)
+		changes = append(changes, models.Diff(models.AuditEntity(models.AuditEntityBranchStock, source.BranchId, source.BookId), &sourceBefore, source)...)
+		changes = append(changes, models.Diff(models.AuditEntity(models.AuditEntityBranchStock, destination.BranchId, destination.BookId), &destinationBefore, destination)...)
+		changes = append(changes, models.Diff(models.AuditEntity(models.AuditEntityBook, book.Id), &bookBefore, updatedBook)...)
+		return s.Auditor.Record(ctx, models.AuditActionTransferDispatched, models.AuditEntityTransfer, id, changes)
+	}, nil); err != nil {
+		log.Printf("error running transfer dispatch transaction: %v", err)
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// ReceiveTransfer shelves an in-transit copy at the destination branch, making it available again
+func (s *BranchService) ReceiveTransfer(ctx context.Context, id int) (*models.Transfer, error) {
+	if err := auth.Authorize(ctx, models.PermissionInventoryManage); err != nil {
+		return nil, err
+	}
+	transfer, book, err := s.getTransferAndBook(ctx, id, models.TransferStatusInTransit)
+	if err != nil {
+		return nil, err
+	}
+	destination, err := s.BranchRepository.GetBranchStock(ctx, transfer.BookId, transfer.ToBranchId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = db_manager.WrapInTransaction(ctx, s.TxDB, func(ctx context.Context) error {
+		transferBefore, bookBefore, destinationBefore := *transfer, *book, *destination
+		if destination.InTransitCopies > 0 {
+			destination.InTransitCopies--
+		}
+		destination.AvailableCopies++
+		if _, err = s.BranchRepository.UpdateBranchStock(ctx, destination); err != nil {
+			return err
+		}
+		updatedBook, err := s.BookRepository.UpdateBook(ctx, book.Title, book.AvailableCopies+1)
+		if err != nil {
+			return err
+		}
+		if transfer, err = s.TransferRepository.UpdateTransferStatus(ctx, id, models.TransferStatusReceived, s.Clock.Now().UTC()); err != nil {
+			return err
+		}
+
+		changes := models.Diff(models.AuditEntity(models.AuditEntityTransfer, id), &transferBefore, transfer)
+		changes = append(changes, models.Diff(models.AuditEntity(models.AuditEntityBranchStock, destination.BranchId, destination.BookId), &destinationBefore, destination)...)
+		changes = append(changes, models.Diff(models.AuditEntity(models.AuditEntityBook, book.Id), &bookBefore, updatedBook)...)
+		return s.Auditor.Record(ctx, models.AuditActionTransferReceived, models.AuditEntityTransfer, id, changes)
+	}, nil); err != nil {
+		log.Printf("error running transfer receive transaction: %v", err)
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// CancelTransfer drops a transfer request that has not been dispatched yet
+func (s *BranchService) CancelTransfer(ctx context.Context, id int) (*models.Transfer, error) {
+	if err := auth.Authorize(ctx, models.PermissionInventoryManage); err != nil {
+		return nil, err
+	}
+	transfer, _, err := s.getTransferAndBook(ctx, id, models.TransferStatusRequested)
+	if err != nil {
+		return nil, err
+	}
+	transferBefore := *transfer
+	if err = db_manager.WrapInTransaction(ctx, s.TxDB, func(ctx context.Context) error {
+		if transfer, err = s.TransferRepository.UpdateTransferStatus(ctx, id, models.TransferStatusCancelled, s.Clock.Now().UTC()); err != nil {
+			log.Printf("error updating transfer from repository: %v", err)
+			return err
+		}
+		changes := models.Diff(models.AuditEntity(models.AuditEntityTransfer, id), &transferBefore, transfer)
+		return s.Auditor.Record(ctx, models.AuditActionTransferCancelled, models.AuditEntityTransfer, id, changes)
+	}, nil); err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+func (s *BranchService) getTransferAndBook(ctx context.Context, id int, expectedStatus models.TransferStatus) (*models.Transfer, *models.Book, error) {
+	transfer, err := s.TransferRepository.GetTransfer(ctx, id)
+	if err != nil {
+		if errors.Is(err, repositories.ErrTransferNotFound) {
+			log.Printf("transfer '%d' not found", id)
+		} else {
+			log.Printf("error getting transfer from repository: %v", err)
+		}
+		return nil, nil, err
+	}
+	if transfer.Status != expectedStatus {
+		return nil, nil, ErrInvalidTransferStatus
+	}
+	book, err := s.BookRepository.GetBookById(ctx, transfer.BookId)
+	if err != nil {
+		log.Printf("error getting book: %v", err)
+		return nil, nil, err
+	}
+	return transfer, book, nil
+}
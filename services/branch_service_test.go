@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBranchService_TransferWorkflow(t *testing.T) {
+	bookRepo := repositories.NewBookRepository()
+	branchRepo := repositories.NewBranchRepository()
+	branchService := NewBranchService(bookRepo, branchRepo, repositories.NewTransferRepository())
+	ctx := context.Background()
+
+	transfer, err := branchService.RequestTransfer(ctx, &models.TransferRequest{Title: "book2", FromBranchId: 1, ToBranchId: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, models.TransferStatusRequested, transfer.Status)
+
+	t.Run("Fail to receive a transfer not dispatched", func(t *testing.T) {
+		_, err := branchService.ReceiveTransfer(ctx, transfer.Id)
+		assert.Equal(t, ErrInvalidTransferStatus, err)
+	})
+
+	t.Run("Successfully dispatch a transfer", func(t *testing.T) {
+		dispatched, err := branchService.DispatchTransfer(ctx, transfer.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, models.TransferStatusInTransit, dispatched.Status)
+
+		book, _ := bookRepo.GetBook(ctx, "book2")
+		assert.Equal(t, 2, book.AvailableCopies)
+		destination, _ := branchRepo.GetBranchStock(ctx, book.Id, 2)
+		assert.Equal(t, 1, destination.InTransitCopies)
+	})
+
+	t.Run("Successfully receive a transfer", func(t *testing.T) {
+		received, err := branchService.ReceiveTransfer(ctx, transfer.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, models.TransferStatusReceived, received.Status)
+
+		book, _ := bookRepo.GetBook(ctx, "book2")
+		assert.Equal(t, 3, book.AvailableCopies)
+		source, _ := branchRepo.GetBranchStock(ctx, book.Id, 1)
+		assert.Equal(t, 2, source.AvailableCopies)
+		destination, _ := branchRepo.GetBranchStock(ctx, book.Id, 2)
+		assert.Equal(t, 1, destination.AvailableCopies)
+		assert.Equal(t, 0, destination.InTransitCopies)
+	})
+
+	t.Run("Fail to dispatch without available copies", func(t *testing.T) {
+		request, err := branchService.RequestTransfer(ctx, &models.TransferRequest{Title: "book4", FromBranchId: 1, ToBranchId: 2})
+		assert.NoError(t, err)
+		_, err = branchService.DispatchTransfer(ctx, request.Id)
+		assert.Equal(t, ErrNoAvailableCopiesFound, err)
+	})
+
+	t.Run("Fail to request a transfer to an unknown branch", func(t *testing.T) {
+		_, err := branchService.RequestTransfer(ctx, &models.TransferRequest{Title: "book2", FromBranchId: 1, ToBranchId: 20})
+		assert.Equal(t, repositories.ErrBranchNotFound, err)
+	})
+}
+
+func TestBranchService_UpdateBranch(t *testing.T) {
+	branchRepo := repositories.NewBranchRepository()
+	branchService := NewBranchService(repositories.NewBookRepository(), branchRepo, repositories.NewTransferRepository())
+	ctx := context.Background()
+
+	t.Run("Successfully create a branch", func(t *testing.T) {
+		branch, err := branchService.CreateBranch(ctx, &models.BranchRequest{Name: "west", TimeZone: "America/New_York"})
+		assert.NoError(t, err)
+		assert.Equal(t, "America/New_York", branch.Location().String())
+	})
+
+	t.Run("Fail to create a branch with an existing name", func(t *testing.T) {
+		_, err := branchService.CreateBranch(ctx, &models.BranchRequest{Name: "east", TimeZone: "UTC"})
+		assert.Equal(t, repositories.ErrExistingBranch, err)
+	})
+
+	t.Run("Successfully move a branch to another time zone", func(t *testing.T) {
+		branch, err := branchService.UpdateBranch(ctx, 2, &models.BranchRequest{Name: "east", TimeZone: "Asia/Tokyo"})
+		assert.NoError(t, err)
+		assert.Equal(t, "Asia/Tokyo", branch.TimeZone)
+
+		stored, _ := branchRepo.GetBranch(ctx, 2)
+		assert.Equal(t, "Asia/Tokyo", stored.Location().String())
+	})
+
+	t.Run("Fail to update an unknown branch", func(t *testing.T) {
+		_, err := branchService.UpdateBranch(ctx, 20, &models.BranchRequest{Name: "north", TimeZone: "UTC"})
+		assert.Equal(t, repositories.ErrBranchNotFound, err)
+	})
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"github.com/aftaab60/e-library-api/internal/auth"
+	"github.com/aftaab60/e-library-api/internal/clock"
+	"github.com/aftaab60/e-library-api/internal/db_manager"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"log"
+	"time"
+)
+
+type CalendarService struct {
+	CalendarRepository repositories.ICalendarRepository
+	BranchRepository   repositories.IBranchRepository
+	TxDB               db_manager.ItxDB
+	//Auditor records calendar changes, nil records nothing
+	Auditor *AuditService
+	//Clock tells which day is today, the wall clock by default
+	Clock clock.Clock
+}
+
+// NewCalendarService uses interface so that we can switch between in-memory and actual pgsql repo data easily
+func NewCalendarService(calendarRepository repositories.ICalendarRepository, branchRepository repositories.IBranchRepository) *CalendarService {
+	return &CalendarService{
+		CalendarRepository: calendarRepository,
+		BranchRepository:   branchRepository,
+		Clock:              clock.System{},
+	}
+}
+
+// GetCalendar returns the opening hours and closures of a branch, along with whether it is open on each day of the filter
+func (s *CalendarService) GetCalendar(ctx context.Context, branchId int, filter *models.CalendarFilter) (*models.BranchCalendar, error) {
+	calendar, err := s.branchCalendar(ctx, branchId)
+	if err != nil {
+		return nil, err
+	}
+	//days are those of the branch, noon keeps them clear of daylight saving changes
+	from, _ := time.ParseInLocation(models.DateLayout, filter.From, calendar.Location())
+	to, _ := time.ParseInLocation(models.DateLayout, filter.To, calendar.Location())
+	from, to = from.Add(12*time.Hour), to.Add(12*time.Hour)
+	calendar.Days = make([]models.CalendarDay, 0)
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		calendar.Days = append(calendar.Days, calendar.Day(day))
+	}
+	return calendar, nil
+}
+
+// branchCalendar fails with ErrBranchNotFound for an unknown branch
+func (s *CalendarService) branchCalendar(ctx context.Context, branchId int) (*models.BranchCalendar, error) {
+	branch, err := s.BranchRepository.GetBranch(ctx, branchId)
+	if err != nil {
+		return nil, err
+	}
+	hours, err := s.CalendarRepository.ListOpeningHours(ctx, branchId)
+	if err != nil {
+		log.Printf("error getting opening hours of branch %d from repository: %v", branchId, err)
+		return nil, err
+	}
+	closures, err := s.CalendarRepository.ListClosures(ctx, branchId)
+	if err != nil {
+		log.Printf("error getting closures of branch %d from repository: %v", branchId, err)
+		return nil, err
+	}
+	return &models.BranchCalendar{BranchId: branchId, TimeZone: branch.Location().String(), Hours: hours, Closures: closures}, nil
+}
+
+// SetOpeningHours replaces the opening hours of a branch
+func (s *CalendarService) SetOpeningHours(ctx context.Context, branchId int, request *models.OpeningHoursRequest) (*models.BranchCalendar, error) {
+	if err := auth.Authorize(ctx, models.PermissionConfigManage); err != nil {
+		return nil, err
+	}
+	before, err := s.branchCalendar(ctx, branchId)
+	if err != nil {
+		return nil, err
+	}
+	if err = db_manager.WrapInTransaction(ctx, s.TxDB, func(ctx context.Context) error {
+		if err := s.CalendarRepository.ReplaceOpeningHours(ctx, branchId, request.Hours); err != nil {
+			log.Printf("error replacing opening hours of branch %d from repository: %v", branchId, err)
+			return err
+		}
+		changes := models.Diff(models.AuditEntity(models.AuditEntityBranch, branchId),
+			&models.BranchCalendar{Hours: before.Hours}, &models.BranchCalendar{Hours: request.Hours})
+		return s.Auditor.Record(ctx, models.AuditActionHoursUpdated, models.AuditEntityBranch, branchId, changes)
+	}, nil); err != nil {
+		return nil, err
+	}
+	return s.branchCalendar(ctx, branchId)
+}
+
+// CreateClosure closes a branch, or every branch, on a day
+func (s *CalendarService) CreateClosure(ctx context.Context, request *models.ClosureRequest) (*models.Closure, error) {
+	if err := auth.Authorize(ctx, models.PermissionConfigManage); err != nil {
+		return nil, err
+	}
+	if request.BranchId != 0 {
+		if _, err := s.BranchRepository.GetBranch(ctx, request.BranchId); err != nil {
+			return nil, err
+		}
+	}
+	var closure *models.Closure
+	if err := db_manager.WrapInTransaction(ctx, s.TxDB, func(ctx context.Context) error {
+		var err error
+		closure, err = s.CalendarRepository.CreateClosure(ctx, &models.Closure{
+			BranchId:  request.BranchId,
+			Date:      request.Date,
+			Name:      request.Name,
+			Recurring: request.Recurring,
+		})
+		if err != nil {
+			log.Printf("error creating closure from repository: %v", err)
+			return err
+		}
+		changes := models.Diff(models.AuditEntity(models.AuditEntityClosure, closure.Id), nil, closure)
+		return s.Auditor.Record(ctx, models.AuditActionClosureCreated, models.AuditEntityClosure, closure.Id, changes)
+	}, nil); err != nil {
+		return nil, err
+	}
+	return closure, nil
+}
+
+// DeleteClosure reopens the day of a closure
+func (s *CalendarService) DeleteClosure(ctx context.Context, id int) error {
+	if err := auth.Authorize(ctx, models.PermissionConfigManage); err != nil {
+		return err
+	}
+	closure, err := s.CalendarRepository.GetClosureById(ctx, id)
+	if err != nil {
+		return err
+	}
+	return db_manager.WrapInTransaction(ctx, s.TxDB, func(ctx context.Context) error {
+		if err := s.CalendarRepository.DeleteClosure(ctx, id); err != nil {
+			log.Printf("error deleting closure %d from repository: %v", id, err)
+			return err
+		}
+		changes := models.Diff(models.AuditEntity(models.AuditEntityClosure, id), closure, nil)
+		return s.Auditor.Record(ctx, models.AuditActionClosureDeleted, models.AuditEntityClosure, id, changes)
+	}, nil)
+}
+
+// DueDate moves a due date at a branch forward to the next day the branch is open, in the time zone of the branch.
+// A nil service leaves it as is.
+func (s *CalendarService) DueDate(ctx context.Context, branchId int, dueDate time.Time) (time.Time, error) {
+	if s == nil {
+		return dueDate, nil
+	}
+	calendar, err := s.branchCalendar(ctx, branchId)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return calendar.NextOpenDay(dueDate), nil
+}
+
+// DueTime moves a due time at a branch forward to the next time the branch opens, when it is closed at the time.
+// A nil service leaves it as is.
+func (s *CalendarService) DueTime(ctx context.Context, branchId int, dueTime time.Time) (time.Time, error) {
+	if s == nil {
+		return dueTime, nil
+	}
+	calendar, err := s.branchCalendar(ctx, branchId)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return calendar.NextOpenTime(dueTime), nil
+}
+
+// OpenDaysBetween counts the days a branch is open after the day of from, up to and including the day of to.
+// A nil service counts every day.
+func (s *CalendarService) OpenDaysBetween(ctx context.Context, branchId int, from time.Time, to time.Time) (int, error) {
+	calendar := &models.BranchCalendar{BranchId: branchId}
+	if s != nil {
+		var err error
+		if calendar, err = s.branchCalendar(ctx, branchId); err != nil {
+			return 0, err
+		}
+	}
+	return calendar.OpenDaysBetween(from, to), nil
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aftaab60/e-library-api/internal/auth"
+	"github.com/aftaab60/e-library-api/internal/tenant"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalendarService_GetCalendar(t *testing.T) {
+	calendarService := NewCalendarService(repositories.NewCalendarRepository(), repositories.NewBranchRepository())
+	ctx := context.Background()
+
+	_, err := calendarService.SetOpeningHours(ctx, 1, &models.OpeningHoursRequest{Hours: []models.OpeningHours{
+		{Weekday: "monday", Opens: "09:00", Closes: "17:00"},
+		{Weekday: "tuesday", Opens: "09:00", Closes: "17:00"},
+	}})
+	assert.NoError(t, err)
+	_, err = calendarService.CreateClosure(ctx, &models.ClosureRequest{Date: "2024-01-01", Name: "New Year's Day", Recurring: true})
+	assert.NoError(t, err)
+
+	t.Run("Days follow opening hours and closures", func(t *testing.T) {
+		//1 January 2029 is a Monday
+		calendar, err := calendarService.GetCalendar(ctx, 1, &models.CalendarFilter{From: "2029-01-01", To: "2029-01-03"})
+		assert.NoError(t, err)
+		assert.Equal(t, []models.CalendarDay{
+			{Date: "2029-01-01", Open: false, Reason: "New Year's Day"},
+			{Date: "2029-01-02", Open: true, Opens: "09:00", Closes: "17:00"},
+			{Date: "2029-01-03", Open: false, Reason: "closed"},
+		}, calendar.Days)
+	})
+
+	t.Run("Branch without opening hours is open every day", func(t *testing.T) {
+		calendar, err := calendarService.GetCalendar(ctx, 2, &models.CalendarFilter{From: "2029-01-01", To: "2029-01-02"})
+		assert.NoError(t, err)
+		assert.False(t, calendar.Days[0].Open)
+		assert.True(t, calendar.Days[1].Open)
+	})
+
+	t.Run("Fail on unknown branch", func(t *testing.T) {
+		_, err := calendarService.GetCalendar(ctx, 100, &models.CalendarFilter{From: "2029-01-01", To: "2029-01-02"})
+		assert.Equal(t, repositories.ErrBranchNotFound, err)
+	})
+
+	t.Run("Patron can't change the calendar", func(t *testing.T) {
+		patron := auth.NewContext(ctx, &models.Principal{Type: models.PrincipalTypePatron, Role: models.RolePatron, Id: 1, Name: "user1"})
+		_, err := calendarService.CreateClosure(patron, &models.ClosureRequest{Date: "2029-01-02", Name: "Closed"})
+		assert.ErrorIs(t, err, auth.ErrForbidden)
+		assert.ErrorIs(t, calendarService.DeleteClosure(patron, 1), auth.ErrForbidden)
+	})
+}
+
+func TestLoanService_Calendar(t *testing.T) {
+	loanRepo := repositories.NewLoanRepository()
+	chargeRepo := repositories.NewChargeRepository()
+	calendarRepo := repositories.NewCalendarRepository()
+	loanService := NewLoanService(loanRepo, repositories.NewBookRepository(), chargeRepo, repositories.NewBranchRepository(), repositories.NewTransferRepository(), repositories.NewMemberRepository(), repositories.NewLoanEventRepository())
+	loanService.Calendar = NewCalendarService(calendarRepo, loanService.BranchRepository)
+	policy := models.DefaultLoanPolicy
+	policy.OverdueFine = 10
+	ctx := tenant.NewContext(context.Background(), &models.Tenant{Id: 1, Slug: "default", DefaultBranchId: 1, Policy: policy})
+	now := time.Now()
+
+	closed := func(day time.Time) {
+		_, err := calendarRepo.CreateClosure(ctx, &models.Closure{BranchId: 1, Date: day.Format(models.DateLayout), Name: "Closed"})
+		assert.NoError(t, err)
+	}
+
+	t.Run("Due date rolls forward to the next open day", func(t *testing.T) {
+		closed(now.AddDate(0, 0, policy.LoanPeriodDays))
+		closed(now.AddDate(0, 0, policy.LoanPeriodDays+1))
+
+		loan, err := loanService.BorrowBook(ctx, "book1", "borrower1")
+		assert.NoError(t, err)
+		assert.Equal(t, models.EndOfDay(loan.LoanDate.AddDate(0, 0, policy.LoanPeriodDays+2)).Unix(), loan.ReturnDate.Unix())
+	})
+
+	t.Run("Renewal due date rolls forward to the next open day", func(t *testing.T) {
+		loan, err := loanRepo.GetLoan(ctx, "book1", "borrower1")
+		assert.NoError(t, err)
+		closed(loan.ReturnDate.AddDate(0, 0, policy.ExtensionDays))
+
+		extended, err := loanService.ExtendLoanById(ctx, loan.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, models.EndOfDay(loan.ReturnDate.AddDate(0, 0, policy.ExtensionDays+1)).Unix(), extended.ReturnDate.Unix())
+	})
+
+	t.Run("Overdue fine only accrues on open days", func(t *testing.T) {
+		//due five days ago, the branch was closed on one of the days since
+		created, err := loanRepo.CreateLoan(ctx, "book3", &models.Loan{
+			BorrowerName: "borrower2",
+			LoanDate:     now.AddDate(0, 0, -33),
+			ReturnDate:   now.AddDate(0, 0, -5),
+			BookId:       3,
+			BranchId:     1,
+		})
+		assert.NoError(t, err)
+		closed(now.AddDate(0, 0, -2))
+
+		assert.NoError(t, loanService.ReturnBook(ctx, "book3", "borrower2"))
+		charges, err := chargeRepo.GetChargesByLoan(ctx, created.Id)
+		assert.NoError(t, err)
+		assert.Len(t, charges, 1)
+		assert.Equal(t, models.ChargeTypeOverdue, charges[0].Type)
+		assert.Equal(t, 4*policy.OverdueFine, charges[0].Amount)
+	})
+
+	t.Run("No fine for a loan returned on time", func(t *testing.T) {
+		loan, err := loanRepo.GetLoan(ctx, "book1", "borrower1")
+		assert.NoError(t, err)
+		assert.NoError(t, loanService.ReturnBook(ctx, "book1", "borrower1"))
+		charges, err := chargeRepo.GetChargesByLoan(ctx, loan.Id)
+		assert.NoError(t, err)
+		assert.Empty(t, charges)
+	})
+}
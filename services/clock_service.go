@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/aftaab60/e-library-api/internal/auth"
+	"github.com/aftaab60/e-library-api/internal/clock"
+	"github.com/aftaab60/e-library-api/internal/tenant"
+	"github.com/aftaab60/e-library-api/models"
+	"log"
+)
+
+// ClockService moves the clock the services read the time from, so that staff training and integration tests can
+// simulate weeks of circulation. The clock is shared by every tenant of the deployment, so only the admins of the
+// operator tenant, the one running the deployment, may move it.
+type ClockService struct {
+	Clock *clock.Adjustable
+	//OperatorTenant is the slug of the tenant whose admins may read and move the clock
+	OperatorTenant string
+	//Auditor records clock adjustments in the audit log of the operator tenant, nil records nothing
+	Auditor *AuditService
+}
+
+func NewClockService(c *clock.Adjustable, operatorTenant string) *ClockService {
+	return &ClockService{Clock: c, OperatorTenant: operatorTenant}
+}
+
+// authorize lets the admins of the operator tenant through, along with internal calls
+func (s *ClockService) authorize(ctx context.Context) error {
+	if err := auth.Authorize(ctx, models.PermissionConfigManage); err != nil {
+		return err
+	}
+	if auth.FromContext(ctx) == nil {
+		return nil
+	}
+	if t := tenant.FromContext(ctx); t == nil || t.Slug != s.OperatorTenant {
+		return &auth.ForbiddenError{Reason: fmt.Sprintf("the clock is shared by every tenant, only admins of %s may move it", s.OperatorTenant)}
+	}
+	return nil
+}
+
+func (s *ClockService) GetClock(ctx context.Context) (*clock.State, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	state := s.Clock.State()
+	return &state, nil
+}
+
+// AdjustClock sets, advances, freezes or unfreezes the clock as requested
+func (s *ClockService) AdjustClock(ctx context.Context, request *models.ClockRequest) (*clock.State, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	before := s.Clock.State()
+	//a clock frozen first stands exactly where it is set
+	if request.Frozen != nil && *request.Frozen {
+		s.Clock.Freeze()
+	}
+	if request.Now != nil {
+		s.Clock.Set(*request.Now)
+	}
+	if d := request.AdvanceDuration(); d != 0 {
+		s.Clock.Advance(d)
+	}
+	if request.Frozen != nil && !*request.Frozen {
+		s.Clock.Unfreeze()
+	}
+	return s.record(ctx, before)
+}
+
+// ResetClock puts the clock back to the wall clock
+func (s *ClockService) ResetClock(ctx context.Context) (*clock.State, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	before := s.Clock.State()
+	s.Clock.Reset()
+	return s.record(ctx, before)
+}
+
+func (s *ClockService) record(ctx context.Context, before clock.State) (*clock.State, error) {
+	state := s.Clock.State()
+	log.Printf("clock adjusted, now %s (offset %s, frozen %t)\n", state.Now, state.Offset, state.Frozen)
+	changes := models.Diff(models.AuditEntity(models.AuditEntityClock), &before, &state)
+	if err := s.Auditor.Record(ctx, models.AuditActionClockAdjusted, models.AuditEntityClock, 0, changes); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aftaab60/e-library-api/internal/auth"
+	"github.com/aftaab60/e-library-api/internal/clock"
+	"github.com/aftaab60/e-library-api/internal/tenant"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClockService_AdjustClock(t *testing.T) {
+	travelClock := clock.NewAdjustable(clock.System{})
+	clockService := NewClockService(travelClock, "default")
+	auditRepo := repositories.NewAuditRepository()
+	clockService.Auditor = NewAuditService(auditRepo)
+	ctx := context.Background()
+	frozen := true
+
+	t.Run("Advance and freeze the clock", func(t *testing.T) {
+		state, err := clockService.AdjustClock(ctx, &models.ClockRequest{Advance: "14d", Frozen: &frozen})
+		assert.NoError(t, err)
+		assert.Equal(t, "336h0m0s", state.Offset)
+		assert.True(t, state.Frozen)
+		assert.Equal(t, state.Now, travelClock.Now())
+
+		entries, total, err := auditRepo.ListAuditEntries(ctx, &models.AuditFilter{Limit: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, total)
+		assert.Equal(t, models.AuditActionClockAdjusted, entries[0].Action)
+	})
+
+	t.Run("Reset the clock", func(t *testing.T) {
+		state, err := clockService.ResetClock(ctx)
+		assert.NoError(t, err)
+		assert.False(t, state.Frozen)
+		assert.Equal(t, "0s", state.Offset)
+	})
+
+	t.Run("Admins of other tenants can't travel in time", func(t *testing.T) {
+		admin := auth.NewContext(ctx, &models.Principal{Type: models.PrincipalTypeApiKey, Role: models.RoleAdmin, Id: 1, Name: "tenant-key"})
+		_, err := clockService.AdjustClock(tenant.NewContext(admin, &models.Tenant{Id: 2, Slug: "other"}), &models.ClockRequest{Advance: "1d"})
+		assert.ErrorIs(t, err, auth.ErrForbidden)
+
+		_, err = clockService.GetClock(tenant.NewContext(admin, &models.Tenant{Id: 1, Slug: "default"}))
+		assert.NoError(t, err)
+	})
+
+	t.Run("Librarian can't travel in time", func(t *testing.T) {
+		librarian := auth.NewContext(ctx, &models.Principal{Type: models.PrincipalTypeApiKey, Role: models.RoleLibrarian, Id: 1, Name: "desk"})
+		_, err := clockService.AdjustClock(librarian, &models.ClockRequest{Advance: "1d"})
+		assert.ErrorIs(t, err, auth.ErrForbidden)
+	})
+}
+
+func TestLoanService_TimeTravel(t *testing.T) {
+	travelClock := clock.NewAdjustable(clock.System{})
+	chargeRepo := repositories.NewChargeRepository()
+	loanService := NewLoanService(repositories.NewLoanRepository(), repositories.NewBookRepository(), chargeRepo, repositories.NewBranchRepository(), repositories.NewTransferRepository(), repositories.NewMemberRepository(), repositories.NewLoanEventRepository())
+	loanService.Clock = travelClock
+	policy := models.DefaultLoanPolicy
+	policy.OverdueFine = 25
+	ctx := tenant.NewContext(context.Background(), &models.Tenant{Id: 1, Slug: "default", DefaultBranchId: 1, Policy: policy})
+	//noon keeps the simulated days clear of midnight
+	travelClock.Set(time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC))
+	travelClock.Freeze()
+
+	loan, err := loanService.BorrowBook(ctx, "book1", "borrower1")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 3, 31, 23, 59, 59, 0, time.UTC), loan.ReturnDate)
+
+	page, err := loanService.ListLoans(ctx, &models.LoanFilter{Overdue: true, Limit: 10})
+	assert.NoError(t, err)
+	assert.Zero(t, page.Total)
+
+	//two days past the due date
+	travelClock.Advance(30 * 24 * time.Hour)
+	page, err = loanService.ListLoans(ctx, &models.LoanFilter{Overdue: true, Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, page.Total)
+	assert.NoError(t, loanService.ReturnBook(ctx, "book1", "borrower1"))
+	charges, err := chargeRepo.GetChargesByLoan(ctx, 1)
+	assert.NoError(t, err)
+	assert.Len(t, charges, 1)
+	assert.Equal(t, 2*policy.OverdueFine, charges[0].Amount)
+	assert.Equal(t, travelClock.Now().UTC(), charges[0].CreatedAt)
+}
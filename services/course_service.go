@@ -0,0 +1,291 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aftaab60/e-library-api/internal/auth"
+	"github.com/aftaab60/e-library-api/internal/clock"
+	"github.com/aftaab60/e-library-api/internal/db_manager"
+	"github.com/aftaab60/e-library-api/internal/tenant"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"log"
+)
+
+type CourseService struct {
+	CourseRepository repositories.ICourseRepository
+	BookRepository   repositories.IBookRepository
+	//BranchRepository tells the time zone of the default branch, terms end at midnight in it
+	BranchRepository repositories.IBranchRepository
+	TxDB             db_manager.ItxDB
+	//Auditor records terms, courses and reserves, nil records nothing
+	Auditor *AuditService
+	//Clock tells which day is today, the wall clock by default
+	Clock clock.Clock
+}
+
+// NewCourseService uses interface so that we can switch between in-memory and actual pgsql repo data easily
+func NewCourseService(courseRepository repositories.ICourseRepository, bookRepository repositories.IBookRepository,
+	branchRepository repositories.IBranchRepository) *CourseService {
+	return &CourseService{
+		CourseRepository: courseRepository,
+		BookRepository:   bookRepository,
+		BranchRepository: branchRepository,
+		Clock:            clock.System{},
+	}
+}
+
+// ErrTermEnded is returned when putting a book on reserve for a course of a term that is over
+var ErrTermEnded = errors.New("term has ended")
+
+var ErrReserveNotActive = errors.New("reserve is not active")
+
+func (s *CourseService) CreateTerm(ctx context.Context, request *models.TermRequest) (*models.Term, error) {
+	if err := auth.Authorize(ctx, models.PermissionCourseManage); err != nil {
+		return nil, err
+	}
+	var term *models.Term
+	if err := db_manager.WrapInTransaction(ctx, s.TxDB, func(ctx context.Context) error {
+		var err error
+		if term, err = s.CourseRepository.CreateTerm(ctx, &models.Term{Name: request.Name, StartsOn: request.StartsOn, EndsOn: request.EndsOn}); err != nil {
+			log.Printf("error creating term from repository: %v", err)
+			return err
+		}
+		changes := models.Diff(models.AuditEntity(models.AuditEntityTerm, term.Id), nil, term)
+		return s.Auditor.Record(ctx, models.AuditActionTermCreated, models.AuditEntityTerm, term.Id, changes)
+	}, nil); err != nil {
+		return nil, err
+	}
+	return term, nil
+}
+
+func (s *CourseService) ListTerms(ctx context.Context) ([]models.Term, error) {
+	terms, err := s.CourseRepository.ListTerms(ctx)
+	if err != nil {
+		log.Printf("error listing terms from repository: %v", err)
+		return nil, err
+	}
+	return terms, nil
+}
+
+func (s *CourseService) CreateCourse(ctx context.Context, request *models.CourseRequest) (*models.Course, error) {
+	if err := auth.Authorize(ctx, models.PermissionCourseManage); err != nil {
+		return nil, err
+	}
+	if _, err := s.CourseRepository.GetTermById(ctx, request.TermId); err != nil {
+		return nil, err
+	}
+	var course *models.Course
+	if err := db_manager.WrapInTransaction(ctx, s.TxDB, func(ctx context.Context) error {
+		var err error
+		course, err = s.CourseRepository.CreateCourse(ctx, &models.Course{TermId: request.TermId, Code: request.Code,
+			Name: request.Name, Instructors: request.Instructors})
+		if err != nil {
+			if !errors.Is(err, repositories.ErrExistingCourse) {
+				log.Printf("error creating course from repository: %v", err)
+			}
+			return err
+		}
+		changes := models.Diff(models.AuditEntity(models.AuditEntityCourse, course.Id), nil, course)
+		return s.Auditor.Record(ctx, models.AuditActionCourseCreated, models.AuditEntityCourse, course.Id, changes)
+	}, nil); err != nil {
+		return nil, err
+	}
+	return course, nil
+}
+
+func (s *CourseService) GetCourseById(ctx context.Context, id int) (*models.Course, error) {
+	course, err := s.CourseRepository.GetCourseById(ctx, id)
+	if err != nil {
+		if !errors.Is(err, repositories.ErrCourseNotFound) {
+			log.Printf("error getting course from repository: %v", err)
+		}
+		return nil, err
+	}
+	return course, nil
+}
+
+func (s *CourseService) ListCourses(ctx context.Context, filter *models.CourseFilter) ([]models.Course, error) {
+	courses, err := s.CourseRepository.ListCourses(ctx, filter.TermId)
+	if err != nil {
+		log.Printf("error listing courses from repository: %v", err)
+		return nil, err
+	}
+	return courses, nil
+}
+
+// GetCourseReserves returns the reserve list of a course along with its term
+func (s *CourseService) GetCourseReserves(ctx context.Context, courseId int) (*models.CourseReserves, error) {
+	course, err := s.GetCourseById(ctx, courseId)
+	if err != nil {
+		return nil, err
+	}
+	term, err := s.CourseRepository.GetTermById(ctx, course.TermId)
+	if err != nil {
+		return nil, err
+	}
+	reserves, err := s.CourseRepository.ListReserves(ctx, courseId)
+	if err != nil {
+		log.Printf("error listing reserves of course %d from repository: %v", courseId, err)
+		return nil, err
+	}
+	return &models.CourseReserves{Course: *course, Term: *term, Reserves: reserves}, nil
+}
+
+// AddReserve puts a book on reserve for a course: copies of the book are lent with the loan type of the reserve until
+// the term of the course ends, or the reserve is ended sooner
+func (s *CourseService) AddReserve(ctx context.Context, courseId int, request *models.ReserveRequest) (*models.Reserve, error) {
+	if err := auth.Authorize(ctx, models.PermissionCourseManage); err != nil {
+		return nil, err
+	}
+	course, err := s.GetCourseById(ctx, courseId)
+	if err != nil {
+		return nil, err
+	}
+	term, err := s.CourseRepository.GetTermById(ctx, course.TermId)
+	if err != nil {
+		return nil, err
+	}
+	today, err := s.today(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if term.Ended(today) {
+		return nil, fmt.Errorf("%w: %s ended on %s", ErrTermEnded, term.Name, term.EndsOn)
+	}
+
+	var reserve *models.Reserve
+	if err = db_manager.WrapInTransaction(ctx, s.TxDB, func(ctx context.Context) error {
+		book, err := s.BookRepository.GetBook(ctx, request.Title)
+		if err != nil {
+			log.Printf("error getting book: %v", err)
+			return err
+		}
+		bookBefore := *book
+		reserve, err = s.CourseRepository.CreateReserve(ctx, &models.Reserve{
+			CourseId:          courseId,
+			BookId:            book.Id,
+			Title:             book.Title,
+			LoanType:          request.LoanType,
+			LoanHours:         request.LoanHours,
+			PreviousLoanType:  book.LoanType,
+			PreviousLoanHours: book.LoanHours,
+			Status:            models.ReserveStatusActive,
+			CreatedAt:         s.Clock.Now().UTC(),
+		})
+		if err != nil {
+			if !errors.Is(err, repositories.ErrExistingReserve) {
+				log.Printf("error creating reserve from repository: %v", err)
+			}
+			return err
+		}
+		updatedBook, err := s.BookRepository.UpdateBookLoanType(ctx, book.Title, request.LoanType, request.LoanHours)
+		if err != nil {
+			log.Printf("error updating loan type of book '%s' from repository: %v", book.Title, err)
+			return err
+		}
+		changes := models.Diff(models.AuditEntity(models.AuditEntityReserve, reserve.Id), nil, reserve)
+		changes = append(changes, models.Diff(models.AuditEntity(models.AuditEntityBook, book.Id), &bookBefore, updatedBook)...)
+		return s.Auditor.Record(ctx, models.AuditActionReserveAdded, models.AuditEntityReserve, reserve.Id, changes)
+	}, nil); err != nil {
+		return nil, err
+	}
+	return reserve, nil
+}
+
+// EndReserve takes a book off reserve before the term ends, giving it back its previous loan type
+func (s *CourseService) EndReserve(ctx context.Context, id int) (*models.Reserve, error) {
+	if err := auth.Authorize(ctx, models.PermissionCourseManage); err != nil {
+		return nil, err
+	}
+	reserve, err := s.CourseRepository.GetReserveById(ctx, id)
+	if err != nil {
+		if !errors.Is(err, repositories.ErrReserveNotFound) {
+			log.Printf("error getting reserve from repository: %v", err)
+		}
+		return nil, err
+	}
+	if reserve.Status != models.ReserveStatusActive {
+		return nil, ErrReserveNotActive
+	}
+	return s.endReserve(ctx, reserve)
+}
+
+// EndReserves ends the active reserves of the courses of terms that are over, giving the books back their previous
+// loan type. It returns how many reserves it ended.
+func (s *CourseService) EndReserves(ctx context.Context) (int, error) {
+	today, err := s.today(ctx)
+	if err != nil {
+		return 0, err
+	}
+	reserves, err := s.CourseRepository.ListReserves(ctx, 0)
+	if err != nil {
+		log.Printf("error listing active reserves from repository: %v", err)
+		return 0, err
+	}
+	ended := 0
+	terms := make(map[int]*models.Term)
+	for i := range reserves {
+		term, ok := terms[reserves[i].CourseId]
+		if !ok {
+			course, err := s.CourseRepository.GetCourseById(ctx, reserves[i].CourseId)
+			if err != nil {
+				return ended, err
+			}
+			if term, err = s.CourseRepository.GetTermById(ctx, course.TermId); err != nil {
+				return ended, err
+			}
+			terms[reserves[i].CourseId] = term
+		}
+		if !term.Ended(today) {
+			continue
+		}
+		if _, err := s.endReserve(ctx, &reserves[i]); err != nil {
+			return ended, err
+		}
+		ended++
+	}
+	return ended, nil
+}
+
+func (s *CourseService) endReserve(ctx context.Context, reserve *models.Reserve) (*models.Reserve, error) {
+	var endedReserve *models.Reserve
+	if err := db_manager.WrapInTransaction(ctx, s.TxDB, func(ctx context.Context) error {
+		book, err := s.BookRepository.GetBookById(ctx, reserve.BookId)
+		if err != nil {
+			log.Printf("error getting book: %v", err)
+			return err
+		}
+		bookBefore := *book
+		updatedBook, err := s.BookRepository.UpdateBookLoanType(ctx, book.Title, reserve.PreviousLoanType, reserve.PreviousLoanHours)
+		if err != nil {
+			log.Printf("error updating loan type of book '%s' from repository: %v", book.Title, err)
+			return err
+		}
+		update := *reserve
+		endedAt := s.Clock.Now().UTC()
+		update.Status, update.EndedAt = models.ReserveStatusEnded, &endedAt
+		if endedReserve, err = s.CourseRepository.UpdateReserve(ctx, &update); err != nil {
+			log.Printf("error updating reserve %d from repository: %v", reserve.Id, err)
+			return err
+		}
+		changes := models.Diff(models.AuditEntity(models.AuditEntityReserve, reserve.Id), reserve, endedReserve)
+		changes = append(changes, models.Diff(models.AuditEntity(models.AuditEntityBook, book.Id), &bookBefore, updatedBook)...)
+		return s.Auditor.Record(ctx, models.AuditActionReserveEnded, models.AuditEntityReserve, reserve.Id, changes)
+	}, nil); err != nil {
+		return nil, err
+	}
+	log.Printf("reserve %d of course %d ended, book %d is %s again", reserve.Id, reserve.CourseId, reserve.BookId, reserve.PreviousLoanType)
+	return endedReserve, nil
+}
+
+// today is the day in the time zone of the tenant's default branch, in DateLayout
+func (s *CourseService) today(ctx context.Context) (string, error) {
+	branch, err := s.BranchRepository.GetBranch(ctx, tenant.DefaultBranchId(ctx))
+	if err != nil {
+		log.Printf("error getting branch: %v", err)
+		return "", err
+	}
+	return s.Clock.Now().In(branch.Location()).Format(models.DateLayout), nil
+}
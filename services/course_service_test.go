@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aftaab60/e-library-api/internal/auth"
+	"github.com/aftaab60/e-library-api/internal/clock"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCourseService_Reserves(t *testing.T) {
+	travelClock := clock.NewAdjustable(clock.System{})
+	travelClock.Freeze()
+	travelClock.Set(time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC))
+	bookRepo := repositories.NewBookRepository()
+	courseService := NewCourseService(repositories.NewCourseRepository(), bookRepo, repositories.NewBranchRepository())
+	courseService.Clock = travelClock
+	ctx := context.Background()
+
+	term, err := courseService.CreateTerm(ctx, &models.TermRequest{Name: "Spring 2025", StartsOn: "2025-01-13", EndsOn: "2025-05-09"})
+	assert.NoError(t, err)
+	course, err := courseService.CreateCourse(ctx, &models.CourseRequest{TermId: term.Id, Code: "CS101", Name: "Programming",
+		Instructors: []string{"ada"}})
+	assert.NoError(t, err)
+
+	t.Run("Patrons can't manage courses", func(t *testing.T) {
+		patron := auth.NewContext(ctx, &models.Principal{Type: models.PrincipalTypePatron, Role: models.RolePatron, Id: 1, Name: "user1"})
+		_, err := courseService.AddReserve(patron, course.Id, &models.ReserveRequest{Title: "book1", LoanType: models.LoanTypeReferenceOnly})
+		assert.ErrorIs(t, err, auth.ErrForbidden)
+	})
+
+	t.Run("Courses belong to a term", func(t *testing.T) {
+		_, err := courseService.CreateCourse(ctx, &models.CourseRequest{TermId: 9, Code: "CS102", Name: "Programming", Instructors: []string{}})
+		assert.ErrorIs(t, err, repositories.ErrTermNotFound)
+	})
+
+	reserve, err := courseService.AddReserve(ctx, course.Id, &models.ReserveRequest{Title: "book1", LoanType: models.LoanTypeShortLoan, LoanHours: 4})
+	assert.NoError(t, err)
+
+	t.Run("Books on reserve are lent with the loan type of the reserve", func(t *testing.T) {
+		assert.Equal(t, models.LoanTypeStandard, reserve.PreviousLoanType)
+		book, err := bookRepo.GetBook(ctx, "book1")
+		assert.NoError(t, err)
+		assert.Equal(t, models.LoanTypeShortLoan, book.LoanType)
+		assert.Equal(t, 4, book.LoanHours)
+
+		_, err = courseService.AddReserve(ctx, course.Id, &models.ReserveRequest{Title: "book1", LoanType: models.LoanTypeReferenceOnly})
+		assert.ErrorIs(t, err, repositories.ErrExistingReserve)
+	})
+
+	ended, err := courseService.AddReserve(ctx, course.Id, &models.ReserveRequest{Title: "book2", LoanType: models.LoanTypeReferenceOnly})
+	assert.NoError(t, err)
+
+	t.Run("Reserves ended sooner give the book its loan type back", func(t *testing.T) {
+		_, err := courseService.EndReserve(ctx, ended.Id)
+		assert.NoError(t, err)
+		book, err := bookRepo.GetBook(ctx, "book2")
+		assert.NoError(t, err)
+		assert.Equal(t, models.LoanTypeStandard, book.LoanType)
+
+		_, err = courseService.EndReserve(ctx, ended.Id)
+		assert.ErrorIs(t, err, ErrReserveNotActive)
+	})
+
+	t.Run("Reserves end once the term is over", func(t *testing.T) {
+		travelClock.Set(time.Date(2025, 5, 9, 23, 0, 0, 0, time.UTC))
+		count, err := courseService.EndReserves(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+
+		travelClock.Set(time.Date(2025, 5, 10, 1, 0, 0, 0, time.UTC))
+		count, err = courseService.EndReserves(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+		book, err := bookRepo.GetBook(ctx, "book1")
+		assert.NoError(t, err)
+		assert.Equal(t, models.LoanTypeStandard, book.LoanType)
+		assert.Zero(t, book.LoanHours)
+
+		reserves, err := courseService.GetCourseReserves(ctx, course.Id)
+		assert.NoError(t, err)
+		assert.Len(t, reserves.Reserves, 2)
+		assert.Equal(t, models.ReserveStatusEnded, reserves.Reserves[0].Status)
+		assert.Equal(t, time.Date(2025, 5, 10, 1, 0, 0, 0, time.UTC), *reserves.Reserves[0].EndedAt)
+	})
+
+	t.Run("Books are not put on reserve for terms that ended", func(t *testing.T) {
+		_, err := courseService.AddReserve(ctx, course.Id, &models.ReserveRequest{Title: "book3", LoanType: models.LoanTypeReferenceOnly})
+		assert.ErrorIs(t, err, ErrTermEnded)
+	})
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aftaab60/e-library-api/internal/auth"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"log"
+	"time"
+)
+
+// DefaultIdempotencyTTL is how long the first response to an idempotent request is replayed to retries
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+type IdempotencyService struct {
+	IdempotencyRepository repositories.IIdempotencyRepository
+	TTL                   time.Duration
+}
+
+func NewIdempotencyService(idempotencyRepository repositories.IIdempotencyRepository) IdempotencyService {
+	return IdempotencyService{
+		IdempotencyRepository: idempotencyRepository,
+		TTL:                   DefaultIdempotencyTTL,
+	}
+}
+
+var (
+	ErrIdempotencyKeyReused        = errors.New("idempotency key was used with a different request")
+	ErrIdempotentRequestInProgress = errors.New("a request with this idempotency key is still in progress")
+)
+
+// scopedKey keeps the keys of callers apart, a caller never gets the response to another caller's request
+func scopedKey(ctx context.Context, key string) string {
+	principal := auth.FromContext(ctx)
+	if principal == nil {
+		return "anonymous:" + key
+	}
+	return fmt.Sprintf("%s:%d:%s:%s", principal.Type, principal.Id, principal.Name, key)
+}
+
+// Begin claims key for a request. It returns nil when the caller should process the request and complete or release
+// the key after, or the completed record of the first request with the same key, which the caller replays.
+func (s *IdempotencyService) Begin(ctx context.Context, key string, requestHash string) (*models.IdempotencyRecord, error) {
+	now := time.Now()
+	record := &models.IdempotencyRecord{
+		Key:         scopedKey(ctx, key),
+		RequestHash: requestHash,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(s.TTL),
+	}
+
+	//the existing record may expire between the two calls, the key is then claimed on the second attempt
+	for attempt := 0; attempt < 2; attempt++ {
+		_, err := s.IdempotencyRepository.CreateIdempotencyRecord(ctx, record)
+		if err == nil {
+			return nil, nil
+		}
+		if !errors.Is(err, repositories.ErrExistingIdempotencyKey) {
+			log.Printf("error creating idempotency record from repository: %v", err)
+			return nil, err
+		}
+
+		existing, err := s.IdempotencyRepository.GetIdempotencyRecord(ctx, record.Key, now)
+		if errors.Is(err, repositories.ErrIdempotencyRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			log.Printf("error getting idempotency record from repository: %v", err)
+			return nil, err
+		}
+		if existing.RequestHash != requestHash {
+			return nil, ErrIdempotencyKeyReused
+		}
+		if !existing.IsCompleted() {
+			return nil, ErrIdempotentRequestInProgress
+		}
+		return existing, nil
+	}
+	return nil, ErrIdempotentRequestInProgress
+}
+
+// Complete stores the response to the request that claimed key
+func (s *IdempotencyService) Complete(ctx context.Context, key string, statusCode int, contentType string, body []byte) error {
+	if _, err := s.IdempotencyRepository.CompleteIdempotencyRecord(ctx, scopedKey(ctx, key), statusCode, contentType, body); err != nil {
+		log.Printf("error completing idempotency record from repository: %v", err)
+		return err
+	}
+	return nil
+}
+
+// Release gives up key without a response, so that a retry processes the request again
+func (s *IdempotencyService) Release(ctx context.Context, key string) error {
+	if err := s.IdempotencyRepository.DeleteIdempotencyRecord(ctx, scopedKey(ctx, key)); err != nil {
+		log.Printf("error deleting idempotency record from repository: %v", err)
+		return err
+	}
+	return nil
+}
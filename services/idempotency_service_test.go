@@ -0,0 +1,52 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aftaab60/e-library-api/internal/auth"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotencyService_Begin(t *testing.T) {
+	idempotencyService := NewIdempotencyService(repositories.NewIdempotencyRepository())
+	patron := auth.NewContext(context.Background(), &models.Principal{Type: models.PrincipalTypePatron, Role: models.RolePatron, Id: 1, Name: "user1"})
+	otherPatron := auth.NewContext(context.Background(), &models.Principal{Type: models.PrincipalTypePatron, Role: models.RolePatron, Id: 2, Name: "user2"})
+
+	record, err := idempotencyService.Begin(patron, "key1", "hash1")
+	assert.NoError(t, err)
+	assert.Nil(t, record)
+
+	t.Run("Fail while the first request is in progress", func(t *testing.T) {
+		_, err := idempotencyService.Begin(patron, "key1", "hash1")
+		assert.Equal(t, ErrIdempotentRequestInProgress, err)
+	})
+
+	t.Run("Keys are scoped to the caller", func(t *testing.T) {
+		record, err := idempotencyService.Begin(otherPatron, "key1", "hash2")
+		assert.NoError(t, err)
+		assert.Nil(t, record)
+	})
+
+	t.Run("Replay the completed request", func(t *testing.T) {
+		assert.NoError(t, idempotencyService.Complete(patron, "key1", 200, "application/json", []byte(`{"ok":true}`)))
+		record, err := idempotencyService.Begin(patron, "key1", "hash1")
+		assert.NoError(t, err)
+		assert.Equal(t, 200, record.StatusCode)
+		assert.Equal(t, []byte(`{"ok":true}`), record.Body)
+	})
+
+	t.Run("Fail on a different request", func(t *testing.T) {
+		_, err := idempotencyService.Begin(patron, "key1", "hash2")
+		assert.Equal(t, ErrIdempotencyKeyReused, err)
+	})
+
+	t.Run("Released key is claimed again", func(t *testing.T) {
+		assert.NoError(t, idempotencyService.Release(otherPatron, "key1"))
+		record, err := idempotencyService.Begin(otherPatron, "key1", "hash3")
+		assert.NoError(t, err)
+		assert.Nil(t, record)
+	})
+}
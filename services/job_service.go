@@ -0,0 +1,323 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aftaab60/e-library-api/internal/auth"
+	"github.com/aftaab60/e-library-api/internal/cron"
+	"github.com/aftaab60/e-library-api/internal/tenant"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"hash/fnv"
+	"log"
+	"sync"
+	"time"
+)
+
+// JobFunc does the work of a job for the tenant in context and returns how many items it processed.
+// ctx is cancelled when the service shuts down, the job should return soon after.
+type JobFunc func(ctx context.Context) (int, error)
+
+// TenantBinder scopes a context to a tenant in the storage layer, like routes.TenantBinder does for requests
+type TenantBinder interface {
+	BindTenant(ctx context.Context, tenantId int) (context.Context, func(), error)
+}
+
+// JobLocker takes locks shared by every instance of the service. db_manager.DB implements it with Postgres advisory locks.
+type JobLocker interface {
+	TryAdvisoryLock(ctx context.Context, key1 int32, key2 int32) (unlock func(), ok bool, err error)
+}
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	// ErrJobRunning is returned when triggering a job that is already running for the tenant
+	ErrJobRunning = errors.New("job is running")
+)
+
+type scheduledJob struct {
+	name        string
+	description string
+	spec        string
+	schedule    cron.Schedule
+	run         JobFunc
+	//lockKey identifies the job in advisory locks, the tenant id is the second key
+	lockKey int32
+}
+
+// JobService runs background jobs on cron-style schedules, once for every tenant, and keeps the history of their runs.
+// A job runs at most once at a time for a tenant, and once per scheduled time, across every instance of the service.
+// Schedules and run times keep to the wall clock rather than the time travel clock: moving the clock would otherwise
+// fire every scheduled time in between on every instance. Jobs read the time travel clock through their services.
+type JobService struct {
+	JobRunRepository repositories.IJobRunRepository
+	TenantRepository repositories.ITenantRepository
+	//Binder and Locker are nil with in-memory repositories, runs then only exclude each other within the process
+	Binder TenantBinder
+	Locker JobLocker
+	jobs   []*scheduledJob
+	//running holds the job and tenant pairs running in this process
+	running map[string]bool
+	mutex   sync.Mutex
+	//ctx is the context of Start, runs are cancelled along with it
+	ctx context.Context
+	wg  sync.WaitGroup
+}
+
+func NewJobService(jobRunRepository repositories.IJobRunRepository, tenantRepository repositories.ITenantRepository) *JobService {
+	return &JobService{
+		JobRunRepository: jobRunRepository,
+		TenantRepository: tenantRepository,
+		running:          make(map[string]bool),
+		ctx:              context.Background(),
+	}
+}
+
+// Register adds a job running on the schedule spec, see cron.Parse. Jobs are registered before Start.
+func (s *JobService) Register(name string, spec string, description string, run JobFunc) error {
+	schedule, err := cron.Parse(spec)
+	if err != nil {
+		return err
+	}
+	if s.job(name) != nil {
+		return fmt.Errorf("job %s is already registered", name)
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(name))
+	s.jobs = append(s.jobs, &scheduledJob{
+		name:        name,
+		description: description,
+		spec:        spec,
+		schedule:    schedule,
+		run:         run,
+		lockKey:     int32(hash.Sum32()),
+	})
+	return nil
+}
+
+// Start runs the registered jobs on their schedule until ctx is cancelled. Runs in progress are cancelled with ctx,
+// Wait returns once they are done.
+func (s *JobService) Start(ctx context.Context) {
+	s.ctx = ctx
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go s.schedule(job)
+	}
+}
+
+// Wait waits for the schedules and runs of jobs to end after the context of Start is cancelled
+func (s *JobService) Wait() {
+	s.wg.Wait()
+}
+
+func (s *JobService) ListJobs(ctx context.Context) ([]models.Job, error) {
+	if err := auth.Authorize(ctx, models.PermissionConfigManage); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	jobs := make([]models.Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		runs, _, err := s.JobRunRepository.ListJobRuns(ctx, &models.JobRunFilter{Job: job.name, Limit: 1})
+		if err != nil {
+			log.Printf("error listing runs of job %s from repository: %v", job.name, err)
+			return nil, err
+		}
+		j := models.Job{Name: job.name, Description: job.description, Schedule: job.spec, NextRunAt: job.schedule.Next(now)}
+		if len(runs) > 0 {
+			j.LastRun = &runs[0]
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, nil
+}
+
+func (s *JobService) ListRuns(ctx context.Context, filter *models.JobRunFilter) (*models.JobRunPage, error) {
+	if err := auth.Authorize(ctx, models.PermissionConfigManage); err != nil {
+		return nil, err
+	}
+	runs, total, err := s.JobRunRepository.ListJobRuns(ctx, filter)
+	if err != nil {
+		log.Printf("error listing job runs from repository: %v", err)
+		return nil, err
+	}
+	return &models.JobRunPage{Runs: runs, Total: total, Limit: filter.Limit, Offset: filter.Offset}, nil
+}
+
+// TriggerJob runs a job for the tenant in context right away, whatever its schedule. The job runs in the background,
+// the run returned is updated in the history once it is done.
+func (s *JobService) TriggerJob(ctx context.Context, name string) (*models.JobRun, error) {
+	if err := auth.Authorize(ctx, models.PermissionConfigManage); err != nil {
+		return nil, err
+	}
+	job := s.job(name)
+	if job == nil {
+		return nil, ErrJobNotFound
+	}
+	t := tenant.FromContext(ctx)
+	if t == nil {
+		t = &models.Tenant{}
+	}
+	run := &models.JobRun{Trigger: models.JobTriggerManual}
+	if principal := auth.FromContext(ctx); principal != nil {
+		run.TriggeredBy = principal.Name
+	}
+
+	jobCtx, run, release, err := s.begin(t, job, run)
+	if err != nil {
+		return nil, err
+	}
+	started := *run
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.execute(jobCtx, job, run, release)
+	}()
+	return &started, nil
+}
+
+func (s *JobService) job(name string) *scheduledJob {
+	for _, job := range s.jobs {
+		if job.name == name {
+			return job
+		}
+	}
+	return nil
+}
+
+// schedule runs a job at every time of its schedule, for one tenant after the other
+func (s *JobService) schedule(job *scheduledJob) {
+	defer s.wg.Done()
+	for {
+		next := job.schedule.Next(time.Now())
+		if next.IsZero() {
+			log.Printf("job %s has no next run on schedule %q", job.name, job.spec)
+			return
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-s.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		tenants, err := s.TenantRepository.ListTenants(s.ctx)
+		if err != nil {
+			log.Printf("error listing tenants for job %s: %v", job.name, err)
+			continue
+		}
+		for i := range tenants {
+			if s.ctx.Err() != nil {
+				return
+			}
+			ctx, run, release, err := s.begin(&tenants[i], job, &models.JobRun{Trigger: models.JobTriggerSchedule, ScheduledAt: &next})
+			if errors.Is(err, ErrJobRunning) || errors.Is(err, repositories.ErrExistingJobRun) {
+				//a run of the job is still going, or this time was run by another instance
+				continue
+			}
+			if err != nil {
+				log.Printf("error starting job %s for tenant %s: %v", job.name, tenants[i].Slug, err)
+				continue
+			}
+			s.execute(ctx, job, run, release)
+		}
+	}
+}
+
+// begin scopes a context to tenant t, takes the lock of the job for the tenant and records the run.
+// The job is then executed with the returned context, release frees the tenant and the lock.
+func (s *JobService) begin(t *models.Tenant, job *scheduledJob, run *models.JobRun) (context.Context, *models.JobRun, func(), error) {
+	key := fmt.Sprintf("%s/%d", job.name, t.Id)
+	s.mutex.Lock()
+	if s.running[key] {
+		s.mutex.Unlock()
+		return nil, nil, nil, ErrJobRunning
+	}
+	s.running[key] = true
+	s.mutex.Unlock()
+
+	releases := []func(){func() {
+		s.mutex.Lock()
+		delete(s.running, key)
+		s.mutex.Unlock()
+	}}
+	release := func() {
+		for i := len(releases) - 1; i >= 0; i-- {
+			releases[i]()
+		}
+	}
+	fail := func(err error) (context.Context, *models.JobRun, func(), error) {
+		release()
+		return nil, nil, nil, err
+	}
+
+	ctx := tenant.NewContext(s.ctx, t)
+	if s.Binder != nil {
+		var unbind func()
+		var err error
+		if ctx, unbind, err = s.Binder.BindTenant(ctx, t.Id); err != nil {
+			return fail(err)
+		}
+		releases = append(releases, unbind)
+	}
+	if s.Locker != nil {
+		unlock, ok, err := s.Locker.TryAdvisoryLock(ctx, job.lockKey, int32(t.Id))
+		if err != nil {
+			return fail(err)
+		}
+		if !ok {
+			return fail(ErrJobRunning)
+		}
+		releases = append(releases, unlock)
+	}
+
+	run.Job = job.name
+	run.Status = models.JobRunRunning
+	run.StartedAt = time.Now()
+	createdRun, err := s.JobRunRepository.CreateJobRun(ctx, run)
+	if err != nil {
+		return fail(err)
+	}
+	return ctx, createdRun, release, nil
+}
+
+// execute runs a job begun with begin and records its outcome. Scheduled runs that had nothing to do aren't kept,
+// frequent jobs would fill the history with them.
+func (s *JobService) execute(ctx context.Context, job *scheduledJob, run *models.JobRun, release func()) {
+	defer release()
+
+	processed, err := runJob(ctx, job.run)
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	run.Processed = processed
+	run.Status = models.JobRunSucceeded
+	if err != nil {
+		run.Status = models.JobRunFailed
+		if ctx.Err() != nil {
+			run.Status = models.JobRunCancelled
+		}
+		run.Error = err.Error()
+		log.Printf("error running job %s for tenant %d: %v", job.name, tenant.Id(ctx), err)
+	}
+
+	//the outcome is recorded even when the run was cancelled
+	ctx = context.WithoutCancel(ctx)
+	if run.Trigger == models.JobTriggerSchedule && run.Status == models.JobRunSucceeded && processed == 0 {
+		err = s.JobRunRepository.DeleteJobRun(ctx, run.Id)
+	} else {
+		_, err = s.JobRunRepository.UpdateJobRun(ctx, run)
+	}
+	if err != nil {
+		log.Printf("error recording run %d of job %s: %v", run.Id, job.name, err)
+	}
+}
+
+// runJob calls a job, a panic fails the run instead of bringing the service down
+func runJob(ctx context.Context, run JobFunc) (processed int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return run(ctx)
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aftaab60/e-library-api/internal/auth"
+	"github.com/aftaab60/e-library-api/internal/tenant"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobService_TriggerJob(t *testing.T) {
+	jobService := NewJobService(repositories.NewJobRunRepository(), repositories.NewTenantRepository())
+	release := make(chan struct{})
+	assert.NoError(t, jobService.Register("count", "@daily", "Counts to three", func(ctx context.Context) (int, error) {
+		<-release
+		return 3, nil
+	}))
+	assert.NoError(t, jobService.Register("fail", "@daily", "Fails", func(ctx context.Context) (int, error) {
+		return 1, errors.New("out of paper")
+	}))
+	assert.NoError(t, jobService.Register("panic", "@daily", "Panics", func(ctx context.Context) (int, error) {
+		panic("out of ink")
+	}))
+	assert.Error(t, jobService.Register("count", "@daily", "Counts again", nil))
+	assert.Error(t, jobService.Register("never", "every day", "Invalid schedule", nil))
+
+	admin := auth.NewContext(context.Background(), &models.Principal{Type: models.PrincipalTypeStaff, Role: models.RoleAdmin, Name: "admin1"})
+
+	t.Run("A job runs once at a time", func(t *testing.T) {
+		run, err := jobService.TriggerJob(admin, "count")
+		assert.NoError(t, err)
+		assert.Equal(t, models.JobRunRunning, run.Status)
+		assert.Equal(t, "admin1", run.TriggeredBy)
+
+		_, err = jobService.TriggerJob(admin, "count")
+		assert.Equal(t, ErrJobRunning, err)
+
+		close(release)
+		jobService.Wait()
+		page, err := jobService.ListRuns(admin, &models.JobRunFilter{Job: "count", Limit: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, page.Total)
+		assert.Equal(t, models.JobRunSucceeded, page.Runs[0].Status)
+		assert.Equal(t, 3, page.Runs[0].Processed)
+		assert.NotNil(t, page.Runs[0].FinishedAt)
+	})
+
+	t.Run("Failures and panics are recorded", func(t *testing.T) {
+		_, err := jobService.TriggerJob(admin, "fail")
+		assert.NoError(t, err)
+		_, err = jobService.TriggerJob(admin, "panic")
+		assert.NoError(t, err)
+		jobService.Wait()
+
+		page, err := jobService.ListRuns(admin, &models.JobRunFilter{Status: models.JobRunFailed, Limit: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, page.Total)
+		errs := []string{page.Runs[0].Error, page.Runs[1].Error}
+		assert.Contains(t, errs, "out of paper")
+		assert.Contains(t, errs, "panic: out of ink")
+	})
+
+	t.Run("List jobs with their last run", func(t *testing.T) {
+		jobs, err := jobService.ListJobs(admin)
+		assert.NoError(t, err)
+		assert.Len(t, jobs, 3)
+		assert.Equal(t, "count", jobs[0].Name)
+		assert.Equal(t, models.JobRunSucceeded, jobs[0].LastRun.Status)
+		assert.True(t, jobs[0].NextRunAt.After(time.Now()))
+	})
+
+	t.Run("Unknown jobs and patrons", func(t *testing.T) {
+		_, err := jobService.TriggerJob(admin, "sweep")
+		assert.Equal(t, ErrJobNotFound, err)
+
+		patron := auth.NewContext(context.Background(), &models.Principal{Type: models.PrincipalTypePatron, Role: models.RolePatron, Id: 1, Name: "user1"})
+		_, err = jobService.TriggerJob(patron, "count")
+		assert.ErrorIs(t, err, auth.ErrForbidden)
+	})
+}
+
+func TestJobService_Schedule(t *testing.T) {
+	jobRunRepository := repositories.NewTenantJobRunRepository()
+	jobService := NewJobService(jobRunRepository, repositories.NewTenantRepository())
+	started := make(chan struct{}, 1)
+	assert.NoError(t, jobService.Register("wait", "@every 1s", "Waits for shutdown", func(ctx context.Context) (int, error) {
+		started <- struct{}{}
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	jobService.Start(ctx)
+	select {
+	case <-started:
+	case <-time.After(3 * time.Second):
+		t.Fatal("job didn't run on schedule")
+	}
+	//shutting down cancels the run and waits for it
+	cancel()
+	jobService.Wait()
+
+	tenants, err := jobService.TenantRepository.ListTenants(context.Background())
+	assert.NoError(t, err)
+	admin := auth.NewContext(context.Background(), &models.Principal{Type: models.PrincipalTypeStaff, Role: models.RoleAdmin, Name: "admin1"})
+	page, err := jobService.ListRuns(tenant.NewContext(admin, &tenants[0]), &models.JobRunFilter{Job: "wait", Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, page.Total)
+	assert.Equal(t, models.JobTriggerSchedule, page.Runs[0].Trigger)
+	assert.Equal(t, models.JobRunCancelled, page.Runs[0].Status)
+	assert.NotNil(t, page.Runs[0].ScheduledAt)
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/aftaab60/e-library-api/internal/auth"
+	"github.com/aftaab60/e-library-api/internal/db_manager"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"log"
+	"sort"
+	"time"
+)
+
+// replayBatchSize is how many events a replay reads at once
+const replayBatchSize = 500
+
+// recordLoanEvent appends an event to the stream of a loan and projects it onto the stored loan, loan is nil for LoanCreated.
+// It is called within the transaction of the change, so that stored loans never diverge from their streams
+// and the event is published to webhooks if and only if the change is committed.
+func (s *LoanService) recordLoanEvent(ctx context.Context, loan *models.Loan, event models.LoanEvent) (*models.Loan, error) {
+	projected, err := event.Apply(loan)
+	if err != nil {
+		return nil, err
+	}
+	if loan == nil {
+		//default branches differ between tenants, so the home branch is always stored
+		projected.BranchId = s.homeBranchId(ctx, projected)
+		created, err := s.LoanRepository.CreateLoan(ctx, event.Data.Title, projected)
+		if err != nil {
+			return nil, err
+		}
+		event.LoanId, event.Version = created.Id, 1
+		appended, err := s.LoanEventRepository.AppendLoanEvent(ctx, &event)
+		if err != nil {
+			log.Printf("error appending loan event: %v", err)
+			return nil, err
+		}
+		if err := s.Outbox.Publish(ctx, appended, created); err != nil {
+			return nil, err
+		}
+		return created, nil
+	}
+
+	stream, err := s.LoanEventRepository.GetLoanEvents(ctx, loan.Id)
+	if err != nil {
+		log.Printf("error getting loan events: %v", err)
+		return nil, err
+	}
+	version := len(stream)
+	if version == 0 {
+		//the loan predates event sourcing, its current state starts the stream
+		title := ""
+		if book, err := s.BookRepository.GetBookById(ctx, loan.BookId); err == nil {
+			title = book.Title
+		} else if !errors.Is(err, repositories.ErrBookNotFound) {
+			return nil, err
+		}
+		imported := models.NewLoanImportedEvent(title, loan, event.OccurredAt)
+		imported.Version = 1
+		if _, err := s.LoanEventRepository.AppendLoanEvent(ctx, &imported); err != nil {
+			log.Printf("error appending loan event: %v", err)
+			return nil, err
+		}
+		version = 1
+	}
+	event.LoanId, event.Version = loan.Id, version+1
+	appended, err := s.LoanEventRepository.AppendLoanEvent(ctx, &event)
+	if err != nil {
+		log.Printf("error appending loan event: %v", err)
+		return nil, err
+	}
+	updatedLoan, err := s.LoanRepository.UpdateLoanById(ctx, loan.Id, &models.LoanUpdate{
+		ReturnDate: &projected.ReturnDate,
+		IsReturn:   &projected.IsReturn,
+		Status:     &projected.Status,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Outbox.Publish(ctx, appended, updatedLoan); err != nil {
+		return nil, err
+	}
+	return updatedLoan, nil
+}
+
+// GetLoanEvents returns the history of a loan, oldest event first
+func (s *LoanService) GetLoanEvents(ctx context.Context, loanId int) ([]models.LoanEvent, error) {
+	if _, err := s.GetLoanById(ctx, loanId); err != nil {
+		return nil, err
+	}
+	events, err := s.LoanEventRepository.GetLoanEvents(ctx, loanId)
+	if err != nil {
+		log.Printf("error getting loan events: %v", err)
+		return nil, err
+	}
+	return events, nil
+}
+
+// ReplayLoanEvents rebuilds loans from their event streams. A loan whose stored state differs from its stream is replaced,
+// and when that changes whether the loan holds a copy, the available copies of its book and home branch are adjusted.
+// Copies moved by transfers are not loan events, hence availability is corrected by the difference rather than recounted.
+// With dryRun nothing is changed and the report tells what would be.
+func (s *LoanService) ReplayLoanEvents(ctx context.Context, dryRun bool) (*models.LoanReplayReport, error) {
+	if err := auth.Authorize(ctx, models.PermissionConfigManage); err != nil {
+		return nil, err
+	}
+	report := &models.LoanReplayReport{
+		DryRun:      dryRun,
+		Rebuilt:     make([]int, 0),
+		Adjustments: make([]models.AvailabilityAdjustment, 0),
+	}
+
+	streams := make(map[int][]models.LoanEvent)
+	for afterId := 0; ; {
+		events, err := s.LoanEventRepository.ListLoanEvents(ctx, afterId, replayBatchSize)
+		if err != nil {
+			log.Printf("error listing loan events: %v", err)
+			return nil, err
+		}
+		if len(events) == 0 {
+			break
+		}
+		for _, event := range events {
+			streams[event.LoanId] = append(streams[event.LoanId], event)
+			afterId = event.Id
+		}
+		report.Events += len(events)
+	}
+	loanIds := make([]int, 0, len(streams))
+	for loanId := range streams {
+		loanIds = append(loanIds, loanId)
+	}
+	sort.Ints(loanIds)
+	report.Loans = len(loanIds)
+
+	err := db_manager.WrapInTransaction(ctx, s.TxDB, func(ctx context.Context) error {
+		deltas := make(map[models.AvailabilityAdjustment]int)
+		for _, loanId := range loanIds {
+			stream := streams[loanId]
+			sort.Slice(stream, func(i, j int) bool { return stream[i].Version < stream[j].Version })
+			projected, err := models.ProjectLoan(stream)
+			if err != nil {
+				return err
+			}
+			//stored loans always have their home branch, see recordLoanEvent
+			projected.BranchId = s.homeBranchId(ctx, projected)
+			stored, err := s.LoanRepository.GetLoanById(ctx, loanId)
+			if err != nil && !errors.Is(err, repositories.ErrLoanNotFound) {
+				return err
+			}
+			if stored != nil && sameLoan(stored, projected) {
+				continue
+			}
+
+			report.Rebuilt = append(report.Rebuilt, loanId)
+			if stored.HoldsCopy() != projected.HoldsCopy() {
+				key := models.AvailabilityAdjustment{BookId: projected.BookId, BranchId: projected.BranchId}
+				if projected.HoldsCopy() {
+					deltas[key]--
+				} else {
+					deltas[key]++
+				}
+			}
+			if !dryRun {
+				if _, err := s.LoanRepository.SaveLoan(ctx, stream[0].Data.Title, projected); err != nil {
+					return err
+				}
+			}
+		}
+
+		for key, delta := range deltas {
+			if delta != 0 {
+				report.Adjustments = append(report.Adjustments, models.AvailabilityAdjustment{BookId: key.BookId, BranchId: key.BranchId, Delta: delta})
+			}
+		}
+		sort.Slice(report.Adjustments, func(i, j int) bool {
+			a, b := report.Adjustments[i], report.Adjustments[j]
+			return a.BookId < b.BookId || (a.BookId == b.BookId && a.BranchId < b.BranchId)
+		})
+		if dryRun {
+			return nil
+		}
+		for _, adjustment := range report.Adjustments {
+			if err := s.adjustAvailability(ctx, adjustment); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		log.Printf("error replaying loan events: %v", err)
+		return nil, err
+	}
+	log.Printf("replayed %d events of %d loans, %d loans rebuilt\n", report.Events, report.Loans, len(report.Rebuilt))
+	return report, nil
+}
+
+func (s *LoanService) adjustAvailability(ctx context.Context, adjustment models.AvailabilityAdjustment) error {
+	book, err := s.BookRepository.GetBookById(ctx, adjustment.BookId)
+	if err != nil {
+		return err
+	}
+	if _, err := s.BookRepository.UpdateBook(ctx, book.Title, book.AvailableCopies+adjustment.Delta); err != nil {
+		return err
+	}
+	stock, err := s.BranchRepository.GetBranchStock(ctx, adjustment.BookId, adjustment.BranchId)
+	if err != nil {
+		return err
+	}
+	stock.AvailableCopies += adjustment.Delta
+	_, err = s.BranchRepository.UpdateBranchStock(ctx, stock)
+	return err
+}
+
+// sameLoan compares loans up to the microsecond precision timestamps are stored with
+func sameLoan(a *models.Loan, b *models.Loan) bool {
+	sameTime := func(x time.Time, y time.Time) bool {
+		return x.Truncate(time.Microsecond).Equal(y.Truncate(time.Microsecond))
+	}
+	return a.Id == b.Id && a.BookId == b.BookId && a.BorrowerName == b.BorrowerName && a.IsReturn == b.IsReturn &&
+		a.Status == b.Status && a.BranchId == b.BranchId && sameTime(a.LoanDate, b.LoanDate) && sameTime(a.ReturnDate, b.ReturnDate)
+}
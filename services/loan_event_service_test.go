@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoanService_LoanEvents(t *testing.T) {
+	bookRepo := repositories.NewBookRepository()
+	loanRepo := repositories.NewLoanRepository()
+	loanEventRepo := repositories.NewLoanEventRepository()
+	loanService := NewLoanService(loanRepo, bookRepo, repositories.NewChargeRepository(), repositories.NewBranchRepository(), repositories.NewTransferRepository(), repositories.NewMemberRepository(), loanEventRepo)
+	ctx := context.Background()
+
+	_, err := loanService.BorrowBook(ctx, "book1", "user1")
+	assert.NoError(t, err)
+	loan, err := loanRepo.GetLoan(ctx, "book1", "user1")
+	assert.NoError(t, err)
+	_, err = loanService.ExtendLoanById(ctx, loan.Id)
+	assert.NoError(t, err)
+	_, err = loanService.ExtendLoanById(ctx, loan.Id)
+	assert.NoError(t, err)
+	assert.NoError(t, loanService.ReturnLoanById(ctx, loan.Id, 0))
+
+	t.Run("Every change is an event of the loan's stream", func(t *testing.T) {
+		events, err := loanService.GetLoanEvents(ctx, loan.Id)
+		assert.NoError(t, err)
+		assert.Len(t, events, 4)
+		for i, eventType := range []models.LoanEventType{models.LoanEventCreated, models.LoanEventExtended, models.LoanEventExtended, models.LoanEventReturned} {
+			assert.Equal(t, eventType, events[i].Type)
+			assert.Equal(t, i+1, events[i].Version)
+		}
+		assert.True(t, events[2].Data.DueDate.After(*events[1].Data.DueDate))
+	})
+
+	t.Run("Stored loan is the projection of its stream", func(t *testing.T) {
+		events, _ := loanEventRepo.GetLoanEvents(ctx, loan.Id)
+		projected, err := models.ProjectLoan(events)
+		assert.NoError(t, err)
+		stored, err := loanRepo.GetLoanById(ctx, loan.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, stored, projected)
+	})
+
+	t.Run("Loan made before event sourcing starts its stream with an import", func(t *testing.T) {
+		now := time.Now()
+		legacy, err := loanRepo.CreateLoan(ctx, "book2", &models.Loan{BookId: 2, BorrowerName: "user2", LoanDate: now, ReturnDate: now.AddDate(0, 0, 28), BranchId: 1})
+		assert.NoError(t, err)
+		_, err = loanService.ExtendLoanById(ctx, legacy.Id)
+		assert.NoError(t, err)
+
+		events, err := loanService.GetLoanEvents(ctx, legacy.Id)
+		assert.NoError(t, err)
+		assert.Len(t, events, 2)
+		assert.Equal(t, models.LoanEventImported, events[0].Type)
+		assert.Equal(t, "book2", events[0].Data.Title)
+		assert.Equal(t, models.LoanEventExtended, events[1].Type)
+	})
+}
+
+func TestLoanService_ReplayLoanEvents(t *testing.T) {
+	bookRepo := repositories.NewBookRepository()
+	loanRepo := repositories.NewLoanRepository()
+	branchRepo := repositories.NewBranchRepository()
+	loanService := NewLoanService(loanRepo, bookRepo, repositories.NewChargeRepository(), branchRepo, repositories.NewTransferRepository(), repositories.NewMemberRepository(), repositories.NewLoanEventRepository())
+	ctx := context.Background()
+
+	_, err := loanService.BorrowBook(ctx, "book1", "user1")
+	assert.NoError(t, err)
+	_, err = loanService.BorrowBook(ctx, "book2", "user1")
+	assert.NoError(t, err)
+	assert.NoError(t, loanService.ReturnBook(ctx, "book2", "user1"))
+
+	t.Run("Nothing to rebuild when loans match their streams", func(t *testing.T) {
+		report, err := loanService.ReplayLoanEvents(ctx, false)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, report.Events)
+		assert.Equal(t, 2, report.Loans)
+		assert.Empty(t, report.Rebuilt)
+	})
+
+	//a loan wrongly marked returned in place, and its copy put back on the shelf
+	loan, _ := loanRepo.GetLoan(ctx, "book1", "user1")
+	isReturn, status := true, models.LoanStatusReturned
+	_, err = loanRepo.UpdateLoanById(ctx, loan.Id, &models.LoanUpdate{IsReturn: &isReturn, Status: &status})
+	assert.NoError(t, err)
+	_, err = bookRepo.UpdateBook(ctx, "book1", 5)
+	assert.NoError(t, err)
+	stock, _ := branchRepo.GetBranchStock(ctx, loan.BookId, 1)
+	stock.AvailableCopies++
+	_, err = branchRepo.UpdateBranchStock(ctx, stock)
+	assert.NoError(t, err)
+
+	t.Run("Dry run reports without changing", func(t *testing.T) {
+		report, err := loanService.ReplayLoanEvents(ctx, true)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{loan.Id}, report.Rebuilt)
+		assert.Equal(t, []models.AvailabilityAdjustment{{BookId: loan.BookId, BranchId: 1, Delta: -1}}, report.Adjustments)
+
+		stored, _ := loanRepo.GetLoanById(ctx, loan.Id)
+		assert.Equal(t, models.LoanStatusReturned, stored.Status)
+	})
+
+	t.Run("Replay rebuilds the loan and availability", func(t *testing.T) {
+		report, err := loanService.ReplayLoanEvents(ctx, false)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{loan.Id}, report.Rebuilt)
+
+		stored, _ := loanRepo.GetLoanById(ctx, loan.Id)
+		assert.Equal(t, models.LoanStatusActive, stored.Status)
+		assert.False(t, stored.IsReturn)
+		book, _ := bookRepo.GetBook(ctx, "book1")
+		assert.Equal(t, 4, book.AvailableCopies)
+		stock, _ := branchRepo.GetBranchStock(ctx, loan.BookId, 1)
+		assert.Equal(t, 4, stock.AvailableCopies)
+	})
+
+	t.Run("Replay restores a missing loan", func(t *testing.T) {
+		returned, _, err := loanRepo.ListLoans(ctx, &models.LoanFilter{Status: models.LoanStatusReturned, Limit: 10})
+		assert.NoError(t, err)
+		assert.Len(t, returned, 1)
+		assert.NoError(t, loanRepo.DeleteLoan(ctx, "book2", "user1"))
+
+		report, err := loanService.ReplayLoanEvents(ctx, false)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{returned[0].Id}, report.Rebuilt)
+		assert.Empty(t, report.Adjustments)
+
+		restored, err := loanRepo.GetLoanById(ctx, returned[0].Id)
+		assert.NoError(t, err)
+		assert.Equal(t, returned[0], *restored)
+	})
+}
@@ -3,7 +3,11 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
+	"github.com/aftaab60/e-library-api/internal/auth"
+	"github.com/aftaab60/e-library-api/internal/clock"
 	"github.com/aftaab60/e-library-api/internal/db_manager"
+	"github.com/aftaab60/e-library-api/internal/tenant"
 	"github.com/aftaab60/e-library-api/models"
 	"github.com/aftaab60/e-library-api/repositories"
 	"log"
@@ -11,20 +15,47 @@ import (
 )
 
 type LoanService struct {
-	LoanRepository repositories.ILoanRepository
-	BookRepository repositories.IBookRepository
-	TxDB           db_manager.ItxDB
+	LoanRepository     repositories.ILoanRepository
+	BookRepository     repositories.IBookRepository
+	ChargeRepository   repositories.IChargeRepository
+	BranchRepository   repositories.IBranchRepository
+	TransferRepository repositories.ITransferRepository
+	MemberRepository   repositories.IMemberRepository
+	//LoanEventRepository is the source of truth of loans, LoanRepository holds their projection
+	LoanEventRepository repositories.ILoanEventRepository
+	TxDB                db_manager.ItxDB
+	//Auditor records loan state changes, nil records nothing
+	Auditor *AuditService
+	//Outbox publishes loan events to webhooks, nil publishes nothing
+	Outbox *WebhookService
+	//Calendar moves due dates to days branches are open and counts the days fines accrue on, nil treats every day as open
+	Calendar *CalendarService
+	//Clock tells the time of loans, the wall clock by default
+	Clock clock.Clock
+	//Bookings keeps copies held by bookings from being lent, nil has no bookings
+	Bookings *BookingService
 }
 
 // NewLoanService uses interface so that we can switch between in-memory and actual pgsql repo data easily
-func NewLoanService(loanRepository repositories.ILoanRepository, bookRepository repositories.IBookRepository) LoanService {
+func NewLoanService(loanRepository repositories.ILoanRepository, bookRepository repositories.IBookRepository, chargeRepository repositories.IChargeRepository,
+	branchRepository repositories.IBranchRepository, transferRepository repositories.ITransferRepository, memberRepository repositories.IMemberRepository,
+	loanEventRepository repositories.ILoanEventRepository) LoanService {
 	return LoanService{
-		LoanRepository: loanRepository,
-		BookRepository: bookRepository,
+		LoanRepository:      loanRepository,
+		BookRepository:      bookRepository,
+		ChargeRepository:    chargeRepository,
+		BranchRepository:    branchRepository,
+		TransferRepository:  transferRepository,
+		MemberRepository:    memberRepository,
+		LoanEventRepository: loanEventRepository,
+		Clock:               clock.System{},
 	}
 }
 
 func (s *LoanService) GetLoanDetailByTitleAndBorrower(ctx context.Context, title string, borrowerName string) (*models.Loan, error) {
+	if err := auth.AuthorizeBorrower(ctx, borrowerName); err != nil {
+		return nil, err
+	}
 	loan, err := s.LoanRepository.GetLoan(ctx, title, borrowerName)
 	if err != nil {
 		if errors.Is(err, repositories.ErrLoanNotFound) {
@@ -41,7 +72,115 @@ var ErrExistingLoanFound = errors.New("existing loan found")
 
 var ErrNoAvailableCopiesFound = errors.New("no available copies found")
 
+// ErrReferenceOnly is returned when borrowing a book that is only read in the library
+var ErrReferenceOnly = errors.New("book is for use in the library only")
+
+// ErrNonCirculating is returned when borrowing a book that is not handed to readers
+var ErrNonCirculating = errors.New("book does not circulate")
+
+// lendable refuses the books that are never lent, reference-only and non-circulating ones
+func lendable(book *models.Book) error {
+	switch book.LoanType {
+	case models.LoanTypeReferenceOnly:
+		return fmt.Errorf("%w: %s", ErrReferenceOnly, book.Title)
+	case models.LoanTypeNonCirculating:
+		return fmt.Errorf("%w: %s", ErrNonCirculating, book.Title)
+	}
+	return nil
+}
+
+// ErrBorrowingBlocked is matched by every BorrowingBlockedError
+var ErrBorrowingBlocked = errors.New("borrowing blocked")
+
+// BorrowingBlockedError is returned when a member may not borrow or renew, Reason tells why
+type BorrowingBlockedError struct {
+	Reason string
+	Detail string
+}
+
+func (e *BorrowingBlockedError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrBorrowingBlocked, e.Detail)
+}
+
+// Is matches ErrBorrowingBlocked, and blocks of the same reason, e.g. ErrAccountSuspended
+func (e *BorrowingBlockedError) Is(target error) bool {
+	if target == ErrBorrowingBlocked {
+		return true
+	}
+	var block *BorrowingBlockedError
+	return errors.As(target, &block) && block.Reason == e.Reason
+}
+
+var (
+	ErrLoanLimitReached  = &BorrowingBlockedError{Reason: models.BlockReasonLoanLimit, Detail: "loan limit reached"}
+	ErrFineLimitExceeded = &BorrowingBlockedError{Reason: models.BlockReasonFineLimit, Detail: "outstanding charges exceed the fine limit"}
+	ErrAccountSuspended  = &BorrowingBlockedError{Reason: models.BlockReasonSuspended, Detail: "account suspended"}
+	ErrAccountExpired    = &BorrowingBlockedError{Reason: models.BlockReasonExpired, Detail: "account expired"}
+)
+
 func (s *LoanService) BorrowBook(ctx context.Context, title string, borrowerName string) (*models.LoanDetail, error) {
+	return s.BorrowBookAtBranch(ctx, title, borrowerName, 0, false)
+}
+
+// BorrowBookAtBranch lends a copy held by the given branch, which becomes the loan's home branch.
+// The tenant's default branch is used when branchId is 0. Borrowers who may not borrow are refused with a
+// BorrowingBlockedError, unless override is set by a principal allowed to override loan policies.
+func (s *LoanService) BorrowBookAtBranch(ctx context.Context, title string, borrowerName string, branchId int, override bool) (*models.LoanDetail, error) {
+	return s.borrow(ctx, title, borrowerName, branchId, override, nil)
+}
+
+// BorrowBooking lends a copy held by a booking to a borrower, e.g. a student of the class the booking was made for.
+// The loan is due at the end of the last day of the booking.
+func (s *LoanService) BorrowBooking(ctx context.Context, bookingId int, borrowerName string) (*models.LoanDetail, error) {
+	if s.Bookings == nil {
+		return nil, repositories.ErrBookingNotFound
+	}
+	booking, err := s.Bookings.GetBookingById(ctx, bookingId)
+	if err != nil {
+		return nil, err
+	}
+	if booking.Status != models.BookingStatusActive {
+		return nil, ErrBookingNotActive
+	}
+	branch, err := s.BranchRepository.GetBranch(ctx, booking.BranchId)
+	if err != nil {
+		return nil, err
+	}
+	today := s.Clock.Now().In(branch.Location()).Format(models.DateLayout)
+	if !booking.Overlaps(today, today) {
+		return nil, fmt.Errorf("%w: it is lent from %s to %s", ErrBookingNotOpen, booking.StartsOn, booking.EndsOn)
+	}
+	book, err := s.BookRepository.GetBookById(ctx, booking.BookId)
+	if err != nil {
+		log.Printf("error getting book: %v", err)
+		return nil, err
+	}
+	return s.borrow(ctx, book.Title, borrowerName, booking.BranchId, false, booking)
+}
+
+// borrow lends a copy of a branch, one held by the booking when it is set
+func (s *LoanService) borrow(ctx context.Context, title string, borrowerName string, branchId int, override bool, booking *models.Booking) (*models.LoanDetail, error) {
+	if err := auth.AuthorizeBorrower(ctx, borrowerName); err != nil {
+		return nil, err
+	}
+	if override {
+		if err := auth.Authorize(ctx, models.PermissionPolicyOverride); err != nil {
+			return nil, err
+		}
+	}
+	if branchId == 0 {
+		branchId = tenant.DefaultBranchId(ctx)
+	}
+	policy := tenant.LoanPolicy(ctx)
+
+	blocks, err := s.borrowerBlocks(ctx, borrowerName, branchId, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(blocks) > 0 && !override {
+		return nil, blocks[0]
+	}
+
 	//check existing loan
 	loan, err := s.LoanRepository.GetLoan(ctx, title, borrowerName)
 	if err != nil && !errors.Is(err, repositories.ErrLoanNotFound) {
@@ -57,42 +196,206 @@ func (s *LoanService) BorrowBook(ctx context.Context, title string, borrowerName
 		log.Printf("error getting book: %v", err)
 		return nil, err
 	}
+	if err := lendable(book); err != nil {
+		return nil, err
+	}
 	if book.AvailableCopies == 0 {
 		return nil, ErrNoAvailableCopiesFound
 	}
+	stock, err := s.BranchRepository.GetBranchStock(ctx, book.Id, branchId)
+	if err != nil {
+		log.Printf("error getting branch stock: %v", err)
+		return nil, err
+	}
+	if stock.AvailableCopies == 0 {
+		return nil, ErrNoAvailableCopiesFound
+	}
 
-	//book and loan, both should be part of atomic operation and need to run in a transaction
+	t := s.Clock.Now().UTC()
+	var dueDate time.Time
+	if booking == nil {
+		dueDate, err = s.loanDue(ctx, book, branchId, t, policy.LoanPeriodDays)
+	} else {
+		var branch *models.Branch
+		if branch, err = s.BranchRepository.GetBranch(ctx, branchId); err != nil {
+			return nil, err
+		}
+		//the last day of the booking, in the time zone of the branch, whatever the loan type
+		var endsOn time.Time
+		if endsOn, err = time.ParseInLocation(models.DateLayout, booking.EndsOn, branch.Location()); err != nil {
+			return nil, fmt.Errorf("error parsing end of booking %d: %w", booking.Id, err)
+		}
+		dueDate, err = s.dueDate(ctx, branchId, endsOn.Add(12*time.Hour))
+	}
+	if err != nil {
+		return nil, err
+	}
+	//a copy held by a booking goes to the booking, any other copy must be back before the bookings needing it start
+	if booking == nil {
+		if err := s.Bookings.CheckLoan(ctx, book.Id, branchId, 0, dueDate); err != nil {
+			return nil, err
+		}
+	}
+
+	//book, branch stock and loan, all should be part of atomic operation and need to run in a transaction
 	if err = db_manager.WrapInTransaction(ctx, s.TxDB, func(ctx context.Context) error {
-		if _, err = s.BookRepository.UpdateBook(ctx, title, book.AvailableCopies-1); err != nil {
+		bookBefore, stockBefore := *book, *stock
+		updatedBook, err := s.BookRepository.UpdateBook(ctx, title, book.AvailableCopies-1)
+		if err != nil {
 			log.Printf("error updating book available copies: %v", err)
 			return err
 		}
+		stock.AvailableCopies--
+		if _, err = s.BranchRepository.UpdateBranchStock(ctx, stock); err != nil {
+			log.Printf("error updating branch stock: %v", err)
+			return err
+		}
 
-		loan, err = s.LoanRepository.CreateLoan(ctx, title, &models.Loan{
-			BookId:       book.Id,
-			BorrowerName: borrowerName,
-			LoanDate:     time.Now(),
-			ReturnDate:   time.Now().AddDate(0, 0, 28),
-			IsReturn:     false,
+		loan, err = s.recordLoanEvent(ctx, nil, models.LoanEvent{
+			Type:       models.LoanEventCreated,
+			OccurredAt: t,
+			Data: models.LoanEventData{
+				Title:        title,
+				BookId:       book.Id,
+				BorrowerName: borrowerName,
+				BranchId:     branchId,
+				DueDate:      &dueDate,
+			},
 		})
 		if err != nil {
 			log.Printf("error creating loan from repository: %v", err)
 			return err
 		}
-		return nil
+
+		changes := models.Diff(models.AuditEntity(models.AuditEntityLoan, loan.Id), nil, loan)
+		changes = append(changes, models.Diff(models.AuditEntity(models.AuditEntityBook, book.Id), &bookBefore, updatedBook)...)
+		changes = append(changes, models.Diff(models.AuditEntity(models.AuditEntityBranchStock, branchId, book.Id), &stockBefore, stock)...)
+		if booking != nil {
+			lent := *booking
+			lent.LentCopies++
+			if lent.LentCopies == lent.Copies {
+				lent.Status = models.BookingStatusFulfilled
+			}
+			updatedBooking, err := s.Bookings.BookingRepository.UpdateBooking(ctx, &lent)
+			if err != nil {
+				log.Printf("error updating booking %d from repository: %v", booking.Id, err)
+				return err
+			}
+			changes = append(changes, models.Diff(models.AuditEntity(models.AuditEntityBooking, booking.Id), booking, updatedBooking)...)
+		}
+		if err := s.Auditor.Record(ctx, models.AuditActionLoanBorrowed, models.AuditEntityLoan, loan.Id, changes); err != nil {
+			return err
+		}
+		if len(blocks) == 0 {
+			return nil
+		}
+		reasons := make([]string, 0, len(blocks))
+		for _, block := range blocks {
+			reasons = append(reasons, block.Reason)
+		}
+		overridden := []models.AuditChange{{Entity: models.AuditEntity(models.AuditEntityLoan, loan.Id), Field: "overridden_blocks", After: reasons}}
+		return s.Auditor.Record(ctx, models.AuditActionBlockOverridden, models.AuditEntityLoan, loan.Id, overridden)
 	}, nil); err != nil {
 		log.Printf("error running book and loan update transaction: %v", err)
 		return nil, err
 	}
 
+	return s.loanDetail(ctx, loan)
+}
+
+// borrowerBlocks returns why a borrower may not borrow, when borrowing, or renew a loan of the given branch: an account
+// suspended or expired on the day at the branch, outstanding charges over the fine limit of the loan policy and, when
+// borrowing, as many loans as the policy allows. Borrowers without a member account only have loans and charges.
+func (s *LoanService) borrowerBlocks(ctx context.Context, borrowerName string, branchId int, borrowing bool) ([]*BorrowingBlockedError, error) {
+	policy := tenant.LoanPolicy(ctx)
+	blocks := make([]*BorrowingBlockedError, 0)
+	member, err := s.MemberRepository.GetMemberByName(ctx, borrowerName)
+	if err != nil && !errors.Is(err, repositories.ErrMemberNotFound) {
+		log.Printf("error getting member from repository: %v", err)
+		return nil, err
+	}
+	if member != nil {
+		branch, err := s.BranchRepository.GetBranch(ctx, branchId)
+		if err != nil {
+			log.Printf("error getting branch: %v", err)
+			return nil, err
+		}
+		if member.Suspended {
+			blocks = append(blocks, ErrAccountSuspended)
+		}
+		if member.Expired(s.Clock.Now().In(branch.Location())) {
+			blocks = append(blocks, &BorrowingBlockedError{Reason: models.BlockReasonExpired, Detail: "account expired on " + member.ExpiresOn})
+		}
+	}
+	if policy.FineLimit > 0 {
+		owed, err := s.ChargeRepository.GetOutstandingAmount(ctx, borrowerName)
+		if err != nil {
+			log.Printf("error getting outstanding charges from repository: %v", err)
+			return nil, err
+		}
+		if owed > policy.FineLimit {
+			blocks = append(blocks, &BorrowingBlockedError{Reason: models.BlockReasonFineLimit,
+				Detail: fmt.Sprintf("outstanding charges of %d exceed the fine limit of %d", owed, policy.FineLimit)})
+		}
+	}
+	if borrowing && policy.MaxLoans > 0 {
+		_, loans, err := s.LoanRepository.ListLoans(ctx, &models.LoanFilter{BorrowerName: borrowerName, Status: models.LoanStatusActive, Limit: 1})
+		if err != nil {
+			log.Printf("error listing loans from repository: %v", err)
+			return nil, err
+		}
+		if loans >= policy.MaxLoans {
+			blocks = append(blocks, &BorrowingBlockedError{Reason: models.BlockReasonLoanLimit,
+				Detail: fmt.Sprintf("%d loans, at most %d are allowed at once", loans, policy.MaxLoans)})
+		}
+	}
+	return blocks, nil
+}
+
+// loanDue returns when a loan of the book made or due at t falls due: short loans after the hours of the book, at a time
+// the branch is open, other loans after days, at the end of a day the branch is open
+func (s *LoanService) loanDue(ctx context.Context, book *models.Book, branchId int, t time.Time, days int) (time.Time, error) {
+	if book.LoanType == models.LoanTypeShortLoan {
+		due, err := s.Calendar.DueTime(ctx, branchId, t.Add(time.Duration(book.LoanHours)*time.Hour))
+		return due.UTC(), err
+	}
+	return s.dueDate(ctx, branchId, t.AddDate(0, 0, days))
+}
+
+// dueDate returns the end of the first day the branch is open from the day of t on, in the time zone of the branch
+func (s *LoanService) dueDate(ctx context.Context, branchId int, t time.Time) (time.Time, error) {
+	branch, err := s.BranchRepository.GetBranch(ctx, branchId)
+	if err != nil {
+		log.Printf("error getting branch: %v", err)
+		return time.Time{}, err
+	}
+	day, err := s.Calendar.DueDate(ctx, branchId, t.In(branch.Location()))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return models.EndOfDay(day), nil
+}
+
+// loanDetail renders the dates of a loan in the time zone of its home branch
+func (s *LoanService) loanDetail(ctx context.Context, loan *models.Loan) (*models.LoanDetail, error) {
+	branch, err := s.BranchRepository.GetBranch(ctx, s.homeBranchId(ctx, loan))
+	if err != nil {
+		log.Printf("error getting branch: %v", err)
+		return nil, err
+	}
+	loc := branch.Location()
 	return &models.LoanDetail{
 		NameOfBorrower: loan.BorrowerName,
-		LoanDate:       loan.LoanDate,
-		ReturnDate:     loan.ReturnDate,
+		LoanDate:       loan.LoanDate.In(loc),
+		ReturnDate:     loan.ReturnDate.In(loc),
+		TimeZone:       loc.String(),
 	}, nil
 }
 
 func (s *LoanService) ExtendLoan(ctx context.Context, title string, borrowerName string) (*models.LoanDetail, error) {
+	if err := auth.AuthorizeBorrower(ctx, borrowerName); err != nil {
+		return nil, err
+	}
 	loan, err := s.LoanRepository.GetLoan(ctx, title, borrowerName)
 	if err != nil {
 		if errors.Is(err, repositories.ErrLoanNotFound) {
@@ -102,24 +405,91 @@ func (s *LoanService) ExtendLoan(ctx context.Context, title string, borrowerName
 		}
 		return nil, err
 	}
+	return s.extendLoan(ctx, loan)
+}
 
-	//extend 3 more weeks
-	t := loan.ReturnDate.AddDate(0, 0, 21)
-	updatedLoanDetail, err := s.LoanRepository.UpdateLoan(ctx, title, borrowerName, &models.LoanUpdate{
-		ReturnDate: &t,
-	})
+// ExtendLoanById extends an active loan looked up by its id
+func (s *LoanService) ExtendLoanById(ctx context.Context, loanId int) (*models.LoanDetail, error) {
+	loan, err := s.GetLoanById(ctx, loanId)
 	if err != nil {
-		log.Printf("error updating Loan from repository: %v", err)
 		return nil, err
 	}
-	return &models.LoanDetail{
-		NameOfBorrower: updatedLoanDetail.BorrowerName,
-		LoanDate:       updatedLoanDetail.LoanDate,
-		ReturnDate:     updatedLoanDetail.ReturnDate,
-	}, nil
+	if loan.IsReturn {
+		return nil, ErrLoanNotActive
+	}
+	return s.extendLoan(ctx, loan)
+}
+
+func (s *LoanService) extendLoan(ctx context.Context, loan *models.Loan) (*models.LoanDetail, error) {
+	blocks, err := s.borrowerBlocks(ctx, loan.BorrowerName, s.homeBranchId(ctx, loan), false)
+	if err != nil {
+		return nil, err
+	}
+	if len(blocks) > 0 {
+		return nil, blocks[0]
+	}
+	book, err := s.BookRepository.GetBookById(ctx, loan.BookId)
+	if err != nil {
+		log.Printf("error getting book: %v", err)
+		return nil, err
+	}
+	if err := lendable(book); err != nil {
+		return nil, err
+	}
+	var t time.Time
+	var updatedLoanDetail *models.Loan
+	if err := db_manager.WrapInTransaction(ctx, s.TxDB, func(ctx context.Context) error {
+		var err error
+		//extend by the tenant's extension period, 3 more weeks by default, or the hours of a short loan
+		if t, err = s.loanDue(ctx, book, s.homeBranchId(ctx, loan), loan.ReturnDate, tenant.LoanPolicy(ctx).ExtensionDays); err != nil {
+			return err
+		}
+		if err := s.Bookings.CheckLoan(ctx, loan.BookId, s.homeBranchId(ctx, loan), loan.Id, t); err != nil {
+			return err
+		}
+		updatedLoanDetail, err = s.recordLoanEvent(ctx, loan, models.LoanEvent{
+			Type:       models.LoanEventExtended,
+			OccurredAt: s.Clock.Now().UTC(),
+			Data:       models.LoanEventData{DueDate: &t},
+		})
+		if err != nil {
+			log.Printf("error updating Loan from repository: %v", err)
+			return err
+		}
+		changes := models.Diff(models.AuditEntity(models.AuditEntityLoan, loan.Id), loan, updatedLoanDetail)
+		return s.Auditor.Record(ctx, models.AuditActionLoanExtended, models.AuditEntityLoan, loan.Id, changes)
+	}, nil); err != nil {
+		return nil, err
+	}
+	return s.loanDetail(ctx, updatedLoanDetail)
+}
+
+// countRenewals counts the extensions of a loan in its history
+func (s *LoanService) countRenewals(ctx context.Context, loanId int) (int, error) {
+	events, err := s.LoanEventRepository.GetLoanEvents(ctx, loanId)
+	if err != nil {
+		log.Printf("error getting events of loan %d from repository: %v", loanId, err)
+		return 0, err
+	}
+	renewals := 0
+	for _, event := range events {
+		if event.Type == models.LoanEventExtended {
+			renewals++
+		}
+	}
+	return renewals, nil
 }
 
 func (s *LoanService) ReturnBook(ctx context.Context, title string, borrowerName string) error {
+	return s.ReturnBookAtBranch(ctx, title, borrowerName, 0)
+}
+
+// ReturnBookAtBranch returns a loan at the given branch, or at its home branch when branchId is 0.
+// A copy returned away from its home branch goes in transit back home and is not available until received.
+func (s *LoanService) ReturnBookAtBranch(ctx context.Context, title string, borrowerName string, branchId int) error {
+	if err := auth.AuthorizeBorrower(ctx, borrowerName); err != nil {
+		return err
+	}
 	// check if the loan is already returned
 	loan, err := s.LoanRepository.GetLoan(ctx, title, borrowerName)
 	if err != nil {
@@ -134,32 +504,364 @@ func (s *LoanService) ReturnBook(ctx context.Context, title string, borrowerName
 	if err != nil {
 		return err
 	}
+	return s.returnLoan(ctx, loan, book, branchId)
+}
+
+// ReturnLoanById returns an active loan looked up by its id, at the given branch or at its home branch when branchId is 0
+func (s *LoanService) ReturnLoanById(ctx context.Context, loanId int, branchId int) error {
+	loan, err := s.GetLoanById(ctx, loanId)
+	if err != nil {
+		return err
+	}
+	if loan.IsReturn {
+		return ErrLoanNotActive
+	}
+
+	book, err := s.BookRepository.GetBookById(ctx, loan.BookId)
+	if err != nil {
+		return err
+	}
+	return s.returnLoan(ctx, loan, book, branchId)
+}
+
+// homeBranchId returns the branch a loan was borrowed from, loans made before branches belong to the default branch
+func (s *LoanService) homeBranchId(ctx context.Context, loan *models.Loan) int {
+	if loan.BranchId == 0 {
+		return tenant.DefaultBranchId(ctx)
+	}
+	return loan.BranchId
+}
+
+func (s *LoanService) returnLoan(ctx context.Context, loan *models.Loan, book *models.Book, branchId int) error {
+	homeBranchId := s.homeBranchId(ctx, loan)
+	if branchId == 0 {
+		branchId = homeBranchId
+	}
+	if _, err := s.BranchRepository.GetBranch(ctx, branchId); err != nil {
+		return err
+	}
+	stock, err := s.BranchRepository.GetBranchStock(ctx, loan.BookId, homeBranchId)
+	if err != nil {
+		return err
+	}
 
 	if err = db_manager.WrapInTransaction(ctx, s.TxDB, func(ctx context.Context) error {
-		t := time.Now()
-		isReturn := true
-		_, err := s.LoanRepository.UpdateLoan(ctx, title, borrowerName, &models.LoanUpdate{
-			ReturnDate: &t,
-			IsReturn:   &isReturn,
+		t := s.Clock.Now().UTC()
+		bookBefore, stockBefore := *book, *stock
+		updatedLoan, err := s.recordLoanEvent(ctx, loan, models.LoanEvent{
+			Type:       models.LoanEventReturned,
+			OccurredAt: t,
+			Data:       models.LoanEventData{ReturnedAt: &t, ReturnBranchId: branchId},
 		})
 		if err != nil {
 			if errors.Is(err, repositories.ErrLoanNotFound) {
-				log.Printf("Loan '%s' not found", title)
+				log.Printf("Loan '%d' not found", loan.Id)
 			}
 			return err
 		}
+		changes := models.Diff(models.AuditEntity(models.AuditEntityLoan, loan.Id), loan, updatedLoan)
+		fine, err := s.chargeOverdueFine(ctx, loan, homeBranchId, t)
+		if err != nil {
+			return err
+		}
+		if fine != nil {
+			changes = append(changes, models.Diff(models.AuditEntity(models.AuditEntityCharge, fine.Id), nil, fine)...)
+		}
+
+		if branchId != homeBranchId {
+			//copy travels back to its home branch and is available once the transfer is received
+			stock.InTransitCopies++
+			if _, err := s.BranchRepository.UpdateBranchStock(ctx, stock); err != nil {
+				log.Printf("error updating branch stock: %v", err)
+				return err
+			}
+			transfer, err := s.TransferRepository.CreateTransfer(ctx, &models.Transfer{
+				BookId:       loan.BookId,
+				FromBranchId: branchId,
+				ToBranchId:   homeBranchId,
+				LoanId:       loan.Id,
+				Status:       models.TransferStatusInTransit,
+				CreatedAt:    t,
+				UpdatedAt:    t,
+			})
+			if err != nil {
+				log.Printf("error creating transfer: %v", err)
+				return err
+			}
+			changes = append(changes, models.Diff(models.AuditEntity(models.AuditEntityBranchStock, homeBranchId, loan.BookId), &stockBefore, stock)...)
+			changes = append(changes, models.Diff(models.AuditEntity(models.AuditEntityTransfer, transfer.Id), nil, transfer)...)
+			return s.Auditor.Record(ctx, models.AuditActionLoanReturned, models.AuditEntityLoan, loan.Id, changes)
+		}
 
-		if _, err := s.BookRepository.UpdateBook(ctx, title, book.AvailableCopies+1); err != nil {
+		updatedBook, err := s.BookRepository.UpdateBook(ctx, book.Title, book.AvailableCopies+1)
+		if err != nil {
 			log.Printf("error updating book available copies: %v", err)
 			return err
 		}
+		stock.AvailableCopies++
+		if _, err := s.BranchRepository.UpdateBranchStock(ctx, stock); err != nil {
+			log.Printf("error updating branch stock: %v", err)
+			return err
+		}
 
-		return nil
+		changes = append(changes, models.Diff(models.AuditEntity(models.AuditEntityBook, book.Id), &bookBefore, updatedBook)...)
+		changes = append(changes, models.Diff(models.AuditEntity(models.AuditEntityBranchStock, homeBranchId, loan.BookId), &stockBefore, stock)...)
+		return s.Auditor.Record(ctx, models.AuditActionLoanReturned, models.AuditEntityLoan, loan.Id, changes)
 	}, nil); err != nil {
 		log.Printf("error in returning book and loan update transaction: %v", err)
 		return err
 	}
+	s.applyHistoryPreference(ctx, loan.BorrowerName)
 
-	log.Printf("book has been returned, booktitle: %s, borrowerName: %s\n", title, borrowerName)
+	log.Printf("book has been returned, booktitle: %s, borrowerName: %s, branchId: %d\n", book.Title, loan.BorrowerName, branchId)
 	return nil
 }
+
+// chargeOverdueFine bills the borrower of a loan returned late the overdue fine of the loan policy for each day its
+// home branch was open since the due date. Closed days are free, as the copy could not have been returned on them.
+func (s *LoanService) chargeOverdueFine(ctx context.Context, loan *models.Loan, homeBranchId int, returnedAt time.Time) (*models.Charge, error) {
+	fine := tenant.LoanPolicy(ctx).OverdueFine
+	if fine == 0 || !returnedAt.After(loan.ReturnDate) {
+		return nil, nil
+	}
+	days, err := s.Calendar.OpenDaysBetween(ctx, homeBranchId, loan.ReturnDate, returnedAt)
+	if err != nil || days == 0 {
+		return nil, err
+	}
+	charge, err := s.ChargeRepository.CreateCharge(ctx, &models.Charge{
+		LoanId:       loan.Id,
+		BorrowerName: loan.BorrowerName,
+		Type:         models.ChargeTypeOverdue,
+		Amount:       days * fine,
+		Status:       models.ChargeStatusOutstanding,
+		CreatedAt:    returnedAt,
+	})
+	if err != nil {
+		log.Printf("error creating charge from repository: %v", err)
+		return nil, err
+	}
+	return charge, nil
+}
+
+func (s *LoanService) GetLoanById(ctx context.Context, loanId int) (*models.Loan, error) {
+	loan, err := s.LoanRepository.GetLoanById(ctx, loanId)
+	if err != nil {
+		if errors.Is(err, repositories.ErrLoanNotFound) {
+			log.Printf("Loan '%d' not found", loanId)
+		} else {
+			log.Printf("error getting Loan from repository: %v", err)
+		}
+		return nil, err
+	}
+	if err := auth.AuthorizeBorrower(ctx, loan.BorrowerName); err != nil {
+		return nil, err
+	}
+	return loan, nil
+}
+
+// ListLoans returns a page of loans matching the filter. A title that matches no book yields an empty page.
+// Principals that may not act on any loan only see their own loans.
+func (s *LoanService) ListLoans(ctx context.Context, filter *models.LoanFilter) (*models.LoanPage, error) {
+	if principal := auth.FromContext(ctx); principal != nil && !principal.Can(models.PermissionLoanAny) {
+		filter.BorrowerName = principal.Name
+	}
+	if filter.Title != "" {
+		book, err := s.BookRepository.GetBook(ctx, filter.Title)
+		if err != nil {
+			if errors.Is(err, repositories.ErrBookNotFound) {
+				return &models.LoanPage{Loans: make([]models.Loan, 0), Limit: filter.Limit, Offset: filter.Offset}, nil
+			}
+			log.Printf("error getting book: %v", err)
+			return nil, err
+		}
+		filter.BookId = book.Id
+	}
+
+	filter.AsOf = s.Clock.Now()
+	loans, total, err := s.LoanRepository.ListLoans(ctx, filter)
+	if err != nil {
+		log.Printf("error listing loans from repository: %v", err)
+		return nil, err
+	}
+	return &models.LoanPage{
+		Loans:  loans,
+		Total:  total,
+		Limit:  filter.Limit,
+		Offset: filter.Offset,
+	}, nil
+}
+
+var ErrLoanNotActive = errors.New("loan is not active")
+
+var ErrLoanNotLost = errors.New("loan is not marked as lost")
+
+// MarkLoanLost closes an active loan whose copy will never come back and bills the borrower a replacement fee.
+// The copy stays out of the available copies, it is written off until it is found.
+func (s *LoanService) MarkLoanLost(ctx context.Context, loanId int) (*models.LoanResolution, error) {
+	return s.closeUnreturnedLoan(ctx, loanId, models.LoanEventLost, models.ChargeTypeReplacement, tenant.LoanPolicy(ctx).ReplacementFee, models.AuditActionLoanLost)
+}
+
+// MarkLoanDamaged closes an active loan whose copy came back unfit for lending and bills the borrower a damage fee.
+// The copy is withdrawn, hence available copies are not increased.
+func (s *LoanService) MarkLoanDamaged(ctx context.Context, loanId int) (*models.LoanResolution, error) {
+	return s.closeUnreturnedLoan(ctx, loanId, models.LoanEventDamaged, models.ChargeTypeDamage, tenant.LoanPolicy(ctx).DamageFee, models.AuditActionLoanDamaged)
+}
+
+func (s *LoanService) closeUnreturnedLoan(ctx context.Context, loanId int, eventType models.LoanEventType, chargeType models.ChargeType, amount int,
+	action models.AuditAction) (*models.LoanResolution, error) {
+	if err := auth.Authorize(ctx, models.PermissionLoanManage); err != nil {
+		return nil, err
+	}
+	loan, err := s.GetLoanById(ctx, loanId)
+	if err != nil {
+		return nil, err
+	}
+	if loan.IsReturn {
+		return nil, ErrLoanNotActive
+	}
+
+	var charge *models.Charge
+	//loan closure and its charge should be part of atomic operation and need to run in a transaction
+	if err = db_manager.WrapInTransaction(ctx, s.TxDB, func(ctx context.Context) error {
+		loanBefore := loan
+		loan, err = s.recordLoanEvent(ctx, loan, models.LoanEvent{Type: eventType, OccurredAt: s.Clock.Now().UTC()})
+		if err != nil {
+			log.Printf("error updating Loan from repository: %v", err)
+			return err
+		}
+
+		charge, err = s.ChargeRepository.CreateCharge(ctx, &models.Charge{
+			LoanId:       loan.Id,
+			BorrowerName: loan.BorrowerName,
+			Type:         chargeType,
+			Amount:       amount,
+			Status:       models.ChargeStatusOutstanding,
+			CreatedAt:    s.Clock.Now().UTC(),
+		})
+		if err != nil {
+			log.Printf("error creating charge from repository: %v", err)
+			return err
+		}
+
+		changes := models.Diff(models.AuditEntity(models.AuditEntityLoan, loan.Id), loanBefore, loan)
+		changes = append(changes, models.Diff(models.AuditEntity(models.AuditEntityCharge, charge.Id), nil, charge)...)
+		return s.Auditor.Record(ctx, action, models.AuditEntityLoan, loan.Id, changes)
+	}, nil); err != nil {
+		log.Printf("error running loan closure and charge transaction: %v", err)
+		return nil, err
+	}
+
+	log.Printf("loan %d closed as %s, borrowerName: %s\n", loan.Id, loan.Status, loan.BorrowerName)
+	return &models.LoanResolution{
+		LoanId:         loan.Id,
+		NameOfBorrower: loan.BorrowerName,
+		Status:         loan.Status,
+		Charge:         charge,
+	}, nil
+}
+
+// MarkLoanFound reverses a lost loan: the copy goes back into available copies of its home branch and the replacement fee is refunded.
+func (s *LoanService) MarkLoanFound(ctx context.Context, loanId int) (*models.LoanResolution, error) {
+	if err := auth.Authorize(ctx, models.PermissionLoanManage); err != nil {
+		return nil, err
+	}
+	loan, err := s.GetLoanById(ctx, loanId)
+	if err != nil {
+		return nil, err
+	}
+	if loan.Status != models.LoanStatusLost {
+		return nil, ErrLoanNotLost
+	}
+
+	book, err := s.BookRepository.GetBookById(ctx, loan.BookId)
+	if err != nil {
+		log.Printf("error getting book: %v", err)
+		return nil, err
+	}
+	charges, err := s.ChargeRepository.GetChargesByLoan(ctx, loan.Id)
+	if err != nil {
+		log.Printf("error getting charges from repository: %v", err)
+		return nil, err
+	}
+	homeBranchId := s.homeBranchId(ctx, loan)
+	stock, err := s.BranchRepository.GetBranchStock(ctx, loan.BookId, homeBranchId)
+	if err != nil {
+		log.Printf("error getting branch stock: %v", err)
+		return nil, err
+	}
+
+	var refund *models.Charge
+	if err = db_manager.WrapInTransaction(ctx, s.TxDB, func(ctx context.Context) error {
+		t := s.Clock.Now().UTC()
+		loanBefore, bookBefore, stockBefore := loan, *book, *stock
+		loan, err = s.recordLoanEvent(ctx, loan, models.LoanEvent{
+			Type:       models.LoanEventFound,
+			OccurredAt: t,
+			Data:       models.LoanEventData{ReturnedAt: &t},
+		})
+		if err != nil {
+			log.Printf("error updating Loan from repository: %v", err)
+			return err
+		}
+
+		updatedBook, err := s.BookRepository.UpdateBook(ctx, book.Title, book.AvailableCopies+1)
+		if err != nil {
+			log.Printf("error updating book available copies: %v", err)
+			return err
+		}
+		stock.AvailableCopies++
+		if _, err = s.BranchRepository.UpdateBranchStock(ctx, stock); err != nil {
+			log.Printf("error updating branch stock: %v", err)
+			return err
+		}
+
+		changes := models.Diff(models.AuditEntity(models.AuditEntityLoan, loan.Id), loanBefore, loan)
+		changes = append(changes, models.Diff(models.AuditEntity(models.AuditEntityBook, book.Id), &bookBefore, updatedBook)...)
+		changes = append(changes, models.Diff(models.AuditEntity(models.AuditEntityBranchStock, homeBranchId, loan.BookId), &stockBefore, stock)...)
+		for _, charge := range charges {
+			if charge.Type != models.ChargeTypeReplacement || charge.Status == models.ChargeStatusRefunded {
+				continue
+			}
+			if refund, err = s.ChargeRepository.UpdateChargeStatus(ctx, charge.Id, models.ChargeStatusRefunded); err != nil {
+				log.Printf("error refunding charge from repository: %v", err)
+				return err
+			}
+			changes = append(changes, models.Diff(models.AuditEntity(models.AuditEntityCharge, charge.Id), &charge, refund)...)
+		}
+		return s.Auditor.Record(ctx, models.AuditActionLoanFound, models.AuditEntityLoan, loan.Id, changes)
+	}, nil); err != nil {
+		log.Printf("error running found loan transaction: %v", err)
+		return nil, err
+	}
+
+	s.applyHistoryPreference(ctx, loan.BorrowerName)
+
+	log.Printf("lost loan %d has been found, booktitle: %s, borrowerName: %s\n", loan.Id, book.Title, loan.BorrowerName)
+	return &models.LoanResolution{
+		LoanId:         loan.Id,
+		NameOfBorrower: loan.BorrowerName,
+		Status:         loan.Status,
+		Charge:         refund,
+	}, nil
+}
+
+// applyHistoryPreference anonymizes returned loans of a member who opted out of history retention.
+// The return itself has succeeded at this point, so a failure here is only logged.
+func (s *LoanService) applyHistoryPreference(ctx context.Context, borrowerName string) {
+	member, err := s.MemberRepository.GetMemberByName(ctx, borrowerName)
+	if err != nil {
+		if !errors.Is(err, repositories.ErrMemberNotFound) {
+			log.Printf("error getting member from repository: %v", err)
+		}
+		return
+	}
+	if member.RetainHistory {
+		return
+	}
+	if _, err := s.LoanRepository.AnonymizeLoans(ctx, borrowerName); err != nil {
+		log.Printf("error anonymizing loans of member %d: %v", member.Id, err)
+	}
+	if _, err := s.LoanEventRepository.AnonymizeLoanEvents(ctx, borrowerName); err != nil {
+		log.Printf("error anonymizing loan events of member %d: %v", member.Id, err)
+	}
+}
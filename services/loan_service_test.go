@@ -4,7 +4,11 @@ import (
 	"context"
 	"testing"
 	"time"
+	_ "time/tzdata"
 
+	"github.com/aftaab60/e-library-api/internal/auth"
+	"github.com/aftaab60/e-library-api/internal/clock"
+	"github.com/aftaab60/e-library-api/internal/tenant"
 	"github.com/aftaab60/e-library-api/models"
 	"github.com/aftaab60/e-library-api/repositories"
 	"github.com/stretchr/testify/assert"
@@ -13,7 +17,7 @@ import (
 func TestLoanService_BorrowBook(t *testing.T) {
 	bookRepo := repositories.NewBookRepository()
 	loanRepo := repositories.NewLoanRepository()
-	loanService := NewLoanService(loanRepo, bookRepo)
+	loanService := NewLoanService(loanRepo, bookRepo, repositories.NewChargeRepository(), repositories.NewBranchRepository(), repositories.NewTransferRepository(), repositories.NewMemberRepository(), repositories.NewLoanEventRepository())
 
 	ctx := context.Background()
 	// Add test book data
@@ -46,7 +50,7 @@ func TestLoanService_BorrowBook(t *testing.T) {
 func TestLoanService_ExtendLoan(t *testing.T) {
 	bookRepo := repositories.NewBookRepository()
 	loanRepo := repositories.NewLoanRepository()
-	loanService := NewLoanService(loanRepo, bookRepo)
+	loanService := NewLoanService(loanRepo, bookRepo, repositories.NewChargeRepository(), repositories.NewBranchRepository(), repositories.NewTransferRepository(), repositories.NewMemberRepository(), repositories.NewLoanEventRepository())
 
 	ctx := context.Background()
 	currTime := time.Now()
@@ -64,14 +68,22 @@ func TestLoanService_ExtendLoan(t *testing.T) {
 		loan, err := loanService.ExtendLoan(ctx, "book1", "borrower2")
 		assert.NoError(t, err)
 		assert.NotNil(t, loan)
-		assert.Equal(t, currTime.Unix(), loan.ReturnDate.Unix()) // Extended by 21 days
+		assert.Equal(t, models.EndOfDay(currTime.UTC()).Unix(), loan.ReturnDate.Unix()) // Extended by 21 days, to the end of the day
+	})
+
+	t.Run("Successfully extend beyond the renewal limit by hand", func(t *testing.T) {
+		//the loan policy renews 3 times by default, one extension was made above, the limit only stops automatic renewals
+		for i := 0; i < 3; i++ {
+			_, err := loanService.ExtendLoan(ctx, "book1", "borrower2")
+			assert.NoError(t, err)
+		}
 	})
 }
 
 func TestLoanService_ReturnBook(t *testing.T) {
 	bookRepo := repositories.NewBookRepository()
 	loanRepo := repositories.NewLoanRepository()
-	loanService := NewLoanService(loanRepo, bookRepo)
+	loanService := NewLoanService(loanRepo, bookRepo, repositories.NewChargeRepository(), repositories.NewBranchRepository(), repositories.NewTransferRepository(), repositories.NewMemberRepository(), repositories.NewLoanEventRepository())
 	ctx := context.Background()
 
 	// Add a book and loan
@@ -101,3 +113,346 @@ func TestLoanService_ReturnBook(t *testing.T) {
 		assert.Equal(t, repositories.ErrLoanNotFound, err)
 	})
 }
+
+func TestLoanService_MarkLoanLost(t *testing.T) {
+	bookRepo := repositories.NewBookRepository()
+	loanRepo := repositories.NewLoanRepository()
+	chargeRepo := repositories.NewChargeRepository()
+	loanService := NewLoanService(loanRepo, bookRepo, chargeRepo, repositories.NewBranchRepository(), repositories.NewTransferRepository(), repositories.NewMemberRepository(), repositories.NewLoanEventRepository())
+	ctx := context.Background()
+
+	loan, err := loanService.BorrowBook(ctx, "book2", "borrower4")
+	assert.NoError(t, err)
+	assert.NotNil(t, loan)
+	activeLoan, err := loanRepo.GetLoan(ctx, "book2", "borrower4")
+	assert.NoError(t, err)
+
+	t.Run("Successfully mark a loan lost", func(t *testing.T) {
+		resolution, err := loanService.MarkLoanLost(ctx, activeLoan.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, models.LoanStatusLost, resolution.Status)
+		assert.Equal(t, models.ChargeTypeReplacement, resolution.Charge.Type)
+		assert.Equal(t, models.DefaultLoanPolicy.ReplacementFee, resolution.Charge.Amount)
+
+		// Ensure the copy stays written off
+		book, _ := bookRepo.GetBook(ctx, "book2")
+		assert.Equal(t, 2, book.AvailableCopies)
+	})
+
+	t.Run("Fail to mark a closed loan lost", func(t *testing.T) {
+		_, err := loanService.MarkLoanLost(ctx, activeLoan.Id)
+		assert.Equal(t, ErrLoanNotActive, err)
+	})
+
+	t.Run("Successfully reverse a lost loan once found", func(t *testing.T) {
+		resolution, err := loanService.MarkLoanFound(ctx, activeLoan.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, models.LoanStatusReturned, resolution.Status)
+		assert.Equal(t, models.ChargeStatusRefunded, resolution.Charge.Status)
+
+		// Ensure the copy is back in circulation
+		book, _ := bookRepo.GetBook(ctx, "book2")
+		assert.Equal(t, 3, book.AvailableCopies)
+	})
+
+	t.Run("Fail to find a loan that is not lost", func(t *testing.T) {
+		_, err := loanService.MarkLoanFound(ctx, activeLoan.Id)
+		assert.Equal(t, ErrLoanNotLost, err)
+	})
+}
+
+func TestLoanService_MarkLoanDamaged(t *testing.T) {
+	bookRepo := repositories.NewBookRepository()
+	loanRepo := repositories.NewLoanRepository()
+	loanService := NewLoanService(loanRepo, bookRepo, repositories.NewChargeRepository(), repositories.NewBranchRepository(), repositories.NewTransferRepository(), repositories.NewMemberRepository(), repositories.NewLoanEventRepository())
+	ctx := context.Background()
+
+	_, err := loanService.BorrowBook(ctx, "book3", "borrower5")
+	assert.NoError(t, err)
+	activeLoan, err := loanRepo.GetLoan(ctx, "book3", "borrower5")
+	assert.NoError(t, err)
+
+	t.Run("Successfully mark a loan damaged", func(t *testing.T) {
+		resolution, err := loanService.MarkLoanDamaged(ctx, activeLoan.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, models.LoanStatusDamaged, resolution.Status)
+		assert.Equal(t, models.DefaultLoanPolicy.DamageFee, resolution.Charge.Amount)
+
+		book, _ := bookRepo.GetBook(ctx, "book3")
+		assert.Equal(t, 0, book.AvailableCopies)
+	})
+
+	t.Run("Fail to mark a non-existent loan damaged", func(t *testing.T) {
+		_, err := loanService.MarkLoanDamaged(ctx, 100)
+		assert.Equal(t, repositories.ErrLoanNotFound, err)
+	})
+}
+
+func TestLoanService_ReturnBookAtBranch(t *testing.T) {
+	bookRepo := repositories.NewBookRepository()
+	loanRepo := repositories.NewLoanRepository()
+	branchRepo := repositories.NewBranchRepository()
+	transferRepo := repositories.NewTransferRepository()
+	loanService := NewLoanService(loanRepo, bookRepo, repositories.NewChargeRepository(), branchRepo, transferRepo, repositories.NewMemberRepository(), repositories.NewLoanEventRepository())
+	branchService := NewBranchService(bookRepo, branchRepo, transferRepo)
+	ctx := context.Background()
+
+	_, err := loanService.BorrowBookAtBranch(ctx, "book1", "borrower6", models.DefaultBranchId, false)
+	assert.NoError(t, err)
+
+	t.Run("Fail to borrow at a branch without copies", func(t *testing.T) {
+		_, err := loanService.BorrowBookAtBranch(ctx, "book1", "borrower7", 2, false)
+		assert.Equal(t, ErrNoAvailableCopiesFound, err)
+	})
+
+	t.Run("Book returned at another branch goes in transit to its home branch", func(t *testing.T) {
+		err := loanService.ReturnBookAtBranch(ctx, "book1", "borrower6", 2)
+		assert.NoError(t, err)
+
+		book, _ := bookRepo.GetBook(ctx, "book1")
+		assert.Equal(t, 4, book.AvailableCopies)
+		stock, _ := branchRepo.GetBranchStock(ctx, book.Id, models.DefaultBranchId)
+		assert.Equal(t, 4, stock.AvailableCopies)
+		assert.Equal(t, 1, stock.InTransitCopies)
+
+		transfers, _ := transferRepo.ListTransfers(ctx, models.TransferStatusInTransit)
+		assert.Len(t, transfers, 1)
+		assert.Equal(t, 2, transfers[0].FromBranchId)
+		assert.Equal(t, models.DefaultBranchId, transfers[0].ToBranchId)
+
+		// copy is available again once received at home
+		_, err = branchService.ReceiveTransfer(ctx, transfers[0].Id)
+		assert.NoError(t, err)
+		book, _ = bookRepo.GetBook(ctx, "book1")
+		assert.Equal(t, 5, book.AvailableCopies)
+		stock, _ = branchRepo.GetBranchStock(ctx, book.Id, models.DefaultBranchId)
+		assert.Equal(t, 5, stock.AvailableCopies)
+		assert.Equal(t, 0, stock.InTransitCopies)
+	})
+}
+
+func TestLoanService_LoansById(t *testing.T) {
+	bookRepo := repositories.NewBookRepository()
+	loanRepo := repositories.NewLoanRepository()
+	loanService := NewLoanService(loanRepo, bookRepo, repositories.NewChargeRepository(), repositories.NewBranchRepository(), repositories.NewTransferRepository(), repositories.NewMemberRepository(), repositories.NewLoanEventRepository())
+	ctx := context.Background()
+
+	borrowed, err := loanService.BorrowBook(ctx, "book2", "borrower8")
+	assert.NoError(t, err)
+	loan, err := loanRepo.GetLoan(ctx, "book2", "borrower8")
+	assert.NoError(t, err)
+
+	t.Run("Successfully extend a loan by id", func(t *testing.T) {
+		extended, err := loanService.ExtendLoanById(ctx, loan.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, borrowed.ReturnDate.AddDate(0, 0, 21).Unix(), extended.ReturnDate.Unix())
+	})
+
+	t.Run("List loans by title", func(t *testing.T) {
+		page, err := loanService.ListLoans(ctx, &models.LoanFilter{Title: "book2", Limit: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, page.Total)
+		assert.Equal(t, loan.Id, page.Loans[0].Id)
+
+		page, err = loanService.ListLoans(ctx, &models.LoanFilter{Title: "book100", Limit: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, 0, page.Total)
+	})
+
+	t.Run("Successfully return a loan by id", func(t *testing.T) {
+		err := loanService.ReturnLoanById(ctx, loan.Id, 0)
+		assert.NoError(t, err)
+
+		book, _ := bookRepo.GetBook(ctx, "book2")
+		assert.Equal(t, 3, book.AvailableCopies)
+	})
+
+	t.Run("Fail to extend or return a returned loan", func(t *testing.T) {
+		_, err := loanService.ExtendLoanById(ctx, loan.Id)
+		assert.Equal(t, ErrLoanNotActive, err)
+		err = loanService.ReturnLoanById(ctx, loan.Id, 0)
+		assert.Equal(t, ErrLoanNotActive, err)
+	})
+}
+
+func TestLoanService_PatronPermissions(t *testing.T) {
+	loanService := NewLoanService(repositories.NewLoanRepository(), repositories.NewBookRepository(), repositories.NewChargeRepository(), repositories.NewBranchRepository(), repositories.NewTransferRepository(), repositories.NewMemberRepository(), repositories.NewLoanEventRepository())
+	ctx := context.Background()
+	patron := auth.NewContext(ctx, &models.Principal{Type: models.PrincipalTypePatron, Role: models.RolePatron, Id: 1, Name: "user1"})
+	librarian := auth.NewContext(ctx, &models.Principal{Type: models.PrincipalTypeApiKey, Role: models.RoleLibrarian, Id: 1, Name: "desk"})
+
+	_, err := loanService.BorrowBook(librarian, "book1", "user2")
+	assert.NoError(t, err)
+	_, err = loanService.BorrowBook(patron, "book2", "user1")
+	assert.NoError(t, err)
+
+	t.Run("Patron can't borrow for another member", func(t *testing.T) {
+		_, err := loanService.BorrowBook(patron, "book3", "user2")
+		assert.ErrorIs(t, err, auth.ErrForbidden)
+	})
+
+	t.Run("Patron can't act on another member's loan", func(t *testing.T) {
+		_, err := loanService.ExtendLoanById(patron, 1)
+		assert.ErrorIs(t, err, auth.ErrForbidden)
+		err = loanService.ReturnLoanById(patron, 1, 0)
+		assert.ErrorIs(t, err, auth.ErrForbidden)
+	})
+
+	t.Run("Patron only lists its own loans", func(t *testing.T) {
+		page, err := loanService.ListLoans(patron, &models.LoanFilter{BorrowerName: "user2", Limit: 20})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, page.Total)
+		assert.Equal(t, "user1", page.Loans[0].BorrowerName)
+	})
+
+	t.Run("Patron can't mark a loan lost", func(t *testing.T) {
+		_, err := loanService.MarkLoanLost(patron, 2)
+		var forbiddenErr *auth.ForbiddenError
+		assert.ErrorAs(t, err, &forbiddenErr)
+		assert.Equal(t, "role patron lacks permission loan:manage", forbiddenErr.Reason)
+	})
+
+	t.Run("Librarian acts on any loan", func(t *testing.T) {
+		_, err := loanService.ExtendLoanById(librarian, 2)
+		assert.NoError(t, err)
+		_, err = loanService.MarkLoanLost(librarian, 1)
+		assert.NoError(t, err)
+	})
+}
+
+func TestLoanService_BranchTimeZone(t *testing.T) {
+	branchRepo := repositories.NewBranchRepository()
+	loanService := NewLoanService(repositories.NewLoanRepository(), repositories.NewBookRepository(), repositories.NewChargeRepository(), branchRepo, repositories.NewTransferRepository(), repositories.NewMemberRepository(), repositories.NewLoanEventRepository())
+	ctx := context.Background()
+	_, err := branchRepo.UpdateBranch(ctx, &models.Branch{Id: 2, Name: "east", TimeZone: "Pacific/Auckland"})
+	assert.NoError(t, err)
+	_, err = branchRepo.UpdateBranchStock(ctx, &models.BranchStock{BookId: 1, BranchId: 2, AvailableCopies: 1})
+	assert.NoError(t, err)
+
+	t.Run("Loan is due at the end of the day in the branch time zone", func(t *testing.T) {
+		loan, err := loanService.BorrowBookAtBranch(ctx, "book1", "borrower1", 2, false)
+		assert.NoError(t, err)
+		assert.Equal(t, "Pacific/Auckland", loan.TimeZone)
+		assert.Equal(t, "Pacific/Auckland", loan.ReturnDate.Location().String())
+		assert.Equal(t, "23:59:59", loan.ReturnDate.Format("15:04:05"))
+		assert.Equal(t, loan.LoanDate.AddDate(0, 0, models.DefaultLoanPolicy.LoanPeriodDays).Format(models.DateLayout), loan.ReturnDate.Format(models.DateLayout))
+
+		stored, err := loanService.LoanRepository.GetLoan(ctx, "book1", "borrower1")
+		assert.NoError(t, err)
+		assert.Equal(t, time.UTC, stored.ReturnDate.Location())
+		assert.True(t, stored.ReturnDate.Equal(loan.ReturnDate))
+	})
+}
+
+func TestLoanService_BorrowerLimits(t *testing.T) {
+	memberRepo := repositories.NewMemberRepository()
+	chargeRepo := repositories.NewChargeRepository()
+	auditService := NewAuditService(repositories.NewAuditRepository())
+	loanService := NewLoanService(repositories.NewLoanRepository(), repositories.NewBookRepository(), chargeRepo, repositories.NewBranchRepository(), repositories.NewTransferRepository(), memberRepo, repositories.NewLoanEventRepository())
+	loanService.Auditor = auditService
+	policy := models.DefaultLoanPolicy
+	policy.MaxLoans = 1
+	policy.FineLimit = 500
+	ctx := tenant.NewContext(context.Background(), &models.Tenant{Id: 1, DefaultBranchId: models.DefaultBranchId, Policy: policy})
+	patron := auth.NewContext(ctx, &models.Principal{Type: models.PrincipalTypePatron, Role: models.RolePatron, Id: 1, Name: "user1"})
+	librarian := auth.NewContext(ctx, &models.Principal{Type: models.PrincipalTypeApiKey, Role: models.RoleLibrarian, Id: 1, Name: "desk"})
+
+	_, err := loanService.BorrowBook(ctx, "book1", "user1")
+	assert.NoError(t, err)
+
+	t.Run("Borrowers are refused past the loan limit", func(t *testing.T) {
+		_, err := loanService.BorrowBook(patron, "book2", "user1")
+		assert.ErrorIs(t, err, ErrBorrowingBlocked)
+		assert.ErrorIs(t, err, ErrLoanLimitReached)
+		var blockedErr *BorrowingBlockedError
+		assert.ErrorAs(t, err, &blockedErr)
+		assert.Equal(t, models.BlockReasonLoanLimit, blockedErr.Reason)
+	})
+
+	t.Run("Only librarians may override a block, and the override is audited", func(t *testing.T) {
+		_, err := loanService.BorrowBookAtBranch(patron, "book2", "user1", 0, true)
+		assert.ErrorIs(t, err, auth.ErrForbidden)
+
+		_, err = loanService.BorrowBookAtBranch(librarian, "book2", "user1", 0, true)
+		assert.NoError(t, err)
+		page, err := auditService.ListAuditEntries(ctx, &models.AuditFilter{Action: models.AuditActionBlockOverridden, Limit: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, page.Total)
+		assert.Equal(t, "desk", page.Entries[0].ActorName)
+		assert.Equal(t, []string{models.BlockReasonLoanLimit}, page.Entries[0].Changes[0].After)
+	})
+
+	t.Run("Borrowers owing more than the fine limit may neither borrow nor renew", func(t *testing.T) {
+		_, err := chargeRepo.CreateCharge(ctx, &models.Charge{BorrowerName: "user2", Type: models.ChargeTypeOverdue, Amount: 600, Status: models.ChargeStatusOutstanding})
+		assert.NoError(t, err)
+		_, err = loanService.BorrowBook(ctx, "book3", "user2")
+		assert.ErrorIs(t, err, ErrFineLimitExceeded)
+		_, err = loanService.ExtendLoan(ctx, "book1", "user1")
+		assert.NoError(t, err)
+	})
+
+	t.Run("Suspended and expired members are refused", func(t *testing.T) {
+		suspended := true
+		_, err := memberRepo.UpdateMemberStanding(ctx, 1, &models.MemberStanding{Suspended: &suspended})
+		assert.NoError(t, err)
+		_, err = loanService.ExtendLoan(ctx, "book1", "user1")
+		assert.ErrorIs(t, err, ErrAccountSuspended)
+
+		expiresOn := time.Now().AddDate(0, 0, -2).Format(models.DateLayout)
+		suspended = false
+		_, err = memberRepo.UpdateMemberStanding(ctx, 1, &models.MemberStanding{Suspended: &suspended, ExpiresOn: &expiresOn})
+		assert.NoError(t, err)
+		_, err = loanService.ExtendLoan(ctx, "book1", "user1")
+		assert.ErrorIs(t, err, ErrAccountExpired)
+		assert.Contains(t, err.Error(), "account expired on "+expiresOn)
+	})
+}
+
+func TestLoanService_LoanTypes(t *testing.T) {
+	travelClock := clock.NewAdjustable(clock.System{})
+	travelClock.Freeze()
+	//a Monday afternoon, the branch closes at 17:00 on weekdays
+	travelClock.Set(time.Date(2025, 3, 3, 15, 30, 0, 0, time.UTC))
+	bookRepo, calendarRepo := repositories.NewBookRepository(), repositories.NewCalendarRepository()
+	loanService := NewLoanService(repositories.NewLoanRepository(), bookRepo, repositories.NewChargeRepository(), repositories.NewBranchRepository(), repositories.NewTransferRepository(), repositories.NewMemberRepository(), repositories.NewLoanEventRepository())
+	loanService.Calendar = NewCalendarService(calendarRepo, loanService.BranchRepository)
+	loanService.Clock = travelClock
+	ctx := context.Background()
+	hours := make([]models.OpeningHours, 0)
+	for _, weekday := range models.Weekdays[1:6] {
+		hours = append(hours, models.OpeningHours{Weekday: weekday, Opens: "09:00", Closes: "17:00"})
+	}
+	assert.NoError(t, calendarRepo.ReplaceOpeningHours(ctx, 1, hours))
+
+	t.Run("Short loans are due after their hours, when the branch is open", func(t *testing.T) {
+		_, err := bookRepo.UpdateBookLoanType(ctx, "book1", models.LoanTypeShortLoan, 3)
+		assert.NoError(t, err)
+
+		loan, err := loanService.BorrowBook(ctx, "book1", "user1")
+		assert.NoError(t, err)
+		assert.Equal(t, time.Date(2025, 3, 4, 9, 0, 0, 0, time.UTC), loan.ReturnDate)
+
+		extended, err := loanService.ExtendLoan(ctx, "book1", "user1")
+		assert.NoError(t, err)
+		assert.Equal(t, time.Date(2025, 3, 4, 12, 0, 0, 0, time.UTC), extended.ReturnDate)
+	})
+
+	t.Run("Reference-only and non-circulating books are not lent", func(t *testing.T) {
+		_, err := bookRepo.UpdateBookLoanType(ctx, "book2", models.LoanTypeReferenceOnly, 0)
+		assert.NoError(t, err)
+		_, err = loanService.BorrowBook(ctx, "book2", "user1")
+		assert.ErrorIs(t, err, ErrReferenceOnly)
+
+		_, err = bookRepo.UpdateBookLoanType(ctx, "book3", models.LoanTypeNonCirculating, 0)
+		assert.NoError(t, err)
+		_, err = loanService.BorrowBook(ctx, "book3", "user1")
+		assert.ErrorIs(t, err, ErrNonCirculating)
+	})
+
+	t.Run("Loans of books no longer lent are not extended", func(t *testing.T) {
+		_, err := bookRepo.UpdateBookLoanType(ctx, "book1", models.LoanTypeReferenceOnly, 0)
+		assert.NoError(t, err)
+		_, err = loanService.ExtendLoan(ctx, "book1", "user1")
+		assert.ErrorIs(t, err, ErrReferenceOnly)
+	})
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/aftaab60/e-library-api/internal/auth"
+	"github.com/aftaab60/e-library-api/internal/clock"
+	"github.com/aftaab60/e-library-api/internal/db_manager"
+	"github.com/aftaab60/e-library-api/internal/tenant"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"log"
+	"math"
+	"time"
+)
+
+type MemberService struct {
+	MemberRepository repositories.IMemberRepository
+	LoanRepository   repositories.ILoanRepository
+	BookRepository   repositories.IBookRepository
+	//BranchRepository tells the time zones of the branches, loans are rendered in the one of their home branch
+	BranchRepository repositories.IBranchRepository
+	//LoanEventRepository keeps the loan streams, whose borrower names are anonymized along with the loans
+	LoanEventRepository repositories.ILoanEventRepository
+	//Clock tells how long is left on loans, the wall clock by default
+	Clock clock.Clock
+	TxDB  db_manager.ItxDB
+	//Auditor records changes of the standing of members, nil records nothing
+	Auditor *AuditService
+}
+
+// NewMemberService uses interface so that we can switch between in-memory and actual pgsql repo data easily
+func NewMemberService(memberRepository repositories.IMemberRepository, loanRepository repositories.ILoanRepository, bookRepository repositories.IBookRepository,
+	branchRepository repositories.IBranchRepository, loanEventRepository repositories.ILoanEventRepository) MemberService {
+	return MemberService{
+		MemberRepository:    memberRepository,
+		LoanRepository:      loanRepository,
+		BookRepository:      bookRepository,
+		BranchRepository:    branchRepository,
+		LoanEventRepository: loanEventRepository,
+		Clock:               clock.System{},
+	}
+}
+
+func (s *MemberService) GetMember(ctx context.Context, id int) (*models.Member, error) {
+	if err := auth.AuthorizeMember(ctx, id); err != nil {
+		return nil, err
+	}
+	member, err := s.MemberRepository.GetMember(ctx, id)
+	if err != nil {
+		if errors.Is(err, repositories.ErrMemberNotFound) {
+			log.Printf("member '%d' not found", id)
+		} else {
+			log.Printf("error getting member from repository: %v", err)
+		}
+		return nil, err
+	}
+	return member, nil
+}
+
+// CreateMember registers a member, history is retained unless the member opts out later
+func (s *MemberService) CreateMember(ctx context.Context, request *models.MemberRequest) (*models.Member, error) {
+	if err := auth.Authorize(ctx, models.PermissionMemberManage); err != nil {
+		return nil, err
+	}
+	member := models.NewMember(request.Name, "")
+	member.CardNumber = request.CardNumber
+	member, err := s.MemberRepository.CreateMember(ctx, member)
+	if err != nil {
+		log.Printf("error creating member from repository: %v", err)
+		return nil, err
+	}
+	return member, nil
+}
+
+// UpdatePreferences stores the member's privacy and notification preferences. Opting out of history retention anonymizes
+// the existing history at once.
+func (s *MemberService) UpdatePreferences(ctx context.Context, id int, preferences *models.MemberPreferences) (*models.Member, error) {
+	if _, err := s.GetMember(ctx, id); err != nil {
+		return nil, err
+	}
+	member, err := s.MemberRepository.UpdateMemberPreferences(ctx, id, preferences)
+	if err != nil {
+		log.Printf("error updating member from repository: %v", err)
+		return nil, err
+	}
+	if preferences.RetainHistory != nil && !member.RetainHistory {
+		count, err := s.LoanRepository.AnonymizeLoans(ctx, member.Name)
+		if err != nil {
+			log.Printf("error anonymizing loans of member %d: %v", member.Id, err)
+			return nil, err
+		}
+		if _, err := s.LoanEventRepository.AnonymizeLoanEvents(ctx, member.Name); err != nil {
+			log.Printf("error anonymizing loan events of member %d: %v", member.Id, err)
+			return nil, err
+		}
+		log.Printf("member %d opted out of history retention, %d loans anonymized\n", member.Id, count)
+	}
+	return member, nil
+}
+
+// UpdateStanding suspends or reinstates a member, or changes when the membership expires
+func (s *MemberService) UpdateStanding(ctx context.Context, id int, standing *models.MemberStanding) (*models.Member, error) {
+	if err := auth.Authorize(ctx, models.PermissionMemberManage); err != nil {
+		return nil, err
+	}
+	before, err := s.GetMember(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	var member *models.Member
+	if err = db_manager.WrapInTransaction(ctx, s.TxDB, func(ctx context.Context) error {
+		if member, err = s.MemberRepository.UpdateMemberStanding(ctx, id, standing); err != nil {
+			log.Printf("error updating member from repository: %v", err)
+			return err
+		}
+		changes := models.Diff(models.AuditEntity(models.AuditEntityMember, id), before, member)
+		return s.Auditor.Record(ctx, models.AuditActionStandingUpdated, models.AuditEntityMember, id, changes)
+	}, nil); err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+// GetMemberLoans returns current and past loans of a member, most useful with the filter's status and pagination
+func (s *MemberService) GetMemberLoans(ctx context.Context, id int, filter *models.LoanFilter) (*models.MemberLoanPage, error) {
+	member, err := s.GetMember(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.Clock.Now()
+	filter.BorrowerName, filter.AsOf = member.Name, now
+	loans, total, err := s.LoanRepository.ListLoans(ctx, filter)
+	if err != nil {
+		log.Printf("error listing loans from repository: %v", err)
+		return nil, err
+	}
+
+	titles := make(map[int]string)
+	locations := make(map[int]*time.Location)
+	memberLoans := make([]models.MemberLoan, 0, len(loans))
+	for _, loan := range loans {
+		title, ok := titles[loan.BookId]
+		if !ok {
+			book, err := s.BookRepository.GetBookById(ctx, loan.BookId)
+			if err != nil && !errors.Is(err, repositories.ErrBookNotFound) {
+				log.Printf("error getting book: %v", err)
+				return nil, err
+			}
+			if book != nil {
+				title = book.Title
+			}
+			titles[loan.BookId] = title
+		}
+		loc, err := loanLocation(ctx, s.BranchRepository, &loan, locations)
+		if err != nil {
+			return nil, err
+		}
+		memberLoans = append(memberLoans, newMemberLoan(&loan, title, now, loc))
+	}
+
+	return &models.MemberLoanPage{
+		Member: *member,
+		Loans:  memberLoans,
+		Total:  total,
+		Limit:  filter.Limit,
+		Offset: filter.Offset,
+	}, nil
+}
+
+// loanLocation is the time zone of the home branch of a loan, locations caches them across loans
+func loanLocation(ctx context.Context, branchRepository repositories.IBranchRepository, loan *models.Loan,
+	locations map[int]*time.Location) (*time.Location, error) {
+	branchId := loan.BranchId
+	if branchId == 0 {
+		branchId = tenant.DefaultBranchId(ctx)
+	}
+	if loc, ok := locations[branchId]; ok {
+		return loc, nil
+	}
+	branch, err := branchRepository.GetBranch(ctx, branchId)
+	if err != nil {
+		log.Printf("error getting branch: %v", err)
+		return nil, err
+	}
+	locations[branchId] = branch.Location()
+	return locations[branchId], nil
+}
+
+// newMemberLoan renders a loan for its borrower, with its dates in loc
+func newMemberLoan(loan *models.Loan, title string, now time.Time, loc *time.Location) models.MemberLoan {
+	memberLoan := models.MemberLoan{
+		LoanId:     loan.Id,
+		BookId:     loan.BookId,
+		Title:      title,
+		LoanDate:   loan.LoanDate.In(loc),
+		ReturnDate: loan.ReturnDate.In(loc),
+		Status:     loan.Status,
+		BranchId:   loan.BranchId,
+		TimeZone:   loc.String(),
+	}
+	switch {
+	case loan.Status == models.LoanStatusReturned:
+		memberLoan.DueStatus = models.DueStatusReturned
+	case loan.IsReturn:
+		memberLoan.DueStatus = models.DueStatusClosed
+	default:
+		//whole days left, rounded up so that a loan due later today has 1 day remaining
+		daysRemaining := int(math.Ceil(loan.ReturnDate.Sub(now).Hours() / 24))
+		memberLoan.DaysRemaining = &daysRemaining
+		if loan.ReturnDate.Before(now) {
+			memberLoan.DueStatus = models.DueStatusOverdue
+		} else if daysRemaining <= models.DueSoonDays {
+			memberLoan.DueStatus = models.DueStatusDueSoon
+		} else {
+			memberLoan.DueStatus = models.DueStatusOnLoan
+		}
+	}
+	return memberLoan
+}
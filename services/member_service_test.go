@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemberService_GetMemberLoans(t *testing.T) {
+	bookRepo := repositories.NewBookRepository()
+	loanRepo := repositories.NewLoanRepository()
+	memberRepo := repositories.NewMemberRepository()
+	loanEventRepo := repositories.NewLoanEventRepository()
+	loanService := NewLoanService(loanRepo, bookRepo, repositories.NewChargeRepository(), repositories.NewBranchRepository(), repositories.NewTransferRepository(), memberRepo, loanEventRepo)
+	memberService := NewMemberService(memberRepo, loanRepo, bookRepo, repositories.NewBranchRepository(), loanEventRepo)
+	ctx := context.Background()
+
+	_, err := loanService.BorrowBook(ctx, "book1", "user1")
+	assert.NoError(t, err)
+	_, err = loanService.BorrowBook(ctx, "book2", "user1")
+	assert.NoError(t, err)
+	err = loanService.ReturnBook(ctx, "book2", "user1")
+	assert.NoError(t, err)
+
+	t.Run("List current and past loans", func(t *testing.T) {
+		page, err := memberService.GetMemberLoans(ctx, 1, &models.LoanFilter{Limit: 20})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, page.Total)
+		for _, loan := range page.Loans {
+			switch loan.Title {
+			case "book1":
+				assert.Equal(t, models.DueStatusOnLoan, loan.DueStatus)
+				//due at the end of the 28th day from today, and today isn't over yet
+				assert.Equal(t, 29, *loan.DaysRemaining)
+			case "book2":
+				assert.Equal(t, models.DueStatusReturned, loan.DueStatus)
+				assert.Nil(t, loan.DaysRemaining)
+			default:
+				t.Errorf("unexpected loan of %q", loan.Title)
+			}
+		}
+	})
+
+	t.Run("Filter loans by status", func(t *testing.T) {
+		page, err := memberService.GetMemberLoans(ctx, 1, &models.LoanFilter{Status: models.LoanStatusActive, Limit: 20})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, page.Total)
+		assert.Equal(t, "book1", page.Loans[0].Title)
+	})
+
+	t.Run("Fail to list loans of unknown member", func(t *testing.T) {
+		_, err := memberService.GetMemberLoans(ctx, 100, &models.LoanFilter{Limit: 20})
+		assert.Equal(t, repositories.ErrMemberNotFound, err)
+	})
+}
+
+func TestMemberService_HistoryOptOut(t *testing.T) {
+	bookRepo := repositories.NewBookRepository()
+	loanRepo := repositories.NewLoanRepository()
+	memberRepo := repositories.NewMemberRepository()
+	loanEventRepo := repositories.NewLoanEventRepository()
+	loanService := NewLoanService(loanRepo, bookRepo, repositories.NewChargeRepository(), repositories.NewBranchRepository(), repositories.NewTransferRepository(), memberRepo, loanEventRepo)
+	memberService := NewMemberService(memberRepo, loanRepo, bookRepo, repositories.NewBranchRepository(), loanEventRepo)
+	ctx := context.Background()
+
+	_, err := loanService.BorrowBook(ctx, "book1", "user2")
+	assert.NoError(t, err)
+	err = loanService.ReturnBook(ctx, "book1", "user2")
+	assert.NoError(t, err)
+	_, err = loanService.BorrowBook(ctx, "book2", "user2")
+	assert.NoError(t, err)
+
+	retainHistory := false
+	t.Run("Opting out anonymizes returned loans", func(t *testing.T) {
+		member, err := memberService.UpdatePreferences(ctx, 2, &models.MemberPreferences{RetainHistory: &retainHistory})
+		assert.NoError(t, err)
+		assert.False(t, member.RetainHistory)
+
+		page, err := memberService.GetMemberLoans(ctx, 2, &models.LoanFilter{Limit: 20})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, page.Total)
+		assert.Equal(t, models.LoanStatusActive, page.Loans[0].Status)
+	})
+
+	t.Run("Later returns are anonymized", func(t *testing.T) {
+		err := loanService.ReturnBook(ctx, "book2", "user2")
+		assert.NoError(t, err)
+
+		page, err := memberService.GetMemberLoans(ctx, 2, &models.LoanFilter{Limit: 20})
+		assert.NoError(t, err)
+		assert.Equal(t, 0, page.Total)
+
+		_, total, err := loanRepo.ListLoans(ctx, &models.LoanFilter{BorrowerName: models.AnonymizedBorrower, Limit: 20})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, total)
+
+		events, err := loanEventRepo.ListLoanEvents(ctx, 0, 20)
+		assert.NoError(t, err)
+		assert.Len(t, events, 4)
+		for _, event := range events {
+			assert.NotEqual(t, "user2", event.Data.BorrowerName)
+		}
+	})
+}
@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aftaab60/e-library-api/internal/auth"
+	"github.com/aftaab60/e-library-api/internal/oidc"
+	"github.com/aftaab60/e-library-api/internal/tenant"
+	"github.com/aftaab60/e-library-api/models"
+	"log"
+	"strings"
+	"time"
+)
+
+// DefaultOidcStateTTL is how long a staff member has to log in at the identity provider
+const DefaultOidcStateTTL = 10 * time.Minute
+
+// OidcService logs staff in through an external identity provider and issues them the API's own session tokens.
+// The identity provider is shared by every tenant, so staff are only logged in to the tenants their ID token names.
+type OidcService struct {
+	Provider    *oidc.Provider
+	AuthService AuthService
+	//RoleClaim names the ID token claim holding the staff groups or roles, either a string or an array of strings
+	RoleClaim string
+	//RoleMapping maps values of RoleClaim to roles, the most privileged match wins
+	RoleMapping map[string]models.Role
+	//TenantClaim names the ID token claim holding the slugs of the tenants the staff member works for, either a
+	//string or an array of strings
+	TenantClaim string
+	StateTTL    time.Duration
+}
+
+func NewOidcService(provider *oidc.Provider, authService AuthService, roleClaim string, roleMapping map[string]models.Role,
+	tenantClaim string) OidcService {
+	return OidcService{
+		Provider:    provider,
+		AuthService: authService,
+		RoleClaim:   roleClaim,
+		RoleMapping: roleMapping,
+		TenantClaim: tenantClaim,
+		StateTTL:    DefaultOidcStateTTL,
+	}
+}
+
+var (
+	ErrInvalidOidcState = errors.New("invalid or expired login state")
+	ErrOidcLoginFailed  = errors.New("identity provider login failed")
+)
+
+// ParseRoleMapping parses a mapping like "library-admins=admin,library-staff=librarian".
+// Only staff roles can be mapped, patrons log in with a password.
+func ParseRoleMapping(mapping string) (map[string]models.Role, error) {
+	roles := make(map[string]models.Role)
+	for _, pair := range strings.Split(mapping, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		value, role, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid role mapping %q", pair)
+		}
+		r := models.Role(strings.TrimSpace(role))
+		if r != models.RoleLibrarian && r != models.RoleAdmin {
+			return nil, fmt.Errorf("invalid role %q in role mapping, must be librarian or admin", role)
+		}
+		roles[strings.TrimSpace(value)] = r
+	}
+	return roles, nil
+}
+
+// oidcState travels through the identity provider and back, signed so that it can't be forged
+type oidcState struct {
+	Nonce     string `json:"n"`
+	TenantId  int    `json:"t"`
+	ExpiresAt int64  `json:"e"`
+}
+
+func (s *OidcService) signState(state oidcState) (string, error) {
+	payload, err := json.Marshal(state)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, s.AuthService.Secret)
+	mac.Write([]byte(encoded))
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+func (s *OidcService) verifyState(ctx context.Context, signed string) (*oidcState, error) {
+	encoded, signature, ok := strings.Cut(signed, ".")
+	if !ok {
+		return nil, ErrInvalidOidcState
+	}
+	mac := hmac.New(sha256.New, s.AuthService.Secret)
+	mac.Write([]byte(encoded))
+	if !hmac.Equal([]byte(base64.RawURLEncoding.EncodeToString(mac.Sum(nil))), []byte(signature)) {
+		return nil, ErrInvalidOidcState
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidOidcState
+	}
+	var state oidcState
+	if err := json.Unmarshal(payload, &state); err != nil {
+		return nil, ErrInvalidOidcState
+	}
+	if time.Now().Unix() >= state.ExpiresAt || state.TenantId != tenant.Id(ctx) {
+		return nil, ErrInvalidOidcState
+	}
+	return &state, nil
+}
+
+// LoginURL starts a login: it returns the identity provider URL to send the staff member to, and the state to
+// remember in the user agent. The callback only accepts the state back from the same user agent.
+func (s *OidcService) LoginURL(ctx context.Context) (string, string, error) {
+	nonce, err := auth.RandomId(16)
+	if err != nil {
+		return "", "", err
+	}
+	state, err := s.signState(oidcState{Nonce: nonce, TenantId: tenant.Id(ctx), ExpiresAt: time.Now().Add(s.StateTTL).Unix()})
+	if err != nil {
+		return "", "", err
+	}
+	loginURL, err := s.Provider.AuthCodeURL(ctx, state, nonce)
+	if err != nil {
+		log.Printf("error building identity provider login url: %v", err)
+		return "", "", err
+	}
+	return loginURL, state, nil
+}
+
+// Callback completes a login: it redeems the code, verifies the ID token and maps its claims to a staff role.
+// expectedState is the state remembered by the user agent when the login started.
+func (s *OidcService) Callback(ctx context.Context, code string, state string, expectedState string) (*models.IssuedToken, error) {
+	if state == "" || !hmac.Equal([]byte(state), []byte(expectedState)) {
+		return nil, ErrInvalidOidcState
+	}
+	verifiedState, err := s.verifyState(ctx, state)
+	if err != nil {
+		return nil, err
+	}
+
+	rawIdToken, err := s.Provider.Exchange(ctx, code)
+	if err != nil {
+		log.Printf("error redeeming authorization code: %v", err)
+		return nil, errors.Join(ErrOidcLoginFailed, err)
+	}
+	idToken, err := s.Provider.Verify(ctx, rawIdToken, verifiedState.Nonce, time.Now())
+	if err != nil {
+		log.Printf("error verifying id token: %v", err)
+		return nil, errors.Join(ErrOidcLoginFailed, err)
+	}
+
+	if !s.ForTenant(ctx, idToken.Claims) {
+		return nil, &auth.ForbiddenError{Reason: "identity provider grants no access to this library"}
+	}
+	role, ok := s.MapRole(idToken.Claims)
+	if !ok {
+		return nil, &auth.ForbiddenError{Reason: "identity provider grants no library staff role"}
+	}
+	name := idToken.Name
+	if name == "" {
+		name = idToken.Email
+	}
+	if name == "" {
+		name = idToken.Subject
+	}
+	log.Printf("staff %s logged in through identity provider as %s\n", idToken.Subject, role)
+	return s.AuthService.IssueStaffToken(ctx, "oidc:"+idToken.Subject, name, role)
+}
+
+// claimValues reads a claim holding space separated strings or an array of strings
+func claimValues(claims map[string]any, claim string) []string {
+	var values []string
+	switch v := claims[claim].(type) {
+	case string:
+		values = strings.Fields(v)
+	case []any:
+		for _, item := range v {
+			if str, ok := item.(string); ok {
+				values = append(values, str)
+			}
+		}
+	}
+	return values
+}
+
+// ForTenant tells whether the tenant claim of an ID token names the tenant in context
+func (s *OidcService) ForTenant(ctx context.Context, claims map[string]any) bool {
+	t := tenant.FromContext(ctx)
+	if t == nil {
+		return false
+	}
+	for _, slug := range claimValues(claims, s.TenantClaim) {
+		if slug == t.Slug {
+			return true
+		}
+	}
+	return false
+}
+
+// MapRole maps the role claim of an ID token to the most privileged staff role
+func (s *OidcService) MapRole(claims map[string]any) (models.Role, bool) {
+	var mapped models.Role
+	for _, value := range claimValues(claims, s.RoleClaim) {
+		role, ok := s.RoleMapping[value]
+		if !ok {
+			continue
+		}
+		if role == models.RoleAdmin || mapped == "" {
+			mapped = role
+		}
+	}
+	return mapped, mapped != ""
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aftaab60/e-library-api/internal/auth"
+	"github.com/aftaab60/e-library-api/internal/oidc"
+	"github.com/aftaab60/e-library-api/internal/oidc/oidctest"
+	"github.com/aftaab60/e-library-api/internal/tenant"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestOidcService(t *testing.T) (OidcService, *oidctest.Server) {
+	idp := oidctest.NewServer("e-library", "client-secret")
+	t.Cleanup(idp.Close)
+	provider := oidc.NewProvider(oidc.Config{
+		Issuer:       idp.URL,
+		ClientId:     "e-library",
+		ClientSecret: "client-secret",
+		RedirectURL:  "http://localhost:3000/auth/oidc/callback",
+	}, idp.Client())
+	authService := NewAuthService(repositories.NewApiKeyRepository(), repositories.NewTokenRepository(), repositories.NewMemberRepository(), []byte("test-secret"))
+	roleMapping, err := ParseRoleMapping("library-staff=librarian,library-admins=admin")
+	assert.NoError(t, err)
+	return NewOidcService(provider, authService, "groups", roleMapping, "tenants"), idp
+}
+
+func TestOidcService_Callback(t *testing.T) {
+	oidcService, idp := newTestOidcService(t)
+	ctx := tenant.NewContext(context.Background(), &models.Tenant{Id: 1, Slug: "default"})
+
+	login := func(claims map[string]any) (code string, state string) {
+		loginURL, state, err := oidcService.LoginURL(ctx)
+		assert.NoError(t, err)
+		if _, ok := claims["tenants"]; !ok {
+			claims["tenants"] = []any{"default"}
+		}
+		code, returnedState, err := idp.Login(loginURL, claims)
+		assert.NoError(t, err)
+		assert.Equal(t, state, returnedState)
+		return code, state
+	}
+
+	t.Run("Librarian logs in and authenticates as staff", func(t *testing.T) {
+		code, state := login(map[string]any{"sub": "alice", "name": "Alice", "groups": []any{"everyone", "library-staff"}})
+		token, err := oidcService.Callback(ctx, code, state, state)
+		assert.NoError(t, err)
+
+		principal, err := oidcService.AuthService.Authenticate(ctx, "", token.Token)
+		assert.NoError(t, err)
+		assert.Equal(t, models.PrincipalTypeStaff, principal.Type)
+		assert.Equal(t, models.RoleLibrarian, principal.Role)
+		assert.Equal(t, "Alice", principal.Name)
+		assert.True(t, principal.Can(models.PermissionLoanManage))
+		assert.False(t, principal.Can(models.PermissionConfigManage))
+	})
+
+	t.Run("Admin group takes precedence", func(t *testing.T) {
+		code, state := login(map[string]any{"sub": "bob", "groups": []any{"library-staff", "library-admins"}})
+		token, err := oidcService.Callback(ctx, code, state, state)
+		assert.NoError(t, err)
+
+		principal, err := oidcService.AuthService.Authenticate(ctx, "", token.Token)
+		assert.NoError(t, err)
+		assert.Equal(t, models.RoleAdmin, principal.Role)
+		assert.Equal(t, "bob", principal.Name)
+	})
+
+	t.Run("Fail without a library role", func(t *testing.T) {
+		code, state := login(map[string]any{"sub": "carol", "groups": []any{"everyone"}})
+		_, err := oidcService.Callback(ctx, code, state, state)
+		assert.ErrorIs(t, err, auth.ErrForbidden)
+	})
+
+	t.Run("Fail for a tenant the staff member doesn't work for", func(t *testing.T) {
+		code, state := login(map[string]any{"sub": "dave", "groups": "library-admins", "tenants": "other"})
+		_, err := oidcService.Callback(ctx, code, state, state)
+		assert.ErrorIs(t, err, auth.ErrForbidden)
+
+		code, state = login(map[string]any{"sub": "dave", "groups": "library-admins", "tenants": []any{}})
+		_, err = oidcService.Callback(ctx, code, state, state)
+		assert.ErrorIs(t, err, auth.ErrForbidden)
+	})
+
+	t.Run("Fail on a state from another user agent", func(t *testing.T) {
+		code, state := login(map[string]any{"sub": "alice", "groups": "library-staff"})
+		_, otherState, err := oidcService.LoginURL(ctx)
+		assert.NoError(t, err)
+		_, err = oidcService.Callback(ctx, code, state, otherState)
+		assert.Equal(t, ErrInvalidOidcState, err)
+	})
+
+	t.Run("Fail on a tampered state", func(t *testing.T) {
+		code, state := login(map[string]any{"sub": "alice", "groups": "library-staff"})
+		tampered := state[:len(state)-2] + "xx"
+		_, err := oidcService.Callback(ctx, code, tampered, tampered)
+		assert.Equal(t, ErrInvalidOidcState, err)
+	})
+
+	t.Run("Fail on a token for another client", func(t *testing.T) {
+		code, state := login(map[string]any{"sub": "alice", "groups": "library-staff", "aud": "other-client"})
+		_, err := oidcService.Callback(ctx, code, state, state)
+		assert.ErrorIs(t, err, ErrOidcLoginFailed)
+		assert.ErrorIs(t, err, oidc.ErrInvalidIdToken)
+	})
+
+	t.Run("Fail on a replayed code", func(t *testing.T) {
+		code, state := login(map[string]any{"sub": "alice", "groups": "library-staff"})
+		_, err := oidcService.Callback(ctx, code, state, state)
+		assert.NoError(t, err)
+		_, err = oidcService.Callback(ctx, code, state, state)
+		assert.ErrorIs(t, err, ErrOidcLoginFailed)
+	})
+}
+
+func TestParseRoleMapping(t *testing.T) {
+	roles, err := ParseRoleMapping(" staff = librarian ,admins=admin,")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]models.Role{"staff": models.RoleLibrarian, "admins": models.RoleAdmin}, roles)
+
+	_, err = ParseRoleMapping("everyone=patron")
+	assert.Error(t, err)
+	_, err = ParseRoleMapping("staff")
+	assert.Error(t, err)
+}
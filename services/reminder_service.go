@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/aftaab60/e-library-api/internal/mail"
+	"github.com/aftaab60/e-library-api/internal/tenant"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"log"
+	"time"
+)
+
+// reminderBatchSize is how many active loans a reminder run reads at once
+const reminderBatchSize = 100
+
+// ReminderTemplates are the default templates of notifications, executed with ReminderData. Dates are in the time zone
+// of the home branch of the loan, which is printed along.
+var ReminderTemplates = map[models.NotificationKind]mail.Template{
+	models.NotificationDueSoon: mail.MustParseTemplate(string(models.NotificationDueSoon),
+		`{{.Library}}: "{{.Loan.Title}}" is due {{if eq .DaysRemaining 1}}tomorrow{{else}}in {{.DaysRemaining}} days{{end}}`,
+		`Hello {{.Member.Name}},
+
+"{{.Loan.Title}}" is due back on {{.Loan.ReturnDate.Format "Monday 2 January 2006, 15:04 MST"}}.
+Please return or extend it before then.
+
+{{.Library}}
+`),
+	models.NotificationOverdue: mail.MustParseTemplate(string(models.NotificationOverdue),
+		`{{.Library}}: "{{.Loan.Title}}" is overdue`,
+		`Hello {{.Member.Name}},
+
+"{{.Loan.Title}}" was due back on {{.Loan.ReturnDate.Format "Monday 2 January 2006, 15:04 MST"}} and is now {{.DaysOverdue}} day{{if ne .DaysOverdue 1}}s{{end}} overdue.
+Please return it as soon as possible.
+
+{{.Library}}
+`),
+}
+
+// ReminderData is what notification templates are executed with
+type ReminderData struct {
+	Library string
+	Member  models.Member
+	Loan    models.MemberLoan
+	//DaysRemaining is set for due soon reminders, DaysOverdue for overdue notices
+	DaysRemaining int
+	DaysOverdue   int
+}
+
+type ReminderService struct {
+	LoanRepository   repositories.ILoanRepository
+	BookRepository   repositories.IBookRepository
+	MemberRepository repositories.IMemberRepository
+	//BranchRepository tells the time zones of the branches, due dates are written in the one of the loan's home branch
+	BranchRepository       repositories.IBranchRepository
+	NotificationRepository repositories.INotificationRepository
+	//Notifier delivers notifications, see mail.Sender for the implementations
+	Notifier  mail.Sender
+	Templates map[models.NotificationKind]mail.Template
+}
+
+func NewReminderService(loanRepository repositories.ILoanRepository, bookRepository repositories.IBookRepository, memberRepository repositories.IMemberRepository,
+	branchRepository repositories.IBranchRepository, notificationRepository repositories.INotificationRepository, notifier mail.Sender) *ReminderService {
+	return &ReminderService{
+		LoanRepository:         loanRepository,
+		BookRepository:         bookRepository,
+		MemberRepository:       memberRepository,
+		BranchRepository:       branchRepository,
+		NotificationRepository: notificationRepository,
+		Notifier:               notifier,
+		Templates:              ReminderTemplates,
+	}
+}
+
+// SendReminders notifies members of the tenant in context of their loans that are due soon or overdue at now.
+// Each loan gets one reminder and one overdue notice per due date, whatever the number of runs. Members who turned
+// the notification off in their preferences, and borrowers without a member email, aren't notified.
+// A notification that fails to send is counted and tried again on the next run.
+func (s *ReminderService) SendReminders(ctx context.Context, now time.Time) (*models.ReminderReport, error) {
+	report := &models.ReminderReport{}
+	titles := make(map[int]string)
+	members := make(map[string]*models.Member)
+	locations := make(map[int]*time.Location)
+	for offset := 0; ; offset += reminderBatchSize {
+		loans, _, err := s.LoanRepository.ListLoans(ctx, &models.LoanFilter{Status: models.LoanStatusActive, Limit: reminderBatchSize, Offset: offset})
+		if err != nil {
+			log.Printf("error listing loans from repository: %v", err)
+			return nil, err
+		}
+		for i := range loans {
+			if err := s.remind(ctx, &loans[i], now, titles, members, locations, report); err != nil {
+				return nil, err
+			}
+		}
+		if len(loans) < reminderBatchSize {
+			return report, nil
+		}
+	}
+}
+
+// remind sends the notification a loan is due at now, if any. titles, members and locations cache lookups across loans.
+func (s *ReminderService) remind(ctx context.Context, loan *models.Loan, now time.Time, titles map[int]string, members map[string]*models.Member,
+	locations map[int]*time.Location, report *models.ReminderReport) error {
+	title, ok := titles[loan.BookId]
+	if !ok {
+		book, err := s.BookRepository.GetBookById(ctx, loan.BookId)
+		if err != nil && !errors.Is(err, repositories.ErrBookNotFound) {
+			return err
+		}
+		if book != nil {
+			title = book.Title
+		}
+		titles[loan.BookId] = title
+	}
+	loc, err := loanLocation(ctx, s.BranchRepository, loan, locations)
+	if err != nil {
+		return err
+	}
+	data := ReminderData{Library: tenantName(ctx), Loan: newMemberLoan(loan, title, now, loc)}
+	var kind models.NotificationKind
+	switch data.Loan.DueStatus {
+	case models.DueStatusDueSoon:
+		kind, data.DaysRemaining = models.NotificationDueSoon, *data.Loan.DaysRemaining
+	case models.DueStatusOverdue:
+		kind, data.DaysOverdue = models.NotificationOverdue, max(1, -*data.Loan.DaysRemaining)
+	default:
+		return nil
+	}
+
+	member, ok := members[loan.BorrowerName]
+	if !ok {
+		var err error
+		member, err = s.MemberRepository.GetMemberByName(ctx, loan.BorrowerName)
+		if err != nil && !errors.Is(err, repositories.ErrMemberNotFound) {
+			return err
+		}
+		members[loan.BorrowerName] = member
+	}
+	if member == nil || member.Email == "" || (kind == models.NotificationDueSoon && !member.DueReminders) ||
+		(kind == models.NotificationOverdue && !member.OverdueNotices) {
+		report.Skipped++
+		return nil
+	}
+	data.Member = *member
+
+	//the notification is recorded before it is sent, so that concurrent runs can't both send it
+	notification, err := s.NotificationRepository.CreateNotification(ctx, &models.Notification{
+		LoanId:    loan.Id,
+		MemberId:  member.Id,
+		Kind:      kind,
+		DueDate:   loan.ReturnDate,
+		Recipient: member.Email,
+		SentAt:    now,
+	})
+	if errors.Is(err, repositories.ErrExistingNotification) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	msg, err := s.Templates[kind].Render(member.Email, data)
+	if err == nil {
+		err = s.Notifier.Send(ctx, msg)
+	}
+	if err != nil {
+		log.Printf("error sending %s notification of loan %d to member %d: %v", kind, loan.Id, member.Id, err)
+		report.Failed++
+		return s.NotificationRepository.DeleteNotification(ctx, notification.Id)
+	}
+	if kind == models.NotificationDueSoon {
+		report.DueSoon++
+	} else {
+		report.Overdue++
+	}
+	return nil
+}
+
+// tenantName is the name of the library in context, for messages sent to its members
+func tenantName(ctx context.Context) string {
+	if t := tenant.FromContext(ctx); t != nil {
+		return t.Name
+	}
+	return "Your library"
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aftaab60/e-library-api/internal/mail"
+	"github.com/aftaab60/e-library-api/internal/tenant"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingSender keeps the messages sent, it fails while err is set
+type recordingSender struct {
+	messages []mail.Message
+	err      error
+	mutex    sync.Mutex
+}
+
+func (rs *recordingSender) Send(ctx context.Context, msg mail.Message) error {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	if rs.err != nil {
+		return rs.err
+	}
+	rs.messages = append(rs.messages, msg)
+	return nil
+}
+
+func TestReminderService_SendReminders(t *testing.T) {
+	loanRepo := repositories.NewLoanRepository()
+	bookRepo := repositories.NewBookRepository()
+	memberRepo := repositories.NewMemberRepository()
+	branchRepo := repositories.NewBranchRepository()
+	loanService := NewLoanService(loanRepo, bookRepo, repositories.NewChargeRepository(), branchRepo, repositories.NewTransferRepository(), memberRepo, repositories.NewLoanEventRepository())
+	sender := &recordingSender{}
+	reminderService := NewReminderService(loanRepo, bookRepo, memberRepo, branchRepo, repositories.NewNotificationRepository(), sender)
+	ctx := tenant.NewContext(context.Background(), &models.Tenant{Id: 1, Name: "Default Library", Policy: models.DefaultLoanPolicy})
+	//west of UTC, the end of the due day is the next day in UTC
+	_, err := branchRepo.UpdateBranch(ctx, &models.Branch{Id: 1, Name: "main", TimeZone: "America/Los_Angeles"})
+	assert.NoError(t, err)
+
+	_, err = memberRepo.CreateMember(ctx, models.NewMember("user3", "user3@example.com"))
+	assert.NoError(t, err)
+	quiet := models.NewMember("user4", "user4@example.com")
+	quiet.DueReminders = false
+	_, err = memberRepo.CreateMember(ctx, quiet)
+	assert.NoError(t, err)
+
+	loan, err := loanService.BorrowBook(ctx, "book1", "user3")
+	assert.NoError(t, err)
+	for _, borrower := range []string{"user1", "user4"} {
+		_, err = loanService.BorrowBook(ctx, "book1", borrower)
+		assert.NoError(t, err)
+	}
+
+	t.Run("Nothing is sent for loans not due soon", func(t *testing.T) {
+		report, err := reminderService.SendReminders(ctx, time.Now())
+		assert.NoError(t, err)
+		assert.Equal(t, models.ReminderReport{}, *report)
+	})
+
+	t.Run("Loans due soon are reminded once", func(t *testing.T) {
+		now := loan.ReturnDate.Add(-36 * time.Hour)
+		report, err := reminderService.SendReminders(ctx, now)
+		assert.NoError(t, err)
+		//user1 has no email and user4 turned reminders off
+		assert.Equal(t, models.ReminderReport{DueSoon: 1, Skipped: 2}, *report)
+		assert.Len(t, sender.messages, 1)
+		assert.Equal(t, "user3@example.com", sender.messages[0].To)
+		assert.Equal(t, `Default Library: "book1" is due in 2 days`, sender.messages[0].Subject)
+		assert.Contains(t, sender.messages[0].Body, "Hello user3,")
+		assert.Contains(t, sender.messages[0].Body, "is due back on "+loan.ReturnDate.Format("Monday 2 January 2006")+", 23:59 P")
+
+		report, err = reminderService.SendReminders(ctx, now.Add(time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, 0, report.DueSoon)
+		assert.Len(t, sender.messages, 1)
+	})
+
+	t.Run("Failed notices are sent again on the next run", func(t *testing.T) {
+		now := loan.ReturnDate.Add(72 * time.Hour)
+		sender.err = errors.New("connection refused")
+		report, err := reminderService.SendReminders(ctx, now)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, report.Failed)
+
+		sender.err = nil
+		report, err = reminderService.SendReminders(ctx, now)
+		assert.NoError(t, err)
+		//user4 only turned reminders off, overdue notices are still sent
+		assert.Equal(t, models.ReminderReport{Overdue: 2, Skipped: 1}, *report)
+		assert.Equal(t, `Default Library: "book1" is overdue`, sender.messages[1].Subject)
+		assert.Contains(t, sender.messages[1].Body, "is now 3 days overdue")
+	})
+}
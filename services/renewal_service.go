@@ -0,0 +1,243 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/aftaab60/e-library-api/internal/db_manager"
+	"github.com/aftaab60/e-library-api/internal/mail"
+	"github.com/aftaab60/e-library-api/internal/tenant"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"log"
+	"time"
+)
+
+// ErrRenewalLimitReached refuses the automatic renewal of a loan extended as many times as the loan policy allows
+var ErrRenewalLimitReached = errors.New("renewal limit reached")
+
+// renewalRefusals are the errors of extending a loan that refuse a renewal, along with their reason.
+// Other errors fail the auto-renewal run.
+var renewalRefusals = map[error]string{
+	ErrRenewalLimitReached: models.RenewalReasonLimitReached,
+	ErrLoanNotActive:       models.RenewalReasonNotActive,
+	ErrBookingConflict:     models.RenewalReasonBooked,
+	ErrReferenceOnly:       models.RenewalReasonReferenceOnly,
+	ErrNonCirculating:      models.RenewalReasonNonCirculating,
+	ErrFineLimitExceeded:   models.BlockReasonFineLimit,
+	ErrAccountSuspended:    models.BlockReasonSuspended,
+	ErrAccountExpired:      models.BlockReasonExpired,
+}
+
+// RenewalReasons explain the reasons of refused renewals to patrons
+var RenewalReasons = map[string]string{
+	models.RenewalReasonLimitReached:   "it was renewed as many times as the library allows",
+	models.RenewalReasonNotActive:      "it is no longer on loan",
+	models.RenewalReasonBooked:         "the copy is booked by another reader",
+	models.RenewalReasonReferenceOnly:  "the book is now for use in the library only",
+	models.RenewalReasonNonCirculating: "the book is no longer lent",
+	models.BlockReasonFineLimit:        "you owe more in fines and fees than the library allows",
+	models.BlockReasonSuspended:        "your account is suspended",
+	models.BlockReasonExpired:          "your membership has expired",
+}
+
+// RenewalTemplates are the default templates of auto-renewal notices, executed with RenewalData. Dates are in the time
+// zone of the home branch of the loan, which is printed along.
+var RenewalTemplates = map[models.RenewalOutcome]mail.Template{
+	models.RenewalRenewed: mail.MustParseTemplate(string(models.RenewalRenewed),
+		`{{.Library}}: "{{.Loan.Title}}" was renewed`,
+		`Hello {{.Member.Name}},
+
+"{{.Loan.Title}}" was due back on {{.DueDate.Format "Monday 2 January 2006, 15:04 MST"}}, we renewed it for you.
+It is now due back on {{.Loan.ReturnDate.Format "Monday 2 January 2006, 15:04 MST"}}.
+
+{{.Library}}
+`),
+	models.RenewalRefused: mail.MustParseTemplate(string(models.RenewalRefused),
+		`{{.Library}}: "{{.Loan.Title}}" couldn't be renewed`,
+		`Hello {{.Member.Name}},
+
+We couldn't renew "{{.Loan.Title}}" because {{.Reason}}.
+Please return it by {{.DueDate.Format "Monday 2 January 2006, 15:04 MST"}}.
+
+{{.Library}}
+`),
+}
+
+// RenewalData is what auto-renewal templates are executed with
+type RenewalData struct {
+	Library string
+	Member  models.Member
+	Loan    models.MemberLoan
+	//DueDate is the due date before the renewal, Reason explains why a renewal was refused
+	DueDate time.Time
+	Reason  string
+}
+
+type RenewalService struct {
+	//LoanService renews loans the way patrons extend them
+	LoanService           *LoanService
+	BookRepository        repositories.IBookRepository
+	MemberRepository      repositories.IMemberRepository
+	AutoRenewalRepository repositories.IAutoRenewalRepository
+	Notifier              mail.Sender
+	TxDB                  db_manager.ItxDB
+	Templates             map[models.RenewalOutcome]mail.Template
+	//Window is how long before its due date a loan is renewed
+	Window time.Duration
+}
+
+func NewRenewalService(loanService *LoanService, bookRepository repositories.IBookRepository, memberRepository repositories.IMemberRepository,
+	autoRenewalRepository repositories.IAutoRenewalRepository, notifier mail.Sender) *RenewalService {
+	return &RenewalService{
+		LoanService:           loanService,
+		BookRepository:        bookRepository,
+		MemberRepository:      memberRepository,
+		AutoRenewalRepository: autoRenewalRepository,
+		Notifier:              notifier,
+		Templates:             RenewalTemplates,
+		Window:                24 * time.Hour,
+	}
+}
+
+// RenewLoans renews the active loans of the tenant in context that are due within Window of now, as extending them
+// by hand would. Each loan is attempted once per due date, the outcome is recorded and the borrower is told of it
+// unless they turned due date reminders off. Short loans are lent by the hour to bring copies back soon, they are
+// only renewed by hand.
+func (s *RenewalService) RenewLoans(ctx context.Context, now time.Time) (*models.AutoRenewalReport, error) {
+	report := &models.AutoRenewalReport{}
+	due := make([]models.Loan, 0)
+	for offset := 0; ; offset += reminderBatchSize {
+		loans, _, err := s.LoanService.LoanRepository.ListLoans(ctx, &models.LoanFilter{Status: models.LoanStatusActive, Limit: reminderBatchSize, Offset: offset})
+		if err != nil {
+			log.Printf("error listing loans from repository: %v", err)
+			return nil, err
+		}
+		for _, loan := range loans {
+			if loan.ReturnDate.After(now) && !loan.ReturnDate.After(now.Add(s.Window)) {
+				due = append(due, loan)
+			}
+		}
+		if len(loans) < reminderBatchSize {
+			break
+		}
+	}
+
+	//renewing moves loans around the pages of active loans, so they are renewed once all are listed
+	for i := range due {
+		if err := s.renew(ctx, &due[i], now, report); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+func (s *RenewalService) renew(ctx context.Context, loan *models.Loan, now time.Time, report *models.AutoRenewalReport) error {
+	book, err := s.BookRepository.GetBookById(ctx, loan.BookId)
+	if err != nil {
+		log.Printf("error getting book: %v", err)
+		return err
+	}
+	if book.LoanType == models.LoanTypeShortLoan {
+		return nil
+	}
+	renewals, err := s.AutoRenewalRepository.ListAutoRenewals(ctx, loan.Id)
+	if err != nil {
+		return err
+	}
+	for _, r := range renewals {
+		if r.DueDate.Equal(loan.ReturnDate) {
+			return nil
+		}
+	}
+
+	//the extension and its record commit together, a loan is not extended twice for a due date
+	renewal := &models.AutoRenewal{LoanId: loan.Id, DueDate: loan.ReturnDate, Outcome: models.RenewalRenewed, AttemptedAt: now}
+	if err := db_manager.WrapInTransaction(ctx, s.TxDB, func(ctx context.Context) error {
+		renewed, err := s.extend(ctx, loan)
+		for refusal, reason := range renewalRefusals {
+			if errors.Is(err, refusal) {
+				renewal.Outcome, renewal.Reason, err = models.RenewalRefused, reason, nil
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if renewed != nil {
+			renewal.NewDueDate = &renewed.ReturnDate
+		}
+		renewal, err = s.AutoRenewalRepository.CreateAutoRenewal(ctx, renewal)
+		return err
+	}, nil); err != nil {
+		if errors.Is(err, repositories.ErrExistingAutoRenewal) {
+			return nil
+		}
+		return err
+	}
+	if renewal.Outcome == models.RenewalRenewed {
+		report.Renewed++
+	} else {
+		report.Refused++
+	}
+	s.notify(ctx, loan, renewal, now)
+	return nil
+}
+
+// extend extends a loan the way extending it by hand would, unless it was extended as many times as the loan policy
+// allows. The limit only stops automatic renewals, staff and borrowers may still extend the loan themselves.
+func (s *RenewalService) extend(ctx context.Context, loan *models.Loan) (*models.LoanDetail, error) {
+	renewals, err := s.LoanService.countRenewals(ctx, loan.Id)
+	if err != nil {
+		return nil, err
+	}
+	if renewals >= tenant.LoanPolicy(ctx).MaxRenewals {
+		return nil, ErrRenewalLimitReached
+	}
+	return s.LoanService.ExtendLoanById(ctx, loan.Id)
+}
+
+// notify tells the borrower of a loan the outcome of its renewal, failures are logged only: the renewal stands
+func (s *RenewalService) notify(ctx context.Context, loan *models.Loan, renewal *models.AutoRenewal, now time.Time) {
+	member, err := s.MemberRepository.GetMemberByName(ctx, loan.BorrowerName)
+	if err != nil || member.Email == "" || !member.DueReminders {
+		return
+	}
+	title := ""
+	if book, err := s.BookRepository.GetBookById(ctx, loan.BookId); err == nil {
+		title = book.Title
+	}
+	loc, err := loanLocation(ctx, s.LoanService.BranchRepository, loan, make(map[int]*time.Location))
+	if err != nil {
+		return
+	}
+	renewedLoan := *loan
+	if renewal.NewDueDate != nil {
+		renewedLoan.ReturnDate = *renewal.NewDueDate
+	}
+	data := RenewalData{
+		Library: tenantName(ctx),
+		Member:  *member,
+		Loan:    newMemberLoan(&renewedLoan, title, now, loc),
+		DueDate: renewal.DueDate.In(loc),
+		Reason:  RenewalReasons[renewal.Reason],
+	}
+	msg, err := s.Templates[renewal.Outcome].Render(member.Email, data)
+	if err == nil {
+		err = s.Notifier.Send(ctx, msg)
+	}
+	if err != nil {
+		log.Printf("error sending renewal notice of loan %d to member %d: %v", loan.Id, member.Id, err)
+	}
+}
+
+// ListAutoRenewals lists the automatic renewal attempts of a loan
+func (s *RenewalService) ListAutoRenewals(ctx context.Context, loanId int) ([]models.AutoRenewal, error) {
+	if _, err := s.LoanService.GetLoanById(ctx, loanId); err != nil {
+		return nil, err
+	}
+	renewals, err := s.AutoRenewalRepository.ListAutoRenewals(ctx, loanId)
+	if err != nil {
+		log.Printf("error listing auto renewals of loan %d from repository: %v", loanId, err)
+		return nil, err
+	}
+	return renewals, nil
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aftaab60/e-library-api/internal/tenant"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenewalService_RenewLoans(t *testing.T) {
+	loanRepo := repositories.NewLoanRepository()
+	bookRepo := repositories.NewBookRepository()
+	memberRepo := repositories.NewMemberRepository()
+	loanService := NewLoanService(loanRepo, bookRepo, repositories.NewChargeRepository(), repositories.NewBranchRepository(), repositories.NewTransferRepository(), memberRepo, repositories.NewLoanEventRepository())
+	sender := &recordingSender{}
+	renewalService := NewRenewalService(&loanService, bookRepo, memberRepo, repositories.NewAutoRenewalRepository(), sender)
+	policy := models.DefaultLoanPolicy
+	policy.MaxRenewals = 1
+	ctx := tenant.NewContext(context.Background(), &models.Tenant{Id: 1, Name: "Default Library", Policy: policy})
+
+	_, err := memberRepo.CreateMember(ctx, models.NewMember("user3", "user3@example.com"))
+	assert.NoError(t, err)
+	borrowed, err := loanService.BorrowBook(ctx, "book1", "user3")
+	assert.NoError(t, err)
+	loan, err := loanRepo.GetLoan(ctx, "book1", "user3")
+	assert.NoError(t, err)
+
+	t.Run("Loans not due within a day are left alone", func(t *testing.T) {
+		report, err := renewalService.RenewLoans(ctx, borrowed.ReturnDate.Add(-48*time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, models.AutoRenewalReport{}, *report)
+	})
+
+	t.Run("Loans due within a day are renewed and the borrower told", func(t *testing.T) {
+		now := borrowed.ReturnDate.Add(-2 * time.Hour)
+		report, err := renewalService.RenewLoans(ctx, now)
+		assert.NoError(t, err)
+		assert.Equal(t, models.AutoRenewalReport{Renewed: 1}, *report)
+
+		renewed, err := loanRepo.GetLoanById(ctx, loan.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, borrowed.ReturnDate.AddDate(0, 0, policy.ExtensionDays).Unix(), renewed.ReturnDate.Unix())
+		assert.Len(t, sender.messages, 1)
+		assert.Equal(t, `Default Library: "book1" was renewed`, sender.messages[0].Subject)
+
+		//the same due date isn't attempted twice
+		report, err = renewalService.RenewLoans(ctx, now.Add(time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, models.AutoRenewalReport{}, *report)
+	})
+
+	t.Run("Loans without renewals left are refused with the reason", func(t *testing.T) {
+		renewed, err := loanRepo.GetLoanById(ctx, loan.Id)
+		assert.NoError(t, err)
+		report, err := renewalService.RenewLoans(ctx, renewed.ReturnDate.Add(-time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, models.AutoRenewalReport{Refused: 1}, *report)
+		assert.Len(t, sender.messages, 2)
+		assert.Contains(t, sender.messages[1].Body, "as many times as the library allows")
+
+		renewals, err := renewalService.ListAutoRenewals(ctx, loan.Id)
+		assert.NoError(t, err)
+		assert.Len(t, renewals, 2)
+		assert.Equal(t, models.RenewalRenewed, renewals[0].Outcome)
+		assert.NotNil(t, renewals[0].NewDueDate)
+		assert.Equal(t, models.RenewalRefused, renewals[1].Outcome)
+		assert.Equal(t, models.RenewalReasonLimitReached, renewals[1].Reason)
+	})
+
+	t.Run("Loans of suspended members are refused with the reason", func(t *testing.T) {
+		borrowed, err := loanService.BorrowBook(ctx, "book2", "user3")
+		assert.NoError(t, err)
+		suspended := true
+		_, err = memberRepo.UpdateMemberStanding(ctx, 3, &models.MemberStanding{Suspended: &suspended})
+		assert.NoError(t, err)
+
+		report, err := renewalService.RenewLoans(ctx, borrowed.ReturnDate.Add(-time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, models.AutoRenewalReport{Refused: 1}, *report)
+		assert.Contains(t, sender.messages[len(sender.messages)-1].Body, "because your account is suspended")
+	})
+}
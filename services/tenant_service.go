@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/aftaab60/e-library-api/internal/auth"
+	"github.com/aftaab60/e-library-api/internal/db_manager"
+	"github.com/aftaab60/e-library-api/internal/tenant"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"log"
+	"net"
+	"strings"
+)
+
+type TenantService struct {
+	TenantRepository repositories.ITenantRepository
+	TxDB             db_manager.ItxDB
+	//Auditor records policy changes, nil records nothing
+	Auditor *AuditService
+}
+
+// NewTenantService uses interface so that we can switch between in-memory and actual pgsql repo data easily
+func NewTenantService(tenantRepository repositories.ITenantRepository) TenantService {
+	return TenantService{TenantRepository: tenantRepository}
+}
+
+var ErrTenantNotResolved = errors.New("tenant could not be resolved from request")
+
+// ResolveTenant identifies the tenant of a request, in order of precedence: explicit tenant slug header, API key, subdomain of host.
+// An explicit slug or API key that matches no tenant is an error, whereas an unknown subdomain falls back to fallbackSlug if set.
+func (s *TenantService) ResolveTenant(ctx context.Context, slug string, apiKey string, host string, fallbackSlug string) (*models.Tenant, error) {
+	if slug != "" {
+		return s.TenantRepository.GetTenantBySlug(ctx, slug)
+	}
+	if apiKey != "" {
+		return s.TenantRepository.GetTenantByApiKeyHash(ctx, tenant.HashApiKey(apiKey))
+	}
+	if subdomain := subdomainOf(host); subdomain != "" {
+		t, err := s.TenantRepository.GetTenantBySlug(ctx, subdomain)
+		if err == nil {
+			return t, nil
+		}
+		if !errors.Is(err, repositories.ErrTenantNotFound) {
+			log.Printf("error getting tenant from repository: %v", err)
+			return nil, err
+		}
+	}
+	if fallbackSlug != "" {
+		return s.TenantRepository.GetTenantBySlug(ctx, fallbackSlug)
+	}
+	return nil, ErrTenantNotResolved
+}
+
+// subdomainOf returns the left most label of a host name, e.g. "city" for "city.library.example.com:3000"
+func subdomainOf(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if net.ParseIP(host) != nil {
+		return ""
+	}
+	labels := strings.Split(host, ".")
+	if len(labels) < 2 {
+		return ""
+	}
+	return labels[0]
+}
+
+// GetTenant returns the up-to-date tenant of the request
+func (s *TenantService) GetTenant(ctx context.Context) (*models.Tenant, error) {
+	t, err := s.TenantRepository.GetTenant(ctx, tenant.Id(ctx))
+	if err != nil {
+		log.Printf("error getting tenant from repository: %v", err)
+		return nil, err
+	}
+	return t, nil
+}
+
+// UpdateLoanPolicy replaces the loan policy of the request's tenant
+func (s *TenantService) UpdateLoanPolicy(ctx context.Context, policy models.LoanPolicy) (*models.Tenant, error) {
+	if err := auth.Authorize(ctx, models.PermissionConfigManage); err != nil {
+		return nil, err
+	}
+	before, err := s.GetTenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var t *models.Tenant
+	if err = db_manager.WrapInTransaction(ctx, s.TxDB, func(ctx context.Context) error {
+		if t, err = s.TenantRepository.UpdateTenantPolicy(ctx, before.Id, policy); err != nil {
+			log.Printf("error updating tenant policy from repository: %v", err)
+			return err
+		}
+		changes := models.Diff(models.AuditEntity(models.AuditEntityTenant, t.Id), &before.Policy, &t.Policy)
+		return s.Auditor.Record(ctx, models.AuditActionPolicyUpdated, models.AuditEntityTenant, t.Id, changes)
+	}, nil); err != nil {
+		return nil, err
+	}
+	log.Printf("loan policy updated for tenant %s: %+v\n", t.Slug, t.Policy)
+	return t, nil
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aftaab60/e-library-api/internal/tenant"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenantService_ResolveTenant(t *testing.T) {
+	tenantService := NewTenantService(repositories.NewTenantRepository())
+	ctx := context.Background()
+
+	t.Run("Resolve from tenant header", func(t *testing.T) {
+		resolved, err := tenantService.ResolveTenant(ctx, "city", "", "localhost:3000", "")
+		assert.NoError(t, err)
+		assert.Equal(t, "city", resolved.Slug)
+	})
+
+	t.Run("Resolve from API key", func(t *testing.T) {
+		resolved, err := tenantService.ResolveTenant(ctx, "", "city-library-key", "localhost:3000", "")
+		assert.NoError(t, err)
+		assert.Equal(t, "city", resolved.Slug)
+	})
+
+	t.Run("Resolve from subdomain", func(t *testing.T) {
+		resolved, err := tenantService.ResolveTenant(ctx, "", "", "city.library.example.com:3000", "")
+		assert.NoError(t, err)
+		assert.Equal(t, "city", resolved.Slug)
+	})
+
+	t.Run("Fallback when request doesn't identify a tenant", func(t *testing.T) {
+		resolved, err := tenantService.ResolveTenant(ctx, "", "", "localhost:3000", "default")
+		assert.NoError(t, err)
+		assert.Equal(t, "default", resolved.Slug)
+
+		_, err = tenantService.ResolveTenant(ctx, "", "", "127.0.0.1:3000", "")
+		assert.Equal(t, ErrTenantNotResolved, err)
+	})
+
+	t.Run("Fail on unknown API key", func(t *testing.T) {
+		_, err := tenantService.ResolveTenant(ctx, "", "wrong-key", "localhost:3000", "default")
+		assert.Equal(t, repositories.ErrTenantNotFound, err)
+	})
+}
+
+func TestLoanService_TenantLoanPolicy(t *testing.T) {
+	bookRepo := repositories.NewTenantBookRepository()
+	loanService := NewLoanService(repositories.NewTenantLoanRepository(), bookRepo, repositories.NewTenantChargeRepository(),
+		repositories.NewTenantBranchRepository(), repositories.NewTenantTransferRepository(), repositories.NewTenantMemberRepository(), repositories.NewTenantLoanEventRepository())
+	policy := models.DefaultLoanPolicy
+	policy.LoanPeriodDays = 7
+	ctx := tenant.NewContext(context.Background(), &models.Tenant{Id: 2, Slug: "city", Policy: policy})
+
+	t.Run("Loan period follows the tenant policy", func(t *testing.T) {
+		loan, err := loanService.BorrowBook(ctx, "book1", "borrower1")
+		assert.NoError(t, err)
+		assert.Equal(t, models.EndOfDay(loan.LoanDate.AddDate(0, 0, 7)).Unix(), loan.ReturnDate.Unix())
+
+		// other tenant's inventory is untouched
+		book, _ := bookRepo.GetBook(context.Background(), "book1")
+		assert.Equal(t, 5, book.AvailableCopies)
+	})
+}
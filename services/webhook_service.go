@@ -0,0 +1,364 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aftaab60/e-library-api/internal/auth"
+	"github.com/aftaab60/e-library-api/internal/db_manager"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Headers of webhook requests. The signature is the hex HMAC-SHA256 of "<timestamp>.<body>" keyed with the
+// subscription secret, see SignWebhook.
+const (
+	WebhookIdHeader        = "X-Webhook-Id"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+const (
+	// webhookBatchSize is how many outbox messages or deliveries a dispatch handles at once
+	webhookBatchSize = 100
+	// webhookErrorLength is how much of a failed response is kept in the delivery
+	webhookErrorLength = 200
+)
+
+// ErrWebhookDeliveryNotDead is returned when retrying a delivery which isn't in the dead letter queue
+var ErrWebhookDeliveryNotDead = errors.New("webhook delivery is not dead")
+
+// WebhookService keeps to the wall clock: backoffs and the timestamps signed into deliveries are checked by
+// subscribers against their own clock, which time travel doesn't move. Events carry the time they occurred at.
+type WebhookService struct {
+	WebhookRepository repositories.IWebhookRepository
+	OutboxRepository  repositories.IOutboxRepository
+	TxDB              db_manager.ItxDB
+	Client            *http.Client
+	//MaxAttempts is how many times a delivery is attempted before it is dead
+	MaxAttempts int
+	//BaseBackoff is the wait after the first failed attempt, it doubles after every further failure up to MaxBackoff
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	//ClaimTimeout is how long deliveries claimed by a dispatch are left to it, longer than a batch of attempts takes.
+	//Deliveries of a dispatch that stopped before recording them are attempted again once it runs out.
+	ClaimTimeout time.Duration
+}
+
+func NewWebhookService(webhookRepository repositories.IWebhookRepository, outboxRepository repositories.IOutboxRepository) *WebhookService {
+	return &WebhookService{
+		WebhookRepository: webhookRepository,
+		OutboxRepository:  outboxRepository,
+		Client:            newWebhookClient(10 * time.Second),
+		MaxAttempts:       8,
+		BaseBackoff:       30 * time.Second,
+		MaxBackoff:        time.Hour,
+		ClaimTimeout:      30 * time.Minute,
+	}
+}
+
+// Publish writes a loan event to the outbox, to be dispatched to webhooks once the change is committed.
+// It is called within the transaction making the change. A nil WebhookService publishes nothing.
+func (s *WebhookService) Publish(ctx context.Context, event *models.LoanEvent, loan *models.Loan) error {
+	if s == nil || event.Type == models.LoanEventImported {
+		return nil
+	}
+	payload, err := json.Marshal(models.LoanEventPayload{Event: *event, Loan: *loan})
+	if err != nil {
+		return fmt.Errorf("error encoding %s event of loan %d: %w", event.Type, event.LoanId, err)
+	}
+	if _, err := s.OutboxRepository.CreateOutboxMessage(ctx, &models.OutboxMessage{
+		EventType: event.Type,
+		Payload:   payload,
+		CreatedAt: event.OccurredAt,
+	}); err != nil {
+		log.Printf("error writing outbox message: %v", err)
+		return err
+	}
+	return nil
+}
+
+// CreateSubscription registers a webhook. The secret signing its deliveries is returned only here.
+func (s *WebhookService) CreateSubscription(ctx context.Context, request *models.WebhookSubscriptionRequest) (*models.IssuedWebhookSubscription, error) {
+	if err := auth.Authorize(ctx, models.PermissionConfigManage); err != nil {
+		return nil, err
+	}
+	secret, err := auth.RandomId(24)
+	if err != nil {
+		return nil, err
+	}
+	secret = "whsec_" + secret
+	subscription, err := s.WebhookRepository.CreateWebhookSubscription(ctx, &models.WebhookSubscription{
+		Url:        request.Url,
+		EventTypes: request.EventTypes,
+		Secret:     secret,
+		CreatedAt:  time.Now(),
+	})
+	if err != nil {
+		log.Printf("error creating webhook subscription from repository: %v", err)
+		return nil, err
+	}
+	return &models.IssuedWebhookSubscription{WebhookSubscription: *subscription, Secret: secret}, nil
+}
+
+func (s *WebhookService) ListSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error) {
+	if err := auth.Authorize(ctx, models.PermissionConfigManage); err != nil {
+		return nil, err
+	}
+	subscriptions, err := s.WebhookRepository.ListWebhookSubscriptions(ctx)
+	if err != nil {
+		log.Printf("error listing webhook subscriptions from repository: %v", err)
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+// DeleteSubscription stops deliveries to a webhook, pending and dead deliveries are dropped
+func (s *WebhookService) DeleteSubscription(ctx context.Context, id int) error {
+	if err := auth.Authorize(ctx, models.PermissionConfigManage); err != nil {
+		return err
+	}
+	return s.WebhookRepository.DeleteWebhookSubscription(ctx, id)
+}
+
+// ListDeliveries returns deliveries matching the filter, the dead letter queue with status dead
+func (s *WebhookService) ListDeliveries(ctx context.Context, filter *models.WebhookDeliveryFilter) (*models.WebhookDeliveryPage, error) {
+	if err := auth.Authorize(ctx, models.PermissionConfigManage); err != nil {
+		return nil, err
+	}
+	deliveries, total, err := s.WebhookRepository.ListWebhookDeliveries(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return &models.WebhookDeliveryPage{Deliveries: deliveries, Total: total, Limit: filter.Limit, Offset: filter.Offset}, nil
+}
+
+// RetryDelivery takes a delivery out of the dead letter queue, it is attempted again on the next dispatch with
+// a fresh number of attempts
+func (s *WebhookService) RetryDelivery(ctx context.Context, id int) (*models.WebhookDelivery, error) {
+	if err := auth.Authorize(ctx, models.PermissionConfigManage); err != nil {
+		return nil, err
+	}
+	var delivery *models.WebhookDelivery
+	err := db_manager.WrapInTransaction(ctx, s.TxDB, func(ctx context.Context) error {
+		var err error
+		delivery, err = s.WebhookRepository.GetWebhookDeliveryById(ctx, id)
+		if err != nil {
+			return err
+		}
+		if delivery.Status != models.WebhookDeliveryDead {
+			return ErrWebhookDeliveryNotDead
+		}
+		delivery.Status = models.WebhookDeliveryPending
+		delivery.Attempts = 0
+		delivery.NextAttemptAt = time.Now()
+		delivery, err = s.WebhookRepository.UpdateWebhookDelivery(ctx, delivery)
+		return err
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// Dispatch delivers the outbox of the tenant in context: it queues a delivery of every new message to each
+// subscription receiving it, then attempts the deliveries that are due. It returns the number of deliveries made.
+// Dispatchers running at the same time on pgsql skip the messages locked and the deliveries claimed by one another.
+// Deliveries are claimed in a transaction and sent once it is committed, no transaction is held open over a request.
+func (s *WebhookService) Dispatch(ctx context.Context) (int, error) {
+	for {
+		queued, err := s.queueDeliveries(ctx)
+		if err != nil {
+			return 0, err
+		}
+		if queued < webhookBatchSize {
+			break
+		}
+	}
+
+	delivered := 0
+	for {
+		var deliveries []models.WebhookDelivery
+		err := db_manager.WrapInTransaction(ctx, s.TxDB, func(ctx context.Context) error {
+			now := time.Now()
+			var err error
+			deliveries, err = s.WebhookRepository.ClaimDueWebhookDeliveries(ctx, now, now.Add(s.ClaimTimeout), webhookBatchSize)
+			return err
+		}, nil)
+		if err != nil {
+			return delivered, err
+		}
+		for i := range deliveries {
+			ok, err := s.attempt(ctx, &deliveries[i])
+			if err != nil {
+				return delivered, err
+			}
+			if ok {
+				delivered++
+			}
+		}
+		if len(deliveries) < webhookBatchSize {
+			return delivered, nil
+		}
+	}
+}
+
+// queueDeliveries fans a batch of outbox messages out to the subscriptions receiving them, it returns the number of messages dispatched
+func (s *WebhookService) queueDeliveries(ctx context.Context) (int, error) {
+	var dispatched int
+	err := db_manager.WrapInTransaction(ctx, s.TxDB, func(ctx context.Context) error {
+		messages, err := s.OutboxRepository.ListPendingOutboxMessages(ctx, webhookBatchSize)
+		if err != nil || len(messages) == 0 {
+			return err
+		}
+		subscriptions, err := s.WebhookRepository.ListWebhookSubscriptions(ctx)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, message := range messages {
+			body, err := json.Marshal(models.WebhookEvent{Id: message.Id, Type: message.EventType, CreatedAt: message.CreatedAt, Data: message.Payload})
+			if err != nil {
+				return fmt.Errorf("error encoding outbox message %d: %w", message.Id, err)
+			}
+			for _, subscription := range subscriptions {
+				if !subscription.Receives(message.EventType) {
+					continue
+				}
+				if _, err := s.WebhookRepository.CreateWebhookDelivery(ctx, &models.WebhookDelivery{
+					SubscriptionId: subscription.Id,
+					MessageId:      message.Id,
+					EventType:      message.EventType,
+					Status:         models.WebhookDeliveryPending,
+					Body:           body,
+					NextAttemptAt:  now,
+					CreatedAt:      now,
+				}); err != nil {
+					return err
+				}
+			}
+			if err := s.OutboxRepository.MarkOutboxMessageDispatched(ctx, message.Id, now); err != nil {
+				return err
+			}
+		}
+		dispatched = len(messages)
+		return nil
+	}, nil)
+	return dispatched, err
+}
+
+// attempt POSTs a claimed delivery to its webhook and saves the outcome in a transaction of its own. A failed attempt
+// is retried after a backoff, or the delivery is dead once it ran out of attempts.
+func (s *WebhookService) attempt(ctx context.Context, delivery *models.WebhookDelivery) (bool, error) {
+	subscription, err := s.WebhookRepository.GetWebhookSubscriptionById(ctx, delivery.SubscriptionId)
+	if err != nil {
+		if errors.Is(err, repositories.ErrWebhookSubscriptionNotFound) {
+			//deleted since the delivery was claimed, its deliveries went along
+			return false, nil
+		}
+		return false, err
+	}
+
+	now := time.Now()
+	delivery.Attempts++
+	delivery.LastStatusCode, delivery.LastError = s.send(ctx, subscription, delivery, now)
+	now = time.Now()
+	if delivery.LastError == "" {
+		delivery.Status = models.WebhookDeliveryDelivered
+		delivery.DeliveredAt = &now
+	} else if delivery.Attempts >= s.MaxAttempts {
+		log.Printf("webhook delivery %d to %s is dead after %d attempts: %s", delivery.Id, subscription.Url, delivery.Attempts, delivery.LastError)
+		delivery.Status = models.WebhookDeliveryDead
+	} else {
+		delivery.NextAttemptAt = now.Add(s.backoff(delivery.Attempts))
+	}
+	if err := db_manager.WrapInTransaction(ctx, s.TxDB, func(ctx context.Context) error {
+		_, err := s.WebhookRepository.UpdateWebhookDelivery(ctx, delivery)
+		return err
+	}, nil); err != nil {
+		if errors.Is(err, repositories.ErrWebhookDeliveryNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return delivery.Status == models.WebhookDeliveryDelivered, nil
+}
+
+// send makes one attempt of a delivery, it returns the response status and why the attempt failed, empty on success
+func (s *WebhookService) send(ctx context.Context, subscription *models.WebhookSubscription, delivery *models.WebhookDelivery, now time.Time) (int, string) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.Url, bytes.NewReader(delivery.Body))
+	if err != nil {
+		return 0, err.Error()
+	}
+	timestamp := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookIdHeader, strconv.Itoa(delivery.MessageId))
+	req.Header.Set(WebhookEventHeader, string(delivery.EventType))
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhook(subscription.Secret, timestamp, delivery.Body))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return 0, err.Error()
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.StatusCode, ""
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, webhookErrorLength))
+	return resp.StatusCode, fmt.Sprintf("%s: %s", resp.Status, body)
+}
+
+// newWebhookClient returns a client connecting only to public addresses, so that neither a host name resolving to the
+// network the library runs in nor a redirect there lets subscribers reach its internal services
+func newWebhookClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network string, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !models.PublicAddress(ip) {
+				return fmt.Errorf("webhook address %s is not allowed", host)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	//a proxy would connect on behalf of the client, past the check of the dialer
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// backoff is the wait before the next attempt after the given number of failed attempts
+func (s *WebhookService) backoff(attempts int) time.Duration {
+	wait := s.BaseBackoff
+	for i := 1; i < attempts && wait < s.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, s.MaxBackoff)
+}
+
+// SignWebhook returns the hex HMAC-SHA256 of "<timestamp>.<body>" keyed with secret. Receivers compute it to check
+// that a request comes from the library and wasn't replayed, by comparing the timestamp with their clock.
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aftaab60/e-library-api/internal/auth"
+	"github.com/aftaab60/e-library-api/models"
+	"github.com/aftaab60/e-library-api/repositories"
+	"github.com/stretchr/testify/assert"
+)
+
+// webhookReceiver records the requests of a test webhook, it fails with status while status is set
+type webhookReceiver struct {
+	requests []*http.Request
+	bodies   [][]byte
+	status   int
+	mutex    sync.Mutex
+}
+
+func (wr *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	wr.mutex.Lock()
+	defer wr.mutex.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+	wr.requests = append(wr.requests, r)
+	wr.bodies = append(wr.bodies, body)
+	if wr.status != 0 {
+		w.WriteHeader(wr.status)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestWebhookService_Dispatch(t *testing.T) {
+	receiver := &webhookReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	webhookService := NewWebhookService(repositories.NewWebhookRepository(), repositories.NewOutboxRepository())
+	webhookService.MaxAttempts = 2
+	webhookService.BaseBackoff = 0
+	//the test server listens on loopback, which the default client refuses
+	webhookService.Client = server.Client()
+	loanService := NewLoanService(repositories.NewLoanRepository(), repositories.NewBookRepository(), repositories.NewChargeRepository(), repositories.NewBranchRepository(), repositories.NewTransferRepository(), repositories.NewMemberRepository(), repositories.NewLoanEventRepository())
+	loanService.Outbox = webhookService
+
+	admin := auth.NewContext(context.Background(), &models.Principal{Type: models.PrincipalTypeStaff, Role: models.RoleAdmin, Name: "admin1"})
+	subscription, err := webhookService.CreateSubscription(admin, &models.WebhookSubscriptionRequest{Url: server.URL, EventTypes: []models.LoanEventType{models.LoanEventCreated, models.LoanEventReturned}})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, subscription.Secret)
+
+	ctx := context.Background()
+	_, err = loanService.BorrowBook(ctx, "book1", "user1")
+	assert.NoError(t, err)
+	_, err = loanService.ExtendLoan(ctx, "book1", "user1")
+	assert.NoError(t, err)
+
+	t.Run("Subscribed events are delivered signed", func(t *testing.T) {
+		delivered, err := webhookService.Dispatch(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, delivered)
+		assert.Len(t, receiver.requests, 1)
+
+		req, body := receiver.requests[0], receiver.bodies[0]
+		assert.Equal(t, string(models.LoanEventCreated), req.Header.Get(WebhookEventHeader))
+		timestamp, err := strconv.ParseInt(req.Header.Get(WebhookTimestampHeader), 10, 64)
+		assert.NoError(t, err)
+		assert.Equal(t, "sha256="+SignWebhook(subscription.Secret, timestamp, body), req.Header.Get(WebhookSignatureHeader))
+
+		var event models.WebhookEvent
+		assert.NoError(t, json.Unmarshal(body, &event))
+		assert.Equal(t, req.Header.Get(WebhookIdHeader), strconv.Itoa(event.Id))
+		var payload models.LoanEventPayload
+		assert.NoError(t, json.Unmarshal(event.Data, &payload))
+		assert.Equal(t, "user1", payload.Loan.BorrowerName)
+		assert.Equal(t, models.LoanEventCreated, payload.Event.Type)
+
+		delivered, err = webhookService.Dispatch(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, delivered)
+	})
+
+	t.Run("Failed deliveries are retried then dead", func(t *testing.T) {
+		receiver.status = http.StatusInternalServerError
+		assert.NoError(t, loanService.ReturnBook(ctx, "book1", "user1"))
+
+		_, err := webhookService.Dispatch(ctx)
+		assert.NoError(t, err)
+		page, err := webhookService.ListDeliveries(admin, &models.WebhookDeliveryFilter{Status: models.WebhookDeliveryPending, Limit: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, page.Total)
+		assert.Equal(t, 1, page.Deliveries[0].Attempts)
+		assert.Equal(t, http.StatusInternalServerError, page.Deliveries[0].LastStatusCode)
+
+		_, err = webhookService.Dispatch(ctx)
+		assert.NoError(t, err)
+		page, err = webhookService.ListDeliveries(admin, &models.WebhookDeliveryFilter{Status: models.WebhookDeliveryDead, Limit: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, page.Total)
+		assert.Equal(t, models.LoanEventReturned, page.Deliveries[0].EventType)
+		assert.Len(t, receiver.requests, 3)
+		//retries send the same event
+		assert.Equal(t, receiver.bodies[1], receiver.bodies[2])
+	})
+
+	t.Run("Dead deliveries are retried by hand", func(t *testing.T) {
+		page, err := webhookService.ListDeliveries(admin, &models.WebhookDeliveryFilter{Status: models.WebhookDeliveryDead, Limit: 10})
+		assert.NoError(t, err)
+		deadId := page.Deliveries[0].Id
+
+		receiver.status = 0
+		delivery, err := webhookService.RetryDelivery(admin, deadId)
+		assert.NoError(t, err)
+		assert.Equal(t, models.WebhookDeliveryPending, delivery.Status)
+		assert.Equal(t, 0, delivery.Attempts)
+
+		delivered, err := webhookService.Dispatch(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, delivered)
+
+		_, err = webhookService.RetryDelivery(admin, deadId)
+		assert.Equal(t, ErrWebhookDeliveryNotDead, err)
+	})
+
+	t.Run("Patron may not manage webhooks", func(t *testing.T) {
+		patron := auth.NewContext(context.Background(), &models.Principal{Type: models.PrincipalTypePatron, Role: models.RolePatron, Id: 1, Name: "user1"})
+		_, err := webhookService.ListSubscriptions(patron)
+		assert.ErrorIs(t, err, auth.ErrForbidden)
+	})
+}
+
+func TestWebhookService_RefusePrivateAddresses(t *testing.T) {
+	receiver := &webhookReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	webhookService := NewWebhookService(repositories.NewWebhookRepository(), repositories.NewOutboxRepository())
+	status, reason := webhookService.send(context.Background(), &models.WebhookSubscription{Url: server.URL, Secret: "secret"},
+		&models.WebhookDelivery{MessageId: 1, EventType: models.LoanEventCreated}, time.Now())
+	assert.Equal(t, 0, status)
+	assert.Contains(t, reason, "is not allowed")
+	assert.Empty(t, receiver.requests)
+}
+
+func TestWebhookService_Backoff(t *testing.T) {
+	webhookService := NewWebhookService(repositories.NewWebhookRepository(), repositories.NewOutboxRepository())
+	assert.Equal(t, webhookService.BaseBackoff, webhookService.backoff(1))
+	assert.Equal(t, 4*webhookService.BaseBackoff, webhookService.backoff(3))
+	assert.Equal(t, webhookService.MaxBackoff, webhookService.backoff(20))
+}